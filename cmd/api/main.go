@@ -2,121 +2,116 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
-	"time"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 
-	_ "github.com/lib/pq"
-	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/app"
 	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
 )
 
-// define config struct.
-type config struct {
-	port int
-	env  string
-	// db struct field holds configuration settings for database connection pool.
-	db struct {
-		dsn          string
-		maxOpenConns int
-		maxIdleConns int
-		maxIdleTime  string
-	}
-}
-
-// define application struct to hold dependencies for HTTP handlers, helpers.
-type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
-}
-
-const (
-	version = "1.0.0"
-)
-
 func main() {
-	var cfg config
+	os.Exit(run())
+}
 
-	// Read DSN value from db-dsn command-line flag in config struct.
-	// Default is my development DSN if no flag is provided.
-	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("BOOKS_DB_DSN"), "PostgreSQL DSN")
+// run runs the command named by the arguments and returns the exit status of the process.
+// It returns instead of exiting, so the application is always closed on the way out.
+func run() int {
+	// The first argument, unless it is a flag, names the command: "serve", the default,
+	// or "check", which runs a smoke check of the configured setup and exits.
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	if command != "serve" && command != "check" {
+		fmt.Fprintf(os.Stderr, "unknown command %q, want serve or check\n", command)
+		return 2
+	}
 
-	// Read connection pool settings from command-line flags in config struct.
-	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max connection idle time")
-	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
-	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
+	var cfg app.Config
+	cfg.RegisterFlags(flag.CommandLine)
 
-	// Read value of port and env command-line flags in config struct.
-	// Default port number 4000 and environment "development".
-	flag.IntVar(&cfg.port, "port", 4000, "API server port")
-	flag.StringVar(&cfg.env, "env", "development", "Environment (development|production)")
+	displayVersion := flag.Bool("version", false, "Display version and exit")
 
-	flag.Parse()
+	seedFile := flag.String("seed", "", "Load the fixture set of the file, or \"library\" for the built-in one, into the database and exit")
 
-	// Initialize new jsonlog.Logger that writes any messages above INFO level to standard output stream.
-	logger := jsonlog.NewLogger(os.Stdout, jsonlog.LevelInfo)
+	flag.CommandLine.Parse(args)
 
-	// Call openDB() function (below) to create connection pool.
-	db, err := openDB(cfg)
-	if err != nil {
-		logger.PrintFatal(err, nil)
+	// If the version flag value is true, then print out the build details and exit.
+	if *displayVersion {
+		build := app.Build()
+		fmt.Printf("Version:\t%s\n", build.Version)
+		fmt.Printf("Commit:\t\t%s\n", build.Commit)
+		fmt.Printf("Build time:\t%s\n", build.Time)
+		fmt.Printf("Go version:\t%s\n", build.GoVersion)
+		return 0
 	}
 
-	// Defer call to db.Close().
-	defer func() {
-		if err := db.Close(); err != nil {
-			logger.PrintFatal(err, nil)
+	// Fill in the settings not given as flags from the environment and the config file,
+	// then validate the result as a whole.
+	err := cfg.Load(flag.CommandLine)
+	if err != nil {
+		var invalid app.ConfigError
+		if errors.As(err, &invalid) {
+			jsonlog.NewLogger(os.Stderr, jsonlog.LevelInfo).PrintError(err, invalid)
+			return 1
 		}
-	}()
-
-	logger.PrintInfo("database connection pool established", nil)
-
-	// Declare an instance of the application struct.
-	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-	}
-
-	// Call app.serve() to start the server.
-	if err := app.serve(); err != nil {
-		logger.PrintFatal(err, nil)
+		jsonlog.NewLogger(os.Stderr, jsonlog.LevelInfo).PrintError(err, nil)
+		return 1
 	}
-}
 
-// openDB returns a sql.DB connection pool to postgres database.
-func openDB(cfg config) (*sql.DB, error) {
-	// Use sql.Open() to create an empty connection pool, using the DSN from the config struct.
-	db, err := sql.Open("postgres", cfg.db.dsn)
+	api, err := app.New(cfg)
 	if err != nil {
-		return nil, err
+		jsonlog.NewLogger(os.Stderr, jsonlog.LevelInfo).PrintError(err, nil)
+		return 1
 	}
+	defer api.Close()
+	logger := api.Logger()
 
-	// Set the maximum number of open connections in the pool.
-	db.SetMaxOpenConns(cfg.db.maxOpenConns)
-
-	// Set the maximum number of idle connection in the pool.
-	db.SetMaxIdleConns(cfg.db.maxIdleConns)
+	logger.PrintInfo("effective configuration", app.EffectiveConfig(flag.CommandLine))
 
-	duration, err := time.ParseDuration(cfg.db.maxIdleTime)
-	if err != nil {
-		return nil, err
+	// If a fixture set is given, load it into the database and exit.
+	if *seedFile != "" {
+		loaded, tenant, err := api.Seed(*seedFile)
+		if err != nil {
+			logger.PrintError(err, map[string]string{"file": *seedFile})
+			return 1
+		}
+		logger.PrintInfo("database seeded", map[string]string{
+			"file":       *seedFile,
+			"tenant":     tenant,
+			"books":      strconv.Itoa(len(loaded.Books)),
+			"webhooks":   strconv.Itoa(len(loaded.Webhooks)),
+			"deliveries": strconv.Itoa(len(loaded.Deliveries)),
+		})
+		return 0
 	}
 
-	// Set the maximum idle timeout.
-	db.SetConnMaxIdleTime(duration)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Run the smoke check and exit with a non-zero status if it fails.
+	if command == "check" {
+		err = api.Check(ctx)
+		if err != nil {
+			logger.PrintError(err, nil)
+			return 1
+		}
+		logger.PrintInfo("check passed", nil)
+		return 0
+	}
 
-	// Create a context with a 5-second timeout deadline.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// Serve until a termination signal is received.
 
-	// Use PingContext() to establish a new connection to the database.
-	err = db.PingContext(ctx)
+	err = api.Serve(ctx)
 	if err != nil {
-		return nil, err
+		logger.PrintError(err, nil)
+		return 1
 	}
-
-	return db, nil
+	return 0
 }
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/client"
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+// benchGenre is the genre of the books created by the benchmark, which tells them apart.
+const benchGenre = "bench"
+
+// benchConfig holds the settings of a benchmark.
+type benchConfig struct {
+	duration    time.Duration
+	concurrency int
+	// rate is the number of requests per second of all the workers, 0 is unlimited.
+	rate float64
+	// mix holds the relative weights of the operations.
+	mix     map[string]int
+	cleanup bool
+}
+
+// benchOps are the operations of the benchmark, in the order of the report.
+var benchOps = []string{"list", "get", "create"}
+
+// parseMix parses the weights of the operations in the form "<op>=<weight>,...", e.g.
+// "list=6,get=3,create=1".
+func parseMix(s string) (map[string]int, error) {
+	mix := make(map[string]int)
+	total := 0
+	for _, item := range strings.Split(s, ",") {
+		op, weight, ok := strings.Cut(strings.TrimSpace(item), "=")
+		n, err := strconv.Atoi(weight)
+		if !ok || err != nil || n < 0 || !slices.Contains(benchOps, op) {
+			return nil, fmt.Errorf("invalid mix %q, want <op>=<weight>,... of list, get and create", item)
+		}
+		mix[op] = n
+		total += n
+	}
+	if total == 0 {
+		return nil, errors.New("the mix has no operation of a positive weight")
+	}
+	return mix, nil
+}
+
+// benchStats holds the outcome of the requests of an operation.
+type benchStats struct {
+	latencies []time.Duration
+	// errors counts the failed requests by status, or by error for requests without a response.
+	errors map[string]int
+}
+
+// benchIDs holds the IDs of the books requested by the get operation: the existing books
+// listed at the start and the books created since.
+type benchIDs struct {
+	mu      sync.Mutex
+	ids     []int64
+	created []int64
+}
+
+func (b *benchIDs) random() (int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.ids) == 0 {
+		return 0, false
+	}
+	return b.ids[rand.IntN(len(b.ids))], true
+}
+
+func (b *benchIDs) add(id int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.ids = append(b.ids, id)
+	b.created = append(b.created, id)
+}
+
+// bench runs the operations of the mix against the API for the duration of the benchmark,
+// and writes a report of the latencies and errors of every operation to w.
+func bench(ctx context.Context, c *client.Client, cfg benchConfig, w io.Writer) error {
+	// the books of the first page are the targets of get until books are created.
+	ids := &benchIDs{}
+	books, err := c.ListBooks(ctx, url.Values{"page_size": {"100"}})
+	if err != nil {
+		return fmt.Errorf("list the books to get: %w", err)
+	}
+	for _, book := range books {
+		ids.ids = append(ids.ids, book.ID)
+	}
+
+	var ops []string
+	for _, op := range benchOps {
+		for range cfg.mix[op] {
+			ops = append(ops, op)
+		}
+	}
+
+	var limiter *rate.Limiter
+	if cfg.rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(cfg.rate), 1)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	results := make([]map[string]*benchStats, cfg.concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range results {
+		stats := make(map[string]*benchStats)
+		for _, op := range benchOps {
+			stats[op] = &benchStats{errors: make(map[string]int)}
+		}
+		results[i] = stats
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for runCtx.Err() == nil {
+				if limiter != nil && limiter.Wait(runCtx) != nil {
+					return
+				}
+
+				op := ops[rand.IntN(len(ops))]
+				began := time.Now()
+				err := benchRequest(runCtx, c, op, ids)
+				elapsed := time.Since(began)
+				if runCtx.Err() != nil {
+					// the request was cut short by the end of the benchmark.
+					return
+				}
+
+				s := stats[op]
+				s.latencies = append(s.latencies, elapsed)
+				if err != nil {
+					var apiErr *client.Error
+					if errors.As(err, &apiErr) {
+						s.errors[strconv.Itoa(apiErr.Status)]++
+					} else {
+						s.errors[err.Error()]++
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	report(w, results, elapsed)
+
+	if cfg.cleanup {
+		for _, id := range ids.created {
+			if err := c.DeleteBook(ctx, id); err != nil {
+				return fmt.Errorf("delete created book %d: %w", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// benchRequest sends a request of the operation.
+func benchRequest(ctx context.Context, c *client.Client, op string, ids *benchIDs) error {
+	switch op {
+	case "list":
+		query := url.Values{}
+		query.Set("page", strconv.Itoa(1+rand.IntN(5)))
+		_, err := c.ListBooks(ctx, query)
+		return err
+	case "get":
+		id, ok := ids.random()
+		if !ok {
+			return errors.New("no books to get")
+		}
+		_, err := c.GetBook(ctx, id)
+		return err
+	default:
+		year := int32(1950 + rand.IntN(70))
+		pages := data.Pages(100 + rand.IntN(900))
+		book, err := c.CreateBook(ctx, client.BookInput{
+			Title:  fmt.Sprintf("Bench %d", rand.Int64()),
+			Year:   &year,
+			Pages:  &pages,
+			Genres: []string{benchGenre},
+		})
+		if err == nil {
+			ids.add(book.ID)
+		}
+		return err
+	}
+}
+
+// report writes a line of the request rate, error count and latency percentiles of every
+// operation, then the errors by status.
+func report(w io.Writer, results []map[string]*benchStats, elapsed time.Duration) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "op\trequests\terrors\terror rate\treq/s\tp50\tp90\tp99\tmax")
+
+	var errorLines []string
+	for _, op := range benchOps {
+		var all benchStats
+		all.errors = make(map[string]int)
+		for _, stats := range results {
+			all.latencies = append(all.latencies, stats[op].latencies...)
+			for status, n := range stats[op].errors {
+				all.errors[status] += n
+			}
+		}
+		if len(all.latencies) == 0 {
+			continue
+		}
+
+		sort.Slice(all.latencies, func(i, j int) bool { return all.latencies[i] < all.latencies[j] })
+		failed := 0
+		for status, n := range all.errors {
+			failed += n
+			errorLines = append(errorLines, fmt.Sprintf("%s: %d × %s", op, n, status))
+		}
+
+		n := len(all.latencies)
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%.1f\t%s\t%s\t%s\t%s\n", op, n, failed,
+			100*float64(failed)/float64(n), float64(n)/elapsed.Seconds(),
+			percentile(all.latencies, 50), percentile(all.latencies, 90), percentile(all.latencies, 99),
+			all.latencies[n-1].Round(time.Microsecond))
+	}
+	tw.Flush()
+
+	if len(errorLines) > 0 {
+		sort.Strings(errorLines)
+		fmt.Fprintln(w, "\nerrors:")
+		for _, line := range errorLines {
+			fmt.Fprintln(w, "  "+line)
+		}
+	}
+}
+
+// percentile returns the p-th percentile of the sorted latencies, by the nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank-1, 0)].Round(time.Microsecond)
+}
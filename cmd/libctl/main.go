@@ -0,0 +1,347 @@
+// Command libctl manages the catalog through the HTTP API, for scripting what would
+// otherwise be curl calls:
+//
+//	libctl [global flags] <command> [flags] [arguments]
+//
+// Run libctl -h for the commands and flags.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/client"
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+const usage = `Usage: libctl [global flags] <command> [flags] [arguments]
+
+Commands:
+  list     [-title t] [-genres g,...] [-sort s] [-page n] [-page-size n]  List books
+  get      <id>                                                          Show a book
+  create   -title t [-year y] [-pages p] [-genres g,...] [-isbn i]       Create a book
+  delete   <id>...                                                       Delete books
+  export   [-o file]                                                     Write all books as a JSON array
+  import   [-upsert] <file>                                              Create the books of a JSON array
+  bench    [-duration d] [-concurrency n] [-rate r] [-mix m] [-cleanup]  Load the API and report latencies and errors
+
+Global flags:
+`
+
+// errUsage is returned for invalid command lines, after the usage is printed.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+
+	baseURL := flag.String("url", env("LIBCTL_URL", "http://localhost:4000"), "Base URL of the API (LIBCTL_URL)")
+	token := flag.String("token", os.Getenv("LIBCTL_TOKEN"), "Bearer token sent with every request (LIBCTL_TOKEN)")
+	apiKey := flag.String("api-key", os.Getenv("LIBCTL_API_KEY"), "API key sent with every request, for servers enforcing quotas (LIBCTL_API_KEY)")
+	apiKeyHeader := flag.String("api-key-header", "X-API-Key", "Request header carrying the API key")
+	tenant := flag.String("tenant", os.Getenv("LIBCTL_TENANT"), "Tenant of the requests, for servers in multi-tenant mode (LIBCTL_TENANT)")
+	tenantHeader := flag.String("tenant-header", "X-Tenant-ID", "Request header carrying the tenant")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout of every request")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := client.New(*baseURL)
+	c.Token = *token
+	c.APIKey = *apiKey
+	c.APIKeyHeader = *apiKeyHeader
+	c.Tenant = *tenant
+	c.TenantHeader = *tenantHeader
+	c.HTTPClient.Timeout = *timeout
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, c, flag.Arg(0), flag.Args()[1:])
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "libctl:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command with its arguments.
+func run(ctx context.Context, c *client.Client, command string, args []string) error {
+	fs := flag.NewFlagSet("libctl "+command, flag.ContinueOnError)
+
+	switch command {
+	case "list":
+		title := fs.String("title", "", "Match titles containing all the words")
+		genres := fs.String("genres", "", "Comma separated genres the books must all have")
+		sort := fs.String("sort", "id", "Sort field, prefixed with - for descending order")
+		page := fs.Int("page", 1, "Page")
+		pageSize := fs.Int("page-size", 20, "Books per page (at most 100)")
+		if err := parse(fs, args, 0); err != nil {
+			return err
+		}
+
+		query := url.Values{}
+		setQuery(query, "title", *title)
+		setQuery(query, "genres", *genres)
+		query.Set("sort", *sort)
+		query.Set("page", strconv.Itoa(*page))
+		query.Set("page_size", strconv.Itoa(*pageSize))
+
+		books, err := c.ListBooks(ctx, query)
+		if err != nil {
+			return err
+		}
+		return printJSON(os.Stdout, books)
+
+	case "get":
+		if err := parse(fs, args, 1); err != nil {
+			return err
+		}
+		id, err := parseID(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		book, err := c.GetBook(ctx, id)
+		if err != nil {
+			return err
+		}
+		return printJSON(os.Stdout, book)
+
+	case "create":
+		var in client.BookInput
+		fs.StringVar(&in.Title, "title", "", "Title")
+		year := fs.Int("year", 0, "Year of publication")
+		pages := fs.Int("pages", 0, "Number of pages")
+		genres := fs.String("genres", "", "Comma separated genres")
+		fs.StringVar(&in.ISBN, "isbn", "", "ISBN")
+		if err := parse(fs, args, 0); err != nil {
+			return err
+		}
+
+		if *year != 0 {
+			y := int32(*year)
+			in.Year = &y
+		}
+		if *pages != 0 {
+			p := data.Pages(*pages)
+			in.Pages = &p
+		}
+		in.Genres = splitList(*genres)
+
+		book, err := c.CreateBook(ctx, in)
+		if err != nil {
+			return err
+		}
+		return printJSON(os.Stdout, book)
+
+	case "delete":
+		if err := parse(fs, args, -1); err != nil {
+			return err
+		}
+		for _, arg := range fs.Args() {
+			id, err := parseID(arg)
+			if err != nil {
+				return err
+			}
+			if err := c.DeleteBook(ctx, id); err != nil {
+				return fmt.Errorf("book %d: %w", id, err)
+			}
+			fmt.Fprintf(os.Stderr, "deleted book %d\n", id)
+		}
+		return nil
+
+	case "export":
+		output := fs.String("o", "", "Output file (default standard output)")
+		if err := parse(fs, args, 0); err != nil {
+			return err
+		}
+		return export(ctx, c, *output)
+
+	case "import":
+		upsert := fs.Bool("upsert", false, "Create or replace the books with an ISBN by their ISBN, so imports can be repeated")
+		if err := parse(fs, args, 1); err != nil {
+			return err
+		}
+		return importBooks(ctx, c, fs.Arg(0), *upsert)
+
+	case "bench":
+		var cfg benchConfig
+		fs.DurationVar(&cfg.duration, "duration", 30*time.Second, "Duration of the benchmark")
+		fs.IntVar(&cfg.concurrency, "concurrency", 10, "Number of requests in flight at once")
+		fs.Float64Var(&cfg.rate, "rate", 0, "Requests per second of all the workers (0 is unlimited)")
+		mix := fs.String("mix", "list=6,get=3,create=1", "Relative weights of the list, get and create requests")
+		fs.BoolVar(&cfg.cleanup, "cleanup", true, "Delete the created books at the end")
+		if err := parse(fs, args, 0); err != nil {
+			return err
+		}
+
+		var err error
+		cfg.mix, err = parseMix(*mix)
+		if err != nil {
+			return err
+		}
+		if cfg.concurrency < 1 || cfg.duration <= 0 {
+			return errors.New("concurrency and duration must be positive")
+		}
+		return bench(ctx, c, cfg, os.Stdout)
+
+	default:
+		fmt.Fprintf(os.Stderr, "libctl: unknown command %q\n\n", command)
+		flag.Usage()
+		return errUsage
+	}
+}
+
+// export writes all the books, archived ones included, in the order of their IDs to the
+// file as a JSON array.
+func export(ctx context.Context, c *client.Client, output string) error {
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	const pageSize = 100
+
+	var all []*data.Book
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("sort", "id")
+		query.Set("include_archived", "true")
+		query.Set("page", strconv.Itoa(page))
+		query.Set("page_size", strconv.Itoa(pageSize))
+
+		books, err := c.ListBooks(ctx, query)
+		if err != nil {
+			return err
+		}
+		all = append(all, books...)
+		if len(books) < pageSize {
+			break
+		}
+	}
+
+	if err := printJSON(w, all); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d books\n", len(all))
+	return nil
+}
+
+// importBooks creates the books of the JSON array in the file, as written by export. With
+// upsert, the books with an ISBN are created or replaced by their ISBN instead. It stops at
+// the first rejected book.
+func importBooks(ctx context.Context, c *client.Client, path string, upsert bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var books []*data.Book
+	if err := json.NewDecoder(f).Decode(&books); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	created, replaced := 0, 0
+	for i, book := range books {
+		in := client.Input(book)
+
+		isNew := true
+		var err error
+		if upsert && in.ISBN != "" {
+			_, isNew, err = c.UpsertBook(ctx, in)
+		} else {
+			_, err = c.CreateBook(ctx, in)
+		}
+		if err != nil {
+			return fmt.Errorf("book %d of %s (%q): %w", i+1, path, book.Title, err)
+		}
+
+		if isNew {
+			created++
+		} else {
+			replaced++
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d books: %d created, %d replaced\n", len(books), created, replaced)
+	return nil
+}
+
+// parse parses the flags of a command followed by n arguments, or at least one if n is -1.
+func parse(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if (n >= 0 && fs.NArg() != n) || (n < 0 && fs.NArg() == 0) {
+		fmt.Fprintf(os.Stderr, "libctl: wrong number of arguments of %s\n\n", fs.Name())
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// parseID parses a book ID argument.
+func parseID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid book id %q", s)
+	}
+	return id, nil
+}
+
+// setQuery sets the query parameter, unless the value is empty.
+func setQuery(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+// splitList splits a comma separated list, leaving out empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// printJSON writes v to w as indented JSON.
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
+
+// env returns the value of the environment variable, or def if it is not set.
+func env(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
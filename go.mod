@@ -1,12 +1,16 @@
 module github.com/nikitashershunov/LibraryAPI
 
-go 1.23.0
-
-toolchain go1.23.12
+go 1.24.0
 
 require (
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/lib/pq v1.10.2
 )
 
-require golang.org/x/time v0.12.0 // indirect
+require golang.org/x/time v0.12.0
+
+require (
+	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/text v0.21.0
+)
@@ -0,0 +1,217 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// newAPIKey returns a new random API key.
+func newAPIKey() string {
+	key := make([]byte, 24)
+	rand.Read(key)
+	return "lib_" + hex.EncodeToString(key)
+}
+
+// createAPIKeyHandler handles the "POST /v1/api-keys" endpoint. It creates an API key of the
+// tenant with the given quotas, or the configured default ones, and returns the record along
+// with the key itself, which is not shown again.
+func (app *Application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Name         string `json:"name"`
+		DailyQuota   *int64 `json:"daily_quota"`
+		MonthlyQuota *int64 `json:"monthly_quota"`
+	}
+	err := app.readJSON(w, r, &in)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	key := &data.APIKey{
+		Name:         in.Name,
+		DailyQuota:   app.config.quotas.daily,
+		MonthlyQuota: app.config.quotas.monthly,
+	}
+	if in.DailyQuota != nil {
+		key.DailyQuota = *in.DailyQuota
+	}
+	if in.MonthlyQuota != nil {
+		key.MonthlyQuota = *in.MonthlyQuota
+	}
+
+	v := validator.New()
+	if data.ValidateAPIKey(v, key); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	plaintext := newAPIKey()
+	err = app.apiKeys(r).Insert(key, plaintext)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/api-keys/%d", key.ID))
+	err = app.writeJSON(w, r, http.StatusCreated, wrapper{"api_key": key, "key": plaintext}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listAPIKeysHandler handles the "GET /v1/api-keys" endpoint and returns the API keys of
+// the tenant.
+func (app *Application) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := app.apiKeys(r).GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"api_keys": keys}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteAPIKeyHandler handles the "DELETE /v1/api-keys/:id" endpoint and revokes the API key.
+func (app *Application) deleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.apiKeys(r).Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"message": "API key successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// apiKeys returns the API key model scoped to the tenant of the request.
+func (app *Application) apiKeys(r *http.Request) data.APIKeyModel {
+	return app.models.APIKeys.ForTenant(app.contextGetTenant(r))
+}
+
+// quotaPeriod is the allowance of an API key over a day or a month.
+type quotaPeriod struct {
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining *int64    `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// newQuotaPeriod returns the allowance of a quota given the requests used, with a null
+// remaining allowance if the quota is unlimited.
+func newQuotaPeriod(limit, used int64, resetsAt time.Time) quotaPeriod {
+	p := quotaPeriod{Limit: limit, Used: used, ResetsAt: resetsAt}
+	if limit > 0 {
+		remaining := max(limit-used, 0)
+		p.Remaining = &remaining
+	}
+	return p
+}
+
+// quotaResets returns when the daily and the monthly quotas in effect at now reset, at the
+// next midnight and the first of the next month in UTC.
+func quotaResets(now time.Time) (day, month time.Time) {
+	now = now.UTC()
+	day = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	month = time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	return day, month
+}
+
+// showQuotaHandler handles the "GET /v1/quota" endpoint and returns the daily and monthly
+// allowance of the API key of the request: the quotas, the requests used and remaining, and
+// when they reset. Asking does not count against the quotas.
+func (app *Application) showQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	key, usage, err := app.apiKeys(r).Usage(r.Header.Get(app.config.quotas.header), now)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAPIKeyResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	day, month := quotaResets(now)
+	quota := wrapper{
+		"api_key": key,
+		"day":     newQuotaPeriod(key.DailyQuota, usage.Day, day),
+		"month":   newQuotaPeriod(key.MonthlyQuota, usage.Month, month),
+	}
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"quota": quota}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// requireQuota counts the request against the quotas of the API key it carries and rejects
+// requests without a valid API key with 401 Unauthorized, and those over the daily or the
+// monthly quota of their key with 429 Too Many Requests until the quota resets. Rejected
+// requests are counted too. Unlike the rate limiter, which smooths out bursts, the quotas
+// cap the total use of the catalog by the access plan of a client. API keys are only valid
+// for the catalog of their tenant, so the route must be wrapped in requireTenant first.
+// Without quotas enabled the route is open to everyone.
+func (app *Application) requireQuota(next http.HandlerFunc) http.HandlerFunc {
+	if !app.config.quotas.enabled {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// the responses differ by API key, which shared caches must not serve to other keys.
+		w.Header().Add("Vary", app.config.quotas.header)
+
+		plaintext := r.Header.Get(app.config.quotas.header)
+		if plaintext == "" {
+			app.invalidAPIKeyResponse(w, r)
+			return
+		}
+
+		now := time.Now()
+		key, usage, err := app.apiKeys(r).Consume(plaintext, now)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAPIKeyResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		day, month := quotaResets(now)
+		switch {
+		case key.MonthlyQuota > 0 && usage.Month > key.MonthlyQuota:
+			app.quotaExceededResponse(w, r, "monthly", month.Sub(now))
+			return
+		case key.DailyQuota > 0 && usage.Day > key.DailyQuota:
+			app.quotaExceededResponse(w, r, "daily", day.Sub(now))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
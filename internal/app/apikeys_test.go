@@ -0,0 +1,66 @@
+package app
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRequireQuota(t *testing.T) {
+	app := newTestApp()
+	app.config.quotas.header = "X-API-Key"
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open", app.requireQuota(ok))
+	app.config.quotas.enabled = true
+	mux.HandleFunc("/quota", app.requireQuota(ok))
+
+	ts := newTestServer(mux)
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/open")
+	if code != http.StatusOK || headers.Get("Vary") != "" {
+		t.Errorf("want routes open without quotas, got %d with Vary %q", code, headers.Get("Vary"))
+	}
+
+	// requests without an API key are rejected before the database is asked.
+	code, headers, _ = ts.get(t, "/quota")
+	if code != http.StatusUnauthorized {
+		t.Errorf("want %d without an API key, got %d", http.StatusUnauthorized, code)
+	}
+	if headers.Get("Vary") != "X-API-Key" {
+		t.Errorf("want the responses to vary by API key, got Vary %q", headers.Get("Vary"))
+	}
+}
+
+func TestNewQuotaPeriod(t *testing.T) {
+	resets := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	p := newQuotaPeriod(100, 120, resets)
+	if p.Remaining == nil || *p.Remaining != 0 {
+		t.Errorf("want nothing remaining over the quota, got %v", p.Remaining)
+	}
+	p = newQuotaPeriod(100, 40, resets)
+	if p.Remaining == nil || *p.Remaining != 60 {
+		t.Errorf("want 60 remaining, got %v", p.Remaining)
+	}
+	p = newQuotaPeriod(0, 40, resets)
+	if p.Remaining != nil {
+		t.Errorf("want no remaining allowance of an unlimited quota, got %d", *p.Remaining)
+	}
+}
+
+func TestQuotaResets(t *testing.T) {
+	now := time.Date(2024, time.December, 31, 23, 30, 0, 0, time.FixedZone("UTC-1", -3600))
+
+	day, month := quotaResets(now)
+	if want := time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC); !day.Equal(want) {
+		t.Errorf("want the day to reset at %s, got %s", want, day)
+	}
+	if want := time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC); !month.Equal(want) {
+		t.Errorf("want the month to reset at %s, got %s", want, month)
+	}
+}
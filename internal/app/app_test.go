@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
+)
+
+// defaultConfig returns the config of the default values of the flags.
+func defaultConfig(t *testing.T) Config {
+	t.Helper()
+
+	var cfg Config
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestEmbed(t *testing.T) {
+	// the pool only connects when it is first used, which the liveness check doesn't.
+	db, err := sql.Open("postgres", "postgres://localhost:1/books?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := defaultConfig(t)
+	cfg.port = 0
+	cfg.storage.dir = t.TempDir()
+	cfg.shutdownDelay = 0
+
+	api, err := New(cfg, WithDB(db), WithLogger(jsonlog.NewLogger(io.Discard, jsonlog.LevelOff)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close()
+
+	ts := newTestServer(api.Handler())
+	defer ts.Close()
+
+	if code, _, body := ts.get(t, "/v1/livez"); code != http.StatusOK {
+		t.Errorf("want %d, got %d: %s", http.StatusOK, code, body)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- api.Serve(ctx)
+	}()
+	cancel()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("want a graceful shutdown, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Serve didn't return after the context was done")
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	db, err := sql.Open("postgres", "postgres://localhost:1/books?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	cfg := defaultConfig(t)
+	cfg.port = ln.Addr().(*net.TCPAddr).Port
+	cfg.storage.dir = t.TempDir()
+	cfg.shutdownDelay = time.Hour
+
+	api, err := New(cfg, WithDB(db), WithLogger(jsonlog.NewLogger(io.Discard, jsonlog.LevelOff)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- api.Serve(context.Background())
+	}()
+
+	// the port is taken, so Serve stops the background jobs without draining traffic.
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Error("want the listen error")
+		}
+		if !api.shuttingDown.Load() {
+			t.Error("want the background jobs told to stop")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Serve didn't return after the server failed")
+	}
+}
+
+func TestCheckUnreachableDB(t *testing.T) {
+	db, err := sql.Open("postgres", "postgres://localhost:1/books?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := defaultConfig(t)
+	cfg.storage.dir = t.TempDir()
+
+	api, err := New(cfg, WithDB(db), WithLogger(jsonlog.NewLogger(io.Discard, jsonlog.LevelOff)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close()
+
+	err = api.Check(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "check readiness") {
+		t.Errorf("want the readiness step failed, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"fmt"
+	"time"
+)
+
+// archiveStaleBooks runs the archival job every archive interval until the server shuts
+// down. Books not updated for the configured number of years get archived, which hides
+// them from the default listings and keeps the set of searched records small.
+func (app *Application) archiveStaleBooks() {
+	if app.config.archive.after <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(app.config.archive.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if app.shuttingDown.Load() {
+			return
+		}
+
+		// Run on the worker pool so shutdown waits for it instead of closing the database under it.
+		app.background("archival", app.archiveRun)
+	}
+}
+
+// archiveRun archives the books not updated for the configured number of years.
+func (app *Application) archiveRun() {
+	before := time.Now().AddDate(-app.config.archive.after, 0, 0)
+
+	archived, err := app.models.Books.ArchiveStale(before)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"job": "archival"})
+		return
+	}
+
+	app.logger.PrintInfo("archived stale books", map[string]string{
+		"job":      "archival",
+		"archived": fmt.Sprintf("%d", archived),
+		"before":   before.UTC().Format(time.RFC3339),
+	})
+}
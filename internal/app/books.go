@@ -0,0 +1,461 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// getBookHandler handles the "GET /v1/books/:id" endpoint and returns a JSON response of the
+// requested book record, limited to the fields of the "fields" parameter if there is one.
+// If there is an error a JSON error is returned.
+func (app *Application) getBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	fields := app.readBookFields(r.URL.Query(), v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	book, err := app.books(r).Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.usage.View(app.contextGetTenant(r), book.ID)
+
+	app.presentBooks(w, r, book)
+	sparse, err := app.bookFields(book, fields)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"book": sparse}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createBookHandler handles the "POST /v1/books" endpoint and returns a JSON response of
+// the newly created book record. A book duplicating an existing one is refused with 409
+// Conflict unless the "force" parameter is true. If there is an error a JSON error is returned.
+func (app *Application) createBookHandler(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Title    string          `json:"title"`
+		Language string          `json:"language"`
+		Titles   data.Titles     `json:"titles"`
+		Year     *int32          `json:"year"`
+		Pages    *data.Pages     `json:"pages"`
+		Genres   []string        `json:"genres"`
+		ISBN     string          `json:"isbn"`
+		Metadata data.Attributes `json:"metadata"`
+	}
+	err := app.readJSON(w, r, &in)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	book := &data.Book{
+		Title:    in.Title,
+		Language: in.Language,
+		Titles:   in.Titles,
+		Year:     in.Year,
+		Pages:    in.Pages,
+		Genres:   in.Genres,
+		ISBN:     data.NormalizeISBN(in.ISBN),
+		Metadata: in.Metadata,
+	}
+
+	v := validator.New()
+	force := app.readBool(r.URL.Query(), "force", false, v)
+	if data.ValidateBook(v, book, app.bookRules()); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = resolveBookGenres(app.genreAliases(r), book)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Double-submitted forms create the same book twice; those are refused unless forced.
+	if !force {
+		id, err := app.books(r).FindDuplicate(book)
+		switch {
+		case err == nil:
+			app.duplicateBookResponse(w, r, id)
+			return
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.books(r).Insert(book)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateISBN):
+			v.AddError("isbn", "a book with this ISBN already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", routePath(routeBook, "id", strconv.FormatInt(book.ID, 10)))
+	app.presentBooks(w, r, book)
+	err = app.writeJSON(w, r, http.StatusCreated, withWarnings(wrapper{"book": book}, v), headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateBookHandler handles "PATCH /v1/books/:id" endpoint and returns a JSON response
+// of the updated book record. If there is an error a JSON error is returned.
+func (app *Application) updateBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	book, err := app.books(r).Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if r.Header.Get("X-Expected-Version") != "" {
+		if strconv.FormatInt(int64(book.Version), 10) != r.Header.Get("X-Expected-Version") {
+			app.editConflictResponse(w, r)
+			return
+		}
+	}
+
+	var in struct {
+		Title    *string          `json:"title"`
+		Language *string          `json:"language"`
+		Titles   data.Titles      `json:"titles"`
+		Year     *int32           `json:"year"`
+		Pages    *data.Pages      `json:"pages"`
+		Genres   []string         `json:"genres"`
+		ISBN     *string          `json:"isbn"`
+		Metadata *data.Attributes `json:"metadata"`
+		Archived *bool            `json:"archived"`
+	}
+
+	err = app.readJSON(w, r, &in)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if in.Title != nil {
+		book.Title = *in.Title
+	}
+	if in.Language != nil {
+		book.Language = *in.Language
+	}
+	if in.Titles != nil {
+		book.Titles = in.Titles
+	}
+	if in.Year != nil {
+		book.Year = in.Year
+	}
+	if in.Pages != nil {
+		book.Pages = in.Pages
+	}
+	if in.Genres != nil {
+		book.Genres = in.Genres
+	}
+	if in.ISBN != nil {
+		book.ISBN = data.NormalizeISBN(*in.ISBN)
+	}
+	if in.Metadata != nil {
+		book.Metadata = *in.Metadata
+	}
+	if in.Archived != nil {
+		switch {
+		case !*in.Archived:
+			book.Archived = nil
+		case book.Archived == nil:
+			now := time.Now().UTC()
+			book.Archived = &now
+		}
+	}
+
+	v := validator.New()
+	if data.ValidateBook(v, book, app.bookRules()); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = resolveBookGenres(app.genreAliases(r), book)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.books(r).Update(book)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateISBN):
+			v.AddError("isbn", "a book with this ISBN already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.presentBooks(w, r, book)
+	err = app.writeJSON(w, r, http.StatusOK, withWarnings(wrapper{"book": book}, v), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// upsertBookHandler handles "PUT /v1/books/isbn/:isbn" endpoint. It creates the book with the
+// given ISBN or replaces the existing one, and returns 201 Created or 200 OK respectively
+// with a JSON response of the book record. If there is an error a JSON error is returned.
+func (app *Application) upsertBookHandler(w http.ResponseWriter, r *http.Request) {
+	isbn := data.NormalizeISBN(app.readParam(r, "isbn"))
+
+	var in struct {
+		Title    string          `json:"title"`
+		Language string          `json:"language"`
+		Titles   data.Titles     `json:"titles"`
+		Year     *int32          `json:"year"`
+		Pages    *data.Pages     `json:"pages"`
+		Genres   []string        `json:"genres"`
+		Metadata data.Attributes `json:"metadata"`
+	}
+	err := app.readJSON(w, r, &in)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	book := &data.Book{
+		Title:    in.Title,
+		Language: in.Language,
+		Titles:   in.Titles,
+		Year:     in.Year,
+		Pages:    in.Pages,
+		Genres:   in.Genres,
+		ISBN:     isbn,
+		Metadata: in.Metadata,
+	}
+
+	v := validator.New()
+	v.Check(isbn != "", "isbn", "must be provided")
+	if data.ValidateBook(v, book, app.bookRules()); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = resolveBookGenres(app.genreAliases(r), book)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	created, err := app.books(r).Upsert(book)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	headers := make(http.Header)
+	if created {
+		status = http.StatusCreated
+		headers.Set("Location", routePath(routeBook, "id", strconv.FormatInt(book.ID, 10)))
+	}
+	app.presentBooks(w, r, book)
+	err = app.writeJSON(w, r, status, withWarnings(wrapper{"book": book}, v), headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteBookHandler handles "DELETE /v1/books/:id" endpoint and returns a 200 OK status code
+// with a success message in a JSON response. If there is an error a JSON formatted error is returned.
+func (app *Application) deleteBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.deleteBook(app.books(r), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"message": "book successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteBook removes the book with the given ID from books, and its cover and thumbnails
+// from the storage in the background. The HTTP and gRPC endpoints both delete through it.
+func (app *Application) deleteBook(books data.BookModel, id int64) error {
+	err := books.Delete(id)
+	if err != nil {
+		return err
+	}
+
+	// the cover goes with the book; a failure to remove it only leaves an orphaned file.
+	key := tenantCoverKey(books.Tenant, id)
+	app.background("delete cover", func() {
+		ctx := context.Background()
+		err := app.storage.Delete(ctx, key)
+		if err == nil {
+			err = app.deleteThumbnails(ctx, key)
+		}
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"key": key})
+		}
+	})
+
+	return nil
+}
+
+// listBooksHandler handles the "GET /v1/books" endpoint and returns a JSON response of
+// the array of book records based on the query string parameters (provided filters),
+// limited to the fields of the "fields" parameter if there is one.
+// If there is an error a JSON error is returned.
+func (app *Application) listBooksHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.BookFilter
+		data.Filters
+	}
+
+	v := validator.New()
+
+	input.BookFilter.Genres = []string{}
+	input.Filters = data.Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: bookSortSafelist}
+	app.readQuery(r.URL.Query(), &input, v)
+	fields := app.readBookFields(r.URL.Query(), v)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var err error
+	input.BookFilter.Genres, err = app.resolveGenres(r, input.BookFilter.Genres)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Free text searches go to the search index, if there is one, which adds facets.
+	var books []*data.Book
+	var meta data.Metadata
+	var facets data.Facets
+	if input.BookFilter.Query != "" {
+		books, meta, facets, err = app.books(r).Search(input.BookFilter, input.Filters)
+	} else {
+		books, meta, err = app.books(r).GetAll(input.BookFilter, input.Filters)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Only the first page counts, so paging through the results is one search.
+	if input.BookFilter.Query != "" && input.Filters.Page == 1 {
+		app.recordSearch(r, input.BookFilter.Query, meta.TotalRecords)
+	}
+	if input.BookFilter.Query != "" {
+		ids := make([]int64, len(books))
+		for i, book := range books {
+			ids[i] = book.ID
+		}
+		app.usage.Impressions(app.contextGetTenant(r), ids...)
+	}
+
+	app.presentBooks(w, r, books...)
+	sparse, err := app.booksFields(books, fields)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := wrapper{"books": sparse, "metadata": app.withPageURLs(r, meta)}
+	if facets != nil {
+		env["facets"] = facets
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// bookSortSafelist holds the sort values accepted by the book listings.
+var bookSortSafelist = []string{
+	// ascending sort values
+	"id", "title", "year", "pages", "created_at", "updated_at", "views",
+	// descending sort values
+	"-id", "-title", "-year", "-pages", "-created_at", "-updated_at", "-views",
+}
+
+// books returns the book model scoped to the tenant of the request.
+func (app *Application) books(r *http.Request) data.BookModel {
+	return app.models.Books.ForTenant(app.contextGetTenant(r))
+}
+
+// bookRules returns the book validation rules configured for the application.
+func (app *Application) bookRules() data.BookRules {
+	return data.BookRules{OptionalDetails: app.config.books.optionalDetails}
+}
+
+// localizeTitles sets the titles of the books sent in the response to the translations
+// best matching the Accept-Language header of the request, see data.Book.Localize. For a
+// single book the language of the title sent is set in the Content-Language header.
+func localizeTitles(w http.ResponseWriter, r *http.Request, books ...*data.Book) {
+	w.Header().Add("Vary", "Accept-Language")
+
+	accept := r.Header.Get("Accept-Language")
+	for _, book := range books {
+		if tag := book.Localize(accept); tag != "" && len(books) == 1 {
+			w.Header().Set("Content-Language", tag)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// cacheGroups returns a function wrapping the handlers of a route group, such as "public"
+// or "covers", in the caching policy configured for the group. Successful GET and HEAD
+// responses get the Cache-Control and Surrogate-Control headers of the policy, unless the
+// handler set its own Cache-Control; with a no-store policy every response of the group is
+// marked no-store. Routes of groups without a configured policy send no caching headers.
+func (app *Application) cacheGroups() func(group string, next http.HandlerFunc) http.HandlerFunc {
+	policies, _ := parseCachePolicies(app.config.cachePolicies)
+
+	return func(group string, next http.HandlerFunc) http.HandlerFunc {
+		p, ok := policies[group]
+		if !ok {
+			return next
+		}
+
+		cacheControl, surrogateControl := "no-store", "no-store"
+		if !p.noStore {
+			cacheControl = fmt.Sprintf("public, max-age=%d", int(p.maxAge.Seconds()))
+			surrogateControl = fmt.Sprintf("max-age=%d", int(p.surrogateMaxAge.Seconds()))
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !p.noStore && r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// responses differ by tenant, so caches must key them by the tenant header and
+			// the API key the tenant is resolved from too.
+			if !p.noStore && app.config.tenancy.enabled {
+				w.Header().Add("Vary", app.config.tenancy.header)
+				w.Header().Add("Vary", app.config.quotas.header)
+			}
+
+			cw := &cacheResponseWriter{
+				ResponseWriter:   w,
+				noStore:          p.noStore,
+				cacheControl:     cacheControl,
+				surrogateControl: surrogateControl,
+			}
+			next.ServeHTTP(cw, r)
+		}
+	}
+}
+
+// cacheResponseWriter sets the caching headers of a policy when the response status is
+// written: only on successful responses, so errors are not cached, unless the policy is
+// no-store.
+type cacheResponseWriter struct {
+	http.ResponseWriter
+	noStore          bool
+	cacheControl     string
+	surrogateControl string
+	wroteHeader      bool
+}
+
+func (cw *cacheResponseWriter) WriteHeader(statusCode int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+
+		h := cw.Header()
+		if (cw.noStore || statusCode < http.StatusBadRequest) && h.Get("Cache-Control") == "" {
+			h.Set("Cache-Control", cw.cacheControl)
+			h.Set("Surrogate-Control", cw.surrogateControl)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (cw *cacheResponseWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (cw *cacheResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCachePolicies(t *testing.T) {
+	policies, err := parseCachePolicies("covers=24h:168h, public=1m,private=no-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]cachePolicy{
+		"covers":  {maxAge: 24 * time.Hour, surrogateMaxAge: 168 * time.Hour},
+		"public":  {maxAge: time.Minute, surrogateMaxAge: time.Minute},
+		"private": {noStore: true},
+	}
+	if !reflect.DeepEqual(policies, want) {
+		t.Errorf("want %v, got %v", want, policies)
+	}
+
+	for _, s := range []string{"public", "=1m", "public=soon", "public=1m:-1s", "public=no-cache"} {
+		if _, err := parseCachePolicies(s); err == nil {
+			t.Errorf("want %q rejected", s)
+		}
+	}
+}
+
+func TestCacheGroups(t *testing.T) {
+	app := newTestApp()
+	app.config.cachePolicies = "public=1m:10m,private=no-store"
+	app.config.tenancy.enabled = true
+	app.config.tenancy.header = "X-Tenant-ID"
+	app.config.quotas.header = "X-API-Key"
+	cache := app.cacheGroups()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/public", cache("public", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	mux.HandleFunc("/public/missing", cache("public", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	mux.HandleFunc("/public/own", cache("public", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=5")
+		w.Write([]byte("{}"))
+	}))
+	mux.HandleFunc("/private", cache("private", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	mux.HandleFunc("/other", cache("other", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	ts := newTestServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		path             string
+		cacheControl     string
+		surrogateControl string
+	}{
+		{"/public", "public, max-age=60", "max-age=600"},
+		{"/public/missing", "", ""},
+		{"/public/own", "public, max-age=5", ""},
+		{"/private", "no-store", "no-store"},
+		{"/other", "", ""},
+	}
+	for _, tt := range tests {
+		_, headers, _ := ts.get(t, tt.path)
+		if headers.Get("Cache-Control") != tt.cacheControl || headers.Get("Surrogate-Control") != tt.surrogateControl {
+			t.Errorf("%s: want %q and %q, got %q and %q", tt.path, tt.cacheControl, tt.surrogateControl,
+				headers.Get("Cache-Control"), headers.Get("Surrogate-Control"))
+		}
+	}
+
+	_, headers, _ := ts.get(t, "/public")
+	if vary := strings.Join(headers.Values("Vary"), ", "); vary != "X-Tenant-ID, X-API-Key" {
+		t.Errorf("want the responses to vary by tenant and API key, got Vary %q", vary)
+	}
+
+	rs, err := ts.Client().Post(ts.URL+"/public", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	if rs.Header.Get("Cache-Control") != "" {
+		t.Errorf("want writes left alone, got Cache-Control %q", rs.Header.Get("Cache-Control"))
+	}
+}
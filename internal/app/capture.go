@@ -0,0 +1,394 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// captureMaxBody is the number of bytes of request and response bodies kept by a capture.
+// Longer bodies are truncated, and their requests can't be replayed.
+const captureMaxBody = 64 << 10
+
+// capturedRequest is a sanitized record of a request and the response it was answered with.
+type capturedRequest struct {
+	ID        int64             `json:"id"`
+	RequestID string            `json:"request_id,omitempty"`
+	Time      time.Time         `json:"time"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Header    map[string]string `json:"header,omitempty"`
+	Body      string            `json:"body,omitempty"`
+	// Replayable is false if the body was truncated or isn't text, as the request can't be
+	// sent again as received.
+	Replayable   bool   `json:"replayable"`
+	Status       int    `json:"status"`
+	ResponseBody string `json:"response_body,omitempty"`
+	Latency      string `json:"latency"`
+	// ReplayOf is the ID of the captured request this request replayed.
+	ReplayOf int64 `json:"replay_of,omitempty"`
+}
+
+// captureBuffer keeps the latest captured requests in a ring buffer and appends every
+// capture to a file as a line of JSON, if one is configured.
+type captureBuffer struct {
+	mu      sync.Mutex
+	entries []capturedRequest
+	size    int
+	nextID  int64
+	file    *os.File
+}
+
+// newCaptureBuffer returns a buffer of the latest size captures, which also appends them
+// to the file at path if it is not empty.
+func newCaptureBuffer(size int, path string) (*captureBuffer, error) {
+	c := &captureBuffer{size: size, nextID: 1}
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		c.file = f
+	}
+	return c, nil
+}
+
+// add assigns the capture an ID and stores it, evicting the oldest capture once the
+// buffer is full.
+func (c *captureBuffer) add(e capturedRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.ID = c.nextID
+	c.nextID++
+
+	if c.size > 0 {
+		if len(c.entries) == c.size {
+			copy(c.entries, c.entries[1:])
+			c.entries = c.entries[:len(c.entries)-1]
+		}
+		c.entries = append(c.entries, e)
+	}
+
+	if c.file != nil {
+		js, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = c.file.Write(append(js, '\n'))
+		return err
+	}
+	return nil
+}
+
+// list returns the captures in the buffer, oldest first.
+func (c *captureBuffer) list() []capturedRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]capturedRequest, len(c.entries))
+	copy(entries, c.entries)
+	return entries
+}
+
+// get returns the capture of the ID, if it is still in the buffer.
+func (c *captureBuffer) get(id int64) (capturedRequest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range c.entries {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return capturedRequest{}, false
+}
+
+// Close closes the capture file.
+func (c *captureBuffer) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	return c.file.Close()
+}
+
+// captureRedacted is the value sensitive headers, parameters and body members are replaced with.
+const captureRedacted = "xxxxx"
+
+// captureSensitive reports whether a header, query parameter or JSON member of the name
+// carries credentials, such as webhook secrets or bearer tokens.
+func captureSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"authorization", "cookie", "password", "secret", "token", "api-key", "api_key"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeHeader returns the headers of a capture, with the values of sensitive ones redacted.
+func sanitizeHeader(h http.Header) map[string]string {
+	header := make(map[string]string, len(h))
+	for name, values := range h {
+		value := strings.Join(values, ", ")
+		if captureSensitive(name) {
+			value = captureRedacted
+		}
+		header[name] = value
+	}
+	return header
+}
+
+// sanitizePath returns the path and query of the URL, with the values of sensitive query
+// parameters redacted.
+func sanitizePath(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+
+	query := u.Query()
+	for name := range query {
+		if captureSensitive(name) {
+			query[name] = []string{captureRedacted}
+		}
+	}
+	return u.Path + "?" + query.Encode()
+}
+
+// sanitizeBody returns the body for a capture and whether it is kept whole. Bodies which
+// are not text are left out, and the sensitive members of JSON bodies are redacted.
+func sanitizeBody(contentType string, body []byte, truncated bool) (string, bool) {
+	if len(body) == 0 {
+		return "", !truncated
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasSuffix(mediaType, "json") && !truncated:
+		var value interface{}
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		if d.Decode(&value) != nil {
+			return string(body), true
+		}
+		js, err := json.Marshal(redactJSON(value))
+		if err != nil {
+			return string(body), true
+		}
+		return string(js), true
+	case strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") || strings.HasSuffix(mediaType, "xml"):
+		return string(body), !truncated
+	default:
+		return "", false
+	}
+}
+
+// redactJSON replaces the values of the sensitive members of the decoded JSON value, at any depth.
+func redactJSON(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for name, member := range value {
+			if captureSensitive(name) {
+				value[name] = captureRedacted
+			} else {
+				value[name] = redactJSON(member)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactJSON(item)
+		}
+	}
+	return value
+}
+
+// captureResponseWriter wraps http.ResponseWriter to record the status code of the response
+// and the start of its body.
+type captureResponseWriter struct {
+	http.ResponseWriter
+	statusCode    int
+	headerWritten bool
+	body          bytes.Buffer
+	truncated     bool
+}
+
+func (cw *captureResponseWriter) WriteHeader(statusCode int) {
+	cw.ResponseWriter.WriteHeader(statusCode)
+
+	if !cw.headerWritten {
+		cw.statusCode = statusCode
+		cw.headerWritten = true
+	}
+}
+
+func (cw *captureResponseWriter) Write(b []byte) (int, error) {
+	cw.headerWritten = true
+	if room := captureMaxBody - cw.body.Len(); room < len(b) {
+		cw.body.Write(b[:max(room, 0)])
+		cw.truncated = true
+	} else {
+		cw.body.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (cw *captureResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// captureRequests records the requests of the API and their responses, sanitized, in the
+// capture buffer when capturing is enabled, so the requests behind client reported bugs can
+// be inspected and replayed. The debugging endpoints and live update connections are left out.
+func (app *Application) captureRequests(next http.Handler) http.Handler {
+	if app.captures == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/debug/") || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+
+		// read the start of the body and hand the whole body on to the handlers.
+		body, err := io.ReadAll(io.LimitReader(r.Body, captureMaxBody+1))
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		truncated := len(body) > captureMaxBody
+		if truncated {
+			body = body[:captureMaxBody]
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+		cw := &captureResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(cw, r)
+
+		e := capturedRequest{
+			RequestID: app.contextGetRequestID(r),
+			Time:      start.UTC(),
+			Method:    r.Method,
+			Path:      sanitizePath(r.URL),
+			Header:    sanitizeHeader(r.Header),
+			Status:    cw.statusCode,
+			Latency:   time.Since(start).String(),
+		}
+		e.Body, e.Replayable = sanitizeBody(r.Header.Get("Content-Type"), body, truncated)
+		e.ResponseBody, _ = sanitizeBody(cw.Header().Get("Content-Type"), cw.body.Bytes(), cw.truncated)
+		e.ReplayOf, _ = strconv.ParseInt(r.Header.Get("X-Replay-Of"), 10, 64)
+
+		err = app.captures.add(e)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"capture_file": app.config.capture.file})
+		}
+	})
+}
+
+// listCapturedRequestsHandler handles the "GET /debug/requests" endpoint and returns the
+// captured requests in the buffer, oldest first.
+func (app *Application) listCapturedRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, r, http.StatusOK, wrapper{"requests": app.captures.list()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// replayResponseWriter is the http.ResponseWriter a captured request is replayed into.
+type replayResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rw *replayResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *replayResponseWriter) WriteHeader(statusCode int) {
+	if rw.statusCode == 0 {
+		rw.statusCode = statusCode
+	}
+}
+
+func (rw *replayResponseWriter) Write(b []byte) (int, error) {
+	if rw.statusCode == 0 {
+		rw.statusCode = http.StatusOK
+	}
+	return rw.body.Write(b)
+}
+
+// replayRequestHandler handles the "POST /debug/requests/:id/replay" endpoint. It sends the
+// captured request of the ID to handler again, as a request of the client calling the
+// endpoint, and returns the captured request together with the status and body of the
+// response to the replay. Redacted credentials are sent as redacted, so requests of
+// authenticated endpoints need them added back by the caller in the request headers.
+func (app *Application) replayRequestHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readID(r)
+		if err != nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		captured, ok := app.captures.get(id)
+		if !ok {
+			app.notFoundResponse(w, r)
+			return
+		}
+		if !captured.Replayable {
+			app.failedValidationResponse(w, r, map[string]string{"id": "must be a request with a text body of at most 64 KiB"})
+			return
+		}
+
+		req, err := http.NewRequestWithContext(r.Context(), captured.Method, captured.Path, strings.NewReader(captured.Body))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		for name, value := range captured.Header {
+			if value != captureRedacted {
+				req.Header.Set(name, value)
+			}
+		}
+		// credentials given to the endpoint replace the redacted ones.
+		for name, values := range r.Header {
+			if captureSensitive(name) {
+				req.Header[name] = values
+			}
+		}
+		req.Header.Del("Content-Length")
+		req.Header.Set("X-Replay-Of", strconv.FormatInt(captured.ID, 10))
+		req.RemoteAddr = r.RemoteAddr
+
+		rw := &replayResponseWriter{header: make(http.Header)}
+		handler.ServeHTTP(rw, req)
+
+		replay, _ := sanitizeBody(rw.header.Get("Content-Type"), rw.body.Bytes(), false)
+		err = app.writeJSON(w, r, http.StatusOK, wrapper{
+			"captured": captured,
+			"replay": wrapper{
+				"status":        rw.statusCode,
+				"response_body": replay,
+			},
+		}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	})
+}
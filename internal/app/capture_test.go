@@ -0,0 +1,147 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// newCaptureServer returns a test server of an echo endpoint, "POST /v1/echo", capturing
+// its requests into a buffer of the size, and of the replay endpoint.
+func newCaptureServer(t *testing.T, size int, file string) (*testServer, *Application) {
+	t.Helper()
+
+	app := newTestApp()
+	captures, err := newCaptureBuffer(size, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { captures.Close() })
+	app.captures = captures
+
+	router := httprouter.New()
+	router.HandlerFunc(http.MethodPost, "/v1/echo", func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]interface{}
+		if err := app.readJSON(w, r, &in); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		app.writeJSON(w, r, http.StatusCreated, wrapper{"echo": in}, nil)
+	})
+	handler := app.captureRequests(router)
+	router.Handler(http.MethodPost, "/debug/requests/:id/replay", app.replayRequestHandler(handler))
+
+	ts := newTestServer(handler)
+	t.Cleanup(ts.Close)
+	return ts, app
+}
+
+func TestCaptureRequests(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "captures.jsonl")
+	ts, app := newCaptureServer(t, 2, file)
+
+	for _, title := range []string{"Dune", "Neuromancer", "The Hobbit"} {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/echo?api_key=k3y&lang=en", strings.NewReader(`{"title": "`+title+`", "webhook": {"secret": "s3cret"}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer t0ken")
+		req.Header.Set("Content-Type", "application/json")
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, rs.Body)
+		rs.Body.Close()
+		if rs.StatusCode != http.StatusCreated {
+			t.Fatalf("want the body handed on to the handler, got %d", rs.StatusCode)
+		}
+	}
+
+	captured := app.captures.list()
+	if len(captured) != 2 || captured[0].ID != 2 || captured[1].ID != 3 {
+		t.Fatalf("want the latest 2 requests, got %+v", captured)
+	}
+
+	e := captured[1]
+	if e.Method != http.MethodPost || e.Path != "/v1/echo?api_key=xxxxx&lang=en" || e.Status != http.StatusCreated || !e.Replayable {
+		t.Errorf("want the request recorded, got %+v", e)
+	}
+	if e.Header["Authorization"] != "xxxxx" {
+		t.Errorf("want the Authorization header redacted, got %q", e.Header["Authorization"])
+	}
+	for _, body := range []string{e.Body, e.ResponseBody} {
+		if strings.Contains(body, "s3cret") || !strings.Contains(body, "The Hobbit") {
+			t.Errorf("want the secret redacted from the body, got %s", body)
+		}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	lines := 0
+	for s := bufio.NewScanner(f); s.Scan(); lines++ {
+		var e capturedRequest
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lines != 3 {
+		t.Errorf("want every request appended to the file, got %d lines", lines)
+	}
+}
+
+func TestReplayRequest(t *testing.T) {
+	ts, app := newCaptureServer(t, 10, "")
+
+	rs, err := ts.Client().Post(ts.URL+"/v1/echo", "application/json", strings.NewReader(`{"title": "Dune"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	rs, err = ts.Client().Post(ts.URL+"/debug/requests/1/replay", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	var resp struct {
+		Captured capturedRequest `json:"captured"`
+		Replay   struct {
+			Status       int    `json:"status"`
+			ResponseBody string `json:"response_body"`
+		} `json:"replay"`
+	}
+	if err := json.NewDecoder(rs.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Replay.Status != http.StatusCreated || resp.Replay.ResponseBody != resp.Captured.ResponseBody {
+		t.Errorf("want the response of the capture, got %+v", resp)
+	}
+
+	captured := app.captures.list()
+	if len(captured) != 2 || captured[1].ReplayOf != 1 {
+		t.Errorf("want the replay captured, got %+v", captured)
+	}
+
+	rs, err = ts.Client().Post(ts.URL+"/debug/requests/99/replay", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	if rs.StatusCode != http.StatusNotFound {
+		t.Errorf("want %d for a request not in the buffer, got %d", http.StatusNotFound, rs.StatusCode)
+	}
+}
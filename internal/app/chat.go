@@ -0,0 +1,139 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/events"
+)
+
+// chatWebhookFailed is the chat notification of a webhook delivery given up after
+// webhookMaxAttempts attempts. New books are notified as events.BookCreated.
+const chatWebhookFailed = "webhook.failed"
+
+// Default message templates of the chat notifications.
+const (
+	defaultChatBookCreated   = `New book: {{.Book.Title}}{{with .Book.Year}} ({{.}}){{end}}{{with .Book.Genres}}, {{join . ", "}}{{end}}`
+	defaultChatWebhookFailed = `Webhook {{.Webhook.URL}} failed to receive {{.Delivery.Event}} after {{.Delivery.Attempts}} attempts: {{.Delivery.Error}}`
+)
+
+// chatTemplateFuncs are the functions available to the message templates.
+var chatTemplateFuncs = template.FuncMap{"join": strings.Join}
+
+// parseChatTemplates parses the message templates of the events notified to the chat.
+func parseChatTemplates(cfg Config) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template)
+	for _, t := range []struct {
+		event   string
+		enabled bool
+		text    string
+	}{
+		{events.BookCreated, cfg.chat.bookCreated, cfg.chat.bookCreatedTemplate},
+		{chatWebhookFailed, cfg.chat.webhookFailed, cfg.chat.webhookFailedTemplate},
+	} {
+		if !t.enabled {
+			continue
+		}
+		tmpl, err := template.New(t.event).Funcs(chatTemplateFuncs).Option("missingkey=zero").Parse(t.text)
+		if err != nil {
+			return nil, err
+		}
+		templates[t.event] = tmpl
+	}
+	return templates, nil
+}
+
+// notifyNewBooks posts the books to the chat and emails them to the -smtp-new-books
+// addresses as they are created, until the event subscription is cancelled.
+func (app *Application) notifyNewBooks() {
+	recipients, _ := mail.ParseAddressList(app.config.smtp.newBooks)
+	if (app.chat == nil || app.chatTemplates[events.BookCreated] == nil) && len(recipients) == 0 {
+		return
+	}
+
+	ch, cancel := app.events.Subscribe(256)
+	defer cancel()
+
+	for e := range ch {
+		if e.Type != events.BookCreated {
+			continue
+		}
+
+		app.background("chat notification", func() {
+			book, err := app.models.Books.ForTenant(e.Tenant).Get(e.BookID)
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				return
+			case err != nil:
+				app.logger.PrintError(err, map[string]string{"event": e.Type, "book_id": strconv.FormatInt(e.BookID, 10)})
+				return
+			}
+
+			key := fmt.Sprintf("%s:%s:%d", e.Type, e.Tenant, e.BookID)
+			notification := map[string]interface{}{"Tenant": e.Tenant, "Book": book}
+			app.notifyChat(e.Type, key, notification)
+			app.emailNewBook(recipients, key, notification)
+		})
+	}
+}
+
+// emailNewBook sends the new book email to the recipients. Like the chat notifications, it
+// is only sent by the API instance claiming key.
+func (app *Application) emailNewBook(recipients []*mail.Address, key string, data interface{}) {
+	if len(recipients) == 0 {
+		return
+	}
+
+	claimed, err := app.models.Notifications.Claim("email:" + key)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"event": events.BookCreated})
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	for _, recipient := range recipients {
+		app.sendEmail(recipient.Address, "new_book.tmpl", data)
+	}
+}
+
+// notifyChat renders the message template of the event with data and posts it to the
+// chat. Every API instance receives the events, so the notification is only posted by the
+// instance claiming its key. Events without a template are not notified.
+func (app *Application) notifyChat(event, key string, data interface{}) {
+	tmpl := app.chatTemplates[event]
+	if app.chat == nil || tmpl == nil {
+		return
+	}
+
+	var msg bytes.Buffer
+	if err := tmpl.Execute(&msg, data); err != nil {
+		app.logger.PrintError(err, map[string]string{"event": event})
+		return
+	}
+
+	claimed, err := app.models.Notifications.Claim("chat:" + key)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"event": event})
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := app.chat.Notify(ctx, msg.String()); err != nil {
+		app.logger.PrintError(err, map[string]string{"event": event})
+	}
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/events"
+)
+
+func TestChatTemplates(t *testing.T) {
+	var cfg Config
+	cfg.chat.bookCreated = true
+	cfg.chat.bookCreatedTemplate = defaultChatBookCreated
+	cfg.chat.webhookFailed = true
+	cfg.chat.webhookFailedTemplate = defaultChatWebhookFailed
+
+	templates, err := parseChatTemplates(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	year := int32(1965)
+	msg := "connection refused"
+	tests := []struct {
+		event string
+		data  interface{}
+		want  string
+	}{
+		{events.BookCreated, map[string]interface{}{"Tenant": "acme", "Book": &data.Book{Title: "Dune", Year: &year, Genres: []string{"sci-fi", "classic"}}},
+			"New book: Dune (1965), sci-fi, classic"},
+		{events.BookCreated, map[string]interface{}{"Tenant": "acme", "Book": &data.Book{Title: "Dune"}},
+			"New book: Dune"},
+		{chatWebhookFailed, map[string]interface{}{"Webhook": &data.Webhook{URL: "https://example.org/hook"}, "Delivery": &data.WebhookDelivery{Event: "book.created", Attempts: 5, Error: &msg}},
+			"Webhook https://example.org/hook failed to receive book.created after 5 attempts: connection refused"},
+	}
+
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := templates[tt.event].Execute(&b, tt.data); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("want %q, got %q", tt.want, b.String())
+		}
+	}
+
+	cfg.chat.webhookFailed = false
+	cfg.chat.bookCreatedTemplate = "{{.Book.Title"
+	if _, err := parseChatTemplates(cfg); err == nil {
+		t.Error("want an error for an invalid template")
+	}
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+// checkTenant is the tenant of the temporary book of the smoke check in multi-tenant mode.
+const checkTenant = "smoke-check"
+
+// checkAPIKey is the name of the temporary API key of the smoke check with quotas or
+// multi-tenancy enabled.
+const checkAPIKey = "smoke-check"
+
+// checkStep is a request of the smoke check and the status code it must be answered with.
+// The response is decoded into dst, unless dst is nil.
+type checkStep struct {
+	name   string
+	method string
+	path   string
+	body   interface{}
+	status int
+	dst    interface{}
+}
+
+// Check serves the API on a local port and runs a smoke check against it: the healthcheck
+// and readiness endpoints, then a round trip creating, reading, updating and deleting a
+// temporary book. With quotas or multi-tenancy enabled the requests carry a temporary API key
+// without quotas.
+// It returns an error describing the first step that failed. Every step is logged.
+func (app *Application) Check(ctx context.Context) error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler:  app.Handler(),
+		ErrorLog: log.New(app.logger, "", 0),
+	}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	base := "http://" + ln.Addr().String()
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	apiKey := ""
+	if app.config.quotas.enabled || app.config.tenancy.enabled {
+		key := &data.APIKey{Name: checkAPIKey}
+		apiKey = newAPIKey()
+		apiKeys := app.models.APIKeys.ForTenant(data.DefaultTenant)
+		if app.config.tenancy.enabled {
+			apiKeys = app.models.APIKeys.ForTenant(checkTenant)
+		}
+		if err := apiKeys.Insert(key, apiKey); err != nil {
+			return fmt.Errorf("check API key: %w", err)
+		}
+		defer apiKeys.Delete(key.ID)
+	}
+
+	book := map[string]interface{}{
+		"title":  fmt.Sprintf("Smoke check %d", time.Now().UnixNano()),
+		"year":   time.Now().Year(),
+		"pages":  1,
+		"genres": []string{"smoke-check"},
+	}
+
+	var created struct {
+		Book data.Book `json:"book"`
+	}
+	steps := []checkStep{
+		{name: "healthcheck", method: http.MethodGet, path: "/v1/healthcheck", status: http.StatusOK},
+		{name: "readiness", method: http.MethodGet, path: "/v1/readyz", status: http.StatusOK},
+		{name: "create", method: http.MethodPost, path: "/v1/books", body: book, status: http.StatusCreated, dst: &created},
+	}
+	for _, step := range steps {
+		if err := app.checkRequest(ctx, client, base, apiKey, step); err != nil {
+			return err
+		}
+	}
+
+	// delete the temporary book even if a later step fails.
+	path := "/v1/books/" + strconv.FormatInt(created.Book.ID, 10)
+	deleted := false
+	defer func() {
+		if !deleted {
+			app.checkRequest(context.Background(), client, base, apiKey, checkStep{name: "cleanup", method: http.MethodDelete, path: path, status: http.StatusOK})
+		}
+	}()
+
+	steps = []checkStep{
+		{name: "read", method: http.MethodGet, path: path, status: http.StatusOK},
+		{name: "update", method: http.MethodPatch, path: path, body: map[string]interface{}{"pages": 2}, status: http.StatusOK},
+		{name: "delete", method: http.MethodDelete, path: path, status: http.StatusOK},
+		{name: "read deleted", method: http.MethodGet, path: path, status: http.StatusNotFound},
+	}
+	for _, step := range steps {
+		if err := app.checkRequest(ctx, client, base, apiKey, step); err != nil {
+			return err
+		}
+		if step.name == "delete" {
+			deleted = true
+		}
+	}
+
+	return nil
+}
+
+// checkRequest sends the request of the step, with the API key unless it is empty, and
+// checks its status code.
+func (app *Application) checkRequest(ctx context.Context, client *http.Client, base, apiKey string, step checkStep) error {
+	var body io.Reader
+	if step.body != nil {
+		js, err := json.Marshal(step.body)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, step.method, base+step.path, body)
+	if err != nil {
+		return err
+	}
+	if app.config.tenancy.enabled {
+		req.Header.Set(app.config.tenancy.header, checkTenant)
+	}
+	if apiKey != "" {
+		req.Header.Set(app.config.quotas.header, apiKey)
+	}
+
+	start := time.Now()
+	rs, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("check %s: %w", step.name, err)
+	}
+	defer rs.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rs.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("check %s: %w", step.name, err)
+	}
+
+	app.logger.PrintInfo("check", map[string]string{
+		"step":     step.name,
+		"request":  step.method + " " + step.path,
+		"status":   strconv.Itoa(rs.StatusCode),
+		"duration": time.Since(start).String(),
+	})
+
+	if rs.StatusCode != step.status {
+		return fmt.Errorf("check %s: %s %s answered %d instead of %d: %s",
+			step.name, step.method, step.path, rs.StatusCode, step.status, bytes.TrimSpace(content))
+	}
+	if step.dst != nil {
+		if err := json.Unmarshal(content, step.dst); err != nil {
+			return fmt.Errorf("check %s: %w", step.name, err)
+		}
+	}
+
+	return nil
+}
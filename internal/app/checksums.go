@@ -0,0 +1,51 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// listChecksumsHandler handles the "GET /v1/books/checksums" endpoint and returns the IDs
+// and content checksums of the books, archived ones included, in ID order. Mirroring clients
+// compare them with the checksums of their copies to find the books which changed, without
+// downloading the books. Pages start after the ID given in "after"; the "next" URL of a full
+// page continues with the following one.
+func (app *Application) listChecksumsHandler(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		After    int64 `query:"after" validate:"min=0"`
+		PageSize int   `query:"page_size" validate:"gt=0,max=5000"`
+	}{PageSize: 1000}
+
+	v := validator.New()
+	app.readQuery(r.URL.Query(), &input, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	books, err := app.books(r).After(input.After, input.PageSize)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	checksums := make([]data.BookChecksum, len(books))
+	for i, book := range books {
+		checksums[i] = data.BookChecksum{ID: book.ID, Checksum: book.ContentHash()}
+	}
+
+	resp := wrapper{"checksums": checksums}
+	if len(books) == input.PageSize {
+		qs := r.URL.Query()
+		qs.Set("after", strconv.FormatInt(books[len(books)-1].ID, 10))
+		resp["next"] = app.routeURL(r, routeBookChecksums) + "?" + qs.Encode()
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// listCollectionsHandler handles the "GET /v1/collections" endpoint and returns the featured
+// collections shown at the moment, without their books. Admins get all the collections,
+// scheduled and ended ones included.
+func (app *Application) listCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	activeAt := time.Now()
+	if app.adminView(w, r) {
+		activeAt = time.Time{}
+	}
+
+	collections, err := app.collections(r).GetAll(activeAt)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"collections": collections}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showCollectionHandler handles the "GET /v1/collections/:slug" endpoint and returns the
+// collection with its books in order. Collections which are not shown at the moment are
+// not found, except by admins.
+func (app *Application) showCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	admin := app.adminView(w, r)
+
+	collection, err := app.collections(r).Get(app.readParam(r, "slug"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !admin && !collection.Active(time.Now()) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	collection.Books, err = app.collections(r).Books(collection)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.presentBooks(w, r, collection.Books...)
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"collection": collection}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// setCollectionHandler handles the "PUT /v1/collections/:slug" endpoint. It adds the
+// collection in the body or replaces the one with the slug, and returns 201 Created or
+// 200 OK respectively.
+func (app *Application) setCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		BookIDs     []int64    `json:"book_ids"`
+		Starts      *time.Time `json:"starts_at"`
+		Ends        *time.Time `json:"ends_at"`
+	}
+	err := app.readJSON(w, r, &in)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	collection := &data.Collection{
+		Slug:        app.readParam(r, "slug"),
+		Title:       in.Title,
+		Description: in.Description,
+		BookIDs:     in.BookIDs,
+		Starts:      in.Starts,
+		Ends:        in.Ends,
+	}
+
+	v := validator.New()
+	if data.ValidateCollection(v, collection); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	created, err := app.collections(r).Set(collection)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	headers := make(http.Header)
+	if created {
+		status = http.StatusCreated
+		headers.Set("Location", "/v1/collections/"+collection.Slug)
+	}
+	err = app.writeJSON(w, r, status, wrapper{"collection": collection}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteCollectionHandler handles the "DELETE /v1/collections/:slug" endpoint and removes
+// the collection.
+func (app *Application) deleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.collections(r).Delete(app.readParam(r, "slug"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"message": "collection successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// adminView reports whether the request is an admin's, who are shown the collections the
+// public is not. Responses to admins are not stored by caches, and caches keep public
+// responses from being served to them.
+func (app *Application) adminView(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "Authorization")
+	if !app.isAdmin(r) {
+		return false
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	return true
+}
+
+// collections returns the collection model scoped to the tenant of the request.
+func (app *Application) collections(r *http.Request) data.CollectionModel {
+	return app.models.Collections.ForTenant(app.contextGetTenant(r))
+}
@@ -0,0 +1,383 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// envPrefix is prepended to the upper-cased flag name, with dashes replaced by underscores,
+// to get the environment variable of a setting, e.g. BOOKS_DB_DSN for -db-dsn.
+const envPrefix = "BOOKS_"
+
+// envAliases holds the additional environment variables some settings are read from.
+var envAliases = map[string]string{
+	"sentry-dsn": "SENTRY_DSN",
+}
+
+// loadConfig fills in the settings of fs which weren't given on the command line from the
+// environment, then from the config file at path, if any. The defaults of the flags apply
+// to whatever is left, so flags take precedence over env, env over file, file over defaults.
+func loadConfig(fs *flag.FlagSet, path string) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	var file map[string]string
+	if path != "" {
+		var err error
+		file, err = readConfigFile(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for name := range file {
+		if fs.Lookup(name) == nil || name == "config" {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", path, name))
+		}
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || f.Name == "config" || f.Name == "version" || f.Name == "seed" {
+			return
+		}
+
+		value, ok := lookupEnv(f.Name)
+		if !ok {
+			value, ok = file[f.Name]
+		}
+		if !ok {
+			return
+		}
+
+		if err := fs.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for %s: %w", value, f.Name, err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// lookupEnv returns the value of the environment variable of a setting.
+func lookupEnv(name string) (string, bool) {
+	if value, ok := os.LookupEnv(envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))); ok {
+		return value, true
+	}
+	if alias, ok := envAliases[name]; ok {
+		return os.LookupEnv(alias)
+	}
+	return "", false
+}
+
+// readConfigFile reads a config file in a subset of TOML: "key = value" lines, optionally
+// grouped under "[section]" headers, with "#" comments. Keys are the names of the flags;
+// a section name is prepended to the keys under it, so "dsn" under "[db]" is -db-dsn.
+// Underscores in keys are treated as dashes.
+func readConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	settings := make(map[string]string)
+	section := ""
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		key = strings.ReplaceAll(strings.TrimSpace(key), "_", "-")
+		if section != "" {
+			key = strings.ReplaceAll(section, "_", "-") + "-" + key
+		}
+
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			// A quoted string, which may contain "#", followed by an optional comment.
+			end := strings.LastIndex(value, `"`)
+			value, err = strconv.Unquote(value[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid string: %w", path, n, err)
+			}
+		} else if i := strings.Index(value, "#"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+
+		settings[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// validateConfig checks the merged settings for values the flag types can't rule out.
+func validateConfig(v *validator.Validator, cfg Config) {
+	v.Check(cfg.port > 0 && cfg.port <= 65535, "port", "must be between 1 and 65535")
+	v.Check(cfg.grpc.port >= 0 && cfg.grpc.port <= 65535, "grpc-port", "must be between 0 and 65535")
+	v.Check(cfg.grpc.port != cfg.port, "grpc-port", "must be different from port")
+	v.Check(validator.Matches(cfg.oai.repositoryID, oaiRepositoryIDRX), "oai-repository-id", "must be a domain name")
+	v.Check(validator.In(cfg.publisher.kind, "", "kafka", "nats"), "publisher", "must be kafka or nats")
+	if cfg.publisher.kind != "" {
+		v.Check(cfg.publisher.url != "", "publisher-url", "must be provided")
+		v.Check(cfg.publisher.topic != "", "publisher-topic", "must be provided")
+	}
+	v.Check(validator.In(cfg.chat.kind, "", "slack", "discord"), "chat", "must be slack or discord")
+	if cfg.chat.kind != "" {
+		u, err := url.Parse(cfg.chat.webhookURL)
+		v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "chat-webhook-url", "must be an http or https URL")
+		_, err = parseChatTemplates(cfg)
+		v.Check(err == nil, "chat-templates", fmt.Sprintf("must be valid templates: %v", err))
+	}
+	v.Check(validator.In(cfg.search.backend, "postgres", "opensearch"), "search-backend", "must be postgres or opensearch")
+	if cfg.search.backend == "opensearch" {
+		v.Check(cfg.search.index != "", "opensearch-index", "must be provided")
+	}
+	v.Check(validator.In(cfg.storage.backend, "fs", "s3"), "storage", "must be fs or s3")
+	if cfg.storage.backend == "s3" {
+		v.Check(cfg.storage.s3.Bucket != "", "s3-bucket", "must be provided")
+		v.Check(cfg.storage.s3.Region != "", "s3-region", "must be provided")
+		u, err := url.Parse(cfg.storage.s3.Endpoint)
+		v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "s3-endpoint", "must be an http or https URL")
+	}
+	v.Check(validator.In(cfg.env, "development", "staging", "production"), "env", "must be development, staging or production")
+	if cfg.cache.redisURL != "" {
+		v.Check(cfg.cache.bookTTL > 0, "cache-book-ttl", "must be greater than zero")
+		v.Check(cfg.cache.listTTL > 0, "cache-list-ttl", "must be greater than zero")
+	}
+
+	_, err := jsonlog.ParseLevel(cfg.log.level)
+	v.Check(err == nil, "log-level", "must be debug, info, warn, error, fatal or off")
+	v.Check(cfg.log.maxFileSize >= 0, "log-file-max-size", "must not be negative")
+	v.Check(cfg.log.maxFileBackups >= 0, "log-file-max-backups", "must not be negative")
+
+	v.Check(cfg.db.dsn != "", "db-dsn", "must be provided")
+	v.Check(cfg.db.maxOpenConns >= 0, "db-max-open-conns", "must not be negative")
+	v.Check(cfg.db.maxIdleConns >= 0, "db-max-idle-conns", "must not be negative")
+	v.Check(cfg.db.breakerThreshold >= 0, "db-breaker-threshold", "must not be negative")
+	v.Check(cfg.db.breakerCooldown > 0, "db-breaker-cooldown", "must be positive")
+	_, err = time.ParseDuration(cfg.db.maxIdleTime)
+	v.Check(err == nil, "db-max-idle-time", "must be a duration")
+
+	v.Check(cfg.limits.body > 0, "max-body-size", "must be positive")
+	v.Check(cfg.limits.bulkBody >= cfg.limits.body, "max-bulk-body-size", "must not be less than max-body-size")
+	v.Check(validator.In(cfg.envelope.mode, "wrapped", "bare"), "envelope", "must be wrapped or bare")
+	v.Check(cfg.reading.wordsPerPage > 0, "reading-words-per-page", "must be positive")
+	v.Check(cfg.reading.wordsPerMinute > 0, "reading-words-per-minute", "must be positive")
+	_, err = parseEnvelopeKeys(cfg.envelope.keys)
+	v.Check(err == nil, "envelope-keys", "must be a comma separated list of <member>=<name>")
+	v.Check(cfg.limiter.rps > 0, "limiter-rps", "must be positive")
+	v.Check(cfg.limiter.burst > 0, "limiter-burst", "must be positive")
+	_, err = parseRatePolicies(cfg.limiter.policies)
+	v.Check(err == nil, "limiter-policies", "must be a comma separated list of <group>=<rps>:<burst>")
+	v.Check(cfg.concurrency.max >= 0, "max-in-flight", "must not be negative")
+	_, err = parseConcurrencyPolicies(cfg.concurrency.policies)
+	v.Check(err == nil, "max-in-flight-policies", "must be a comma separated list of <group>=<max>")
+	_, err = parseCachePolicies(cfg.cachePolicies)
+	v.Check(err == nil, "cache-policies", "must be a comma separated list of <group>=<max age>[:<surrogate max age>] or <group>=no-store")
+	v.Check(cfg.workers.count > 0, "workers", "must be positive")
+	v.Check(cfg.workers.queue >= 0, "worker-queue", "must not be negative")
+	v.Check(cfg.accessLog.sample >= 0 && cfg.accessLog.sample <= 1, "access-log-sample", "must be between 0 and 1")
+	v.Check(cfg.capture.size >= 0, "capture-size", "must not be negative")
+	_, err = parseFaultPolicies(cfg.faults.policies)
+	v.Check(err == nil, "faults", "must be a comma separated list of <prefix>=<latency>:<error rate>:<drop rate>")
+	v.Check(cfg.faults.dbErrorRate >= 0 && cfg.faults.dbErrorRate <= 1, "faults-db-error-rate", "must be between 0 and 1")
+	v.Check(cfg.env != "production" || cfg.faults.policies == "", "faults", "must not be used in production")
+	v.Check(cfg.env != "production" || cfg.faults.dbErrorRate == 0, "faults-db-error-rate", "must not be used in production")
+	v.Check(cfg.archive.after >= 0, "archive-after", "must not be negative")
+	v.Check(cfg.archive.interval > 0, "archive-interval", "must be positive")
+	v.Check(cfg.usageFlushInterval >= 0, "usage-flush-interval", "must not be negative")
+	v.Check(cfg.shutdownTimeout > 0, "shutdown-timeout", "must be positive")
+
+	v.Check(cfg.smtp.port > 0 && cfg.smtp.port <= 65535, "smtp-port", "must be between 1 and 65535")
+	_, err = mail.ParseAddress(cfg.smtp.sender)
+	v.Check(err == nil, "smtp-sender", "must be a valid email address")
+	if cfg.smtp.newBooks != "" {
+		_, err = mail.ParseAddressList(cfg.smtp.newBooks)
+		v.Check(err == nil, "smtp-new-books", "must be a list of valid email addresses")
+	}
+
+	v.Check((cfg.tls.cert == "") == (cfg.tls.key == ""), "tls-cert", "must be provided together with tls-key")
+	v.Check(cfg.tls.cert == "" || cfg.tls.autocertDomains == "", "autocert-domains", "must not be used together with tls-cert")
+}
+
+// ratePolicy is the rate limit of a route group: the requests per second and the burst.
+type ratePolicy struct {
+	rps   float64
+	burst int
+}
+
+// parseRatePolicies parses rate limits of route groups in the form
+// "<group>=<rps>:<burst>,...", e.g. "search=1:2,write=0.5:1".
+func parseRatePolicies(s string) (map[string]ratePolicy, error) {
+	policies := make(map[string]ratePolicy)
+	if strings.TrimSpace(s) == "" {
+		return policies, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		group, limit, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid policy %q", item)
+		}
+
+		rps, burst, ok := strings.Cut(limit, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid policy %q", item)
+		}
+
+		var p ratePolicy
+		var err error
+		p.rps, err = strconv.ParseFloat(rps, 64)
+		if err != nil || p.rps <= 0 {
+			return nil, fmt.Errorf("invalid requests per second in policy %q", item)
+		}
+		p.burst, err = strconv.Atoi(burst)
+		if err != nil || p.burst <= 0 {
+			return nil, fmt.Errorf("invalid burst in policy %q", item)
+		}
+
+		policies[group] = p
+	}
+
+	return policies, nil
+}
+
+// parseConcurrencyPolicies parses the concurrency limits of route groups in the form
+// "<group>=<max>,...", e.g. "search=10".
+func parseConcurrencyPolicies(s string) (map[string]int, error) {
+	policies := make(map[string]int)
+	if strings.TrimSpace(s) == "" {
+		return policies, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		group, max, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid policy %q", item)
+		}
+
+		n, err := strconv.Atoi(max)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid maximum in policy %q", item)
+		}
+
+		policies[group] = n
+	}
+
+	return policies, nil
+}
+
+// cachePolicy is the caching of the responses of a route group by browsers and by shared
+// caches such as CDNs. maxAge is sent in Cache-Control and surrogateMaxAge, which CDNs
+// honour instead, in Surrogate-Control. With noStore responses must not be cached at all.
+type cachePolicy struct {
+	maxAge          time.Duration
+	surrogateMaxAge time.Duration
+	noStore         bool
+}
+
+// parseCachePolicies parses the caching of route groups in the form
+// "<group>=<max age>[:<surrogate max age>],..." or "<group>=no-store", e.g.
+// "covers=24h:168h,public=1m,private=no-store".
+func parseCachePolicies(s string) (map[string]cachePolicy, error) {
+	policies := make(map[string]cachePolicy)
+	if strings.TrimSpace(s) == "" {
+		return policies, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		group, policy, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid policy %q", item)
+		}
+
+		if policy == "no-store" {
+			policies[group] = cachePolicy{noStore: true}
+			continue
+		}
+
+		maxAge, surrogateMaxAge, hasSurrogate := strings.Cut(policy, ":")
+
+		var p cachePolicy
+		var err error
+		p.maxAge, err = time.ParseDuration(maxAge)
+		if err != nil || p.maxAge < 0 {
+			return nil, fmt.Errorf("invalid max age in policy %q", item)
+		}
+		p.surrogateMaxAge = p.maxAge
+		if hasSurrogate {
+			p.surrogateMaxAge, err = time.ParseDuration(surrogateMaxAge)
+			if err != nil || p.surrogateMaxAge < 0 {
+				return nil, fmt.Errorf("invalid surrogate max age in policy %q", item)
+			}
+		}
+
+		policies[group] = p
+	}
+
+	return policies, nil
+}
+
+// passwordRX matches the password of a key=value connection string.
+var passwordRX = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// EffectiveConfig returns the values of all the settings of fs for logging, with passwords,
+// tokens, secret and API keys and the credentials in DSNs and URLs redacted.
+func EffectiveConfig(fs *flag.FlagSet) map[string]string {
+	settings := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if (strings.HasSuffix(f.Name, "-password") || strings.HasSuffix(f.Name, "-token") || strings.HasSuffix(f.Name, "-tokens") ||
+			strings.HasSuffix(f.Name, "-secret-key") || strings.HasSuffix(f.Name, "-api-key")) && value != "" {
+			value = "xxxxx"
+		}
+		if (strings.HasSuffix(f.Name, "-dsn") || strings.HasSuffix(f.Name, "-url")) && value != "" {
+			if u, err := url.Parse(value); err == nil && u.Scheme != "" {
+				if strings.HasSuffix(f.Name, "-webhook-url") {
+					// chat webhook URLs carry their secret in the path.
+					u.Path, u.RawPath, u.RawQuery = "/xxxxx", "", ""
+					value = u.String()
+				}
+				if _, ok := u.User.Password(); ok {
+					value = u.Redacted()
+				} else if u.User != nil {
+					u.User = url.User("xxxxx")
+					value = u.String()
+				}
+			} else {
+				value = passwordRX.ReplaceAllString(value, "${1}xxxxx")
+			}
+		}
+		settings[f.Name] = value
+	})
+	return settings
+}
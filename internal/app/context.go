@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
+)
+
+// contextKey is a custom type for the keys of request context values.
+type contextKey string
+
+const (
+	tenantContextKey    = contextKey("tenant")
+	requestIDContextKey = contextKey("request_id")
+	bodyLimitContextKey = contextKey("body_limit")
+)
+
+// contextSetTenant returns a new copy of the request with the tenant added to the context.
+// The tenant is also attached to every entry logged with the request context.
+func (app *Application) contextSetTenant(r *http.Request, tenant string) *http.Request {
+	ctx := context.WithValue(r.Context(), tenantContextKey, tenant)
+	ctx = jsonlog.WithAttrs(ctx, slog.String("tenant", tenant))
+	return r.WithContext(ctx)
+}
+
+// contextGetTenant retrieves the tenant from the request context. It panics if the
+// tenant is missing, which only happens if a handler is not wrapped in requireTenant.
+func (app *Application) contextGetTenant(r *http.Request) string {
+	tenant, ok := r.Context().Value(tenantContextKey).(string)
+	if !ok {
+		panic("missing tenant value in request context")
+	}
+	return tenant
+}
+
+// contextSetRequestID returns a new copy of the request with the request ID added to the context.
+// The request ID is also attached to every entry logged with the request context.
+func (app *Application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	ctx = jsonlog.WithAttrs(ctx, slog.String("request_id", id))
+	return r.WithContext(ctx)
+}
+
+// contextGetRequestID retrieves the request ID from the request context, or an empty
+// string if the request did not pass through the requestID middleware.
+func (app *Application) contextGetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// contextSetBodyLimit returns a new copy of the request with the body size limit added to the context.
+func (app *Application) contextSetBodyLimit(r *http.Request, limit int64) *http.Request {
+	ctx := context.WithValue(r.Context(), bodyLimitContextKey, limit)
+	return r.WithContext(ctx)
+}
+
+// contextGetBodyLimit retrieves the body size limit of the route from the request context,
+// or the configured default if the route doesn't set its own.
+func (app *Application) contextGetBodyLimit(r *http.Request) int64 {
+	limit, ok := r.Context().Value(bodyLimitContextKey).(int64)
+	if !ok {
+		return app.config.limits.body
+	}
+	return limit
+}
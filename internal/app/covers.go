@@ -0,0 +1,270 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/storage"
+	"github.com/nikitashershunov/LibraryAPI/internal/thumbnail"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// coverTypes are the image types accepted as book covers.
+var coverTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+// coverURLExpiry is how long the presigned URLs clients are redirected to stay valid.
+const coverURLExpiry = 15 * time.Minute
+
+// coverSizes are the sizes of the thumbnails of covers, the side of the square in pixels
+// they fit in, by the name clients ask for them with.
+var coverSizes = map[string]int{"small": 160, "medium": 320, "large": 640}
+
+// uploadCoverHandler handles the "POST /v1/books/:id/cover" endpoint. The body is the image
+// itself, which replaces the current cover of the book. The image type is detected from the
+// content rather than taken from the Content-Type header.
+func (app *Application) uploadCoverHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.books(r).Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, app.contextGetBodyLimit(r))
+
+	contentType, body, err := storage.SniffContentType(r.Body, coverTypes...)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrContentType):
+			app.unsupportedMediaTypeResponse(w, r, coverTypes)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	key := app.coverKey(r, id)
+
+	err = app.storage.Put(r.Context(), key, contentType, body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.requestTooLargeResponse(w, r, maxBytesError.Limit)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the thumbnails of the previous cover are removed right away rather than served until
+	// the new ones are ready.
+	err = app.deleteThumbnails(r.Context(), key)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.background("cover thumbnails", func() {
+		app.generateThumbnails(key)
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", routePath(routeBookCover, "id", strconv.FormatInt(id, 10)))
+	err = app.writeJSON(w, r, http.StatusCreated, wrapper{"message": "cover successfully uploaded"}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getCoverHandler handles the "GET /v1/books/:id/cover" endpoint. The "size" parameter asks
+// for a thumbnail, small, medium or large, instead of the original image, which is served
+// while the thumbnail is not generated yet and when the original is no larger. Clients are
+// redirected to a presigned URL if the storage backend issues them, otherwise the image is
+// served directly.
+func (app *Application) getCoverHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	size := r.URL.Query().Get("size")
+	_, ok := coverSizes[size]
+	v.Check(size == "" || ok, "size", "must be small, medium or large")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	key := app.coverKey(r, id)
+
+	if size != "" {
+		_, err = app.storage.Stat(r.Context(), thumbnailKey(key, size))
+		switch {
+		case err == nil:
+			key = thumbnailKey(key, size)
+		case errors.Is(err, storage.ErrNotFound):
+			// the original stands in for the thumbnail, which may still be generated.
+			w.Header().Set("Cache-Control", "no-cache")
+		default:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	_, err = app.storage.Stat(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	url, err := app.storage.PresignGet(r.Context(), key, coverURLExpiry)
+	switch {
+	case err == nil:
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	case !errors.Is(err, storage.ErrNoPresign):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	obj, content, err := app.storage.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	w.Header().Set("Last-Modified", obj.Modified.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, content)
+}
+
+// deleteCoverHandler handles the "DELETE /v1/books/:id/cover" endpoint and removes the cover
+// of the book.
+func (app *Application) deleteCoverHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	key := app.coverKey(r, id)
+
+	_, err = app.storage.Stat(r.Context(), key)
+	if err == nil {
+		err = app.storage.Delete(r.Context(), key)
+	}
+	if err == nil {
+		err = app.deleteThumbnails(r.Context(), key)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"message": "cover successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// coverKey returns the storage key of the cover of the book, scoped to the tenant of the
+// request.
+func (app *Application) coverKey(r *http.Request, id int64) string {
+	return tenantCoverKey(app.contextGetTenant(r), id)
+}
+
+// tenantCoverKey returns the storage key of the cover of the book of the tenant.
+func tenantCoverKey(tenant string, id int64) string {
+	return fmt.Sprintf("covers/%s/%d", tenant, id)
+}
+
+// thumbnailKey returns the storage key of the thumbnail of the given size of the cover
+// stored under key.
+func thumbnailKey(key, size string) string {
+	return key + "-" + size
+}
+
+// deleteThumbnails removes the thumbnails of the cover stored under key.
+func (app *Application) deleteThumbnails(ctx context.Context, key string) error {
+	for size := range coverSizes {
+		if err := app.storage.Delete(ctx, thumbnailKey(key, size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateThumbnails stores the thumbnails of the cover stored under key which is larger
+// than them, in the format of the cover. Covers which are not JPEG or PNG images, such as
+// WebP ones, get no thumbnails and are served in full.
+func (app *Application) generateThumbnails(key string) {
+	ctx := context.Background()
+
+	_, content, err := app.storage.Get(ctx, key)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"cover": key})
+		return
+	}
+	img, err := thumbnail.Decode(content)
+	content.Close()
+	switch {
+	case errors.Is(err, thumbnail.ErrFormat):
+		return
+	case err != nil:
+		app.logger.PrintError(err, map[string]string{"cover": key})
+		return
+	}
+
+	for size, side := range coverSizes {
+		thumb, ok := img.Scale(side)
+		if !ok {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := thumb.Encode(&buf); err != nil {
+			app.logger.PrintError(err, map[string]string{"cover": key, "size": size})
+			continue
+		}
+		if err := app.storage.Put(ctx, thumbnailKey(key, size), thumb.ContentType(), &buf); err != nil {
+			app.logger.PrintError(err, map[string]string{"cover": key, "size": size})
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// newReleaseYears is how many publication years, the current one included, count as new
+// releases.
+const newReleaseYears = 2
+
+// newBooksHandler handles the "GET /v1/books/new" endpoint and returns the books published
+// this year and last year, most recent first, for homepage widgets.
+func (app *Application) newBooksHandler(w http.ResponseWriter, r *http.Request) {
+	filter := data.BookFilter{MinYear: int32(time.Now().Year() - newReleaseYears + 1)}
+	app.curatedBooksHandler(w, r, filter, "-year")
+}
+
+// recentBooksHandler handles the "GET /v1/books/recent" endpoint and returns the books
+// most recently added to the catalog, for homepage widgets.
+func (app *Application) recentBooksHandler(w http.ResponseWriter, r *http.Request) {
+	app.curatedBooksHandler(w, r, data.BookFilter{}, "-created_at")
+}
+
+// curatedBooksHandler returns the books of the filter in the sort order, up to "limit"
+// books, optionally only those of all the "genres" and with the "fields". Unlike the
+// listing the response has a single page and no metadata.
+func (app *Application) curatedBooksHandler(w http.ResponseWriter, r *http.Request, filter data.BookFilter, sort string) {
+	input := struct {
+		Genres []string `query:"genres"`
+		Limit  int      `query:"limit" validate:"gt=0,max=50"`
+	}{Genres: []string{}, Limit: 12}
+
+	v := validator.New()
+	app.readQuery(r.URL.Query(), &input, v)
+	fields := app.readBookFields(r.URL.Query(), v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var err error
+	filter.Genres, err = app.resolveGenres(r, input.Genres)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	filters := data.Filters{Page: 1, PageSize: input.Limit, Sort: sort, SortSafelist: []string{sort}}
+	books, _, err := app.books(r).GetAll(filter, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.presentBooks(w, r, books...)
+	sparse, err := app.booksFields(books, fields)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"books": sparse}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
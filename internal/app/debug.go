@@ -0,0 +1,75 @@
+package app
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
+)
+
+// showLogLevelHandler handles the "GET /debug/log-level" endpoint and returns the current
+// minimum log level.
+func (app *Application) showLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	level := strings.ToLower(app.logger.MinLevel().String())
+
+	err := app.writeJSON(w, r, http.StatusOK, wrapper{"level": level}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateLogLevelHandler handles the "PUT /debug/log-level" endpoint. It changes the minimum
+// log level at runtime, e.g. to enable DEBUG entries while investigating an incident.
+func (app *Application) updateLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Level string `json:"level"`
+	}
+
+	err := app.readJSON(w, r, &in)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	level, err := jsonlog.ParseLevel(in.Level)
+	if err != nil {
+		app.failedValidationResponse(w, r, map[string]string{"level": "must be one of debug, info, warn, error, fatal, off"})
+		return
+	}
+
+	previous := app.logger.MinLevel()
+	app.logger.SetMinLevel(level)
+
+	app.logger.PrintInfo("log level changed", map[string]string{
+		"from": previous.String(),
+		"to":   level.String(),
+	})
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"level": strings.ToLower(level.String())}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// pprofHandler handles the "/debug/pprof/*profile" endpoints and serves the runtime
+// profiles in the format expected by "go tool pprof".
+func (app *Application) pprofHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	switch strings.TrimPrefix(params.ByName("profile"), "/") {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		// Index serves the index page and the named profiles, e.g. heap or goroutine.
+		pprof.Index(w, r)
+	}
+}
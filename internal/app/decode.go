@@ -0,0 +1,209 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonFieldErrors is the error of readJSON for request bodies with invalid values, keyed by
+// the field, or by the path of the value within the field, e.g. "year" or "series.title".
+type jsonFieldErrors map[string]string
+
+func (e jsonFieldErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	problems := make([]string, len(keys))
+	for i, key := range keys {
+		problems[i] = fmt.Sprintf("%s: %s", key, e[key])
+	}
+	return strings.Join(problems, "; ")
+}
+
+// decodeJSON decodes the JSON value js into destination, rejecting unknown fields. Numbers
+// decoded into interface{} values are json.Number with the json-use-number setting.
+func (app *Application) decodeJSON(js []byte, destination interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(js))
+	decoder.DisallowUnknownFields()
+	if app.config.jsonUseNumber {
+		decoder.UseNumber()
+	}
+	return decoder.Decode(destination)
+}
+
+// jsonFieldErrors decodes the fields of the JSON object js one by one into the fields of
+// the struct destination points to, and returns the problems of every field. It returns
+// nil if destination is not a pointer to a struct or js is not an object.
+func (app *Application) jsonFieldErrors(js []byte, destination interface{}) jsonFieldErrors {
+	t := reflect.TypeOf(destination)
+	if t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(js, &object); err != nil {
+		return nil
+	}
+
+	fields := jsonFields(t.Elem())
+	fieldErrors := make(jsonFieldErrors)
+	for key, value := range object {
+		fieldType, ok := lookupJSONField(fields, key)
+		if !ok {
+			fieldErrors[key] = "unknown key"
+			continue
+		}
+
+		if err := app.decodeJSON(value, reflect.New(fieldType).Interface()); err != nil {
+			path, message := jsonFieldError(err)
+			if path != "" {
+				key += "." + path
+			}
+			fieldErrors[key] = message
+		}
+	}
+	return fieldErrors
+}
+
+// jsonFields returns the types of the fields of the struct type t by their JSON name,
+// including the fields of embedded structs, as encoding/json sees them.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			for embedded, fieldType := range jsonFields(sf.Type) {
+				if _, ok := fields[embedded]; !ok {
+					fields[embedded] = fieldType
+				}
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		fields[name] = sf.Type
+	}
+	return fields
+}
+
+// lookupJSONField returns the type of the field of key. Like encoding/json, it prefers an
+// exact match of the name but falls back to a case-insensitive one.
+func lookupJSONField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if t, ok := fields[key]; ok {
+		return t, true
+	}
+	for name, t := range fields {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// jsonFieldError returns the path of the invalid value within a field and the message of
+// the error of decoding the field.
+func jsonFieldError(err error) (string, string) {
+	var unmarshalTypeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &unmarshalTypeError):
+		return unmarshalTypeError.Field, jsonTypeMessage(unmarshalTypeError)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`), "unknown key"
+	default:
+		return "", err.Error()
+	}
+}
+
+// jsonTypeMessage describes the value expected instead of the one of the error, stating
+// the range of integers for numbers overflowing the type.
+func jsonTypeMessage(e *json.UnmarshalTypeError) string {
+	t := e.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// Numbers are reported as "number <literal>", integers without fraction or exponent
+	// only fail when out of range.
+	literal, isNumber := strings.CutPrefix(e.Value, "number ")
+	integer := isNumber && !strings.ContainsAny(literal, ".eE")
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if integer {
+			bits := t.Bits()
+			return fmt.Sprintf("must be an integer between %d and %d", int64(-1)<<(bits-1), int64(1)<<(bits-1)-1)
+		}
+		return "must be an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if integer {
+			return fmt.Sprintf("must be an integer between 0 and %d", uint64(math.MaxUint64)>>(64-t.Bits()))
+		}
+		return "must be an integer"
+	case reflect.Float32, reflect.Float64:
+		return "must be a number"
+	case reflect.String:
+		return "must be a string"
+	case reflect.Bool:
+		return "must be a boolean"
+	case reflect.Slice, reflect.Array:
+		return "must be an array"
+	case reflect.Map, reflect.Struct:
+		return "must be an object"
+	default:
+		return "has an incorrect type"
+	}
+}
+
+// jsonError converts an error of decoding a request body into the error returned to the
+// client.
+func jsonError(err error) error {
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		return fmt.Errorf("incorrect form of JSON, character %d", syntaxError.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("incorrect form of JSON")
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field != "" {
+			return fmt.Errorf("incorrect type for field %q", unmarshalTypeError.Field)
+		}
+		return fmt.Errorf("incorrect JSON type, character %d", unmarshalTypeError.Offset)
+	case errors.Is(err, io.EOF):
+		return errors.New("must not be empty")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		keyName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return fmt.Errorf("unknown key %s", keyName)
+	case errors.As(err, &maxBytesError):
+		return maxBytesError
+	case errors.As(err, &invalidUnmarshalError):
+		panic(err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+type decodeInput struct {
+	Title    string          `json:"title"`
+	Year     *int32          `json:"year"`
+	Pages    *data.Pages     `json:"pages"`
+	Genres   []string        `json:"genres"`
+	Metadata data.Attributes `json:"metadata"`
+	Series   struct {
+		Number uint8 `json:"number"`
+	} `json:"series"`
+}
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+		// fields are the problems of the fields, if the body is invalid because of them.
+		fields jsonFieldErrors
+	}{
+		{name: "valid", body: `{"title": "Dune", "year": 1965, "pages": "412 pages", "genres": ["sci-fi"]}`},
+		{name: "empty", body: ``, want: "must not be empty"},
+		{name: "syntax", body: `{"title": "Dune",}`, want: "incorrect form of JSON, character 18"},
+		{name: "several values", body: `{"title": "Dune"} {}`, want: "not single JSON"},
+		{name: "not an object", body: `["Dune"]`, want: "incorrect JSON type, character 1"},
+		{
+			name: "fields",
+			body: `{"title": 1, "year": 3000000000, "pages": -1, "genres": "sci-fi", "colour": "red", "series": {"number": 1.5, "name": "x"}}`,
+			fields: jsonFieldErrors{
+				"title":         "must be a string",
+				"year":          "must be an integer between -2147483648 and 2147483647",
+				"pages":         data.ErrCountPagesOutOfRange.Error(),
+				"genres":        "must be an array",
+				"colour":        "unknown key",
+				"series.number": "must be an integer",
+			},
+		},
+		{
+			name:   "unsigned overflow",
+			body:   `{"series": {"number": -1}}`,
+			fields: jsonFieldErrors{"series.number": "must be an integer between 0 and 255"},
+		},
+	}
+
+	app := newTestApp()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/books", strings.NewReader(tt.body))
+			var in decodeInput
+			err := app.readJSON(httptest.NewRecorder(), r, &in)
+
+			var fieldErrors jsonFieldErrors
+			switch {
+			case tt.fields != nil:
+				if !errors.As(err, &fieldErrors) || !reflect.DeepEqual(fieldErrors, tt.fields) {
+					t.Errorf("want field errors %v, got %v", tt.fields, err)
+				}
+			case tt.want == "" && err != nil:
+				t.Errorf("want no error, got %v", err)
+			case tt.want != "" && (err == nil || err.Error() != tt.want):
+				t.Errorf("want error %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestReadJSONUseNumber(t *testing.T) {
+	app := newTestApp()
+	app.config.jsonUseNumber = true
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/books", strings.NewReader(`{"metadata": {"id": 12345678901234567890}}`))
+	var in decodeInput
+	if err := app.readJSON(httptest.NewRecorder(), r, &in); err != nil {
+		t.Fatal(err)
+	}
+	if got := in.Metadata["id"]; got != json.Number("12345678901234567890") {
+		t.Errorf("want the number kept exact, got %v", got)
+	}
+}
+
+func TestDecompressRequest(t *testing.T) {
+	gzipped := func(s string) string {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(s))
+		zw.Close()
+		return buf.String()
+	}
+
+	app := newTestApp()
+	app.config.limits.body = 1000
+	handler := app.decompressRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in decodeInput
+		if err := app.readJSON(w, r, &in); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		w.Write([]byte(in.Title))
+	}))
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     string
+		status   int
+		want     string
+	}{
+		{"plain", "", `{"title": "Dune"}`, http.StatusOK, "Dune"},
+		{"gzip", "gzip", gzipped(`{"title": "Dune"}`), http.StatusOK, "Dune"},
+		{"decompressed too large", "gzip", gzipped(`{"title": "` + strings.Repeat("a", 2000) + `"}`), http.StatusRequestEntityTooLarge, ""},
+		{"not gzip", "gzip", `{"title": "Dune"}`, http.StatusBadRequest, ""},
+		{"unsupported", "br", `{"title": "Dune"}`, http.StatusUnsupportedMediaType, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/books", strings.NewReader(tt.body))
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, r)
+
+			if rr.Code != tt.status {
+				t.Fatalf("want %d, got %d: %s", tt.status, rr.Code, rr.Body)
+			}
+			if tt.want != "" && rr.Body.String() != tt.want {
+				t.Errorf("want body %q, got %q", tt.want, rr.Body)
+			}
+			if tt.status == http.StatusUnsupportedMediaType && rr.Header().Get("Accept-Encoding") != "gzip" {
+				t.Errorf("want the accepted encodings in Accept-Encoding, got %q", rr.Header().Get("Accept-Encoding"))
+			}
+		})
+	}
+}
+
+// FuzzReadJSON checks that readJSON never panics and that it only accepts bodies it can
+// send back, reporting every other body as a client error. Run with:
+//
+//	go test ./internal/app -run=^$ -fuzz=FuzzReadJSON
+func FuzzReadJSON(f *testing.F) {
+	for _, body := range []string{
+		`{"title": "Dune", "year": 1965, "pages": "412 pages", "genres": ["sci-fi"]}`,
+		`{"title": 1, "year": "1965", "pages": -1, "series": {"number": 256}}`,
+		`{"metadata": {"edition": {"number": 1}}, "colour": "red"}`,
+		`{"title": "Dune",}`,
+		`{"title": "Dune"} {}`,
+		`["Dune"]`,
+		``,
+	} {
+		f.Add([]byte(body))
+	}
+
+	app := newTestApp()
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var input decodeInput
+		r := httptest.NewRequest(http.MethodPost, "/v1/books", strings.NewReader(string(body)))
+
+		err := app.readJSON(httptest.NewRecorder(), r, &input)
+		if err != nil {
+			if err.Error() == "" {
+				t.Fatalf("%q: got an error without a message", body)
+			}
+			return
+		}
+		if _, err := json.Marshal(input); err != nil {
+			t.Fatalf("%q: accepted input cannot be encoded: %v", body, err)
+		}
+	})
+}
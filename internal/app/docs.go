@@ -0,0 +1,131 @@
+package app
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonschema"
+)
+
+// openAPISpec is the OpenAPI 3 document of the API. It is maintained by hand alongside the
+// routes, and TestOpenAPICoversRoutes fails if a route is missing from it.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// swaggerUIPage loads Swagger UI from a CDN and points it at the OpenAPI document.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Library API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({url: "/v1/openapi.json", dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>
+`
+
+// openAPIHandler handles the "GET /v1/openapi.json" endpoint and returns the OpenAPI document.
+func (app *Application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// swaggerUIHandler handles the "GET /docs" endpoint and returns the Swagger UI page.
+func (app *Application) swaggerUIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
+
+// requestSchema is the JSON Schema of the JSON request body of an operation of the OpenAPI
+// document.
+type requestSchema struct {
+	method string
+	// segments are the segments of the path of the operation, "{param}" matching any.
+	segments []string
+	schema   *jsonschema.Schema
+}
+
+// requestSchemas reads the schemas of the JSON request bodies of the operations of the
+// OpenAPI document spec, and the component schemas their $refs point to.
+func requestSchemas(spec []byte) ([]requestSchema, jsonschema.Schemas, error) {
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]*jsonschema.Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	refs := make(jsonschema.Schemas)
+	for name, schema := range doc.Components.Schemas {
+		if err := schema.Compile(); err != nil {
+			return nil, nil, err
+		}
+		refs["#/components/schemas/"+name] = schema
+	}
+
+	var schemas []requestSchema
+	for path, operations := range doc.Paths {
+		for method, js := range operations {
+			var op struct {
+				RequestBody struct {
+					Content map[string]struct {
+						Schema *jsonschema.Schema `json:"schema"`
+					} `json:"content"`
+				} `json:"requestBody"`
+			}
+			// the path item holds parameters besides the operations.
+			if method == "parameters" || json.Unmarshal(js, &op) != nil {
+				continue
+			}
+
+			schema := op.RequestBody.Content["application/json"].Schema
+			if schema == nil {
+				continue
+			}
+			if err := schema.Compile(); err != nil {
+				return nil, nil, err
+			}
+			schemas = append(schemas, requestSchema{
+				method:   strings.ToUpper(method),
+				segments: strings.Split(path, "/"),
+				schema:   schema,
+			})
+		}
+	}
+	return schemas, refs, nil
+}
+
+// matchRequestSchema returns the schema of the request body of the operation serving the
+// method and path, or nil if there is none.
+func matchRequestSchema(schemas []requestSchema, method, path string) *jsonschema.Schema {
+	segments := strings.Split(path, "/")
+
+next:
+	for _, rs := range schemas {
+		if rs.method != method || len(rs.segments) != len(segments) {
+			continue
+		}
+		for i, segment := range rs.segments {
+			isParam := strings.HasPrefix(segment, "{") && segments[i] != ""
+			if segment != segments[i] && !isParam {
+				continue next
+			}
+		}
+		return rs.schema
+	}
+	return nil
+}
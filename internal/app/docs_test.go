@@ -0,0 +1,158 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := os.ReadFile("routes.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// routes are registered with path literals or with the route patterns of links.go.
+	links, err := os.ReadFile("links.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := make(map[string]string)
+	for _, m := range regexp.MustCompile(`(route\w+)\s*= "([^"]+)"`).FindAllStringSubmatch(string(links), -1) {
+		patterns[m[1]] = m[2]
+	}
+
+	routeRX := regexp.MustCompile(`router\.Handler(?:Func)?\(http\.Method(\w+), (?:"([^"]+)"|(route\w+))`)
+	paramRX := regexp.MustCompile(`[:*](\w+)`)
+
+	routes := routeRX.FindAllStringSubmatch(string(src), -1)
+	if len(routes) == 0 {
+		t.Fatal("no routes found in routes.go")
+	}
+
+	for _, route := range routes {
+		method := strings.ToLower(route[1])
+		pattern := route[2]
+		if route[3] != "" {
+			var ok bool
+			if pattern, ok = patterns[route[3]]; !ok {
+				t.Fatalf("route pattern %s is missing from links.go", route[3])
+			}
+		}
+		path := paramRX.ReplaceAllString(pattern, "{$1}")
+
+		if _, ok := spec.Paths[path][method]; !ok {
+			t.Errorf("%s %s is missing from openapi.json", strings.ToUpper(method), path)
+		}
+	}
+}
+
+func TestValidateRequestBody(t *testing.T) {
+	app := newTestApp()
+	app.config.validateRequests = true
+	handler := app.validateRequestBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		method, path, body string
+		want               map[string]string
+	}{
+		{method: http.MethodPost, path: "/v1/books", body: `{"title": "Dune", "year": 1965, "pages": 412, "genres": ["sci-fi"]}`},
+		{
+			method: http.MethodPost, path: "/v1/books",
+			body: `{"year": 1700.5, "pages": "many", "genres": ["sci-fi", "sci-fi", 1]}`,
+			want: map[string]string{
+				"title":     "must be provided",
+				"year":      "must be an integer",
+				"pages":     "must match exactly one of the accepted forms",
+				"genres":    "must not contain duplicate values",
+				"genres[2]": "must be a string",
+			},
+		},
+		{
+			method: http.MethodPatch, path: "/v1/books/12",
+			body: `{"year": 1700}`,
+			want: map[string]string{"year": "must be at least 1900"},
+		},
+		{
+			method: http.MethodPost, path: "/v1/webhooks",
+			body: `{"url": "https://example.org/hooks", "events": ["book.read"]}`,
+			want: map[string]string{"events[0]": "must be one of book.created, book.updated or book.deleted"},
+		},
+		// bodies which are not objects and requests without a schema are left to the handlers.
+		{method: http.MethodPost, path: "/v1/books", body: `["Dune"]`},
+		{method: http.MethodPost, path: "/v1/books/12/enrich", body: `{"title": 1}`},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+		if tt.want == nil {
+			if w.Code != http.StatusNoContent {
+				t.Errorf("%s %s %s: want the request passed on, got %d %s", tt.method, tt.path, tt.body, w.Code, w.Body)
+			}
+			continue
+		}
+
+		var resp struct {
+			Error map[string]string `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusUnprocessableEntity || !reflect.DeepEqual(resp.Error, tt.want) {
+			t.Errorf("%s %s %s: want 422 with %v, got %d %v", tt.method, tt.path, tt.body, tt.want, w.Code, resp.Error)
+		}
+	}
+}
+
+func TestValidateRequestBodyLimit(t *testing.T) {
+	app := newTestApp()
+	app.config.validateRequests = true
+	app.config.limits.body = 100
+	app.config.limits.bulkBody = 1000
+
+	// the handler enforces the limit of its route, as readJSON does.
+	handler := app.validateRequestBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(http.MaxBytesReader(w, r.Body, app.contextGetBodyLimit(r)))
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// the title is invalid, but bodies over the default limit are left to the route.
+	tests := []struct {
+		size int
+		want int
+	}{
+		{size: 50, want: http.StatusUnprocessableEntity},
+		{size: 500, want: http.StatusRequestEntityTooLarge},
+		{size: 5000, want: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		body := `{"title": 1, "padding": "` + strings.Repeat("x", tt.size) + `"}`
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/books", strings.NewReader(body)))
+		if w.Code != tt.want {
+			t.Errorf("%d byte padding: want %d, got %d %s", tt.size, tt.want, w.Code, w.Body)
+		}
+	}
+}
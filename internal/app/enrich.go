@@ -0,0 +1,183 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/events"
+	"github.com/nikitashershunov/LibraryAPI/internal/googlebooks"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// maxDescriptionBytes caps the enriched descriptions, which share the metadata size limit
+// of the book with everything else.
+const maxDescriptionBytes = 4096
+
+// errNoISBN is returned by enrichBook for books without an ISBN to look them up by.
+var errNoISBN = errors.New("book has no isbn")
+
+// enrichBookHandler handles the "POST /v1/books/:id/enrich" endpoint. It looks the book up
+// in Google Books by its ISBN, merges the details found into it and returns the book along
+// with the names of the changed fields.
+func (app *Application) enrichBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	book, changed, err := app.enrichBook(app.books(r), id)
+	if err != nil {
+		v := validator.New()
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, errNoISBN):
+			v.AddError("isbn", "must be set to enrich the book")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, googlebooks.ErrNotFound):
+			v.AddError("isbn", "no book with this ISBN found in Google Books")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, new(*lookupError)):
+			app.badGatewayResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if changed == nil {
+		changed = []string{}
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"book": book, "enriched_fields": changed}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// lookupError wraps the failures of the catalog lookup, which aren't the fault of the API.
+type lookupError struct {
+	err error
+}
+
+func (e *lookupError) Error() string {
+	return "google books lookup: " + e.err.Error()
+}
+
+func (e *lookupError) Unwrap() error {
+	return e.err
+}
+
+// enrichBook merges the details of the book found in Google Books into it with data.Enrich
+// and saves the book if anything changed. Concurrent edits are retried with a fresh copy
+// of the book a couple of times.
+func (app *Application) enrichBook(books data.BookModel, id int64) (*data.Book, []string, error) {
+	var volume *googlebooks.Volume
+
+	for attempt := 1; ; attempt++ {
+		book, err := books.Get(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if book.ISBN == "" {
+			return nil, nil, errNoISBN
+		}
+
+		if volume == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			volume, err = app.googleBooks.LookupISBN(ctx, book.ISBN)
+			cancel()
+			switch {
+			case errors.Is(err, googlebooks.ErrNotFound):
+				return nil, nil, err
+			case err != nil:
+				return nil, nil, &lookupError{err}
+			}
+		}
+
+		changed := data.Enrich(book, enrichment(volume), time.Now())
+		if changed == nil {
+			return book, nil, nil
+		}
+
+		v := validator.New()
+		if data.ValidateBook(v, book, app.bookRules()); !v.Valid() {
+			return nil, nil, fmt.Errorf("enriched book is invalid: %v", v.Errors)
+		}
+
+		err = books.Update(book)
+		if errors.Is(err, data.ErrEditConflict) && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return book, changed, nil
+	}
+}
+
+// enrichment converts a Google Books volume to the details merged into books, leaving out
+// values books cannot have.
+func enrichment(volume *googlebooks.Volume) data.Enrichment {
+	e := data.Enrichment{
+		Source:      "google_books",
+		SourceID:    volume.ID,
+		Description: volume.Description,
+		Categories:  volume.Categories,
+		CoverURL:    volume.Thumbnail,
+	}
+
+	if len(e.Description) > maxDescriptionBytes {
+		cut := maxDescriptionBytes
+		for cut > 0 && !utf8.RuneStart(e.Description[cut]) {
+			cut--
+		}
+		e.Description = e.Description[:cut]
+	}
+	if volume.Year >= 1900 && volume.Year <= time.Now().Year() {
+		year := int32(volume.Year)
+		e.Year = &year
+	}
+	if volume.PageCount > 0 {
+		pages := data.Pages(volume.PageCount)
+		e.Pages = &pages
+	}
+
+	return e
+}
+
+// enrichNewBooks enriches the books with an ISBN as they are created, until the event
+// subscription is cancelled.
+func (app *Application) enrichNewBooks() {
+	if !app.config.enrich.newBooks {
+		return
+	}
+
+	ch, cancel := app.events.Subscribe(256)
+	defer cancel()
+
+	for e := range ch {
+		if e.Type != events.BookCreated {
+			continue
+		}
+
+		app.background("enrich", func() {
+			_, _, err := app.enrichBook(app.models.Books.ForTenant(e.Tenant), e.BookID)
+			switch {
+			case err == nil, errors.Is(err, errNoISBN), errors.Is(err, googlebooks.ErrNotFound), errors.Is(err, data.ErrRecordNotFound):
+			default:
+				app.logger.PrintError(err, map[string]string{"job": "enrich", "book_id": strconv.FormatInt(e.BookID, 10)})
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// resourceKeys are the members of response envelopes holding the resource, which makes up
+// the whole body of bare responses.
+var resourceKeys = []string{"book", "books", "checksums", "export", "webhook", "webhooks", "deliveries", "api_key", "api_keys", "quota", "genre_alias", "genre_aliases", "collection", "collections"}
+
+// parseEnvelopeKeys parses the names of envelope members in the form "<member>=<name>,...",
+// e.g. "book=data,books=data,metadata=meta".
+func parseEnvelopeKeys(s string) (map[string]string, error) {
+	keys := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return keys, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		member, name, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || member == "" || name == "" {
+			return nil, errors.New("invalid envelope key " + item)
+		}
+		keys[member] = name
+	}
+	return keys, nil
+}
+
+// bareEnvelope reports whether the response to r leaves out the envelope. Clients choose
+// with an envelope parameter of the JSON media range they accept, e.g.
+// "Accept: application/json; envelope=bare", or get the configured -envelope.
+func (app *Application) bareEnvelope(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accepted)
+		if err != nil || !validator.In(mediaType, "application/json", "application/*", "*/*") {
+			continue
+		}
+		switch params["envelope"] {
+		case "bare":
+			return true
+		case "wrapped":
+			return false
+		}
+	}
+	return app.config.envelope.mode == "bare"
+}
+
+// envelope returns the body of the response with data. Bare responses are the resource
+// alone, the other members of the envelope being sent in "X-<Member>" headers, strings as
+// they are and other values as JSON. Otherwise the members are renamed as configured with
+// -envelope-keys. Responses without a resource, such as errors, are never bare.
+func (app *Application) envelope(w http.ResponseWriter, r *http.Request, data wrapper) (interface{}, error) {
+	resource := ""
+	for _, key := range resourceKeys {
+		if _, ok := data[key]; ok {
+			resource = key
+			break
+		}
+	}
+
+	if resource != "" {
+		w.Header().Add("Vary", "Accept")
+		if app.bareEnvelope(r) {
+			for key, value := range data {
+				if key == resource {
+					continue
+				}
+				header, ok := value.(string)
+				if !ok {
+					js, err := json.Marshal(value)
+					if err != nil {
+						return nil, err
+					}
+					header = string(js)
+				}
+				w.Header().Set(envelopeHeader(app.envelopeKey(key)), header)
+			}
+			return data[resource], nil
+		}
+	}
+
+	if len(app.envelopeKeys) == 0 {
+		return data, nil
+	}
+	renamed := make(wrapper, len(data))
+	for key, value := range data {
+		renamed[app.envelopeKey(key)] = value
+	}
+	return renamed, nil
+}
+
+// envelopeKey returns the configured name of the envelope member.
+func (app *Application) envelopeKey(key string) string {
+	if name, ok := app.envelopeKeys[key]; ok {
+		return name
+	}
+	return key
+}
+
+// envelopeHeader returns the header of a member of the envelope left out of bare
+// responses, e.g. "X-Enriched-Fields" for "enriched_fields".
+func envelopeHeader(key string) string {
+	return textproto.CanonicalMIMEHeaderKey("X-" + strings.ReplaceAll(key, "_", "-"))
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	env := wrapper{
+		"books":    []string{"Dune"},
+		"metadata": map[string]int{"total_records": 1},
+		"secret":   "s3cret",
+	}
+
+	tests := []struct {
+		name    string
+		mode    string
+		keys    string
+		accept  string
+		data    wrapper
+		want    string
+		headers map[string]string
+	}{
+		{
+			name: "wrapped",
+			data: env,
+			want: `{"books":["Dune"],"metadata":{"total_records":1},"secret":"s3cret"}`,
+		},
+		{
+			name: "renamed",
+			keys: "books=data,metadata=meta",
+			data: env,
+			want: `{"data":["Dune"],"meta":{"total_records":1},"secret":"s3cret"}`,
+		},
+		{
+			name:    "bare",
+			mode:    "bare",
+			keys:    "metadata=meta",
+			data:    env,
+			want:    `["Dune"]`,
+			headers: map[string]string{"X-Meta": `{"total_records":1}`, "X-Secret": "s3cret"},
+		},
+		{
+			name:   "bare requested",
+			accept: "text/html, application/json; envelope=bare",
+			data:   wrapper{"book": map[string]string{"title": "Dune"}},
+			want:   `{"title":"Dune"}`,
+		},
+		{
+			name:   "wrapped requested",
+			mode:   "bare",
+			accept: "application/json;envelope=wrapped",
+			data:   wrapper{"book": map[string]string{"title": "Dune"}},
+			want:   `{"book":{"title":"Dune"}}`,
+		},
+		{
+			name: "no resource",
+			mode: "bare",
+			data: wrapper{"error": "the requested resource could not be found"},
+			want: `{"error":"the requested resource could not be found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			app.config.envelope.mode = tt.mode
+			keys, err := parseEnvelopeKeys(tt.keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.envelopeKeys = keys
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/v1/books", nil)
+			r.Header.Set("Accept", tt.accept)
+			if err := app.writeJSON(w, r, http.StatusOK, tt.data, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := w.Body.String(); got != tt.want+"\n" {
+				t.Errorf("want body %s, got %s", tt.want, got)
+			}
+			for header, want := range tt.headers {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("want %s header %q, got %q", header, want, got)
+				}
+			}
+		})
+	}
+}
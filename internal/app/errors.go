@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/sentry"
+)
+
+// logError method is helper for logging error message in *application,
+// also requested method and request URL. Context fields such as the request ID
+// are added by the logger.
+func (app *Application) logError(r *http.Request, err error) {
+	app.logger.Slog().ErrorContext(r.Context(), err.Error(),
+		"request_method", r.Method,
+		"request_url", r.URL.String(),
+	)
+}
+
+// reportError sends err along with the request metadata to the error reporting service,
+// if one is configured. The event is sent in the background so the response isn't delayed.
+func (app *Application) reportError(r *http.Request, err error) {
+	if app.sentry == nil {
+		return
+	}
+
+	tags := map[string]string{"request_id": app.contextGetRequestID(r)}
+	if tenant, ok := r.Context().Value(tenantContextKey).(string); ok {
+		tags["tenant"] = tenant
+	}
+	event := sentry.NewEvent(err, 2, r, tags)
+
+	app.background("report error", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := app.sentry.Send(ctx, event); err != nil {
+			app.logger.PrintWarn("unable to report error", map[string]string{"error": err.Error()})
+		}
+	})
+}
+
+// errorResponse method is helper for sending JSON error messages to the client with a given status code.
+func (app *Application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
+	wrap := wrapper{"error": message}
+
+	err := app.writeJSON(w, r, status, wrap, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
+
+// serverErrorResponse method is used for unexpected problem at runtime.
+// it logs and reports error message, then uses errorResponse() helper to send
+// 500 Internal Server Error status code and JSON response to client. Calls rejected
+// by the open database circuit breaker get 503 Service Unavailable instead.
+func (app *Application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, data.ErrCircuitOpen) {
+		app.databaseUnavailableResponse(w, r)
+		return
+	}
+
+	app.logError(r, err)
+	app.reportError(r, err)
+	message := "the application encountered a problem and could not process your request"
+	app.errorResponse(w, r, http.StatusInternalServerError, message)
+}
+
+// notFoundResponse method is used to send 404 Not Found status code and JSON response to client.
+func (app *Application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the requested resource could not be found"
+	app.errorResponse(w, r, http.StatusNotFound, message)
+}
+
+// methodNotAllowedResponse method is used to send 405 Method Not Allowed status code and JSON response to client.
+func (app *Application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+}
+
+// badRequestResponse sends JSON error message with 400 Bad Request status code. Bodies over
+// the size limit, as reported by readJSON, get 413 Request Entity Too Large instead, and
+// invalid values of fields are sent as an object of the problems by field.
+func (app *Application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		app.requestTooLargeResponse(w, r, maxBytesError.Limit)
+		return
+	}
+	var fieldErrors jsonFieldErrors
+	if errors.As(err, &fieldErrors) {
+		app.errorResponse(w, r, http.StatusBadRequest, fieldErrors)
+		return
+	}
+	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+}
+
+// requestTooLargeResponse sends JSON error message with 413 Request Entity Too Large status code,
+// stating the body size limit of the route.
+func (app *Application) requestTooLargeResponse(w http.ResponseWriter, r *http.Request, limit int64) {
+	message := fmt.Sprintf("the request body must not be larger than %d bytes", limit)
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, message)
+}
+
+// unsupportedMediaTypeResponse sends JSON error message with 415 Unsupported Media Type status
+// code, listing the accepted types.
+func (app *Application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, types []string) {
+	message := fmt.Sprintf("the content must be one of %s", strings.Join(types, ", "))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+// unsupportedEncodingResponse sends JSON error message with 415 Unsupported Media Type status
+// code, listing the accepted content encodings of request bodies.
+func (app *Application) unsupportedEncodingResponse(w http.ResponseWriter, r *http.Request, encodings []string) {
+	message := fmt.Sprintf("the content encoding must be one of %s", strings.Join(encodings, ", "))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+// failedValidationResponse sends JSON error message to client
+// with Unprocessable Entity 422 status code when validation fails.
+func (app *Application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+}
+
+// editConflictResponse sends JSON error message to client with 409 Conflict status code.
+func (app *Application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to update the record due to an edit conflict, please try again"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+// duplicateBookResponse sends JSON error message with 409 Conflict status code when a new book
+// duplicates an existing one, which is linked in the Link header with the "duplicate" relation.
+func (app *Application) duplicateBookResponse(w http.ResponseWriter, r *http.Request, id int64) {
+	path := routePath(routeBook, "id", strconv.FormatInt(id, 10))
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="duplicate"`, path))
+	message := fmt.Sprintf("the book duplicates the existing book %s, set force=true to create it anyway", path)
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+// overloadedResponse sends JSON error message with 503 Service Unavailable status code when
+// the server sheds load, asking the client to retry after a second.
+func (app *Application) overloadedResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "1")
+	message := "the server is overloaded, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
+// badGatewayResponse logs the failure of an external service the request depends on and
+// sends JSON error message with 502 Bad Gateway status code.
+func (app *Application) badGatewayResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+	message := "an external service failed to process the request, please try again later"
+	app.errorResponse(w, r, http.StatusBadGateway, message)
+}
+
+// databaseUnavailableResponse sends JSON error message with 503 Service Unavailable status code
+// while the database circuit breaker is open, asking the client to retry after the cooldown.
+func (app *Application) databaseUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	retryAfter := int(app.models.Books.Breaker.Cooldown().Seconds())
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	message := "the database is temporarily unavailable, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
+// invalidAuthenticationTokenResponse sends JSON error message with 401 Unauthorized status code
+// when the request lacks a valid token.
+func (app *Application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	message := "invalid or missing authentication token"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// invalidAPIKeyResponse sends JSON error message with 401 Unauthorized status code when the
+// request lacks a valid API key.
+func (app *Application) invalidAPIKeyResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or missing API key"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// quotaExceededResponse sends JSON error message with 429 Too Many Requests status code when
+// the daily or monthly quota of the API key is used up, telling the client when it resets.
+func (app *Application) quotaExceededResponse(w http.ResponseWriter, r *http.Request, period string, resetsIn time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(int(resetsIn.Seconds()), 1)))
+	message := fmt.Sprintf("%s request quota of the API key exceeded", period)
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+func (app *Application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := "rate limit exceeded"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
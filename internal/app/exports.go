@@ -0,0 +1,282 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/storage"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+const (
+	// exportBatchSize is the number of books an export job reads from the database at once.
+	exportBatchSize = 500
+	// exportURLExpiry is how long the presigned download URLs of exports stay valid.
+	exportURLExpiry = 15 * time.Minute
+)
+
+// exportContentTypes are the content types of the export files by format.
+var exportContentTypes = map[string]string{
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+}
+
+// createExportHandler handles the "POST /v1/exports" endpoint. It queues a job writing all
+// the books of the tenant to a file in the storage and answers 202 Accepted right away, with
+// the export to poll at "GET /v1/exports/:id" until it is done.
+func (app *Application) createExportHandler(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Format          *string `json:"format"`
+		IncludeArchived bool    `json:"include_archived"`
+	}
+	err := app.readJSON(w, r, &in)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	export := &data.Export{Format: "json", IncludeArchived: in.IncludeArchived}
+	if in.Format != nil {
+		export.Format = *in.Format
+	}
+
+	v := validator.New()
+	if data.ValidateExport(v, export); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	tenant := app.contextGetTenant(r)
+	exports := app.exports(r)
+
+	err = exports.Insert(export)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the job is not dropped silently like other background tasks: the client would poll
+	// an export which never finishes.
+	job := *export
+	err = app.worker.Submit("export", func() {
+		app.runExport(tenant, &job)
+	})
+	if err != nil {
+		app.failExport(tenant, export, err)
+		app.overloadedResponse(w, r)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", routePath(routeExport, "id", strconv.FormatInt(export.ID, 10)))
+	err = app.writeJSON(w, r, http.StatusAccepted, wrapper{"export": export}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showExportHandler handles the "GET /v1/exports/:id" endpoint and returns the export with
+// its status and, once it is done, the URL its file is downloaded from: a presigned URL of
+// the storage if the backend issues them, otherwise "GET /v1/exports/:id/download".
+func (app *Application) showExportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	export, err := app.exports(r).Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if export.Status == data.ExportDone {
+		url, err := app.storage.PresignGet(r.Context(), exportKey(app.contextGetTenant(r), export), exportURLExpiry)
+		switch {
+		case err == nil:
+			export.DownloadURL = url
+		case errors.Is(err, storage.ErrNoPresign):
+			export.DownloadURL = app.routeURL(r, routeExportDownload, "id", strconv.FormatInt(export.ID, 10))
+		default:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"export": export}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// downloadExportHandler handles the "GET /v1/exports/:id/download" endpoint and serves the
+// file of a finished export. Exports which are not done yet are not found.
+func (app *Application) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	export, err := app.exports(r).Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if export.Status != data.ExportDone {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	obj, content, err := app.storage.Get(r.Context(), exportKey(app.contextGetTenant(r), export))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="books-%d.%s"`, export.ID, export.Format))
+	w.Header().Set("Last-Modified", obj.Modified.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, content)
+}
+
+// exports returns the export model scoped to the tenant of the request.
+func (app *Application) exports(r *http.Request) data.ExportModel {
+	return app.models.Exports.ForTenant(app.contextGetTenant(r))
+}
+
+// exportKey returns the storage key of the file of the export, scoped to the tenant.
+func exportKey(tenant string, export *data.Export) string {
+	return fmt.Sprintf("exports/%s/%d.%s", tenant, export.ID, export.Format)
+}
+
+// runExport runs the export job of the tenant: it writes the books to the storage and
+// records the outcome. Jobs run on the background workers of the instance which accepted
+// them, so an export interrupted by a crash stays running and must be requested again.
+func (app *Application) runExport(tenant string, export *data.Export) {
+	exports := app.models.Exports.ForTenant(tenant)
+
+	export.Status = data.ExportRunning
+	if err := exports.Update(export); err != nil {
+		app.logger.PrintError(err, map[string]string{"export_id": strconv.FormatInt(export.ID, 10)})
+	}
+
+	err := app.writeExport(tenant, export)
+	if err != nil {
+		app.failExport(tenant, export, err)
+		return
+	}
+
+	now := time.Now()
+	export.Status = data.ExportDone
+	export.Finished = &now
+	if err := exports.Update(export); err != nil {
+		app.logger.PrintError(err, map[string]string{"export_id": strconv.FormatInt(export.ID, 10)})
+	}
+}
+
+// failExport logs the error of the export and records it as failed.
+func (app *Application) failExport(tenant string, export *data.Export, err error) {
+	app.logger.PrintError(err, map[string]string{"export_id": strconv.FormatInt(export.ID, 10)})
+
+	msg := err.Error()
+	now := time.Now()
+	export.Status = data.ExportFailed
+	export.Error = &msg
+	export.Finished = &now
+	if err := app.models.Exports.ForTenant(tenant).Update(export); err != nil {
+		app.logger.PrintError(err, map[string]string{"export_id": strconv.FormatInt(export.ID, 10)})
+	}
+}
+
+// writeExport streams the books of the export to its file in the storage, counting them in
+// export.Books.
+func (app *Application) writeExport(tenant string, export *data.Export) error {
+	pr, pw := io.Pipe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(app.encodeExport(pw, tenant, export))
+	}()
+
+	err := app.storage.Put(context.Background(), exportKey(tenant, export), exportContentTypes[export.Format], pr)
+	// unblock the encoder if the storage stopped reading early.
+	pr.CloseWithError(err)
+	<-done
+
+	return err
+}
+
+// encodeExport writes the books of the tenant to w in the format of the export, in ID order,
+// leaving out archived books unless the export includes them.
+func (app *Application) encodeExport(w io.Writer, tenant string, export *data.Export) error {
+	books := app.models.Books.ForTenant(tenant)
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	array := export.Format == "json"
+
+	if array {
+		bw.WriteString("[")
+	}
+
+	var afterID int64
+	for {
+		batch, err := books.After(afterID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, book := range batch {
+			if book.Archived != nil && !export.IncludeArchived {
+				continue
+			}
+			if array && export.Books > 0 {
+				bw.WriteString(",")
+			}
+			// Encode ends every book with a newline, which ndjson requires.
+			if err := enc.Encode(book); err != nil {
+				return err
+			}
+			export.Books++
+		}
+
+		if len(batch) < exportBatchSize {
+			break
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+
+	if array {
+		bw.WriteString("]\n")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// faultPolicy is the faults injected into the requests of a path prefix: the latency added
+// to every request, and the fractions of the requests answered with a random 5xx status and
+// dropped without an answer.
+type faultPolicy struct {
+	latency   time.Duration
+	errorRate float64
+	dropRate  float64
+}
+
+// faultStatuses are the statuses of the injected errors.
+var faultStatuses = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// parseFaultPolicies parses faults of path prefixes in the form
+// "<prefix>=<latency>:<error rate>:<drop rate>,...", e.g. "/v1/books=200ms:0.1:0.05".
+func parseFaultPolicies(s string) (map[string]faultPolicy, error) {
+	policies := make(map[string]faultPolicy)
+	if strings.TrimSpace(s) == "" {
+		return policies, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		prefix, faults, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid fault policy %q", item)
+		}
+
+		values := strings.Split(faults, ":")
+		if len(values) != 3 {
+			return nil, fmt.Errorf("invalid fault policy %q", item)
+		}
+
+		var p faultPolicy
+		var err error
+		p.latency, err = time.ParseDuration(values[0])
+		if err != nil || p.latency < 0 {
+			return nil, fmt.Errorf("invalid latency in fault policy %q", item)
+		}
+		p.errorRate, err = strconv.ParseFloat(values[1], 64)
+		if err != nil || p.errorRate < 0 || p.errorRate > 1 {
+			return nil, fmt.Errorf("invalid error rate in fault policy %q", item)
+		}
+		p.dropRate, err = strconv.ParseFloat(values[2], 64)
+		if err != nil || p.dropRate < 0 || p.errorRate+p.dropRate > 1 {
+			return nil, fmt.Errorf("invalid drop rate in fault policy %q", item)
+		}
+
+		policies[prefix] = p
+	}
+
+	return policies, nil
+}
+
+// matchFaultPolicy returns the policy of the longest prefix of path.
+func matchFaultPolicy(policies map[string]faultPolicy, path string) (faultPolicy, bool) {
+	var match string
+	for prefix := range policies {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	p, ok := policies[match]
+	return p, ok
+}
+
+// injectFaults injects the faults configured for the path of the request, to test the
+// retries of clients: it delays the request, then answers it with a random 5xx status or
+// drops the connection without an answer in the configured fractions of the requests.
+// Fault injection is refused in production by the configuration checks.
+func (app *Application) injectFaults(next http.Handler) http.Handler {
+	policies, _ := parseFaultPolicies(app.config.faults.policies)
+	if len(policies) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := matchFaultPolicy(policies, r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if p.latency > 0 {
+			timer := time.NewTimer(p.latency)
+			select {
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		switch n := rand.Float64(); {
+		case n < p.dropRate:
+			app.logger.Slog().DebugContext(r.Context(), "injected fault", "fault", "drop", "request_path", r.URL.Path)
+			// the server closes the connection, or resets the HTTP/2 stream, without an answer.
+			panic(http.ErrAbortHandler)
+		case n < p.dropRate+p.errorRate:
+			status := faultStatuses[rand.IntN(len(faultStatuses))]
+			app.logger.Slog().DebugContext(r.Context(), "injected fault", "fault", "error", "status", status, "request_path", r.URL.Path)
+			app.errorResponse(w, r, status, "injected fault")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
+)
+
+func TestParseFaultPolicies(t *testing.T) {
+	policies, err := parseFaultPolicies("/v1/books=200ms:0.1:0.05, /v1=0s:0.5:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]faultPolicy{
+		"/v1/books": {latency: 200 * time.Millisecond, errorRate: 0.1, dropRate: 0.05},
+		"/v1":       {errorRate: 0.5},
+	}
+	if !reflect.DeepEqual(policies, want) {
+		t.Errorf("want %v, got %v", want, policies)
+	}
+
+	for _, s := range []string{"books=0s:0:0", "/v1=0s:0.1", "/v1=soon:0:0", "/v1=0s:1.5:0", "/v1=0s:0.6:0.6"} {
+		if _, err := parseFaultPolicies(s); err == nil {
+			t.Errorf("want %q rejected", s)
+		}
+	}
+}
+
+func TestInjectFaults(t *testing.T) {
+	app := newTestApp()
+	app.logger = jsonlog.NewLogger(io.Discard, jsonlog.LevelOff)
+	app.config.faults.policies = "/v1=0s:1:0,/v1/books=50ms:0:0,/v1/books/drop=0s:0:1"
+
+	ts := newTestServer(app.injectFaults(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	defer ts.Close()
+
+	if code, _, _ := ts.get(t, "/v1/healthcheck"); code < http.StatusInternalServerError {
+		t.Errorf("want an injected 5xx, got %d", code)
+	}
+
+	start := time.Now()
+	if code, _, _ := ts.get(t, "/v1/books"); code != http.StatusNoContent || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("want the request of the longest prefix delayed, got %d after %s", code, time.Since(start))
+	}
+
+	if code, _, _ := ts.get(t, "/metrics"); code != http.StatusNoContent {
+		t.Errorf("want paths without a policy left alone, got %d", code)
+	}
+
+	rs, err := ts.Client().Get(ts.URL + "/v1/books/drop")
+	if err == nil {
+		rs.Body.Close()
+		t.Errorf("want the connection dropped, got %d", rs.StatusCode)
+	}
+}
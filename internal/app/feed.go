@@ -0,0 +1,236 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// feedMaxAge is how long feed readers and proxies may cache a feed before checking it again.
+const feedMaxAge = 5 * time.Minute
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	NS      string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title      string   `xml:"title"`
+	Link       string   `xml:"link"`
+	GUID       string   `xml:"guid"`
+	PubDate    string   `xml:"pubDate"`
+	Categories []string `xml:"category"`
+	Desc       string   `xml:"description,omitempty"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	NSAtom  string   `xml:"xmlns:atom,attr"`
+	Channel struct {
+		Title         string    `xml:"title"`
+		Link          string    `xml:"link"`
+		Self          atomLink  `xml:"atom:link"`
+		Description   string    `xml:"description"`
+		LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+		TTL           int       `xml:"ttl"`
+		Items         []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// booksFeedHandler handles the "GET /v1/books/feed" endpoint, an Atom or RSS 2.0 feed of
+// the books most recently added to the catalog, optionally limited to genres. Feeds may
+// be cached for feedMaxAge and carry an ETag and the time of the newest book as
+// Last-Modified, so feed readers polling an unchanged feed get 304 Not Modified.
+func (app *Application) booksFeedHandler(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Format string   `query:"format" validate:"oneof=atom rss"`
+		Genres []string `query:"genres"`
+		Limit  int      `query:"limit" validate:"gt=0,max=100"`
+	}{Format: "atom", Genres: []string{}, Limit: 50}
+
+	v := validator.New()
+	app.readQuery(r.URL.Query(), &input, v)
+
+	format := input.Format
+	filter := data.BookFilter{Genres: input.Genres}
+	filters := data.Filters{
+		Page:         1,
+		PageSize:     input.Limit,
+		Sort:         "-created_at",
+		SortSafelist: []string{"-created_at"},
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var err error
+	filter.Genres, err = app.resolveGenres(r, filter.Genres)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	books, _, err := app.books(r).GetAll(filter, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var lastModified time.Time
+	for _, book := range books {
+		if book.Created.After(lastModified) {
+			lastModified = book.Created
+		}
+	}
+
+	title := app.config.oai.repositoryName + ": new books"
+	if len(filter.Genres) > 0 {
+		title += " in " + strings.Join(filter.Genres, ", ")
+	}
+	self := app.baseURL(r) + r.URL.RequestURI()
+
+	var feed interface{}
+	contentType := "application/atom+xml; charset=utf-8"
+	if format == "rss" {
+		feed = app.rssFeed(r, books, title, self, lastModified)
+		contentType = "application/rss+xml; charset=utf-8"
+	} else {
+		feed = app.atomFeed(r, books, title, self, lastModified)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(feed); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(feedMaxAge.Seconds())))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	if app.config.tenancy.enabled {
+		// the feed is only shared between requests for the same tenant.
+		w.Header().Add("Vary", app.config.tenancy.header)
+		w.Header().Add("Vary", app.config.quotas.header)
+	}
+
+	// ServeContent answers conditional requests from the ETag and modification time.
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(buf.Bytes()))
+}
+
+func (app *Application) atomFeed(r *http.Request, books []*data.Book, title, self string, updated time.Time) *atomFeed {
+	feed := &atomFeed{
+		NS:     "http://www.w3.org/2005/Atom",
+		ID:     self,
+		Title:  title,
+		Author: app.config.oai.repositoryName,
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: self},
+		},
+	}
+	if updated.IsZero() {
+		// an empty feed was never updated, date it to the start of the epoch.
+		updated = time.Unix(0, 0)
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	for _, book := range books {
+		link := app.routeURL(r, routeBook, "id", strconv.FormatInt(book.ID, 10))
+		entry := atomEntry{
+			ID:        link,
+			Title:     book.Title,
+			Published: book.Created.UTC().Format(time.RFC3339),
+			Updated:   book.Updated.UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Rel: "alternate", Type: "application/json", Href: link}},
+			Summary:   bookSummary(book),
+		}
+		for _, genre := range book.Genres {
+			entry.Categories = append(entry.Categories, atomCategory{Term: genre})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
+
+func (app *Application) rssFeed(r *http.Request, books []*data.Book, title, self string, updated time.Time) *rssFeed {
+	feed := &rssFeed{Version: "2.0", NSAtom: "http://www.w3.org/2005/Atom"}
+	feed.Channel.Title = title
+	feed.Channel.Link = app.routeURL(r, routeBooks)
+	feed.Channel.Self = atomLink{Rel: "self", Type: "application/rss+xml", Href: self}
+	feed.Channel.Description = "Books recently added to the catalog."
+	feed.Channel.TTL = int(feedMaxAge.Minutes())
+	if !updated.IsZero() {
+		feed.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, book := range books {
+		link := app.routeURL(r, routeBook, "id", strconv.FormatInt(book.ID, 10))
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:      book.Title,
+			Link:       link,
+			GUID:       link,
+			PubDate:    book.Created.UTC().Format(time.RFC1123Z),
+			Categories: book.Genres,
+			Desc:       bookSummary(book),
+		})
+	}
+
+	return feed
+}
+
+// bookSummary describes the book in a line for feed readers: its description, or its
+// year and pages if it has none.
+func bookSummary(book *data.Book) string {
+	if description, ok := book.Metadata["description"].(string); ok && description != "" {
+		return description
+	}
+
+	var parts []string
+	if book.Year != nil {
+		parts = append(parts, fmt.Sprintf("Published in %d", *book.Year))
+	}
+	if book.Pages != nil {
+		parts = append(parts, fmt.Sprintf("%d pages", *book.Pages))
+	}
+	return strings.Join(parts, ", ")
+}
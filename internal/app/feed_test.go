@@ -0,0 +1,72 @@
+package app
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+func TestBooksFeed(t *testing.T) {
+	app := &Application{}
+	app.config.oai.repositoryName = "Example Library"
+
+	year := int32(1965)
+	books := []*data.Book{{
+		ID:      7,
+		Title:   "Dune",
+		Year:    &year,
+		Genres:  []string{"sci-fi"},
+		Created: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Updated: time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC),
+	}}
+	r := httptest.NewRequest("GET", "http://library.example.org/v1/books/feed?genres=sci-fi", nil)
+	updated := books[0].Created
+
+	tests := []struct {
+		name string
+		feed interface{}
+		want []string
+	}{
+		{"atom", app.atomFeed(r, books, "New books", "http://library.example.org/v1/books/feed", updated), []string{
+			`<feed xmlns="http://www.w3.org/2005/Atom"><id>http://library.example.org/v1/books/feed</id><title>New books</title><updated>2024-03-01T12:00:00Z</updated><author><name>Example Library</name></author>`,
+			`<entry><id>http://library.example.org/v1/books/7</id><title>Dune</title><published>2024-03-01T12:00:00Z</published><updated>2024-03-02T12:00:00Z</updated>`,
+			`<category term="sci-fi"></category><summary>Published in 1965</summary>`,
+		}},
+		{"rss", app.rssFeed(r, books, "New books", "http://library.example.org/v1/books/feed?format=rss", updated), []string{
+			`<lastBuildDate>Fri, 01 Mar 2024 12:00:00 +0000</lastBuildDate><ttl>5</ttl>`,
+			`<item><title>Dune</title><link>http://library.example.org/v1/books/7</link><guid>http://library.example.org/v1/books/7</guid><pubDate>Fri, 01 Mar 2024 12:00:00 +0000</pubDate><category>sci-fi</category>`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := xml.Marshal(tt.feed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(b), want) {
+					t.Errorf("want feed containing %s, got %s", want, b)
+				}
+			}
+		})
+	}
+}
+
+func TestBooksFeedValidation(t *testing.T) {
+	app := newTestApp()
+
+	for _, query := range []string{"format=json", "limit=0", "limit=500"} {
+		r := httptest.NewRequest("GET", "/v1/books/feed?"+query, nil)
+		w := httptest.NewRecorder()
+		app.booksFeedHandler(w, r)
+
+		if w.Code != 422 {
+			t.Errorf("%s: want 422, got %d: %s", query, w.Code, w.Body)
+		}
+	}
+}
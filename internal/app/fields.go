@@ -0,0 +1,89 @@
+package app
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// bookComputedFields are the fields of book responses derived from the book, only sent
+// when asked for with the fields parameter.
+var bookComputedFields = []string{"estimated_reading_time"}
+
+// bookFieldNames holds the fields book responses may be limited to: the JSON names of the
+// fields of data.Book and the computed fields.
+var bookFieldNames = func() []string {
+	var names []string
+	t := reflect.TypeOf(data.Book{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return append(names, bookComputedFields...)
+}()
+
+// readBookFields reads the "fields" parameter, the comma separated fields to send of the
+// books of a response, e.g. "id,title,estimated_reading_time". It returns nil if the
+// parameter is missing, and adds an error under "fields[i]" for unknown fields.
+func (app *Application) readBookFields(qs url.Values, v *validator.Validator) []string {
+	fields := app.readCSV(qs, "fields", nil)
+	for i, field := range fields {
+		v.Check(validator.In(field, bookFieldNames...), validator.Index("fields", i), "unknown field")
+	}
+	return fields
+}
+
+// bookFields returns the book as sent in responses with only the fields, including
+// computed fields. Fields the book has no value of are left out. Without fields the book
+// is sent as is, without the computed fields.
+func (app *Application) bookFields(book *data.Book, fields []string) (interface{}, error) {
+	if fields == nil {
+		return book, nil
+	}
+
+	js, err := json.Marshal(book)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(js, &values); err != nil {
+		return nil, err
+	}
+
+	sparse := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		switch field {
+		case "estimated_reading_time":
+			if minutes, ok := book.ReadingMinutes(app.config.reading.wordsPerPage, app.config.reading.wordsPerMinute); ok {
+				sparse[field] = minutes
+			}
+		default:
+			if value, ok := values[field]; ok {
+				sparse[field] = value
+			}
+		}
+	}
+	return sparse, nil
+}
+
+// booksFields returns the books as sent in responses with only the fields, see bookFields.
+func (app *Application) booksFields(books []*data.Book, fields []string) (interface{}, error) {
+	if fields == nil {
+		return books, nil
+	}
+
+	sparse := make([]interface{}, len(books))
+	for i, book := range books {
+		var err error
+		if sparse[i], err = app.bookFields(book, fields); err != nil {
+			return nil, err
+		}
+	}
+	return sparse, nil
+}
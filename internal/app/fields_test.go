@@ -0,0 +1,47 @@
+package app
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+func TestBookFields(t *testing.T) {
+	app := newTestApp()
+	app.config.reading.wordsPerPage = 250
+	app.config.reading.wordsPerMinute = 200
+
+	v := validator.New()
+	fields := app.readBookFields(url.Values{"fields": {"id,title,estimated_reading_time,year,colour"}}, v)
+	want := map[string]string{"fields[4]": "unknown field"}
+	if !reflect.DeepEqual(v.Errors, want) {
+		t.Errorf("want errors %v, got %v", want, v.Errors)
+	}
+
+	pages := data.Pages(412)
+	sparse, err := app.booksFields([]*data.Book{
+		{ID: 1, Title: "Dune", Pages: &pages},
+		{ID: 2, Title: "Unknown"},
+	}, fields[:4])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js, err := json.Marshal(sparse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON := `[{"estimated_reading_time":515,"id":1,"title":"Dune"},{"id":2,"title":"Unknown"}]`
+	if string(js) != wantJSON {
+		t.Errorf("want %s, got %s", wantJSON, js)
+	}
+
+	book := &data.Book{ID: 1}
+	if got, _ := app.bookFields(book, nil); got != book {
+		t.Errorf("want the book as is without fields, got %v", got)
+	}
+}
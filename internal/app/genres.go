@@ -0,0 +1,175 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// setGenreAliasHandler handles the "PUT /v1/genre-aliases/:alias" endpoint. It maps the
+// alias to the genre in the body, adding it or replacing the genre it mapped to, and returns
+// 201 Created or 200 OK respectively. Books written from then on are given the genre instead
+// of the alias, and listings filtered by the alias list the books of the genre; books
+// written before keep their genres.
+func (app *Application) setGenreAliasHandler(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Genre string `json:"genre"`
+	}
+	err := app.readJSON(w, r, &in)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	alias := &data.GenreAlias{
+		Alias: data.NormalizeGenreAlias(app.readParam(r, "alias")),
+		Genre: in.Genre,
+	}
+
+	v := validator.New()
+	if data.ValidateGenreAlias(v, alias); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	created, err := app.genreAliases(r).Set(alias)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrGenreAliasChain):
+			v.AddError("genre", "must not be an alias, nor may the alias be the genre of other aliases")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	status := http.StatusOK
+	headers := make(http.Header)
+	if created {
+		status = http.StatusCreated
+		headers.Set("Location", "/v1/genre-aliases/"+url.PathEscape(alias.Alias))
+	}
+	err = app.writeJSON(w, r, status, wrapper{"genre_alias": alias}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listGenreAliasesHandler handles the "GET /v1/genre-aliases" endpoint and returns the
+// aliases of the genres.
+func (app *Application) listGenreAliasesHandler(w http.ResponseWriter, r *http.Request) {
+	aliases, err := app.genreAliases(r).GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"genre_aliases": aliases}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteGenreAliasHandler handles the "DELETE /v1/genre-aliases/:alias" endpoint and removes
+// the alias.
+func (app *Application) deleteGenreAliasHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.genreAliases(r).Delete(app.readParam(r, "alias"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"message": "genre alias successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// renameGenreHandler handles the "POST /v1/admin/genres/rename" endpoint. It replaces the
+// genre "from" by the genre "to" in all the books, merging them in books which have both, in
+// one transaction, and returns the number of books changed. A "to" which is an alias is
+// replaced by its genre first. Renames are logged, as the record of who changed what.
+func (app *Application) renameGenreHandler(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	err := app.readJSON(w, r, &in)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(in.From != "", "from", "must be provided")
+	v.Check(in.To != "", "to", "must be provided")
+	v.Check(len(in.To) <= 100, "to", "must not be more than 100 bytes long")
+	v.Check(in.From != in.To, "to", "must differ from the genre renamed")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	to, err := app.resolveGenres(r, []string{in.To})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if in.To = to[0]; in.To == in.From {
+		v.AddError("to", "must not be an alias of the genre renamed")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	updated, err := app.books(r).RenameGenre(in.From, in.To)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logger.PrintInfo("renamed genre", map[string]string{
+		"tenant":     app.contextGetTenant(r),
+		"from":       in.From,
+		"to":         in.To,
+		"books":      strconv.FormatInt(updated, 10),
+		"request_id": app.contextGetRequestID(r),
+	})
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"from": in.From, "to": in.To, "updated": updated}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// genreAliases returns the genre alias model scoped to the tenant of the request.
+func (app *Application) genreAliases(r *http.Request) data.GenreAliasModel {
+	return app.models.GenreAliases.ForTenant(app.contextGetTenant(r))
+}
+
+// resolveGenres returns the genres of a book or a filter of the request with the aliases
+// replaced by their canonical genres, see data.GenreAliasModel.Resolve.
+func (app *Application) resolveGenres(r *http.Request, genres []string) ([]string, error) {
+	return app.genreAliases(r).Resolve(genres)
+}
+
+// resolveBookGenres replaces the genres of a book about to be stored which are aliases of
+// other genres, such as variant spellings, by those canonical genres, so that books are
+// only ever stored with canonical genres.
+func resolveBookGenres(aliases data.GenreAliasModel, book *data.Book) error {
+	genres, err := aliases.Resolve(book.Genres)
+	if err != nil {
+		return err
+	}
+	book.Genres = genres
+	return nil
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update rewrites the golden files with the responses got, run after intended changes of
+// the responses with:
+//
+//	go test ./internal/app -run Golden -update
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with the responses got")
+
+// checkGolden compares the JSON body with the golden file testdata/<name>.json, or writes
+// the file if -update is set. Bodies are indented before the comparison so that the files
+// are readable and do not depend on the ?pretty parameter.
+func checkGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+
+	var got bytes.Buffer
+	if err := json.Indent(&got, bytes.TrimSpace(body), "", "\t"); err != nil {
+		t.Fatalf("%s: response is not JSON: %v\n%s", name, err, body)
+	}
+	got.WriteByte('\n')
+
+	path := filepath.Join("testdata", name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s: %v (run with -update to create it)", name, err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("%s: response differs from %s (run with -update if the change is intended)\nwant:\n%s\ngot:\n%s", name, path, want, got.Bytes())
+	}
+}
+
+// TestGoldenResponses checks the responses of the requests answered without a database
+// against their golden files.
+func TestGoldenResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"liveness", http.MethodGet, "/v1/livez", "", http.StatusOK},
+		{"not_found", http.MethodGet, "/v1/authors", "", http.StatusNotFound},
+		{"method_not_allowed", http.MethodPut, "/v1/books", "", http.StatusMethodNotAllowed},
+		{"create_book_malformed", http.MethodPost, "/v1/books", `{"title": "Dune",`, http.StatusBadRequest},
+		{"create_book_wrong_types", http.MethodPost, "/v1/books", `{"title": 1, "year": "1965", "pages": -1, "genres": "sci-fi"}`, http.StatusBadRequest},
+		{"create_book_unknown_key", http.MethodPost, "/v1/books", `{"title": "Dune", "colour": "red"}`, http.StatusBadRequest},
+		{"create_book_invalid", http.MethodPost, "/v1/books", `{"title": "", "year": 1800, "pages": 0, "genres": ["sci-fi", "sci-fi"], "isbn": "123"}`, http.StatusUnprocessableEntity},
+		{"list_books_invalid", http.MethodGet, "/v1/books?page=0&page_size=1000&sort=isbn&fields=id,colour&created_since=yesterday", "", http.StatusUnprocessableEntity},
+		{"books_feed_invalid", http.MethodGet, "/v1/books/feed?format=xml&limit=0", "", http.StatusUnprocessableEntity},
+		{"curated_books_invalid", http.MethodGet, "/v1/books/new?limit=51&fields=colour", "", http.StatusUnprocessableEntity},
+	}
+
+	ts := newTestServer(newTestApp().routes())
+	defer ts.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			var body bytes.Buffer
+			if _, err := body.ReadFrom(rs.Body); err != nil {
+				t.Fatal(err)
+			}
+
+			if rs.StatusCode != tt.status {
+				t.Errorf("want %d, got %d", tt.status, rs.StatusCode)
+			}
+			checkGolden(t, tt.name, body.Bytes())
+		})
+	}
+}
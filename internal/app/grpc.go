@@ -0,0 +1,354 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/rpc"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+	booksv1 "github.com/nikitashershunov/LibraryAPI/proto/books/v1"
+)
+
+// grpcServer returns an HTTP/2 server serving the Books service of proto/books/v1 on the
+// gRPC port. It is served over TLS if a certificate is configured, and over cleartext
+// HTTP/2 (h2c) otherwise, which is meant for internal networks.
+func (app *Application) grpcServer() *http.Server {
+	s := rpc.NewServer(int(app.config.limits.body))
+	s.ErrorLog = func(method string, err error) {
+		app.logger.PrintError(err, map[string]string{"grpc_method": method})
+	}
+
+	s.Handle(booksv1.Service, "GetBook", app.grpcGetBook)
+	s.Handle(booksv1.Service, "ListBooks", app.grpcListBooks)
+	s.Handle(booksv1.Service, "CreateBook", app.grpcCreateBook)
+	s.Handle(booksv1.Service, "UpdateBook", app.grpcUpdateBook)
+	s.Handle(booksv1.Service, "DeleteBook", app.grpcDeleteBook)
+
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.grpc.port),
+		Handler:      s,
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		ErrorLog:     log.New(app.logger, "", 0),
+		Protocols:    new(http.Protocols),
+	}
+	if app.config.tls.cert != "" || app.config.tls.key != "" {
+		srv.TLSConfig = tlsConfig()
+		srv.Protocols.SetHTTP2(true)
+	} else {
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	return srv
+}
+
+// grpcGetBook handles the GetBook method.
+func (app *Application) grpcGetBook(ctx context.Context, md http.Header, dec func(rpc.Unmarshaler) error) (rpc.Marshaler, error) {
+	in := new(booksv1.GetBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	books, err := app.grpcBooks(md)
+	if err != nil {
+		return nil, err
+	}
+
+	book, err := books.Get(in.ID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return bookMessage(book), nil
+}
+
+// grpcListBooks handles the ListBooks method with the filters and defaults of "GET /v1/books".
+func (app *Application) grpcListBooks(ctx context.Context, md http.Header, dec func(rpc.Unmarshaler) error) (rpc.Marshaler, error) {
+	in := new(booksv1.ListBooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	books, err := app.grpcBooks(md)
+	if err != nil {
+		return nil, err
+	}
+
+	genres, err := app.models.GenreAliases.ForTenant(books.Tenant).Resolve(in.Genres)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	filter := data.BookFilter{
+		Title:           in.Title,
+		Genres:          genres,
+		IncludeArchived: in.IncludeArchived,
+	}
+	filters := data.Filters{
+		Page:         int(in.Page),
+		PageSize:     int(in.PageSize),
+		Sort:         in.Sort,
+		SortSafelist: bookSortSafelist,
+	}
+	if filters.Page == 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize == 0 {
+		filters.PageSize = 20
+	}
+	if filters.Sort == "" {
+		filters.Sort = "id"
+	}
+
+	v := validator.New()
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		return nil, grpcValidationError(v.Errors)
+	}
+
+	list, meta, err := books.GetAll(filter, filters)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	out := &booksv1.ListBooksResponse{
+		Books: make([]*booksv1.Book, 0, len(list)),
+		Metadata: &booksv1.Metadata{
+			CurrentPage:  int32(meta.CurrentPage),
+			PageSize:     int32(meta.PageSize),
+			FirstPage:    int32(meta.FirstPage),
+			LastPage:     int32(meta.LastPage),
+			TotalRecords: int64(meta.TotalRecords),
+		},
+	}
+	for _, book := range list {
+		out.Books = append(out.Books, bookMessage(book))
+	}
+
+	return out, nil
+}
+
+// grpcCreateBook handles the CreateBook method.
+func (app *Application) grpcCreateBook(ctx context.Context, md http.Header, dec func(rpc.Unmarshaler) error) (rpc.Marshaler, error) {
+	in := new(booksv1.CreateBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	books, err := app.grpcBooks(md)
+	if err != nil {
+		return nil, err
+	}
+
+	book := &data.Book{
+		Title:  in.Title,
+		Year:   in.Year,
+		Pages:  (*data.Pages)(in.Pages),
+		Genres: in.Genres,
+		ISBN:   data.NormalizeISBN(in.ISBN),
+	}
+
+	v := validator.New()
+	book.Metadata = readMetadataJSON(in.MetadataJSON, v)
+	if data.ValidateBook(v, book, app.bookRules()); !v.Valid() {
+		return nil, grpcValidationError(v.Errors)
+	}
+
+	err = resolveBookGenres(app.models.GenreAliases.ForTenant(books.Tenant), book)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	err = books.Insert(book)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return bookMessage(book), nil
+}
+
+// grpcUpdateBook handles the UpdateBook method, changing only the fields set in the request.
+func (app *Application) grpcUpdateBook(ctx context.Context, md http.Header, dec func(rpc.Unmarshaler) error) (rpc.Marshaler, error) {
+	in := new(booksv1.UpdateBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	books, err := app.grpcBooks(md)
+	if err != nil {
+		return nil, err
+	}
+
+	book, err := books.Get(in.ID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	if in.ExpectedVersion != nil && *in.ExpectedVersion != book.Version {
+		return nil, grpcError(data.ErrEditConflict)
+	}
+
+	v := validator.New()
+
+	if in.Title != nil {
+		book.Title = *in.Title
+	}
+	if in.Year != nil {
+		book.Year = in.Year
+	}
+	if in.Pages != nil {
+		book.Pages = (*data.Pages)(in.Pages)
+	}
+	if in.Genres != nil {
+		book.Genres = in.Genres.Values
+	}
+	if in.ISBN != nil {
+		book.ISBN = data.NormalizeISBN(*in.ISBN)
+	}
+	if in.MetadataJSON != nil {
+		book.Metadata = readMetadataJSON(*in.MetadataJSON, v)
+	}
+	if in.Archived != nil {
+		switch {
+		case !*in.Archived:
+			book.Archived = nil
+		case book.Archived == nil:
+			now := time.Now().UTC()
+			book.Archived = &now
+		}
+	}
+
+	if data.ValidateBook(v, book, app.bookRules()); !v.Valid() {
+		return nil, grpcValidationError(v.Errors)
+	}
+
+	err = resolveBookGenres(app.models.GenreAliases.ForTenant(books.Tenant), book)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	err = books.Update(book)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return bookMessage(book), nil
+}
+
+// grpcDeleteBook handles the DeleteBook method.
+func (app *Application) grpcDeleteBook(ctx context.Context, md http.Header, dec func(rpc.Unmarshaler) error) (rpc.Marshaler, error) {
+	in := new(booksv1.DeleteBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	books, err := app.grpcBooks(md)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.deleteBook(books, in.ID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &booksv1.DeleteBookResponse{}, nil
+}
+
+// grpcBooks returns the book model scoped to the tenant of the call, resolved from the
+// metadata the same way requireTenant resolves it from the request headers.
+func (app *Application) grpcBooks(md http.Header) (data.BookModel, error) {
+	tenant, err := app.requestTenant(md)
+	if err != nil {
+		return data.BookModel{}, grpcError(err)
+	}
+
+	v := validator.New()
+	if data.ValidateTenant(v, tenant); !v.Valid() {
+		return data.BookModel{}, grpcValidationError(v.Errors)
+	}
+
+	return app.models.Books.ForTenant(tenant), nil
+}
+
+// bookMessage converts a book record to its protobuf message.
+func bookMessage(book *data.Book) *booksv1.Book {
+	m := &booksv1.Book{
+		ID:        book.ID,
+		CreatedAt: book.Created.Format(time.RFC3339),
+		UpdatedAt: book.Updated.Format(time.RFC3339),
+		Title:     book.Title,
+		Year:      book.Year,
+		Pages:     (*int64)(book.Pages),
+		Genres:    book.Genres,
+		ISBN:      book.ISBN,
+		Version:   book.Version,
+	}
+	if len(book.Metadata) > 0 {
+		js, _ := json.Marshal(book.Metadata)
+		m.MetadataJSON = string(js)
+	}
+	if book.Archived != nil {
+		archived := book.Archived.Format(time.RFC3339)
+		m.ArchivedAt = &archived
+	}
+	return m
+}
+
+// readMetadataJSON decodes the metadata_json field of a request, adding a validation error
+// if it isn't a JSON object. An empty string is no metadata.
+func readMetadataJSON(s string, v *validator.Validator) data.Attributes {
+	if s == "" {
+		return nil
+	}
+
+	var metadata data.Attributes
+	if err := json.Unmarshal([]byte(s), &metadata); err != nil {
+		v.AddError("metadata_json", "must be a JSON object")
+		return nil
+	}
+	return metadata
+}
+
+// grpcError maps the errors of the data layer to gRPC status codes. Other errors are
+// returned as they are and reported to the client as Internal.
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		return rpc.Errorf(rpc.NotFound, "the requested resource could not be found")
+	case errors.Is(err, data.ErrEditConflict):
+		return rpc.Errorf(rpc.Aborted, "unable to update the record due to an edit conflict, please try again")
+	case errors.Is(err, data.ErrDuplicateISBN):
+		return rpc.Errorf(rpc.AlreadyExists, "a book with this ISBN already exists")
+	case errors.Is(err, data.ErrCircuitOpen):
+		return rpc.Errorf(rpc.Unavailable, "the database is temporarily unavailable, please retry later")
+	case errors.Is(err, errUnauthorizedTenant):
+		return rpc.Errorf(rpc.Unauthenticated, "invalid or missing API key")
+	default:
+		return err
+	}
+}
+
+// grpcValidationError returns an InvalidArgument error listing the failed checks as
+// "<field>: <message>" in the order of the field names.
+func grpcValidationError(errors map[string]string) error {
+	keys := make([]string, 0, len(errors))
+	for key := range errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]string, len(keys))
+	for i, key := range keys {
+		msgs[i] = key + ": " + errors[key]
+	}
+	return rpc.Errorf(rpc.InvalidArgument, "%s", strings.Join(msgs, "; "))
+}
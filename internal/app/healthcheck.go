@@ -0,0 +1,166 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/migrations"
+)
+
+// healthcheckHandler is a handler for checking if server is running succefully.
+// With "?deep=true" it also checks the dependencies of the application and
+// responds with 503 Service Unavailable if any of them is down.
+func (app *Application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	// Declare wrapper map containing the data for response.
+	systemInfo := map[string]string{
+		"environment": app.config.env,
+		"version":     build.Version,
+		"go_version":  build.GoVersion,
+	}
+	if build.Commit != "" {
+		systemInfo["commit"] = build.Commit
+	}
+	if build.Time != "" {
+		systemInfo["build_time"] = build.Time
+	}
+
+	env := wrapper{
+		"status":      "available",
+		"system_info": systemInfo,
+	}
+
+	status := http.StatusOK
+
+	if r.URL.Query().Get("deep") == "true" {
+		database := app.databaseHealth()
+		if database["status"] != "up" {
+			env["status"] = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+
+		env["dependencies"] = wrapper{"database": database}
+		env["uptime"] = time.Since(app.started).Round(time.Second).String()
+	}
+
+	err := app.writeJSON(w, r, status, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// databaseHealth pings the database and collects connection pool statistics and
+// the applied migration version. Failures are reported in the "error" key instead
+// of being returned, so one broken check does not hide the others.
+func (app *Application) databaseHealth() wrapper {
+	health := wrapper{"status": "up"}
+
+	if err := app.models.System.Ping(); err != nil {
+		app.logger.PrintError(err, map[string]string{"dependency": "database"})
+		health["status"] = "down"
+		health["error"] = "database is unreachable"
+		return health
+	}
+
+	stats := app.models.System.Stats()
+	health["open_connections"] = stats.OpenConnections
+	health["in_use"] = stats.InUse
+	health["idle"] = stats.Idle
+
+	migration, dirty, err := app.models.System.MigrationVersion()
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.logger.PrintError(err, map[string]string{"dependency": "database"})
+		}
+		health["status"] = "down"
+		health["error"] = "unable to read migration version"
+		return health
+	}
+
+	health["migration_version"] = migration
+	health["migration_dirty"] = dirty
+	if dirty {
+		health["status"] = "down"
+		health["error"] = "last migration failed"
+	}
+
+	return health
+}
+
+// livenessHandler handles the "GET /v1/livez" endpoint. It only reports that the process
+// is alive and able to serve HTTP, it does not check any dependencies.
+func (app *Application) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, r, http.StatusOK, wrapper{"status": "alive"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readinessHandler handles the "GET /v1/readyz" endpoint. It responds with 503 Service
+// Unavailable when the server is shutting down, the database is unreachable or the
+// database schema is behind the migrations the application was built with.
+func (app *Application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{
+		"shutdown":   "ok",
+		"database":   "ok",
+		"migrations": "ok",
+	}
+	ready := true
+
+	if app.shuttingDown.Load() {
+		checks["shutdown"] = "in progress"
+		ready = false
+	}
+
+	if err := app.models.System.Ping(); err != nil {
+		app.logger.PrintError(err, map[string]string{"check": "database"})
+		checks["database"] = "unreachable"
+		checks["migrations"] = "unknown"
+		ready = false
+	} else if message := app.migrationsCheck(); message != "" {
+		checks["migrations"] = message
+		ready = false
+	}
+
+	env := wrapper{"status": "ready", "checks": checks}
+	status := http.StatusOK
+	if !ready {
+		env["status"] = "not ready"
+		status = http.StatusServiceUnavailable
+	}
+
+	err := app.writeJSON(w, r, status, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// migrationsCheck compares the applied migration version with the latest embedded one.
+// It returns an empty string if the schema is up to date, otherwise a short description.
+func (app *Application) migrationsCheck() string {
+	latest, err := migrations.Latest()
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"check": "migrations"})
+		return "unknown"
+	}
+
+	applied, dirty, err := app.models.System.MigrationVersion()
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.logger.PrintError(err, map[string]string{"check": "migrations"})
+		}
+		return "not applied"
+	}
+
+	switch {
+	case dirty:
+		return "dirty"
+	case applied < latest:
+		return fmt.Sprintf("pending (applied %d, latest %d)", applied, latest)
+	default:
+		return ""
+	}
+}
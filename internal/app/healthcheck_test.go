@@ -0,0 +1,151 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testServer struct {
+	*httptest.Server
+}
+
+func TestHealthcheck(t *testing.T) {
+	app := newTestApp()
+	ts := newTestServer(app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/v1/healthcheck?pretty=true")
+
+	if code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+
+	resp := fmt.Sprintf(`{
+	"status": "available",
+	"system_info": {
+		"environment": "testing",
+		"go_version": %q,
+		"version": %q
+	}
+}
+`, build.GoVersion, build.Version)
+
+	if string(body) != resp {
+		t.Errorf("want body to equal %q,\n but got %q", resp, string(body))
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	app := newTestApp()
+	ts := newTestServer(app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/v1/livez")
+
+	if code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+
+	resp := `{"status":"alive"}
+`
+
+	if string(body) != resp {
+		t.Errorf("want body to equal %q,\n but got %q", resp, string(body))
+	}
+}
+
+func TestMetricsLocalhostOnly(t *testing.T) {
+	handler := newTestApp().routes()
+
+	for _, tt := range []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"127.0.0.1:40000", http.StatusOK},
+		{"[::1]:40000", http.StatusOK},
+		{"192.0.2.1:40000", http.StatusNotFound},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.RemoteAddr = tt.remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s: want %d, got %d", tt.remoteAddr, tt.want, rr.Code)
+		}
+	}
+}
+
+func TestDebugVarsPerApplication(t *testing.T) {
+	for _, maxOpen := range []int{3, 7} {
+		db, err := sql.Open("postgres", "postgres://localhost/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		db.SetMaxOpenConns(maxOpen)
+
+		app := newTestApp()
+		app.publishMetrics(db)
+
+		r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+		r.RemoteAddr = "127.0.0.1:40000"
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, r)
+
+		var vars struct {
+			Cmdline  []string    `json:"cmdline"`
+			Database sql.DBStats `json:"database"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &vars); err != nil {
+			t.Fatalf("%v in %s", err, rr.Body)
+		}
+		if vars.Database.MaxOpenConnections != maxOpen || len(vars.Cmdline) == 0 {
+			t.Errorf("want the process vars and the pool of the application with %d connections, got %s", maxOpen, rr.Body)
+		}
+	}
+}
+
+func newTestApp() *Application {
+	app := new(Application)
+	cfg := Config{env: "testing"}
+	cfg.limits.body = 1_000_000
+	cfg.limits.bulkBody = 10_000_000
+	app.config = cfg
+
+	return app
+}
+
+func newTestServer(h http.Handler) *testServer {
+	ts := httptest.NewServer(h)
+
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &testServer{ts}
+}
+
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, []byte) {
+	rs, err := ts.Client().Get(ts.URL + urlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := rs.Body.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, body
+}
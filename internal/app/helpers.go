@@ -0,0 +1,411 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// define an wrapper type.
+type wrapper map[string]interface{}
+
+// withWarnings adds the warnings of the validator to the envelope of a successful write
+// under "warnings", if there are any, so clients learn about the values looking wrong.
+func withWarnings(env wrapper, v *validator.Validator) wrapper {
+	if warnings := v.Warned(); warnings != nil {
+		env["warnings"] = warnings
+	}
+	return env
+}
+
+// withPageURLs sets the absolute URLs of the next and previous pages of a listing in its
+// metadata, keeping the query string of the request but for the page.
+func (app *Application) withPageURLs(r *http.Request, meta data.Metadata) data.Metadata {
+	pageURL := func(page int) string {
+		qs := r.URL.Query()
+		qs.Set("page", strconv.Itoa(page))
+		return app.baseURL(r) + r.URL.Path + "?" + qs.Encode()
+	}
+
+	if meta.HasNext {
+		meta.NextURL = pageURL(meta.CurrentPage + 1)
+	}
+	if meta.HasPrev {
+		// pages past the end go back to the last one.
+		meta.PrevURL = pageURL(min(meta.CurrentPage-1, meta.LastPage))
+	}
+	return meta
+}
+
+// readID reads "id" from request URL and returns it and nil.
+// If there is error it returns 0 and error.
+func (app *Application) readID(r *http.Request) (int64, error) {
+	parameters := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(parameters.ByName("id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid id parameter")
+	}
+	return id, nil
+}
+
+// readParam reads the named parameter from request URL.
+func (app *Application) readParam(r *http.Request, name string) string {
+	return httprouter.ParamsFromContext(r.Context()).ByName(name)
+}
+
+// baseURL returns the scheme and host the request was sent to, for absolute links in
+// responses.
+func (app *Application) baseURL(r *http.Request) string {
+	if r.TLS != nil {
+		return "https://" + r.Host
+	}
+	return "http://" + r.Host
+}
+
+// jsonBuffers pools the buffers responses are encoded into, sparing an allocation per
+// response.
+var jsonBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// maxPooledJSONBuffer is the capacity above which buffers are not put back in the pool, so
+// an occasional large response does not keep its memory around.
+const maxPooledJSONBuffer = 1 << 20
+
+// writeJSON marshals data structure to encoded JSON response, in the envelope the request
+// asks for (see envelope). The JSON is compact, unless the request asks for it indented
+// with "?pretty=true".
+// It returns error if there are any issues, else error is nil.
+func (app *Application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data wrapper, headers http.Header) error {
+	buf := jsonBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledJSONBuffer {
+			jsonBuffers.Put(buf)
+		}
+	}()
+
+	encoder := json.NewEncoder(buf)
+	if pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty")); pretty {
+		encoder.SetIndent("", "\t")
+	}
+	body, err := app.envelope(w, r, data)
+	if err != nil {
+		return err
+	}
+	// Encode ends the JSON with a newline.
+	if err := encoder.Encode(body); err != nil {
+		return err
+	}
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+	return nil
+}
+
+// readJSON decodes request Body into corresponding Go type. It triages for any potential errors
+// and returns corresponding appropriate errors. A body larger than the limit of the route
+// results in an *http.MaxBytesError. Invalid values of the fields of a struct destination
+// are all reported at once in a jsonFieldErrors, rather than only the first one.
+func (app *Application) readJSON(w http.ResponseWriter, r *http.Request, destination interface{}) error {
+	maxBytes := app.contextGetBodyLimit(r)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	// The body is read as is first, so that it can be decoded again field by field.
+	var body json.RawMessage
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		return jsonError(err)
+	}
+	err = decoder.Decode(&struct{}{})
+
+	if err != io.EOF {
+		return errors.New("not single JSON")
+	}
+
+	err = app.decodeJSON(body, destination)
+	if err != nil {
+		if fieldErrors := app.jsonFieldErrors(body, destination); len(fieldErrors) > 0 {
+			return fieldErrors
+		}
+		return jsonError(err)
+	}
+	return nil
+}
+
+// readString is helper method on *application that returns string value from the URL query
+// string, or the provided default value if no key is found.
+func (app *Application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	return s
+}
+
+// readCSV is helper method on *application that reads string value from the URL query
+// string and splits it into slice on the comma character.
+func (app *Application) readCSV(qs url.Values, key string, defaultValue []string) []string {
+	csv := qs.Get(key)
+
+	if csv == "" {
+		return defaultValue
+	}
+
+	return strings.Split(csv, ",")
+}
+
+// readInt is helper method on *application that reads string value from the URL query
+// string and converts it to integer. If no key is found it returns the provided default value.
+func (app *Application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddError(key, "must be an integer value")
+		return defaultValue
+	}
+
+	return i
+}
+
+// readFloat is helper method on *application that reads string value from the URL query
+// string and converts it to a finite floating point number. If no key is found it returns
+// the provided default value.
+func (app *Application) readFloat(qs url.Values, key string, defaultValue float64, v *validator.Validator) float64 {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		v.AddError(key, "must be a number")
+		return defaultValue
+	}
+
+	return f
+}
+
+// readDate is helper method on *application that reads RFC 3339 timestamp from the URL query
+// string. If no key is found it returns the provided default value.
+func (app *Application) readDate(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 date-time value")
+		return defaultValue
+	}
+
+	return t
+}
+
+// readMonth is helper method on *application that reads a month in the form "2006-01" from
+// the URL query string, as the first day of the month. If no key is found it returns the
+// provided default value.
+func (app *Application) readMonth(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	t, err := time.Parse(data.MonthFormat, s)
+	if err != nil {
+		v.AddError(key, "must be a month in the form YYYY-MM")
+		return defaultValue
+	}
+
+	return t
+}
+
+// readDay is helper method on *application that reads a day in the form "2006-01-02" from
+// the URL query string, as its midnight UTC. If no key is found it returns the provided
+// default value.
+func (app *Application) readDay(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		v.AddError(key, "must be a day in the form YYYY-MM-DD")
+		return defaultValue
+	}
+
+	return t
+}
+
+// readPrefixed is helper method on *application that collects all URL query string values
+// whose keys start with prefix, e.g. "metadata.edition=2", into a map keyed by the rest of the key.
+func (app *Application) readPrefixed(qs url.Values, prefix string, v *validator.Validator) map[string]string {
+	values := make(map[string]string)
+
+	for key := range qs {
+		name, found := strings.CutPrefix(key, prefix)
+		if !found {
+			continue
+		}
+		if name == "" {
+			v.AddError(key, "must include a key name")
+			continue
+		}
+		values[name] = qs.Get(key)
+	}
+
+	return values
+}
+
+// readBool is helper method on *application that reads boolean value from the URL query
+// string. If no key is found it returns the provided default value.
+func (app *Application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
+// readQuery binds the URL query string values to the fields of the struct dst points to
+// with a query tag naming their key, leaving the values the fields already hold as the
+// defaults of missing keys:
+//
+//	Title  string            `query:"title"`
+//	Genres []string          `query:"genres"`        // comma separated
+//	Since  time.Time         `query:"created_since"` // RFC 3339
+//	Extra  map[string]string `query:"metadata."`     // keys starting with the tag
+//
+// Fields may be strings, integers, float64, time.Time, string slices and maps of
+// strings, and the fields of embedded structs are bound too. Invalid values add an error
+// to v under their key, with the messages of the other read helpers. readQuery panics on
+// fields of other types, which are programming errors.
+//
+// The bound values are then checked against the validate tags of the fields, see
+// validator.Struct, with errors keyed by the query string keys. Comma separated values
+// are restricted to a set with the dive rule, which reports every invalid value under its
+// index, e.g. "genres[1]":
+//
+//	Formats []string `query:"formats" validate:"dive,oneof=atom rss"`
+func (app *Application) readQuery(qs url.Values, dst interface{}, v *validator.Validator) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("readQuery of non-struct pointer type %T", dst))
+	}
+	app.readQueryStruct(qs, rv.Elem(), v)
+	v.StructByTag(dst, "query")
+}
+
+func (app *Application) readQueryStruct(qs url.Values, rv reflect.Value, v *validator.Validator) {
+	for i := 0; i < rv.NumField(); i++ {
+		sf := rv.Type().Field(i)
+		field := rv.Field(i)
+
+		key := sf.Tag.Get("query")
+		if key == "" {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				app.readQueryStruct(qs, field, v)
+			}
+			continue
+		}
+
+		switch field.Interface().(type) {
+		case string:
+			field.SetString(app.readString(qs, key, field.String()))
+		case bool:
+			field.SetBool(app.readBool(qs, key, field.Bool(), v))
+		case time.Time:
+			field.Set(reflect.ValueOf(app.readDate(qs, key, field.Interface().(time.Time), v)))
+		case []string:
+			field.Set(reflect.ValueOf(app.readCSV(qs, key, field.Interface().([]string))))
+		case map[string]string:
+			field.Set(reflect.ValueOf(app.readPrefixed(qs, key, v)))
+		case float64:
+			field.SetFloat(app.readFloat(qs, key, field.Float(), v))
+		default:
+			if !field.CanInt() {
+				panic(fmt.Sprintf("readQuery of unsupported field %s of type %s", sf.Name, sf.Type))
+			}
+			s := qs.Get(key)
+			if s == "" {
+				continue
+			}
+			n, err := strconv.ParseInt(s, 10, sf.Type.Bits())
+			if err != nil {
+				v.AddError(key, "must be an integer value")
+				continue
+			}
+			field.SetInt(n)
+		}
+	}
+}
+
+// background submits fn to the worker pool, so it runs without holding up the response and
+// the server waits for it on shutdown. If the pool can't take more work the task is dropped
+// and logged under name.
+func (app *Application) background(name string, fn func()) {
+	err := app.worker.Submit(name, fn)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"task": name})
+	}
+}
+
+// sendEmail renders the email template with data and sends it to recipient in the
+// background, retrying failed attempts a couple of times before logging the error.
+func (app *Application) sendEmail(recipient, templateFile string, data interface{}) {
+	app.background("send email", func() {
+		var err error
+		for i := 1; i <= 3; i++ {
+			err = app.mailer.Send(recipient, templateFile, data)
+			if err == nil {
+				return
+			}
+			if i < 3 {
+				time.Sleep(time.Duration(i) * 500 * time.Millisecond)
+			}
+		}
+
+		app.logger.PrintError(err, map[string]string{
+			"recipient": recipient,
+			"template":  templateFile,
+		})
+	})
+}
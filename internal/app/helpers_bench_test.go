@@ -0,0 +1,44 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+func BenchmarkWriteJSON(b *testing.B) {
+	books := make([]*data.Book, 100)
+	for i := range books {
+		year, pages := int32(1965), data.Pages(412)
+		books[i] = &data.Book{
+			ID:       int64(i + 1),
+			Created:  time.Now(),
+			Title:    fmt.Sprintf("Dune %d", i),
+			Year:     &year,
+			Pages:    &pages,
+			Genres:   []string{"sci-fi", "novel"},
+			Metadata: data.Attributes{"edition": "first", "publisher": "Chilton Books"},
+			Version:  1,
+		}
+	}
+	env := wrapper{"books": books, "metadata": data.Metadata{CurrentPage: 1, PageSize: 100}}
+
+	app := newTestApp()
+	for name, target := range map[string]string{"compact": "/v1/books", "pretty": "/v1/books?pretty=true"} {
+		b.Run(name, func(b *testing.B) {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				if err := app.writeJSON(w, r, http.StatusOK, env, nil); err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(w.Body.Len()))
+			}
+		})
+	}
+}
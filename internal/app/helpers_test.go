@@ -0,0 +1,149 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+func TestReadQuery(t *testing.T) {
+	var input struct {
+		data.BookFilter
+		data.Filters
+		Limit int8 `query:"limit"`
+	}
+	input.Filters = data.Filters{Page: 1, PageSize: 20, Sort: "id"}
+
+	qs, err := url.ParseQuery("q=dune&genres=sci-fi,novel&created_since=2024-01-02T03:04:05Z" +
+		"&metadata.edition=first&include_archived=true&page_size=50&limit=1000&updated_since=yesterday")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := validator.New()
+	newTestApp().readQuery(qs, &input, v)
+
+	want := data.BookFilter{
+		Query:           "dune",
+		Genres:          []string{"sci-fi", "novel"},
+		CreatedSince:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Metadata:        map[string]string{"edition": "first"},
+		IncludeArchived: true,
+	}
+	if !reflect.DeepEqual(input.BookFilter, want) {
+		t.Errorf("want filter %+v, got %+v", want, input.BookFilter)
+	}
+	if want := (data.Filters{Page: 1, PageSize: 50, Sort: "id"}); !reflect.DeepEqual(input.Filters, want) {
+		t.Errorf("want filters %+v, got %+v", want, input.Filters)
+	}
+
+	wantErrors := map[string]string{
+		"limit":         "must be an integer value",
+		"updated_since": "must be an RFC 3339 date-time value",
+	}
+	if !reflect.DeepEqual(v.Errors, wantErrors) {
+		t.Errorf("want errors %v, got %v", wantErrors, v.Errors)
+	}
+}
+
+func TestReadFloat(t *testing.T) {
+	qs := url.Values{"rating": {"4.5"}, "weight": {"heavy"}, "ratio": {"NaN"}}
+
+	v := validator.New()
+	app := newTestApp()
+	if got := app.readFloat(qs, "rating", 0, v); got != 4.5 {
+		t.Errorf("want 4.5, got %v", got)
+	}
+	if got := app.readFloat(qs, "missing", 1.5, v); got != 1.5 {
+		t.Errorf("want the default 1.5, got %v", got)
+	}
+	app.readFloat(qs, "weight", 0, v)
+	app.readFloat(qs, "ratio", 0, v)
+
+	want := map[string]string{"weight": "must be a number", "ratio": "must be a number"}
+	if !reflect.DeepEqual(v.Errors, want) {
+		t.Errorf("want errors %v, got %v", want, v.Errors)
+	}
+}
+
+func TestReadQueryEnum(t *testing.T) {
+	var input struct {
+		Formats []string `query:"formats" validate:"max=2,dive,oneof=atom rss"`
+	}
+	qs := url.Values{"formats": {"atom,xml,json"}}
+
+	v := validator.New()
+	newTestApp().readQuery(qs, &input, v)
+
+	want := map[string]string{
+		"formats":    "must not contain more than 2 items",
+		"formats[1]": "must be atom or rss",
+		"formats[2]": "must be atom or rss",
+	}
+	if !reflect.DeepEqual(v.Errors, want) {
+		t.Errorf("want errors %v, got %v", want, v.Errors)
+	}
+}
+
+func TestWithPageURLs(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://library.example.org/v1/books?genres=sci-fi&page=2&page_size=10", nil)
+	meta := data.Metadata{CurrentPage: 2, LastPage: 3, HasNext: true, HasPrev: true}
+
+	meta = newTestApp().withPageURLs(r, meta)
+	if want := "http://library.example.org/v1/books?genres=sci-fi&page=3&page_size=10"; meta.NextURL != want {
+		t.Errorf("want next URL %q, got %q", want, meta.NextURL)
+	}
+	if want := "http://library.example.org/v1/books?genres=sci-fi&page=1&page_size=10"; meta.PrevURL != want {
+		t.Errorf("want previous URL %q, got %q", want, meta.PrevURL)
+	}
+
+	meta = newTestApp().withPageURLs(r, data.Metadata{CurrentPage: 1, LastPage: 1})
+	if meta.NextURL != "" || meta.PrevURL != "" {
+		t.Errorf("want no URLs of a single page, got %q and %q", meta.NextURL, meta.PrevURL)
+	}
+}
+
+// FuzzReadQuery checks that reading the book filters never panics on arbitrary query
+// strings and that values read without errors are within their validation rules. Run with:
+//
+//	go test ./internal/app -run=^$ -fuzz=FuzzReadQuery
+func FuzzReadQuery(f *testing.F) {
+	for _, qs := range []string{
+		"q=dune&genres=sci-fi,novel&created_since=2024-01-02T03:04:05Z&metadata.edition=first",
+		"include_archived=true&page=2&page_size=50&sort=-year",
+		"page=0&page_size=1000&sort=isbn&updated_since=yesterday",
+		"genres=&metadata.=&page=9223372036854775808",
+		"%zz&;=&&",
+	} {
+		f.Add(qs)
+	}
+
+	app := newTestApp()
+	f.Fuzz(func(t *testing.T, rawQuery string) {
+		qs, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return
+		}
+
+		var input struct {
+			data.BookFilter
+			data.Filters
+		}
+		input.Filters = data.Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: bookSortSafelist}
+
+		v := validator.New()
+		app.readQuery(qs, &input, v)
+		if data.ValidateFilters(v, input.Filters); !v.Valid() {
+			return
+		}
+		if input.Page < 1 || input.PageSize < 1 || !validator.In(input.Sort, bookSortSafelist...) {
+			t.Fatalf("%q: accepted invalid filters %+v", rawQuery, input.Filters)
+		}
+	})
+}
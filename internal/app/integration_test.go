@@ -0,0 +1,1146 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/fixtures"
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
+	"github.com/nikitashershunov/LibraryAPI/internal/pgtest"
+	"github.com/nikitashershunov/LibraryAPI/internal/rpc"
+	"github.com/nikitashershunov/LibraryAPI/internal/storage"
+	booksv1 "github.com/nikitashershunov/LibraryAPI/proto/books/v1"
+)
+
+// The tests below run the books endpoints end to end against PostgreSQL, see pgtest for
+// how the database is provided. They are skipped without one.
+
+func TestMain(m *testing.M) {
+	pgtest.Main(m)
+}
+
+// integrationServer serves the application backed by the test database to the requests of
+// a tenant seeded with the built-in fixture set, made with an API key of the tenant.
+type integrationServer struct {
+	*testServer
+	app      *Application
+	tenant   string
+	apiKey   string
+	fixtures *fixtures.Loaded
+}
+
+func newIntegrationServer(t *testing.T, configure ...func(cfg *Config)) *integrationServer {
+	db := pgtest.Open(t)
+	tenant := pgtest.Tenant(t, db)
+	loaded := pgtest.Seed(t, db, tenant)
+
+	files, err := storage.NewFS(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := defaultConfig(t)
+	cfg.tenancy.enabled = true
+	cfg.books.optionalDetails = true
+	cfg.oai.repositoryName = "Test Library"
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+
+	app, err := New(cfg, WithDB(db), WithStorage(files), WithLogger(jsonlog.NewLogger(io.Discard, jsonlog.LevelOff)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.Close() })
+
+	// the tenant of a request is the one of its API key, the key has no quotas.
+	apiKey := newAPIKey()
+	if err := app.models.APIKeys.ForTenant(tenant).Insert(&data.APIKey{Name: "integration test"}, apiKey); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(app.Handler())
+	t.Cleanup(ts.Close)
+
+	return &integrationServer{testServer: ts, app: app, tenant: tenant, apiKey: apiKey, fixtures: loaded}
+}
+
+// authorize sets the tenant and API key headers of the requests of the tenant on req.
+func (ts *integrationServer) authorize(req *http.Request) {
+	req.Header.Set("X-Tenant-ID", ts.tenant)
+	req.Header.Set("X-API-Key", ts.apiKey)
+}
+
+// do sends a request of the tenant and decodes the JSON response into dst, unless dst is
+// nil. It returns the status code and headers of the response.
+func (ts *integrationServer) do(t *testing.T, method, urlPath, body string, dst interface{}) (int, http.Header) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.authorize(req)
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	if dst != nil {
+		if err := json.NewDecoder(rs.Body).Decode(dst); err != nil {
+			t.Fatalf("%s %s: %v", method, urlPath, err)
+		}
+	}
+
+	return rs.StatusCode, rs.Header
+}
+
+func TestIntegrationGetBook(t *testing.T) {
+	ts := newIntegrationServer(t)
+	dune := ts.fixtures.Book("dune")
+
+	var resp struct {
+		Book data.Book `json:"book"`
+	}
+	code, _ := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/books/%d", dune.ID), "", &resp)
+	if code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if resp.Book.Title != dune.Title || resp.Book.ISBN != dune.ISBN || *resp.Book.Pages != *dune.Pages {
+		t.Errorf("want book %+v, got %+v", dune, resp.Book)
+	}
+	if want := fmt.Sprintf("%s/v1/books/%d/cover", ts.URL, dune.ID); resp.Book.Links["cover"] != want {
+		t.Errorf("want the cover linked at %s, got %v", want, resp.Book.Links)
+	}
+
+	if code, _ := ts.do(t, http.MethodGet, "/v1/books/999999999", "", nil); code != http.StatusNotFound {
+		t.Errorf("want %d for a missing book, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestIntegrationListBooks(t *testing.T) {
+	ts := newIntegrationServer(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Dune", "Neuromancer", "The Hobbit", "Untitled manuscript"}},
+		{"?genres=sci-fi&sort=-year", []string{"Neuromancer", "Dune"}},
+		{"?title=hobbit", []string{"The Hobbit"}},
+		{"?metadata.edition=first", []string{"Dune"}},
+		{"?sort=title&page=2&page_size=3", []string{"Untitled manuscript"}},
+	}
+
+	for _, tt := range tests {
+		var resp struct {
+			Books    []data.Book   `json:"books"`
+			Metadata data.Metadata `json:"metadata"`
+		}
+		code, _ := ts.do(t, http.MethodGet, "/v1/books"+tt.query, "", &resp)
+		if code != http.StatusOK {
+			t.Fatalf("%s: want %d, got %d", tt.query, http.StatusOK, code)
+		}
+
+		var titles []string
+		for _, book := range resp.Books {
+			titles = append(titles, book.Title)
+		}
+		if strings.Join(titles, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: want books %q, got %q", tt.query, tt.want, titles)
+		}
+	}
+
+	if code, _ := ts.do(t, http.MethodGet, "/v1/books?sort=isbn", "", nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("want %d for an unknown sort, got %d", http.StatusUnprocessableEntity, code)
+	}
+}
+
+func TestIntegrationCreateBook(t *testing.T) {
+	ts := newIntegrationServer(t)
+
+	var resp struct {
+		Book data.Book `json:"book"`
+	}
+	code, headers := ts.do(t, http.MethodPost, "/v1/books",
+		`{"title": "Foundation", "year": 1951, "pages": "255 pages", "genres": ["sci-fi"], "isbn": "978-0-553-29335-7"}`, &resp)
+	if code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+	if want := fmt.Sprintf("/v1/books/%d", resp.Book.ID); headers.Get("Location") != want {
+		t.Errorf("want Location %q, got %q", want, headers.Get("Location"))
+	}
+	if resp.Book.ISBN != "9780553293357" || resp.Book.Version != 1 {
+		t.Errorf("want the normalized ISBN and version 1, got %+v", resp.Book)
+	}
+
+	dune := ts.fixtures.Book("dune")
+	for _, body := range []string{
+		fmt.Sprintf(`{"title": "Dune (reissue)", "year": 2005, "pages": 412, "genres": ["sci-fi"], "isbn": %q}`, dune.ISBN),
+		fmt.Sprintf(`{"title": "  %s ", "year": %d, "pages": 400, "genres": ["sci-fi"]}`, strings.ToUpper(dune.Title), *dune.Year),
+	} {
+		code, headers := ts.do(t, http.MethodPost, "/v1/books", body, nil)
+		want := fmt.Sprintf(`</v1/books/%d>; rel="duplicate"`, dune.ID)
+		if code != http.StatusConflict || headers.Get("Link") != want {
+			t.Errorf("%s: want %d linking %s, got %d %q", body, http.StatusConflict, want, code, headers.Get("Link"))
+		}
+	}
+
+	var errResp struct {
+		Error map[string]string `json:"error"`
+	}
+	code, _ = ts.do(t, http.MethodPost, "/v1/books?force=true",
+		fmt.Sprintf(`{"title": "Dune", "year": 1965, "pages": 412, "genres": ["sci-fi"], "isbn": %q}`, dune.ISBN), &errResp)
+	if code != http.StatusUnprocessableEntity || errResp.Error["isbn"] != "a book with this ISBN already exists" {
+		t.Errorf("want a duplicate ISBN error, got %d %v", code, errResp.Error)
+	}
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/books?force=true",
+		fmt.Sprintf(`{"title": "Dune", "year": %d, "pages": 412, "genres": ["sci-fi"]}`, *dune.Year), nil)
+	if code != http.StatusCreated {
+		t.Errorf("want a forced duplicate created, got %d", code)
+	}
+}
+
+func TestIntegrationUpdateBook(t *testing.T) {
+	ts := newIntegrationServer(t)
+	path := fmt.Sprintf("/v1/books/%d", ts.fixtures.Book("neuromancer").ID)
+
+	var resp struct {
+		Book data.Book `json:"book"`
+	}
+	code, _ := ts.do(t, http.MethodPatch, path, `{"pages": 280}`, &resp)
+	if code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if *resp.Book.Pages != 280 || resp.Book.Title != ts.fixtures.Book("neuromancer").Title || resp.Book.Version != 2 {
+		t.Errorf("want the pages changed in version 2, got %+v", resp.Book)
+	}
+
+	req, err := http.NewRequest(http.MethodPatch, ts.URL+path, strings.NewReader(`{"title": "Count Zero"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.authorize(req)
+	req.Header.Set("X-Expected-Version", "1")
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	if rs.StatusCode != http.StatusConflict {
+		t.Errorf("want %d for a stale version, got %d", http.StatusConflict, rs.StatusCode)
+	}
+}
+
+func TestIntegrationUpsertBook(t *testing.T) {
+	ts := newIntegrationServer(t)
+
+	body := `{"title": "Hyperion", "year": 1989, "pages": 482, "genres": ["sci-fi"]}`
+	var created struct {
+		Book data.Book `json:"book"`
+	}
+	if code, _ := ts.do(t, http.MethodPut, "/v1/books/isbn/9780553283686", body, &created); code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+
+	body = `{"title": "Hyperion", "year": 1989, "pages": 500, "genres": ["sci-fi", "novel"]}`
+	var replaced struct {
+		Book data.Book `json:"book"`
+	}
+	if code, _ := ts.do(t, http.MethodPut, "/v1/books/isbn/9780553283686", body, &replaced); code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if replaced.Book.ID != created.Book.ID || *replaced.Book.Pages != 500 {
+		t.Errorf("want book %d replaced, got %+v", created.Book.ID, replaced.Book)
+	}
+}
+
+func TestIntegrationBookTitles(t *testing.T) {
+	ts := newIntegrationServer(t)
+
+	var created struct {
+		Book data.Book `json:"book"`
+	}
+	code, _ := ts.do(t, http.MethodPost, "/v1/books",
+		`{"title": "Война и мир", "language": "ru", "titles": {"en": "War and Peace"}, "year": 1969, "genres": ["novel"]}`, &created)
+	if code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+	if created.Book.Title != "Война и мир" || created.Book.Titles["en"] != "War and Peace" {
+		t.Errorf("want the original title and its translation, got %+v", created.Book)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/books/%d", ts.URL, created.Book.ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.authorize(req)
+	req.Header.Set("Accept-Language", "en-GB, ru;q=0.5")
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	var resp struct {
+		Book data.Book `json:"book"`
+	}
+	if err := json.NewDecoder(rs.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Book.Title != "War and Peace" || resp.Book.OriginalTitle != "Война и мир" {
+		t.Errorf("want the English title with the original, got %+v", resp.Book)
+	}
+	if rs.Header.Get("Content-Language") != "en" {
+		t.Errorf("want Content-Language en, got %q", rs.Header.Get("Content-Language"))
+	}
+
+	// searches match the original, the translations and the transliteration.
+	for _, title := range []string{"мир", "peace", "voina"} {
+		var list struct {
+			Books []data.Book `json:"books"`
+		}
+		ts.do(t, http.MethodGet, "/v1/books?title="+url.QueryEscape(title), "", &list)
+		if len(list.Books) != 1 || list.Books[0].ID != created.Book.ID {
+			t.Errorf("%s: want the book found, got %+v", title, list.Books)
+		}
+	}
+}
+
+func TestIntegrationDeleteBook(t *testing.T) {
+	ts := newIntegrationServer(t)
+	path := fmt.Sprintf("/v1/books/%d", ts.fixtures.Book("hobbit").ID)
+
+	if code, _ := ts.do(t, http.MethodDelete, path, "", nil); code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if code, _ := ts.do(t, http.MethodGet, path, "", nil); code != http.StatusNotFound {
+		t.Errorf("want %d for a deleted book, got %d", http.StatusNotFound, code)
+	}
+	if code, _ := ts.do(t, http.MethodDelete, path, "", nil); code != http.StatusNotFound {
+		t.Errorf("want %d deleting it again, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestIntegrationGRPCDeleteBookCover(t *testing.T) {
+	ts := newIntegrationServer(t)
+	id := ts.fixtures.Book("dune").ID
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 32)
+
+	if code, _ := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/books/%d/cover", id), png, nil); code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+
+	md := http.Header{"X-Tenant-Id": {ts.tenant}, "X-Api-Key": {ts.apiKey}}
+	dec := func(m rpc.Unmarshaler) error {
+		m.(*booksv1.DeleteBookRequest).ID = id
+		return nil
+	}
+	if _, err := ts.app.grpcDeleteBook(context.Background(), md, dec); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cover is removed in the background.
+	key := tenantCoverKey(ts.tenant, id)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		_, err := ts.app.storage.Stat(context.Background(), key)
+		if errors.Is(err, storage.ErrNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("want the cover of the deleted book removed, got %v", err)
+		}
+	}
+}
+
+func TestIntegrationBookCover(t *testing.T) {
+	ts := newIntegrationServer(t)
+	path := fmt.Sprintf("/v1/books/%d/cover", ts.fixtures.Book("dune").ID)
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 32)
+
+	if code, _ := ts.do(t, http.MethodPost, path, png, nil); code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+	if code, headers := ts.do(t, http.MethodGet, path, "", nil); code != http.StatusOK || headers.Get("Content-Type") != "image/png" {
+		t.Errorf("want the PNG cover, got %d %q", code, headers.Get("Content-Type"))
+	}
+	if code, _ := ts.do(t, http.MethodDelete, path, "", nil); code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+	if code, _ := ts.do(t, http.MethodGet, path, "", nil); code != http.StatusNotFound {
+		t.Errorf("want %d for a deleted cover, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestIntegrationCoverThumbnails(t *testing.T) {
+	ts := newIntegrationServer(t)
+	path := fmt.Sprintf("/v1/books/%d/cover", ts.fixtures.Book("dune").ID)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 800, 1200))); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := ts.do(t, http.MethodPost, path, buf.String(), nil); code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+	if code, _ := ts.do(t, http.MethodGet, path+"?size=huge", "", nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("want %d for an unknown size, got %d", http.StatusUnprocessableEntity, code)
+	}
+
+	// the thumbnail is generated in the background, the original is served meanwhile.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path+"?size=small", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts.authorize(req)
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := png.DecodeConfig(rs.Body)
+		rs.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg.Width == 106 && cfg.Height == 160 {
+			break
+		}
+		if cfg.Width != 800 || time.Now().After(deadline) {
+			t.Fatalf("want the small thumbnail, got %dx%d", cfg.Width, cfg.Height)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestIntegrationEnrichBook(t *testing.T) {
+	ts := newIntegrationServer(t)
+
+	var errResp struct {
+		Error map[string]string `json:"error"`
+	}
+	code, _ := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/books/%d/enrich", ts.fixtures.Book("hobbit").ID), "", &errResp)
+	if code != http.StatusUnprocessableEntity || errResp.Error["isbn"] != "must be set to enrich the book" {
+		t.Errorf("want a missing ISBN error, got %d %v", code, errResp.Error)
+	}
+}
+
+func TestIntegrationBooksFeed(t *testing.T) {
+	ts := newIntegrationServer(t)
+
+	code, headers := ts.do(t, http.MethodGet, "/v1/books/feed?genres=fantasy&format=rss", "", nil)
+	if code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if !strings.HasPrefix(headers.Get("Content-Type"), "application/rss+xml") {
+		t.Errorf("want an RSS feed, got %q", headers.Get("Content-Type"))
+	}
+}
+
+func TestIntegrationCuratedBooks(t *testing.T) {
+	ts := newIntegrationServer(t)
+
+	var resp struct {
+		Books []data.Book `json:"books"`
+	}
+	code, headers := ts.do(t, http.MethodGet, "/v1/books/recent?genres=sci-fi&limit=1", "", &resp)
+	if code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if len(resp.Books) != 1 || !slices.Contains(resp.Books[0].Genres, "sci-fi") {
+		t.Errorf("want 1 sci-fi book, got %+v", resp.Books)
+	}
+	if got := headers.Get("Cache-Control"); got != "public, max-age=300" {
+		t.Errorf("want the curated caching policy, got %q", got)
+	}
+
+	// the fixtures were all published long ago.
+	resp.Books = nil
+	if code, _ := ts.do(t, http.MethodGet, "/v1/books/new", "", &resp); code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if len(resp.Books) != 0 {
+		t.Errorf("want no new releases, got %+v", resp.Books)
+	}
+}
+
+func TestIntegrationGenreTrends(t *testing.T) {
+	ts := newIntegrationServer(t)
+
+	var resp struct {
+		Trends []data.GenreTrend `json:"trends"`
+		Window map[string]string `json:"window"`
+	}
+	if code, _ := ts.do(t, http.MethodGet, "/v1/stats/genres/trends?genres=sci-fi", "", &resp); code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+
+	// the fixtures are all added in the current month, the last of the window.
+	if len(resp.Trends) != 1 || resp.Trends[0].Genre != "sci-fi" || len(resp.Trends[0].Months) != 12 {
+		t.Fatalf("want 12 months of sci-fi, got %+v", resp.Trends)
+	}
+	last := resp.Trends[0].Months[11]
+	if last.Month != resp.Window["to"] || last.Additions != 2 || resp.Trends[0].Additions != 2 {
+		t.Errorf("want the 2 sci-fi fixtures added in %s, got %+v", resp.Window["to"], resp.Trends[0])
+	}
+}
+
+func TestIntegrationGenreAliases(t *testing.T) {
+	ts := newIntegrationServer(t, func(cfg *Config) {
+		cfg.adminToken = "admin"
+	})
+
+	admin := func(method, urlPath, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Tenant-ID", ts.tenant)
+		req.Header.Set("Authorization", "Bearer admin")
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		return rs.StatusCode
+	}
+
+	if code := admin(http.MethodPut, "/v1/genre-aliases/SF", `{"genre": "sci-fi"}`); code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+	if code := admin(http.MethodPut, "/v1/genre-aliases/sf", `{"genre": "sci-fi"}`); code != http.StatusOK {
+		t.Errorf("want %d setting an alias again, got %d", http.StatusOK, code)
+	}
+	// aliases map straight to genres: neither to an alias, nor from a genre aliases map to.
+	if code := admin(http.MethodPut, "/v1/genre-aliases/other", `{"genre": "sf"}`); code != http.StatusUnprocessableEntity {
+		t.Errorf("want %d mapping to an alias, got %d", http.StatusUnprocessableEntity, code)
+	}
+	if code := admin(http.MethodPut, "/v1/genre-aliases/sci-fi", `{"genre": "other"}`); code != http.StatusUnprocessableEntity {
+		t.Errorf("want %d mapping from a genre of an alias, got %d", http.StatusUnprocessableEntity, code)
+	}
+
+	var list struct {
+		Books []data.Book `json:"books"`
+	}
+	if code, _ := ts.do(t, http.MethodGet, "/v1/books?genres=sf", "", &list); code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if len(list.Books) != 2 {
+		t.Errorf("want the 2 sci-fi books, got %d", len(list.Books))
+	}
+
+	var created struct {
+		Book data.Book `json:"book"`
+	}
+	code, _ := ts.do(t, http.MethodPost, "/v1/books", `{"title": "Hyperion", "genres": ["Sf", "novel", "sci-fi"]}`, &created)
+	if code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+	if want := []string{"sci-fi", "novel"}; !reflect.DeepEqual(created.Book.Genres, want) {
+		t.Errorf("want genres %q, got %q", want, created.Book.Genres)
+	}
+
+	if code := admin(http.MethodDelete, "/v1/genre-aliases/sf", ""); code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+	if code := admin(http.MethodDelete, "/v1/genre-aliases/sf", ""); code != http.StatusNotFound {
+		t.Errorf("want %d deleting a deleted alias, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestIntegrationRenameGenre(t *testing.T) {
+	ts := newIntegrationServer(t, func(cfg *Config) {
+		cfg.adminToken = "admin"
+	})
+
+	rename := func(body string, dst interface{}) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/admin/genres/rename", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Tenant-ID", ts.tenant)
+		req.Header.Set("Authorization", "Bearer admin")
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+		if dst != nil {
+			if err := json.NewDecoder(rs.Body).Decode(dst); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rs.StatusCode
+	}
+
+	// cyberpunk merges into sci-fi, which neuromancer already has.
+	var resp struct {
+		Updated int64 `json:"updated"`
+	}
+	if code := rename(`{"from": "cyberpunk", "to": "sci-fi"}`, &resp); code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if resp.Updated != 1 {
+		t.Errorf("want 1 book updated, got %d", resp.Updated)
+	}
+	if code := rename(`{"from": "sci-fi", "to": "science fiction"}`, &resp); code != http.StatusOK || resp.Updated != 2 {
+		t.Errorf("want 2 books updated, got %d %d", code, resp.Updated)
+	}
+
+	var book struct {
+		Book data.Book `json:"book"`
+	}
+	ts.do(t, http.MethodGet, fmt.Sprintf("/v1/books/%d", ts.fixtures.Book("neuromancer").ID), "", &book)
+	if want := []string{"science fiction"}; !reflect.DeepEqual(book.Book.Genres, want) || book.Book.Version != 3 {
+		t.Errorf("want genres %q in version 3, got %q in version %d", want, book.Book.Genres, book.Book.Version)
+	}
+
+	if code := rename(`{"from": "fantasy", "to": "fantasy"}`, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("want %d renaming a genre to itself, got %d", http.StatusUnprocessableEntity, code)
+	}
+}
+
+func TestIntegrationCollections(t *testing.T) {
+	ts := newIntegrationServer(t, func(cfg *Config) {
+		cfg.adminToken = "admin"
+	})
+
+	admin := func(method, urlPath, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Tenant-ID", ts.tenant)
+		req.Header.Set("Authorization", "Bearer admin")
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		return rs.StatusCode
+	}
+
+	hobbit, dune := ts.fixtures.Book("hobbit"), ts.fixtures.Book("dune")
+	picks := fmt.Sprintf(`{"title": "Staff picks", "book_ids": [%d, %d, 999999999]}`, hobbit.ID, dune.ID)
+	if code := admin(http.MethodPut, "/v1/collections/staff-picks", picks); code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+	if code := admin(http.MethodPut, "/v1/collections/staff-picks", picks); code != http.StatusOK {
+		t.Errorf("want %d replacing a collection, got %d", http.StatusOK, code)
+	}
+	next := time.Now().AddDate(1, 0, 0).Format(time.RFC3339)
+	if code := admin(http.MethodPut, "/v1/collections/next-year", `{"title": "Next year", "book_ids": [], "starts_at": "`+next+`"}`); code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+
+	var resp struct {
+		Collection data.Collection `json:"collection"`
+	}
+	if code, _ := ts.do(t, http.MethodGet, "/v1/collections/staff-picks", "", &resp); code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	// the books are in the order of the collection, without the missing one.
+	var titles []string
+	for _, book := range resp.Collection.Books {
+		titles = append(titles, book.Title)
+	}
+	if want := []string{hobbit.Title, dune.Title}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("want books %q, got %q", want, titles)
+	}
+
+	// scheduled collections are only shown to admins.
+	if code, _ := ts.do(t, http.MethodGet, "/v1/collections/next-year", "", nil); code != http.StatusNotFound {
+		t.Errorf("want %d for a scheduled collection, got %d", http.StatusNotFound, code)
+	}
+	if code := admin(http.MethodGet, "/v1/collections/next-year", ""); code != http.StatusOK {
+		t.Errorf("want %d for a scheduled collection shown to admins, got %d", http.StatusOK, code)
+	}
+	var list struct {
+		Collections []data.Collection `json:"collections"`
+	}
+	if code, _ := ts.do(t, http.MethodGet, "/v1/collections", "", &list); code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if len(list.Collections) != 1 || list.Collections[0].Slug != "staff-picks" {
+		t.Errorf("want only the staff picks listed, got %+v", list.Collections)
+	}
+
+	if code, _ := ts.do(t, http.MethodDelete, "/v1/collections/staff-picks", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("want %d deleting without the admin token, got %d", http.StatusUnauthorized, code)
+	}
+	if code := admin(http.MethodDelete, "/v1/collections/staff-picks", ""); code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+}
+
+func TestIntegrationSearchStats(t *testing.T) {
+	ts := newIntegrationServer(t, func(cfg *Config) {
+		cfg.adminToken = "admin"
+		cfg.search.log = true
+	})
+
+	for _, q := range []string{"Dune", "dune", "unicorns", "dune"} {
+		if code, _ := ts.do(t, http.MethodGet, "/v1/books?q="+q, "", nil); code != http.StatusOK {
+			t.Fatalf("want %d, got %d", http.StatusOK, code)
+		}
+	}
+	// the second page of a search does not count again.
+	ts.do(t, http.MethodGet, "/v1/books?q=dune&page=2", "", nil)
+
+	stats := func(urlPath string) []data.SearchQuery {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, ts.URL+urlPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Tenant-ID", ts.tenant)
+		req.Header.Set("Authorization", "Bearer admin")
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+		var resp struct {
+			Searches []data.SearchQuery `json:"searches"`
+		}
+		if err := json.NewDecoder(rs.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Searches
+	}
+
+	// searches are recorded in the background.
+	var top []data.SearchQuery
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		top = stats("/v1/stats/searches/top")
+		if len(top) == 2 && top[0].Searches+top[1].Searches == 4 {
+			break
+		}
+	}
+	want := []data.SearchQuery{
+		{Query: "dune", Searches: 3, Hits: 1},
+		{Query: "unicorns", Searches: 1, ZeroResults: 1},
+	}
+	if !reflect.DeepEqual(top, want) {
+		t.Errorf("want top searches %+v, got %+v", want, top)
+	}
+
+	zero := stats("/v1/stats/searches/zero-results")
+	if len(zero) != 1 || zero[0].Query != "unicorns" {
+		t.Errorf("want unicorns as the only zero-result search, got %+v", zero)
+	}
+}
+
+func TestIntegrationQuotas(t *testing.T) {
+	ts := newIntegrationServer(t, func(cfg *Config) {
+		cfg.adminToken = "admin"
+		cfg.quotas.enabled = true
+		cfg.limiter.enabled = false
+	})
+
+	send := func(method, urlPath, body, apiKey string, dst interface{}) (int, http.Header) {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Tenant-ID", ts.tenant)
+		req.Header.Set("Authorization", "Bearer admin")
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+		if dst != nil {
+			if err := json.NewDecoder(rs.Body).Decode(dst); err != nil {
+				t.Fatalf("%s %s: %v", method, urlPath, err)
+			}
+		}
+		return rs.StatusCode, rs.Header
+	}
+
+	var created struct {
+		APIKey data.APIKey `json:"api_key"`
+		Key    string      `json:"key"`
+	}
+	code, _ := send(http.MethodPost, "/v1/api-keys", `{"name": "quota test", "daily_quota": 2}`, "", &created)
+	if code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+	t.Cleanup(func() { send(http.MethodDelete, fmt.Sprintf("/v1/api-keys/%d", created.APIKey.ID), "", "", nil) })
+	if created.APIKey.MonthlyQuota != 200000 {
+		t.Errorf("want the default monthly quota, got %d", created.APIKey.MonthlyQuota)
+	}
+
+	if code, _ := send(http.MethodGet, "/v1/books", "", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("want %d without an API key, got %d", http.StatusUnauthorized, code)
+	}
+	if code, _ := send(http.MethodGet, "/v1/books", "", "lib_unknown", nil); code != http.StatusUnauthorized {
+		t.Errorf("want %d with an unknown API key, got %d", http.StatusUnauthorized, code)
+	}
+
+	for i := 0; i < 2; i++ {
+		if code, _ := send(http.MethodGet, "/v1/books", "", created.Key, nil); code != http.StatusOK {
+			t.Fatalf("request %d: want %d, got %d", i+1, http.StatusOK, code)
+		}
+	}
+	code, headers := send(http.MethodGet, "/v1/books", "", created.Key, nil)
+	if code != http.StatusTooManyRequests || headers.Get("Retry-After") == "" {
+		t.Errorf("want %d with Retry-After over the daily quota, got %d and %q", http.StatusTooManyRequests, code, headers.Get("Retry-After"))
+	}
+
+	var resp struct {
+		Quota struct {
+			Day   quotaPeriod `json:"day"`
+			Month quotaPeriod `json:"month"`
+		} `json:"quota"`
+	}
+	if code, _ := send(http.MethodGet, "/v1/quota", "", created.Key, &resp); code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	day, month := resp.Quota.Day, resp.Quota.Month
+	if day.Limit != 2 || day.Used != 3 || day.Remaining == nil || *day.Remaining != 0 {
+		t.Errorf("want 3 of 2 daily requests used, got %+v", day)
+	}
+	if month.Used != 3 || month.Remaining == nil || *month.Remaining != 200000-3 {
+		t.Errorf("want 3 monthly requests used, got %+v", month)
+	}
+}
+
+func TestIntegrationWebhookRetries(t *testing.T) {
+	ts := newIntegrationServer(t)
+
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	webhooks := ts.app.models.Webhooks.ForTenant(ts.tenant)
+	webhook := &data.Webhook{URL: receiver.URL, Events: []string{"book.created"}, Secret: "secret"}
+	if err := webhooks.Insert(webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	due := time.Now().Add(-time.Second)
+	delivery := &data.WebhookDelivery{WebhookID: webhook.ID, Event: "book.created", EventKey: "book.created:1:1", Payload: []byte(`{"book_id":1}`), NextAttempt: &due}
+	if _, err := webhooks.InsertDelivery(delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	claim := func() []data.PendingDelivery {
+		t.Helper()
+		pending, err := webhooks.ClaimDeliveries(10, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pending
+	}
+	stored := func() *data.WebhookDelivery {
+		t.Helper()
+		deliveries, _, err := webhooks.GetDeliveries(webhook.ID, data.Filters{Page: 1, PageSize: 20, Sort: "-id", SortSafelist: []string{"-id"}})
+		if err != nil || len(deliveries) != 1 {
+			t.Fatalf("want 1 delivery, got %d and %v", len(deliveries), err)
+		}
+		return deliveries[0]
+	}
+
+	pending := claim()
+	if len(pending) != 1 || pending[0].Delivery.ID != delivery.ID || pending[0].Webhook.URL != receiver.URL {
+		t.Fatalf("want the due delivery claimed, got %+v", pending)
+	}
+	if again := claim(); len(again) != 0 {
+		t.Fatalf("want the claimed delivery leased, got %d claimed again", len(again))
+	}
+
+	ts.app.deliverWebhook(pending[0].Webhook, pending[0].Delivery)
+	if d := stored(); d.Attempts != 1 || d.Delivered != nil || d.NextAttempt == nil || !d.NextAttempt.After(time.Now()) {
+		t.Fatalf("want a retry scheduled after the failed attempt, got %+v", d)
+	}
+
+	// Make the retry due rather than waiting for the backoff.
+	_, err := ts.app.models.Webhooks.DB.Exec("UPDATE webhook_deliveries SET next_attempt_at = NOW() WHERE id = $1", delivery.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending = claim()
+	if len(pending) != 1 {
+		t.Fatalf("want the retry claimed, got %d deliveries", len(pending))
+	}
+
+	ts.app.deliverWebhook(pending[0].Webhook, pending[0].Delivery)
+	if d := stored(); d.Attempts != 2 || d.Delivered == nil || d.NextAttempt != nil {
+		t.Errorf("want the delivery done after the retry, got %+v", d)
+	}
+	if again := claim(); len(again) != 0 {
+		t.Errorf("want nothing left to claim, got %d deliveries", len(again))
+	}
+}
+
+func TestIntegrationAPIKeyTenant(t *testing.T) {
+	ts := newIntegrationServer(t, func(cfg *Config) {
+		cfg.adminToken = "admin"
+		cfg.quotas.enabled = true
+		cfg.limiter.enabled = false
+	})
+	other := ts.tenant + "-other"
+
+	send := func(method, urlPath, body, tenant, apiKey string, dst interface{}) int {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Tenant-ID", tenant)
+		req.Header.Set("Authorization", "Bearer admin")
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+		if dst != nil {
+			if err := json.NewDecoder(rs.Body).Decode(dst); err != nil {
+				t.Fatalf("%s %s: %v", method, urlPath, err)
+			}
+		}
+		return rs.StatusCode
+	}
+
+	var created struct {
+		APIKey data.APIKey `json:"api_key"`
+		Key    string      `json:"key"`
+	}
+	if code := send(http.MethodPost, "/v1/api-keys", `{"name": "tenant test"}`, ts.tenant, "", &created); code != http.StatusCreated {
+		t.Fatalf("want %d, got %d", http.StatusCreated, code)
+	}
+	t.Cleanup(func() {
+		send(http.MethodDelete, fmt.Sprintf("/v1/api-keys/%d", created.APIKey.ID), "", ts.tenant, "", nil)
+	})
+
+	if code := send(http.MethodGet, "/v1/books", "", ts.tenant, created.Key, nil); code != http.StatusOK {
+		t.Errorf("want %d for the tenant of the key, got %d", http.StatusOK, code)
+	}
+	if code := send(http.MethodGet, "/v1/books", "", other, created.Key, nil); code != http.StatusUnauthorized {
+		t.Errorf("want %d for another tenant, got %d", http.StatusUnauthorized, code)
+	}
+	if code := send(http.MethodGet, "/v1/quota", "", other, created.Key, nil); code != http.StatusUnauthorized {
+		t.Errorf("want %d asking for the quota of another tenant, got %d", http.StatusUnauthorized, code)
+	}
+
+	var keys struct {
+		APIKeys []data.APIKey `json:"api_keys"`
+	}
+	if code := send(http.MethodGet, "/v1/api-keys", "", other, "", &keys); code != http.StatusOK || len(keys.APIKeys) != 0 {
+		t.Errorf("want no API keys listed for another tenant, got %d %+v", code, keys.APIKeys)
+	}
+	if code := send(http.MethodDelete, fmt.Sprintf("/v1/api-keys/%d", created.APIKey.ID), "", other, "", nil); code != http.StatusNotFound {
+		t.Errorf("want %d revoking the key from another tenant, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestIntegrationUsageFlushedOnClose(t *testing.T) {
+	ts := newIntegrationServer(t, func(cfg *Config) {
+		cfg.usageFlushInterval = time.Hour
+	})
+	id := ts.fixtures.Book("dune").ID
+
+	// the counts are buffered until the next flush, an hour away.
+	ts.app.usage.View(ts.tenant, id)
+	ts.app.usage.View(ts.tenant, id)
+	ts.app.usage.Impressions(ts.tenant, id)
+
+	if err := ts.app.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := ts.app.models.Books.ForTenant(ts.tenant).Usage("views", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 1 || usage[0].ID != id || usage[0].Views != 2 || usage[0].Impressions != 1 {
+		t.Errorf("want the buffered views and impressions of book %d written on shutdown, got %+v", id, usage)
+	}
+}
+
+func TestIntegrationExport(t *testing.T) {
+	ts := newIntegrationServer(t)
+
+	var resp struct {
+		Export data.Export `json:"export"`
+	}
+	code, headers := ts.do(t, http.MethodPost, "/v1/exports", `{"format": "ndjson"}`, &resp)
+	if code != http.StatusAccepted {
+		t.Fatalf("want %d, got %d", http.StatusAccepted, code)
+	}
+	location := headers.Get("Location")
+
+	deadline := time.Now().Add(10 * time.Second)
+	for resp.Export.Status != data.ExportDone {
+		if resp.Export.Status == data.ExportFailed || time.Now().After(deadline) {
+			t.Fatalf("want the export done, got %+v", resp.Export)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if code, _ := ts.do(t, http.MethodGet, location, "", &resp); code != http.StatusOK {
+			t.Fatalf("want %d, got %d", http.StatusOK, code)
+		}
+	}
+	if resp.Export.Books != int64(len(ts.fixtures.Books)) {
+		t.Errorf("want %d books exported, got %d", len(ts.fixtures.Books), resp.Export.Books)
+	}
+
+	// the fs storage backend issues no presigned URLs, so the file is served by the API.
+	download := strings.TrimPrefix(resp.Export.DownloadURL, ts.URL)
+	req, err := http.NewRequest(http.MethodGet, ts.URL+download, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.authorize(req)
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.StatusCode != http.StatusOK || rs.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("want the ndjson file, got %d with %q", rs.StatusCode, rs.Header.Get("Content-Type"))
+	}
+	if lines := strings.Count(string(body), "\n"); lines != len(ts.fixtures.Books) {
+		t.Errorf("want a line per book, got %d lines", lines)
+	}
+}
+
+func TestIntegrationBookChecksums(t *testing.T) {
+	ts := newIntegrationServer(t)
+
+	var page struct {
+		Checksums []data.BookChecksum `json:"checksums"`
+		Next      string              `json:"next"`
+	}
+	if code, _ := ts.do(t, http.MethodGet, "/v1/books/checksums?page_size=2", "", &page); code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if len(page.Checksums) != 2 || page.Next == "" {
+		t.Fatalf("want a full page of 2 with a next URL, got %+v", page)
+	}
+
+	first := page.Checksums[0]
+	var resp struct {
+		Book data.Book `json:"book"`
+	}
+	if code, _ := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/books/%d", first.ID), "", &resp); code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, code)
+	}
+	if resp.Book.Checksum != first.Checksum {
+		t.Errorf("want the book sent with checksum %s, got %s", first.Checksum, resp.Book.Checksum)
+	}
+
+	seen := len(page.Checksums)
+	for page.Next != "" {
+		next := strings.TrimPrefix(page.Next, ts.URL)
+		page.Next = ""
+		if code, _ := ts.do(t, http.MethodGet, next, "", &page); code != http.StatusOK {
+			t.Fatalf("want %d, got %d", http.StatusOK, code)
+		}
+		seen += len(page.Checksums)
+	}
+	if seen != len(ts.fixtures.Books) {
+		t.Errorf("want the checksums of %d books, got %d", len(ts.fixtures.Books), seen)
+	}
+}
+
+func TestIntegrationTenantIsolation(t *testing.T) {
+	ts := newIntegrationServer(t)
+	other := newIntegrationServer(t)
+
+	path := fmt.Sprintf("/v1/books/%d", ts.fixtures.Book("dune").ID)
+	if code, _ := other.do(t, http.MethodGet, path, "", nil); code != http.StatusNotFound {
+		t.Errorf("want %d for the book of another tenant, got %d", http.StatusNotFound, code)
+	}
+
+	// the tenant header alone grants no access and must name the tenant of the API key.
+	for _, header := range []http.Header{
+		{"X-Tenant-Id": {ts.tenant}},
+		{"X-Tenant-Id": {ts.tenant}, "X-Api-Key": {other.apiKey}},
+		{"X-Tenant-Id": {ts.tenant}, "X-Api-Key": {"lib_unknown"}},
+	} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = header
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		if rs.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%v: want %d, got %d", header, http.StatusUnauthorized, rs.StatusCode)
+		}
+	}
+}
+
+func TestIntegrationCheck(t *testing.T) {
+	db := pgtest.Open(t)
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM books WHERE tenant_id = $1", checkTenant)
+		db.Exec("DELETE FROM outbox WHERE tenant_id = $1", checkTenant)
+	})
+
+	cfg := defaultConfig(t)
+	cfg.tenancy.enabled = true
+	cfg.quotas.enabled = true
+	cfg.storage.dir = t.TempDir()
+
+	app, err := New(cfg, WithDB(db), WithLogger(jsonlog.NewLogger(io.Discard, jsonlog.LevelOff)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	if err := app.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM books WHERE tenant_id = $1", checkTenant).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("want the temporary book deleted, got %d books", count)
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+// Patterns of the routes that resources link to. routes registers the routes with them and
+// links are built from them with routePath, so links cannot drift from the router.
+const (
+	routeBooks     = "/v1/books"
+	routeBook      = "/v1/books/:id"
+	routeBookCover = "/v1/books/:id/cover"
+
+	routeBookChecksums = "/v1/books/checksums"
+
+	routeExports        = "/v1/exports"
+	routeExport         = "/v1/exports/:id"
+	routeExportDownload = "/v1/exports/:id/download"
+)
+
+// routePath returns the path of the route pattern with its parameters set to the values of
+// params, given as name/value pairs, e.g. routePath(routeBook, "id", "12") is "/v1/books/12".
+func routePath(route string, params ...string) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		name, ok := strings.CutPrefix(segment, ":")
+		if !ok {
+			continue
+		}
+		for j := 0; j+1 < len(params); j += 2 {
+			if params[j] == name {
+				segments[i] = url.PathEscape(params[j+1])
+				break
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// routeURL returns the absolute URL of routePath for the request.
+func (app *Application) routeURL(r *http.Request, route string, params ...string) string {
+	return app.baseURL(r) + routePath(route, params...)
+}
+
+// bookLinks returns the links of a book to itself and its related resources by relation.
+// The cover link is sent whether or not the book has a cover, which would take a lookup in
+// the storage per book; a book without one answers it with 404 Not Found.
+func (app *Application) bookLinks(r *http.Request, book *data.Book) map[string]string {
+	id := strconv.FormatInt(book.ID, 10)
+	return map[string]string{
+		"self":       app.routeURL(r, routeBook, "id", id),
+		"cover":      app.routeURL(r, routeBookCover, "id", id),
+		"collection": app.routeURL(r, routeBooks),
+	}
+}
+
+// presentBooks prepares the books sent in a response: they get their checksums, their titles
+// are localized for the request, see localizeTitles, and they get their links.
+func (app *Application) presentBooks(w http.ResponseWriter, r *http.Request, books ...*data.Book) {
+	for _, book := range books {
+		book.Checksum = book.ContentHash()
+	}
+	localizeTitles(w, r, books...)
+	for _, book := range books {
+		book.Links = app.bookLinks(r, book)
+	}
+}
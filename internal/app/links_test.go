@@ -0,0 +1,46 @@
+package app
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+func TestRoutePath(t *testing.T) {
+	tests := []struct {
+		route  string
+		params []string
+		want   string
+	}{
+		{routeBooks, nil, "/v1/books"},
+		{routeBook, []string{"id", "12"}, "/v1/books/12"},
+		{routeBookCover, []string{"id", "12"}, "/v1/books/12/cover"},
+		{"/v1/books/isbn/:isbn", []string{"isbn", "978 0"}, "/v1/books/isbn/978%200"},
+	}
+	for _, tt := range tests {
+		if got := routePath(tt.route, tt.params...); got != tt.want {
+			t.Errorf("%s %v: want %q, got %q", tt.route, tt.params, tt.want, got)
+		}
+	}
+}
+
+func TestPresentBooks(t *testing.T) {
+	app := newTestApp()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/books", nil)
+
+	book := &data.Book{ID: 7, Title: "Dune"}
+	app.presentBooks(w, r, book)
+
+	want := map[string]string{
+		"self":       "http://example.com/v1/books/7",
+		"cover":      "http://example.com/v1/books/7/cover",
+		"collection": "http://example.com/v1/books",
+	}
+	for rel, href := range want {
+		if book.Links[rel] != href {
+			t.Errorf("want %s link %q, got %q", rel, href, book.Links[rel])
+		}
+	}
+}
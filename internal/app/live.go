@@ -0,0 +1,302 @@
+package app
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/events"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+	"github.com/nikitashershunov/LibraryAPI/internal/websocket"
+)
+
+const (
+	// liveBuffer is the number of events queued for a live connection. A connection falling
+	// further behind loses events and receives a resync message.
+	liveBuffer = 64
+	// liveWriteTimeout is the time a client has to accept a message before it is disconnected.
+	liveWriteTimeout = 10 * time.Second
+	// livePingInterval is the interval of the pings keeping idle connections open.
+	livePingInterval = 30 * time.Second
+	// liveMaxSubscriptions is the maximum number of subscriptions of a connection.
+	liveMaxSubscriptions = 10
+)
+
+// liveFilter selects the books a live subscription receives changes of.
+type liveFilter struct {
+	Title           string   `json:"title"`
+	Genres          []string `json:"genres"`
+	IncludeArchived bool     `json:"include_archived"`
+}
+
+// matches reports whether the book passes the filter: the title contains Title, ignoring
+// case, and the book has all the Genres.
+func (f liveFilter) matches(book *data.Book) bool {
+	if book.Archived != nil && !f.IncludeArchived {
+		return false
+	}
+	if f.Title != "" && !strings.Contains(strings.ToLower(book.Title), strings.ToLower(f.Title)) {
+		return false
+	}
+	for _, genre := range f.Genres {
+		if !validator.In(genre, book.Genres...) {
+			return false
+		}
+	}
+	return true
+}
+
+// liveMessage is a message sent by the client on a live connection.
+type liveMessage struct {
+	Action       string     `json:"action"`
+	Subscription string     `json:"subscription"`
+	Filter       liveFilter `json:"filter"`
+}
+
+// liveConns tracks the open live connections so they can be closed on shutdown, as
+// hijacked connections are not closed by the HTTP server. The zero value is ready to use.
+type liveConns struct {
+	mu     sync.Mutex
+	conns  map[*websocket.Conn]struct{}
+	closed bool
+}
+
+// add registers a connection. It returns false if max connections are open already or the
+// server is shutting down.
+func (lc *liveConns) add(c *websocket.Conn, max int) bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.closed || (max > 0 && len(lc.conns) >= max) {
+		return false
+	}
+	if lc.conns == nil {
+		lc.conns = make(map[*websocket.Conn]struct{})
+	}
+	lc.conns[c] = struct{}{}
+	return true
+}
+
+// remove unregisters a connection.
+func (lc *liveConns) remove(c *websocket.Conn) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	delete(lc.conns, c)
+}
+
+// len returns the number of open connections.
+func (lc *liveConns) len() int {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	return len(lc.conns)
+}
+
+// closeAll closes the open connections telling the clients the server is going away, and
+// rejects new ones.
+func (lc *liveConns) closeAll() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.closed = true
+	for c := range lc.conns {
+		c.Close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// liveHandler handles the "GET /v1/live" endpoint. It upgrades the request to a WebSocket
+// connection on which the client subscribes to filters with messages like
+//
+//	{"action": "subscribe", "subscription": "sf", "filter": {"genres": ["sci-fi"]}}
+//	{"action": "unsubscribe", "subscription": "sf"}
+//
+// and receives the created and updated books of its tenant matching a filter as they
+// change. Deleted books are sent to every subscription, as they can no longer be matched.
+// A client which doesn't keep up receives a resync message and should reload its data.
+func (app *Application) liveHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.liveAuthorized(r) {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if !app.live.add(conn, app.config.live.maxConns) {
+		conn.Close(websocket.CloseTryAgainLater, "too many connections")
+		return
+	}
+	defer app.live.remove(conn)
+	defer conn.Close(websocket.CloseNormal, "")
+
+	conn.MaxMessageSize = 4096
+
+	// subscribe before reading client messages, so no change is missed after a subscription
+	// is confirmed.
+	ch, cancel := app.events.Subscribe(liveBuffer)
+	defer cancel()
+
+	tenant := app.contextGetTenant(r)
+
+	var mu sync.Mutex
+	subs := make(map[string]liveFilter)
+
+	send := func(msg wrapper) error {
+		js, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(js, time.Now().Add(liveWriteTimeout))
+	}
+
+	// read the subscriptions of the client until it disconnects.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for {
+			b, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			var msg liveMessage
+			if err := json.Unmarshal(b, &msg); err != nil {
+				send(wrapper{"type": "error", "error": "body contains badly-formed JSON"})
+				continue
+			}
+
+			v := validator.New()
+			v.Check(validator.In(msg.Action, "subscribe", "unsubscribe"), "action", "must be subscribe or unsubscribe")
+			v.Check(msg.Subscription != "", "subscription", "must be provided")
+			v.Check(len(msg.Subscription) <= 64, "subscription", "must not be more than 64 bytes long")
+			v.Check(len(msg.Filter.Title) <= 500, "filter.title", "must not be more than 500 bytes long")
+			v.Check(len(msg.Filter.Genres) <= 5, "filter.genres", "must not contain more than 5 genres")
+
+			mu.Lock()
+			if msg.Action == "subscribe" {
+				_, exists := subs[msg.Subscription]
+				v.Check(exists || len(subs) < liveMaxSubscriptions, "subscription", "too many subscriptions")
+			}
+			if v.Valid() {
+				switch msg.Action {
+				case "subscribe":
+					subs[msg.Subscription] = msg.Filter
+				case "unsubscribe":
+					delete(subs, msg.Subscription)
+				}
+			}
+			mu.Unlock()
+
+			if !v.Valid() {
+				send(wrapper{"type": "error", "subscription": msg.Subscription, "error": v.Errors})
+				continue
+			}
+			send(wrapper{"type": msg.Action + "d", "subscription": msg.Subscription})
+		}
+	}()
+
+	ping := time.NewTicker(livePingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ping.C:
+			if err := conn.Ping(time.Now().Add(liveWriteTimeout)); err != nil {
+				return
+			}
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			mu.Lock()
+			filters := make(map[string]liveFilter, len(subs))
+			for id, f := range subs {
+				filters[id] = f
+			}
+			mu.Unlock()
+
+			if err := app.sendLiveEvent(r, send, tenant, e, filters); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sendLiveEvent sends the event to the subscriptions of the connection it concerns, opened
+// by the request r. Only errors writing to the connection are returned, which end it.
+func (app *Application) sendLiveEvent(r *http.Request, send func(wrapper) error, tenant string, e events.Event, filters map[string]liveFilter) error {
+	switch {
+	case e.Type == events.Resync:
+		return send(wrapper{"type": events.Resync})
+	case e.Tenant != tenant || len(filters) == 0:
+		return nil
+	case e.Type == events.BookDeleted:
+		for id := range filters {
+			err := send(wrapper{"type": e.Type, "subscription": id, "book_id": e.BookID, "version": e.Version})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	book, err := app.models.Books.ForTenant(tenant).Get(e.BookID)
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.logger.PrintError(err, map[string]string{"event": e.Type})
+		}
+		return nil
+	}
+	book.Links = app.bookLinks(r, book)
+
+	for id, f := range filters {
+		if !f.matches(book) {
+			continue
+		}
+		err := send(wrapper{"type": e.Type, "subscription": id, "book": book})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// liveAuthorized reports whether the request carries one of the configured live tokens,
+// as a bearer token or in the access_token query parameter for browsers, which cannot set
+// headers on WebSocket requests, or the admin token. Without configured tokens the
+// endpoint only admits admins, unless it is made public with -live-public.
+func (app *Application) liveAuthorized(r *http.Request) bool {
+	if app.isAdmin(r) {
+		return true
+	}
+	if app.config.live.tokens == "" {
+		return app.config.live.public
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return false
+	}
+
+	for _, t := range strings.Split(app.config.live.tokens, ",") {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(strings.TrimSpace(t))) == 1 {
+			return true
+		}
+	}
+	return false
+}
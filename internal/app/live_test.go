@@ -0,0 +1,74 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+func TestLiveFilterMatches(t *testing.T) {
+	archived := time.Now()
+	book := &data.Book{Title: "Dune Messiah", Genres: []string{"sci-fi", "novel"}}
+
+	tests := []struct {
+		name   string
+		filter liveFilter
+		book   *data.Book
+		want   bool
+	}{
+		{"empty filter", liveFilter{}, book, true},
+		{"title ignoring case", liveFilter{Title: "dune"}, book, true},
+		{"other title", liveFilter{Title: "foundation"}, book, false},
+		{"all genres", liveFilter{Genres: []string{"novel", "sci-fi"}}, book, true},
+		{"missing genre", liveFilter{Genres: []string{"sci-fi", "horror"}}, book, false},
+		{"archived", liveFilter{}, &data.Book{Title: "Dune", Archived: &archived}, false},
+		{"archived included", liveFilter{IncludeArchived: true}, &data.Book{Title: "Dune", Archived: &archived}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(tt.book); got != tt.want {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLiveAuthorized(t *testing.T) {
+	app := newTestApp()
+	app.config.adminToken = "admin"
+
+	tests := []struct {
+		name   string
+		tokens string
+		public bool
+		target string
+		header string
+		want   bool
+	}{
+		{"no tokens", "", false, "/v1/live", "", false},
+		{"no tokens, admin", "", false, "/v1/live", "Bearer admin", true},
+		{"no tokens, public", "", true, "/v1/live", "", true},
+		{"bearer token", "a, b", false, "/v1/live", "Bearer b", true},
+		{"query token", "a, b", false, "/v1/live?access_token=a", "", true},
+		{"wrong token", "a, b", false, "/v1/live?access_token=c", "", false},
+		{"missing token", "a, b", true, "/v1/live", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.config.live.tokens = tt.tokens
+			app.config.live.public = tt.public
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if got := app.liveAuthorized(r); got != tt.want {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
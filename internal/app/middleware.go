@@ -0,0 +1,465 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonschema"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+	"golang.org/x/time/rate"
+)
+
+func (app *Application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				w.Header().Set("Connection", "close")
+				app.serverErrorResponse(w, r, fmt.Errorf("%s", err))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimit applies the global rate limit to every request.
+func (app *Application) rateLimit(next http.Handler) http.Handler {
+	if !app.config.limiter.enabled {
+		return next
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !limiter.Allow() {
+			rateLimitRejections.Inc()
+			app.rateLimitExceededResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unshedPaths are served regardless of load, so that probes and scrapes don't fail
+// and get a busy instance restarted or lose its metrics.
+var unshedPaths = map[string]bool{
+	"/v1/livez":  true,
+	"/v1/readyz": true,
+	"/metrics":   true,
+	// live connections stay open indefinitely and are capped with -live-max-conns.
+	"/v1/live": true,
+}
+
+// limitConcurrency caps the number of requests served at once. Requests over the cap are
+// shed with 503 Service Unavailable right away instead of queueing for database connections.
+func (app *Application) limitConcurrency(next http.Handler) http.Handler {
+	if app.config.concurrency.max <= 0 {
+		return next
+	}
+
+	shed := app.shedLoad("global", make(chan struct{}, app.config.concurrency.max), next.ServeHTTP)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unshedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		shed(w, r)
+	})
+}
+
+// shedLoad serves requests while a slot of sem is free and sheds them otherwise.
+func (app *Application) shedLoad(group string, sem chan struct{}, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			next.ServeHTTP(w, r)
+		default:
+			loadShedRejections.Inc(group)
+			app.overloadedResponse(w, r)
+		}
+	}
+}
+
+// routeGroups returns a function wrapping the handlers of a route group, such as "search"
+// or "write", in the rate limit and the concurrency limit configured for the group. All the
+// routes of a group share one budget, which applies on top of the global limits. Routes of
+// groups without configured policies are only subject to the global limits.
+func (app *Application) routeGroups() func(group string, next http.HandlerFunc) http.HandlerFunc {
+	limiters := make(map[string]*rate.Limiter)
+	if app.config.limiter.enabled {
+		policies, _ := parseRatePolicies(app.config.limiter.policies)
+		for group, p := range policies {
+			limiters[group] = rate.NewLimiter(rate.Limit(p.rps), p.burst)
+		}
+	}
+
+	sems := make(map[string]chan struct{})
+	caps, _ := parseConcurrencyPolicies(app.config.concurrency.policies)
+	for group, n := range caps {
+		sems[group] = make(chan struct{}, n)
+	}
+
+	return func(group string, next http.HandlerFunc) http.HandlerFunc {
+		if sem, ok := sems[group]; ok {
+			next = app.shedLoad(group, sem, next)
+		}
+
+		limiter, ok := limiters[group]
+		if !ok {
+			return next
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.Allow() {
+				rateLimitRejections.Inc()
+				app.rateLimitExceededResponse(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+// requireTenant resolves the tenant of the request, see requestTenant, and stores it in the
+// request context. Requests without an API key of the tenant, or the admin token in
+// multi-tenant mode, are rejected with 401 Unauthorized.
+func (app *Application) requireTenant(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, err := app.requestTenant(r.Header)
+		if err != nil {
+			switch {
+			case errors.Is(err, errUnauthorizedTenant):
+				app.invalidAPIKeyResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		v := validator.New()
+		if data.ValidateTenant(v, tenant); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		next.ServeHTTP(w, app.contextSetTenant(r, tenant))
+	}
+}
+
+// errUnauthorizedTenant is returned by requestTenant for requests which are not allowed to
+// access the catalog of any tenant.
+var errUnauthorizedTenant = errors.New("invalid or missing API key")
+
+// requestTenant returns the tenant of a request with the headers h. In single-tenant mode
+// every request belongs to data.DefaultTenant. Otherwise the tenant is the one the API key
+// of the request was created for, and the tenant header, if any, must name that tenant;
+// only admins choose the tenant with the header alone.
+func (app *Application) requestTenant(h http.Header) (string, error) {
+	if !app.config.tenancy.enabled {
+		return data.DefaultTenant, nil
+	}
+
+	requested := h.Get(app.config.tenancy.header)
+
+	if plaintext := h.Get(app.config.quotas.header); plaintext != "" {
+		tenant, err := app.models.APIKeys.TenantOf(plaintext)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return "", errUnauthorizedTenant
+		case err != nil:
+			return "", err
+		case requested != "" && requested != tenant:
+			return "", errUnauthorizedTenant
+		}
+		return tenant, nil
+	}
+
+	if app.hasAdminToken(h) {
+		return requested, nil
+	}
+	return "", errUnauthorizedTenant
+}
+
+// staticParam serves the requests whose named parameter holds one of the static path
+// segments with their handler and the others with next. It lets routes such as
+// "/v1/books/feed" share a path with "/v1/books/:id", which httprouter does not allow.
+func (app *Application) staticParam(name string, static map[string]http.HandlerFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if handler, ok := static[app.readParam(r, name)]; ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// maxBodySize sets the body size limit of the wrapped route, overriding the default one.
+// Routes accepting large payloads such as imports or uploads get the larger bulk limit.
+func (app *Application) maxBodySize(limit int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, app.contextSetBodyLimit(r, limit))
+	}
+}
+
+// requireAdmin only lets through requests carrying the configured admin token as a bearer
+// token. Without a configured token the admin endpoints are disabled.
+func (app *Application) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.config.adminToken == "" {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		if !app.isAdmin(r) {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// isAdmin reports whether the request carries the configured admin token as a bearer token,
+// for public endpoints which show admins more.
+func (app *Application) isAdmin(r *http.Request) bool {
+	return app.hasAdminToken(r.Header)
+}
+
+// hasAdminToken reports whether the headers h carry the configured admin token as a bearer
+// token.
+func (app *Application) hasAdminToken(h http.Header) bool {
+	if app.config.adminToken == "" {
+		return false
+	}
+
+	token, ok := strings.CutPrefix(h.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(app.config.adminToken)) == 1
+}
+
+// metricsResponseWriter wraps http.ResponseWriter to record the status code of the response.
+type metricsResponseWriter struct {
+	http.ResponseWriter
+	statusCode    int
+	headerWritten bool
+	bytesWritten  int
+}
+
+func (mw *metricsResponseWriter) WriteHeader(statusCode int) {
+	mw.ResponseWriter.WriteHeader(statusCode)
+
+	if !mw.headerWritten {
+		mw.statusCode = statusCode
+		mw.headerWritten = true
+	}
+}
+
+func (mw *metricsResponseWriter) Write(b []byte) (int, error) {
+	mw.headerWritten = true
+	n, err := mw.ResponseWriter.Write(b)
+	mw.bytesWritten += n
+	return n, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (mw *metricsResponseWriter) Unwrap() http.ResponseWriter {
+	return mw.ResponseWriter
+}
+
+// Request metrics published with expvar. They are package level, as expvar names can
+// only be registered once per process.
+var (
+	totalRequestsReceived           = expvar.NewInt("total_requests_received")
+	totalResponsesSent              = expvar.NewInt("total_responses_sent")
+	totalResponsesSentByStatus      = expvar.NewMap("total_responses_sent_by_status")
+	totalProcessingTimeMicroseconds = expvar.NewInt("total_processing_time_μs")
+)
+
+// metrics counts requests, responses by status class and the total processing time.
+func (app *Application) metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		totalRequestsReceived.Add(1)
+
+		mw := &metricsResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(mw, r)
+
+		totalResponsesSent.Add(1)
+		totalResponsesSentByStatus.Add(strconv.Itoa(mw.statusCode/100)+"xx", 1)
+		totalProcessingTimeMicroseconds.Add(time.Since(start).Microseconds())
+	})
+}
+
+// decompressRequest decodes request bodies sent with Content-Encoding: gzip, so large
+// bodies such as cover uploads can be sent compressed. The body size limits of the routes
+// apply to the decompressed body, which keeps small compressed bodies from expanding without
+// bound. Other encodings are answered with 415 Unsupported Media Type and the accepted ones
+// in Accept-Encoding, as RFC 7694 describes.
+func (app *Application) decompressRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+		case "", "identity":
+			next.ServeHTTP(w, r)
+			return
+		case "gzip", "x-gzip":
+		default:
+			w.Header().Set("Accept-Encoding", "gzip")
+			app.unsupportedEncodingResponse(w, r, []string{"gzip", "identity"})
+			return
+		}
+
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("body must be gzip compressed: %w", err))
+			return
+		}
+		defer zr.Close()
+
+		r.Body = zr
+		r.ContentLength = -1
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validateRequestBody checks the JSON request bodies against the schemas of their
+// operations in the OpenAPI document before the handlers run, and answers 422 Unprocessable
+// Entity with the violations by path. Bodies which are not JSON objects are left to the
+// handlers to reject.
+//
+// The route, and so its body size limit, is only known after routing, so bodies are read
+// up to the bulk limit, the largest any route allows. Bodies over the default limit are
+// not validated but left to the route, which answers 413 Request Entity Too Large unless
+// it allows them.
+func (app *Application) validateRequestBody(next http.Handler) http.Handler {
+	if !app.config.validateRequests {
+		return next
+	}
+
+	schemas, refs, err := requestSchemas(openAPISpec)
+	if err != nil {
+		panic(err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		schema := matchRequestSchema(schemas, r.Method, r.URL.Path)
+		if schema == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, app.config.limits.bulkBody))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesError):
+				app.requestTooLargeResponse(w, r, maxBytesError.Limit)
+			default:
+				app.badRequestResponse(w, r, err)
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if int64(len(body)) > app.config.limits.body {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		value, err := jsonschema.Decode(body)
+		if _, ok := value.(map[string]interface{}); err == nil && ok {
+			v := validator.New()
+			if schema.Validate(v, value, refs); !v.Valid() {
+				app.failedValidationResponse(w, r, v.Errors)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireLocalhost rejects requests that do not come from the loopback interface, which
+// keeps the debugging endpoints private to the host running the API.
+func (app *Application) requireLocalhost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestID assigns every request an ID, taken from the X-Request-ID header when the client
+// or a proxy provides one, so log entries of a request can be correlated. The ID is echoed
+// in the X-Request-ID response header.
+func (app *Application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, app.contextSetRequestID(r, id))
+	})
+}
+
+// logRequest writes an access log entry for every request. Under load only a sample
+// of the successful requests can be logged; server errors are always logged.
+func (app *Application) logRequest(next http.Handler) http.Handler {
+	if !app.config.accessLog.enabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		mw := &metricsResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(mw, r)
+
+		if mw.statusCode < http.StatusInternalServerError && mathrand.Float64() >= app.config.accessLog.sample {
+			return
+		}
+
+		clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			clientIP = r.RemoteAddr
+		}
+
+		app.logger.Slog().InfoContext(r.Context(), "request",
+			"request_method", r.Method,
+			"request_path", r.URL.Path,
+			"status", mw.statusCode,
+			"bytes", mw.bytesWritten,
+			"duration", time.Since(start),
+			"client_ip", clientIP,
+		)
+	})
+}
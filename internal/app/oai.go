@@ -0,0 +1,450 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+// oaiPageSize is the number of records or headers in a page of a list response, the rest
+// being requested with the resumption token of the page.
+const oaiPageSize = 100
+
+// oaiGranularity is the format of the datestamps of the repository.
+const oaiGranularity = "2006-01-02T15:04:05Z"
+
+// oaiRepositoryIDRX matches the repository identifiers allowed by the OAI identifier format.
+var oaiRepositoryIDRX = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*(\.[a-zA-Z][a-zA-Z0-9-]*)+$`)
+
+// oaiArguments lists the arguments each verb accepts, true for the required ones. A
+// resumption token replaces the other arguments of the list verbs.
+var oaiArguments = map[string]map[string]bool{
+	"Identify":            {},
+	"ListMetadataFormats": {"identifier": false},
+	"ListSets":            {"resumptionToken": false},
+	"GetRecord":           {"identifier": true, "metadataPrefix": true},
+	"ListIdentifiers":     {"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false},
+	"ListRecords":         {"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false},
+}
+
+// oaiError is an error condition of the protocol, reported in the response body with
+// 200 OK status code.
+type oaiError struct {
+	Code    string `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+type oaiRequest struct {
+	Verb            string `xml:"verb,attr,omitempty"`
+	Identifier      string `xml:"identifier,attr,omitempty"`
+	MetadataPrefix  string `xml:"metadataPrefix,attr,omitempty"`
+	From            string `xml:"from,attr,omitempty"`
+	Until           string `xml:"until,attr,omitempty"`
+	ResumptionToken string `xml:"resumptionToken,attr,omitempty"`
+	BaseURL         string `xml:",chardata"`
+}
+
+type oaiIdentify struct {
+	RepositoryName    string `xml:"repositoryName"`
+	BaseURL           string `xml:"baseURL"`
+	ProtocolVersion   string `xml:"protocolVersion"`
+	AdminEmail        string `xml:"adminEmail"`
+	EarliestDatestamp string `xml:"earliestDatestamp"`
+	DeletedRecord     string `xml:"deletedRecord"`
+	Granularity       string `xml:"granularity"`
+}
+
+type oaiMetadataFormat struct {
+	Prefix    string `xml:"metadataPrefix"`
+	Schema    string `xml:"schema"`
+	Namespace string `xml:"metadataNamespace"`
+}
+
+type oaiHeader struct {
+	Identifier string `xml:"identifier"`
+	Datestamp  string `xml:"datestamp"`
+}
+
+type oaiRecord struct {
+	Header   oaiHeader `xml:"header"`
+	Metadata struct {
+		DC oaiDC `xml:"oai_dc:dc"`
+	} `xml:"metadata"`
+}
+
+// oaiDC is the unqualified Dublin Core description of a book in the OAI container.
+type oaiDC struct {
+	NSOAIDC        string `xml:"xmlns:oai_dc,attr"`
+	NSDC           string `xml:"xmlns:dc,attr"`
+	NSXSI          string `xml:"xmlns:xsi,attr"`
+	SchemaLocation string `xml:"xsi:schemaLocation,attr"`
+	dcElements
+}
+
+// dcElements are the Dublin Core elements describing a book, in the "dc" namespace.
+type dcElements struct {
+	Title       string   `xml:"dc:title"`
+	Subjects    []string `xml:"dc:subject"`
+	Description string   `xml:"dc:description,omitempty"`
+	Date        string   `xml:"dc:date,omitempty"`
+	Type        string   `xml:"dc:type"`
+	Format      string   `xml:"dc:format,omitempty"`
+	Identifiers []string `xml:"dc:identifier"`
+}
+
+type oaiToken struct {
+	Value string `xml:",chardata"`
+}
+
+type oaiResponse struct {
+	XMLName        xml.Name   `xml:"OAI-PMH"`
+	NS             string     `xml:"xmlns,attr"`
+	NSXSI          string     `xml:"xmlns:xsi,attr"`
+	SchemaLocation string     `xml:"xsi:schemaLocation,attr"`
+	ResponseDate   string     `xml:"responseDate"`
+	Request        oaiRequest `xml:"request"`
+	Errors         []oaiError `xml:"error"`
+
+	Identify            *oaiIdentify `xml:"Identify"`
+	ListMetadataFormats *struct {
+		Formats []oaiMetadataFormat `xml:"metadataFormat"`
+	} `xml:"ListMetadataFormats"`
+	GetRecord *struct {
+		Record oaiRecord `xml:"record"`
+	} `xml:"GetRecord"`
+	ListIdentifiers *struct {
+		Headers []oaiHeader `xml:"header"`
+		Token   *oaiToken   `xml:"resumptionToken"`
+	} `xml:"ListIdentifiers"`
+	ListRecords *struct {
+		Records []oaiRecord `xml:"record"`
+		Token   *oaiToken   `xml:"resumptionToken"`
+	} `xml:"ListRecords"`
+}
+
+// oaiDCFormat is the only metadata format of the repository, unqualified Dublin Core.
+var oaiDCFormat = oaiMetadataFormat{
+	Prefix:    "oai_dc",
+	Schema:    "http://www.openarchives.org/OAI/2.0/oai_dc.xsd",
+	Namespace: "http://www.openarchives.org/OAI/2.0/oai_dc/",
+}
+
+// oaiCursor is the state of a list request carried by its resumption token.
+type oaiCursor struct {
+	Verb    string    `json:"v"`
+	From    time.Time `json:"f,omitempty"`
+	Until   time.Time `json:"u,omitempty"`
+	Updated time.Time `json:"t"`
+	ID      int64     `json:"i"`
+}
+
+// oaiHandler handles the "GET /v1/oai" and "POST /v1/oai" endpoints, an OAI-PMH 2.0
+// repository of the books of the tenant. Books are described in Dublin Core, identified
+// as oai:<repository id>:<book id> and stamped with their update time. Deleted books are
+// not tracked, so harvesters must re-harvest fully to drop them.
+func (app *Application) oaiHandler(w http.ResponseWriter, r *http.Request) {
+	args := r.URL.Query()
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, app.contextGetBodyLimit(r))
+		if err := r.ParseForm(); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		args = r.PostForm
+	}
+
+	resp := &oaiResponse{
+		NS:             "http://www.openarchives.org/OAI/2.0/",
+		NSXSI:          "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.openarchives.org/OAI/2.0/ http://www.openarchives.org/OAI/2.0/OAI-PMH.xsd",
+		ResponseDate:   time.Now().UTC().Format(oaiGranularity),
+		Request:        oaiRequest{BaseURL: app.baseURL(r) + r.URL.Path},
+	}
+
+	if errs := oaiCheckArguments(args); errs != nil {
+		resp.Errors = errs
+	} else {
+		resp.Request = oaiRequest{
+			Verb:            args.Get("verb"),
+			Identifier:      args.Get("identifier"),
+			MetadataPrefix:  args.Get("metadataPrefix"),
+			From:            args.Get("from"),
+			Until:           args.Get("until"),
+			ResumptionToken: args.Get("resumptionToken"),
+			BaseURL:         resp.Request.BaseURL,
+		}
+
+		err := app.oaiVerb(r, args, resp)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(resp); err != nil {
+		app.logError(r, err)
+	}
+}
+
+// oaiCheckArguments checks the verb and its arguments, returning the errors to report.
+func oaiCheckArguments(args url.Values) []oaiError {
+	verb := args.Get("verb")
+	allowed, ok := oaiArguments[verb]
+	if !ok || len(args["verb"]) > 1 {
+		return []oaiError{{Code: "badVerb", Message: fmt.Sprintf("illegal OAI verb %q", verb)}}
+	}
+
+	var errs []oaiError
+	for name, values := range args {
+		if _, ok := allowed[name]; !ok && name != "verb" {
+			errs = append(errs, oaiError{Code: "badArgument", Message: fmt.Sprintf("illegal argument %q", name)})
+		} else if len(values) > 1 {
+			errs = append(errs, oaiError{Code: "badArgument", Message: fmt.Sprintf("repeated argument %q", name)})
+		}
+	}
+
+	if args.Has("resumptionToken") {
+		if len(args) > 2 {
+			errs = append(errs, oaiError{Code: "badArgument", Message: "resumptionToken must be the only argument"})
+		}
+		return errs
+	}
+
+	for name, required := range allowed {
+		if required && !args.Has(name) {
+			errs = append(errs, oaiError{Code: "badArgument", Message: fmt.Sprintf("missing argument %q", name)})
+		}
+	}
+
+	return errs
+}
+
+// oaiVerb fills in the response to the verb of a request with valid arguments. Protocol
+// errors are reported in the response, only server errors are returned.
+func (app *Application) oaiVerb(r *http.Request, args url.Values, resp *oaiResponse) error {
+	fail := func(code, message string) error {
+		resp.Errors = append(resp.Errors, oaiError{Code: code, Message: message})
+		return nil
+	}
+
+	if prefix := args.Get("metadataPrefix"); prefix != "" && prefix != oaiDCFormat.Prefix {
+		return fail("cannotDisseminateFormat", fmt.Sprintf("metadata format %q is not supported", prefix))
+	}
+
+	switch verb := args.Get("verb"); verb {
+	case "Identify":
+		adminEmail := "admin@" + app.config.oai.repositoryID
+		if addr, err := mail.ParseAddress(app.config.smtp.sender); err == nil {
+			adminEmail = addr.Address
+		}
+		resp.Identify = &oaiIdentify{
+			RepositoryName:    app.config.oai.repositoryName,
+			BaseURL:           resp.Request.BaseURL,
+			ProtocolVersion:   "2.0",
+			AdminEmail:        adminEmail,
+			EarliestDatestamp: "1970-01-01T00:00:00Z",
+			DeletedRecord:     "no",
+			Granularity:       "YYYY-MM-DDThh:mm:ssZ",
+		}
+
+	case "ListMetadataFormats":
+		if identifier := args.Get("identifier"); identifier != "" {
+			if _, err := app.oaiBook(r, identifier); err != nil {
+				if errors.Is(err, data.ErrRecordNotFound) {
+					return fail("idDoesNotExist", fmt.Sprintf("unknown identifier %q", identifier))
+				}
+				return err
+			}
+		}
+		resp.ListMetadataFormats = &struct {
+			Formats []oaiMetadataFormat `xml:"metadataFormat"`
+		}{Formats: []oaiMetadataFormat{oaiDCFormat}}
+
+	case "ListSets":
+		return fail("noSetHierarchy", "the repository does not support sets")
+
+	case "GetRecord":
+		book, err := app.oaiBook(r, args.Get("identifier"))
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				return fail("idDoesNotExist", fmt.Sprintf("unknown identifier %q", args.Get("identifier")))
+			}
+			return err
+		}
+		resp.GetRecord = &struct {
+			Record oaiRecord `xml:"record"`
+		}{Record: app.oaiRecord(book)}
+
+	case "ListIdentifiers", "ListRecords":
+		var cursor oaiCursor
+		if token := args.Get("resumptionToken"); token != "" {
+			var ok bool
+			if cursor, ok = decodeOAIToken(token); !ok || cursor.Verb != verb {
+				return fail("badResumptionToken", "the resumption token is invalid")
+			}
+		} else {
+			if args.Has("set") {
+				return fail("noSetHierarchy", "the repository does not support sets")
+			}
+
+			var ok bool
+			cursor.Verb = verb
+			if cursor.From, ok = parseOAIDate(args.Get("from"), false); !ok {
+				return fail("badArgument", "from must be a date or a UTC date and time")
+			}
+			if cursor.Until, ok = parseOAIDate(args.Get("until"), true); !ok {
+				return fail("badArgument", "until must be a date or a UTC date and time")
+			}
+			if len(args.Get("from")) > 0 && len(args.Get("until")) > 0 && len(args.Get("from")) != len(args.Get("until")) {
+				return fail("badArgument", "from and until must have the same granularity")
+			}
+		}
+
+		books, err := app.books(r).Changed(cursor.From, cursor.Until, data.ChangeCursor{Updated: cursor.Updated, ID: cursor.ID}, oaiPageSize+1)
+		if err != nil {
+			return err
+		}
+		if len(books) == 0 {
+			return fail("noRecordsMatch", "no books match the request")
+		}
+
+		// the extra book tells whether there is another page.
+		var token *oaiToken
+		if len(books) > oaiPageSize {
+			books = books[:oaiPageSize]
+			last := books[len(books)-1]
+			cursor.Updated, cursor.ID = last.Updated, last.ID
+			token = &oaiToken{Value: encodeOAIToken(cursor)}
+		} else if args.Has("resumptionToken") {
+			// the last page of a list carries an empty token.
+			token = &oaiToken{}
+		}
+
+		if verb == "ListIdentifiers" {
+			resp.ListIdentifiers = &struct {
+				Headers []oaiHeader `xml:"header"`
+				Token   *oaiToken   `xml:"resumptionToken"`
+			}{Token: token}
+			for _, book := range books {
+				resp.ListIdentifiers.Headers = append(resp.ListIdentifiers.Headers, app.oaiHeader(book))
+			}
+		} else {
+			resp.ListRecords = &struct {
+				Records []oaiRecord `xml:"record"`
+				Token   *oaiToken   `xml:"resumptionToken"`
+			}{Token: token}
+			for _, book := range books {
+				resp.ListRecords.Records = append(resp.ListRecords.Records, app.oaiRecord(book))
+			}
+		}
+	}
+
+	return nil
+}
+
+// oaiBook returns the book with the OAI identifier, or data.ErrRecordNotFound if the
+// identifier is not one of the repository.
+func (app *Application) oaiBook(r *http.Request, identifier string) (*data.Book, error) {
+	rest, ok := strings.CutPrefix(identifier, "oai:"+app.config.oai.repositoryID+":")
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil || id < 1 {
+		return nil, data.ErrRecordNotFound
+	}
+	return app.books(r).Get(id)
+}
+
+func (app *Application) oaiHeader(book *data.Book) oaiHeader {
+	return oaiHeader{
+		Identifier: fmt.Sprintf("oai:%s:%d", app.config.oai.repositoryID, book.ID),
+		Datestamp:  book.Updated.UTC().Format(oaiGranularity),
+	}
+}
+
+// oaiRecord describes the book in Dublin Core, identified by its OAI identifier.
+func (app *Application) oaiRecord(book *data.Book) oaiRecord {
+	header := app.oaiHeader(book)
+
+	record := oaiRecord{Header: header}
+	record.Metadata.DC = oaiDC{
+		NSOAIDC:        oaiDCFormat.Namespace,
+		NSDC:           "http://purl.org/dc/elements/1.1/",
+		NSXSI:          "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: oaiDCFormat.Namespace + " " + oaiDCFormat.Schema,
+		dcElements:     bookDC(book, header.Identifier),
+	}
+	return record
+}
+
+// bookDC maps the book to Dublin Core: the genres are subjects, the year the date, the
+// pages the format, and the ISBN an identifier along with the given identifier.
+func bookDC(book *data.Book, identifier string) dcElements {
+	dc := dcElements{
+		Title:       book.Title,
+		Subjects:    book.Genres,
+		Type:        "Text",
+		Identifiers: []string{identifier},
+	}
+	if description, ok := book.Metadata["description"].(string); ok {
+		dc.Description = description
+	}
+	if book.Year != nil {
+		dc.Date = strconv.Itoa(int(*book.Year))
+	}
+	if book.Pages != nil {
+		dc.Format = fmt.Sprintf("%d pages", *book.Pages)
+	}
+	if book.ISBN != "" {
+		dc.Identifiers = append(dc.Identifiers, "urn:isbn:"+book.ISBN)
+	}
+	return dc
+}
+
+// parseOAIDate parses a from or until argument in day or second granularity. An until
+// day includes the whole day. Empty values parse to the zero time.
+func parseOAIDate(s string, until bool) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(oaiGranularity, s); err == nil {
+		return t, true
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if until {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, true
+}
+
+func encodeOAIToken(cursor oaiCursor) string {
+	js, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeOAIToken(token string) (oaiCursor, bool) {
+	var cursor oaiCursor
+	js, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(js, &cursor) != nil || cursor.ID < 1 {
+		return oaiCursor{}, false
+	}
+	return cursor, true
+}
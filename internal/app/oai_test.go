@@ -0,0 +1,89 @@
+package app
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+func TestOAIHandler(t *testing.T) {
+	app := &Application{}
+	app.config.oai.repositoryID = "library.example.org"
+	app.config.oai.repositoryName = "Example Library"
+	app.config.smtp.sender = "Library <library@example.org>"
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"verb=Identify", "<adminEmail>library@example.org</adminEmail>"},
+		{"verb=Identify&verb=Identify", `<error code="badVerb">`},
+		{"verb=Harvest", `<error code="badVerb">`},
+		{"verb=GetRecord&identifier=oai:library.example.org:1", `<error code="badArgument">missing argument &#34;metadataPrefix&#34;</error>`},
+		{"verb=ListRecords&metadataPrefix=marc21", `<error code="cannotDisseminateFormat">`},
+		{"verb=ListRecords&resumptionToken=abc&from=2020-01-01", `<error code="badArgument">`},
+		{"verb=ListIdentifiers&resumptionToken=abc", `<error code="badResumptionToken">`},
+		{"verb=ListSets", `<error code="noSetHierarchy">`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/v1/oai?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			app.oaiHandler(w, r)
+
+			if w.Code != 200 || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("want %d response containing %s, got %d: %s", 200, tt.want, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestOAIRecord(t *testing.T) {
+	app := &Application{}
+	app.config.oai.repositoryID = "library.example.org"
+
+	year := int32(1965)
+	book := &data.Book{
+		ID:       7,
+		Title:    "Dune",
+		Year:     &year,
+		Genres:   []string{"sci-fi"},
+		ISBN:     "9780441013593",
+		Metadata: data.Attributes{"description": "A desert planet."},
+		Updated:  time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	b, err := xml.Marshal(app.oaiRecord(book))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"<identifier>oai:library.example.org:7</identifier><datestamp>2024-03-01T12:00:00Z</datestamp>",
+		"<dc:title>Dune</dc:title><dc:subject>sci-fi</dc:subject><dc:description>A desert planet.</dc:description><dc:date>1965</dc:date>",
+		"<dc:identifier>urn:isbn:9780441013593</dc:identifier>",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("want record containing %s, got %s", want, b)
+		}
+	}
+}
+
+func TestOAIToken(t *testing.T) {
+	cursor := oaiCursor{Verb: "ListRecords", From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Updated: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), ID: 42}
+
+	got, ok := decodeOAIToken(encodeOAIToken(cursor))
+	if !ok || !got.From.Equal(cursor.From) || !got.Updated.Equal(cursor.Updated) || got.ID != 42 || got.Verb != "ListRecords" {
+		t.Errorf("want %+v, got %+v", cursor, got)
+	}
+
+	if _, ok := decodeOAIToken(url.QueryEscape("not a token")); ok {
+		t.Error("want invalid token rejected")
+	}
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/publish"
+)
+
+const (
+	// outboxInterval is the time between the runs of the outbox relay.
+	outboxInterval = time.Second
+	// outboxBatch is the maximum number of events published by a run.
+	outboxBatch = 100
+)
+
+// relayOutbox publishes the events written to the outbox to the message broker every
+// outbox interval until the server shuts down.
+func (app *Application) relayOutbox() {
+	if app.publisher == nil {
+		return
+	}
+
+	ticker := time.NewTicker(outboxInterval)
+	defer ticker.Stop()
+
+	// skip the ticks while a run is still publishing a large backlog.
+	var running atomic.Bool
+
+	for range ticker.C {
+		if app.shuttingDown.Load() {
+			return
+		}
+		if !running.CompareAndSwap(false, true) {
+			continue
+		}
+
+		// Run on the worker pool so shutdown waits for it instead of closing the database under it.
+		app.background("outbox relay", func() {
+			defer running.Store(false)
+			app.relayRun()
+		})
+	}
+}
+
+// relayRun publishes the events waiting in the outbox, a batch at a time.
+func (app *Application) relayRun() {
+	for !app.shuttingDown.Load() {
+		published, err := app.models.Outbox.Relay(outboxBatch, app.publishEvent)
+		outboxPublished.Add(float64(published))
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"job": "outbox relay"})
+			return
+		}
+		if published < outboxBatch {
+			return
+		}
+	}
+}
+
+// publishEvent sends the event to the message broker. Events of a book share a key, so
+// brokers partitioning by key deliver them in order.
+func (app *Application) publishEvent(e data.OutboxEvent) error {
+	payload, err := app.eventPayload(e.Event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return app.publisher.Publish(ctx, publish.Message{
+		ID:      strconv.FormatInt(e.ID, 10),
+		Subject: e.Type,
+		Key:     fmt.Sprintf("%s:%d", e.Tenant, e.BookID),
+		Payload: payload,
+	})
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nikitashershunov/LibraryAPI/internal/metrics"
+)
+
+// Prometheus metrics served at the "GET /metrics" endpoint. Like the expvar metrics they
+// are package level, so they are registered once per process.
+var (
+	promRegistry = metrics.NewRegistry()
+
+	httpRequestDuration = promRegistry.NewHistogramVec("http_request_duration_seconds",
+		"Duration of HTTP requests by route, method and status code.",
+		metrics.DefaultBuckets, "route", "method", "status")
+	httpRequestsInFlight = promRegistry.NewGauge("http_requests_in_flight",
+		"Number of HTTP requests currently being served.")
+	rateLimitRejections = promRegistry.NewCounterVec("http_rate_limit_rejections_total",
+		"Number of requests rejected by the rate limiter.")
+	loadShedRejections = promRegistry.NewCounterVec("http_load_shed_rejections_total",
+		"Number of requests rejected by the concurrency limiter, by route group.", "group")
+	dbQueryDuration = promRegistry.NewHistogramVec("db_query_duration_seconds",
+		"Duration of database queries.", metrics.DefaultBuckets)
+	cacheLookups = promRegistry.NewCounterVec("cache_lookups_total",
+		"Number of book cache lookups by result (hit, miss or error).", "result")
+	outboxPublished = promRegistry.NewCounterVec("outbox_published_total",
+		"Number of outbox events published to the message broker.")
+	searchQueries = promRegistry.NewCounterVec("search_queries_total",
+		"Number of search index queries by result (ok or error, served from the database).", "result")
+)
+
+// prometheus records the duration of requests and the number of requests in flight.
+// Requests are labelled with the route pattern they matched in router rather than the
+// actual path, which keeps the number of series bounded.
+func (app *Application) prometheus(router *httprouter.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		httpRequestsInFlight.Add(1)
+		defer httpRequestsInFlight.Add(-1)
+
+		mw := &metricsResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(mw, r)
+
+		httpRequestDuration.Observe(time.Since(start).Seconds(),
+			routePattern(router, r), r.Method, strconv.Itoa(mw.statusCode))
+	})
+}
+
+// routePattern reconstructs the route pattern of the request, e.g. "/v1/books/:id", by
+// replacing the path segments holding parameter values with the parameter names.
+func routePattern(router *httprouter.Router, r *http.Request) string {
+	handle, params, _ := router.Lookup(r.Method, r.URL.Path)
+	if handle == nil {
+		return "unmatched"
+	}
+
+	segments := strings.Split(r.URL.Path, "/")
+	next := 0
+	for i, segment := range segments {
+		if next < len(params) && segment == params[next].Value {
+			segments[i] = ":" + params[next].Key
+			next++
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nikitashershunov/LibraryAPI/internal/metrics"
+)
+
+// routes is router for my main application.
+func (app *Application) routes() http.Handler {
+	router := httprouter.New()
+
+	// convert the app.notFoundResponse helper to http.Handler using the http.HandlerFunc()
+	// and set it as custom error handler for 404 Not Found.
+	router.NotFound = http.HandlerFunc(app.notFoundResponse)
+
+	// convert the app.methodNotAllowedResponse helper to http.Handler
+	// and set it as custom error handler for 405 Method Not Allowed.
+	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+
+	// healthcheck handler and corresponding endpoint
+	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/livez", app.livenessHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/readyz", app.readinessHandler)
+
+	// rate and concurrency limits of the route groups, on top of the global ones
+	group := app.routeGroups()
+
+	// caching of the responses by browsers and CDNs: public catalog data, covers, and
+	// private data which must never be stored
+	cache := app.cacheGroups()
+
+	// books handlers and corresponding endpoints
+	router.HandlerFunc(http.MethodGet, routeBooks, cache("public", group("search", app.requireTenant(app.requireQuota(app.listBooksHandler)))))
+	router.HandlerFunc(http.MethodPost, routeBooks, group("write", app.requireTenant(app.requireQuota(app.createBookHandler))))
+	router.HandlerFunc(http.MethodGet, routeBook, app.staticParam("id", map[string]http.HandlerFunc{
+		"feed":      cache("public", group("search", app.requireTenant(app.requireQuota(app.booksFeedHandler)))),
+		"checksums": cache("public", group("search", app.requireTenant(app.requireQuota(app.listChecksumsHandler)))),
+		"new":       cache("curated", group("search", app.requireTenant(app.requireQuota(app.newBooksHandler)))),
+		"recent":    cache("curated", group("search", app.requireTenant(app.requireQuota(app.recentBooksHandler)))),
+	}, cache("public", app.requireTenant(app.requireQuota(app.getBookHandler)))))
+	router.HandlerFunc(http.MethodPatch, routeBook, group("write", app.requireTenant(app.requireQuota(app.updateBookHandler))))
+	router.HandlerFunc(http.MethodDelete, routeBook, group("write", app.requireTenant(app.requireQuota(app.deleteBookHandler))))
+	router.HandlerFunc(http.MethodPost, "/v1/books/:id/enrich", group("write", app.requireTenant(app.requireQuota(app.enrichBookHandler))))
+	router.HandlerFunc(http.MethodGet, routeBookCover, cache("covers", app.requireTenant(app.requireQuota(app.getCoverHandler))))
+	router.HandlerFunc(http.MethodPost, routeBookCover, group("write", app.requireTenant(app.requireQuota(app.maxBodySize(app.config.limits.bulkBody, app.uploadCoverHandler)))))
+	router.HandlerFunc(http.MethodDelete, routeBookCover, group("write", app.requireTenant(app.requireQuota(app.deleteCoverHandler))))
+	router.HandlerFunc(http.MethodPut, "/v1/books/isbn/:isbn", group("write", app.requireTenant(app.requireQuota(app.upsertBookHandler))))
+
+	// exports of the whole catalog, written to the storage by background jobs
+	router.HandlerFunc(http.MethodPost, routeExports, group("write", app.requireTenant(app.requireQuota(app.createExportHandler))))
+	router.HandlerFunc(http.MethodGet, routeExport, cache("private", app.requireTenant(app.requireQuota(app.showExportHandler))))
+	router.HandlerFunc(http.MethodGet, routeExportDownload, cache("private", app.requireTenant(app.requireQuota(app.downloadExportHandler))))
+
+	// OAI-PMH repository and SRU server of the catalog for harvesters and discovery systems
+	router.HandlerFunc(http.MethodGet, "/v1/oai", cache("public", group("search", app.requireTenant(app.requireQuota(app.oaiHandler)))))
+	router.HandlerFunc(http.MethodPost, "/v1/oai", group("search", app.requireTenant(app.requireQuota(app.oaiHandler))))
+	router.HandlerFunc(http.MethodGet, "/v1/sru", cache("public", group("search", app.requireTenant(app.requireQuota(app.sruHandler)))))
+
+	// catalog statistics for collection development
+	router.HandlerFunc(http.MethodGet, "/v1/stats/genres/trends", cache("public", group("search", app.requireTenant(app.requireQuota(app.genreTrendsHandler)))))
+
+	// search and usage statistics, only available to admins
+	router.HandlerFunc(http.MethodGet, "/v1/stats/searches/top", cache("private", app.requireAdmin(app.requireTenant(app.topSearchesHandler))))
+	router.HandlerFunc(http.MethodGet, "/v1/stats/searches/zero-results", cache("private", app.requireAdmin(app.requireTenant(app.zeroResultSearchesHandler))))
+	router.HandlerFunc(http.MethodGet, "/v1/stats/books/usage", cache("private", app.requireAdmin(app.requireTenant(app.bookUsageHandler))))
+
+	// live updates of the catalog over WebSocket
+	router.HandlerFunc(http.MethodGet, "/v1/live", app.requireTenant(app.liveHandler))
+
+	// allowance of the API key of the request, see requireQuota
+	router.HandlerFunc(http.MethodGet, "/v1/quota", cache("private", app.requireTenant(app.showQuotaHandler)))
+
+	// API key handlers and corresponding endpoints, only available to admins
+	router.HandlerFunc(http.MethodGet, "/v1/api-keys", cache("private", app.requireAdmin(app.requireTenant(app.listAPIKeysHandler))))
+	router.HandlerFunc(http.MethodPost, "/v1/api-keys", cache("private", app.requireAdmin(app.requireTenant(app.createAPIKeyHandler))))
+	router.HandlerFunc(http.MethodDelete, "/v1/api-keys/:id", cache("private", app.requireAdmin(app.requireTenant(app.deleteAPIKeyHandler))))
+
+	// featured collections, which only admins can change and see before and after they are shown
+	router.HandlerFunc(http.MethodGet, "/v1/collections", cache("public", group("search", app.requireTenant(app.requireQuota(app.listCollectionsHandler)))))
+	router.HandlerFunc(http.MethodGet, "/v1/collections/:slug", cache("public", group("search", app.requireTenant(app.requireQuota(app.showCollectionHandler)))))
+	router.HandlerFunc(http.MethodPut, "/v1/collections/:slug", cache("private", app.requireAdmin(app.requireTenant(app.setCollectionHandler))))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:slug", cache("private", app.requireAdmin(app.requireTenant(app.deleteCollectionHandler))))
+
+	// genre alias and genre handlers and corresponding endpoints, only available to admins
+	router.HandlerFunc(http.MethodGet, "/v1/genre-aliases", cache("private", app.requireAdmin(app.requireTenant(app.listGenreAliasesHandler))))
+	router.HandlerFunc(http.MethodPut, "/v1/genre-aliases/:alias", cache("private", app.requireAdmin(app.requireTenant(app.setGenreAliasHandler))))
+	router.HandlerFunc(http.MethodDelete, "/v1/genre-aliases/:alias", cache("private", app.requireAdmin(app.requireTenant(app.deleteGenreAliasHandler))))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/genres/rename", cache("private", app.requireAdmin(app.requireTenant(app.renameGenreHandler))))
+
+	// webhook handlers and corresponding endpoints, only available to admins
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks", cache("private", app.requireAdmin(app.requireTenant(app.listWebhooksHandler))))
+	router.HandlerFunc(http.MethodPost, "/v1/webhooks", cache("private", app.requireAdmin(app.requireTenant(app.createWebhookHandler))))
+	router.HandlerFunc(http.MethodDelete, "/v1/webhooks/:id", cache("private", app.requireAdmin(app.requireTenant(app.deleteWebhookHandler))))
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks/:id/deliveries", cache("private", app.requireAdmin(app.requireTenant(app.listWebhookDeliveriesHandler))))
+
+	// API documentation, Swagger UI only when enabled
+	router.HandlerFunc(http.MethodGet, "/v1/openapi.json", cache("public", app.openAPIHandler))
+	if app.config.swaggerUI {
+		router.HandlerFunc(http.MethodGet, "/docs", app.swaggerUIHandler)
+	}
+
+	// runtime and application metrics, only available from localhost
+	router.Handler(http.MethodGet, "/debug/vars", app.requireLocalhost(http.HandlerFunc(app.debugVarsHandler)))
+	router.Handler(http.MethodGet, "/metrics", app.requireLocalhost(metrics.Handler(promRegistry, app.gauges)))
+
+	// runtime log level switching, only available from localhost
+	router.Handler(http.MethodGet, "/debug/log-level", app.requireLocalhost(http.HandlerFunc(app.showLogLevelHandler)))
+	router.Handler(http.MethodPut, "/debug/log-level", app.requireLocalhost(http.HandlerFunc(app.updateLogLevelHandler)))
+
+	// runtime profiling, only available from localhost and when enabled
+	if app.config.pprof {
+		router.Handler(http.MethodGet, "/debug/pprof/*profile", app.requireLocalhost(http.HandlerFunc(app.pprofHandler)))
+		router.Handler(http.MethodPost, "/debug/pprof/*profile", app.requireLocalhost(http.HandlerFunc(app.pprofHandler)))
+	}
+
+	handler := app.metrics(app.prometheus(router, app.requestID(app.logRequest(app.captureRequests(app.injectFaults(app.recoverPanic(app.rateLimit(app.limitConcurrency(app.decompressRequest(app.validateRequestBody(router)))))))))))
+
+	// captured requests, only available from localhost and when capturing is enabled. They
+	// are replayed through the whole middleware chain, as they were first received.
+	if app.captures != nil {
+		router.Handler(http.MethodGet, "/debug/requests", app.requireLocalhost(http.HandlerFunc(app.listCapturedRequestsHandler)))
+		router.Handler(http.MethodPost, "/debug/requests/:id/replay", app.requireLocalhost(app.replayRequestHandler(handler)))
+	}
+
+	return handler
+}
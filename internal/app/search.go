@@ -0,0 +1,56 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/events"
+)
+
+// indexBooks mirrors the book events into the search index until the event subscription is
+// cancelled. Every instance indexes every event; the writes are versioned, so duplicates and
+// reordered writes leave the index with the latest state of each book.
+func (app *Application) indexBooks() {
+	if app.models.Books.Index == nil {
+		return
+	}
+
+	ch, cancel := app.events.Subscribe(256)
+	defer cancel()
+
+	for e := range ch {
+		var err error
+
+		switch e.Type {
+		case events.Resync:
+			app.logger.PrintWarn("search index events may have been lost, reindexing", nil)
+			app.background("reindex", app.reindexRun)
+			continue
+		case events.BookDeleted:
+			err = app.models.Books.ForTenant(e.Tenant).UnindexBook(e.BookID, e.Version)
+		default:
+			err = app.models.Books.ForTenant(e.Tenant).IndexBook(e.BookID)
+		}
+
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"event":   e.Type,
+				"book_id": strconv.FormatInt(e.BookID, 10),
+			})
+		}
+	}
+}
+
+// reindexRun writes all the books to the search index.
+func (app *Application) reindexRun() {
+	indexed, err := app.models.Books.Reindex()
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"job": "reindex"})
+		return
+	}
+
+	app.logger.PrintInfo("reindexed books", map[string]string{
+		"job":     "reindex",
+		"indexed": fmt.Sprintf("%d", indexed),
+	})
+}
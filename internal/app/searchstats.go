@@ -0,0 +1,78 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// maxSearchStatsDays is the longest window of the search statistics, in days.
+const maxSearchStatsDays = 366
+
+// recordSearch counts the free text search of the request, which found hits books, in the
+// search statistics if searches are recorded. It is recorded in the background so searching
+// does not wait for it.
+func (app *Application) recordSearch(r *http.Request, query string, hits int) {
+	if !app.config.search.log {
+		return
+	}
+
+	searches := app.models.SearchLog.ForTenant(app.contextGetTenant(r))
+	now := time.Now()
+	app.background("search log", func() {
+		if err := searches.Record(query, hits, now); err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+}
+
+// topSearchesHandler handles the "GET /v1/stats/searches/top" endpoint and returns the most
+// searched queries over a window, see searchStatsHandler.
+func (app *Application) topSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	app.searchStatsHandler(w, r, app.models.SearchLog.ForTenant(app.contextGetTenant(r)).Top)
+}
+
+// zeroResultSearchesHandler handles the "GET /v1/stats/searches/zero-results" endpoint and
+// returns the queries which most often found no books over a window, what patrons look for
+// but cannot find, see searchStatsHandler.
+func (app *Application) zeroResultSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	app.searchStatsHandler(w, r, app.models.SearchLog.ForTenant(app.contextGetTenant(r)).ZeroResults)
+}
+
+// searchStatsHandler returns the search queries listed by list over the window from the day
+// "from" to the day "to" inclusive, by default the last 30 days, limited to "limit" queries.
+func (app *Application) searchStatsHandler(w http.ResponseWriter, r *http.Request, list func(from, until time.Time, limit int) ([]data.SearchQuery, error)) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	until := app.readDay(qs, "to", today, v)
+	from := app.readDay(qs, "from", until.AddDate(0, 0, -29), v)
+	limit := app.readInt(qs, "limit", 20, v)
+
+	days := int(until.Sub(from).Hours()/24) + 1
+	v.Check(days >= 1, "from", "must not be after to")
+	v.Check(days <= maxSearchStatsDays, "from", fmt.Sprintf("must not be more than %d days before to", maxSearchStatsDays))
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 100, "limit", "must be a maximum of 100")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	searches, err := list(from, until, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	window := map[string]string{"from": from.Format(time.DateOnly), "to": until.Format(time.DateOnly)}
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"searches": searches, "window": window}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/fixtures"
+)
+
+// Seed loads the fixture set of the named file, or the built-in set if the name is
+// "library", into the database, for the tenant of the set or data.DefaultTenant. It returns
+// the loaded records and the tenant.
+func (app *Application) Seed(name string) (*fixtures.Loaded, string, error) {
+	var (
+		set *fixtures.Set
+		err error
+	)
+	if name == "library" {
+		set, err = fixtures.Library()
+	} else {
+		set, err = fixtures.ReadFile(name)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	tenant := set.Tenant
+	if tenant == "" {
+		tenant = data.DefaultTenant
+	}
+
+	loaded, err := fixtures.Load(app.models, tenant, set)
+	return loaded, tenant, err
+}
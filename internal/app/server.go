@@ -0,0 +1,192 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Serve starts the background jobs and serves the API until ctx is done, then shuts down
+// gracefully: readiness checks fail first, in-flight requests and background tasks are
+// completed within the shutdown timeout. If the server fails, what it started is shut
+// down the same way before the error is returned.
+func (app *Application) Serve(ctx context.Context) error {
+	serveCtx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	app.startBackgroundJobs()
+
+	// declare an HTTP server.
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.port),
+		Handler:      app.routes(),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		ErrorLog:     log.New(app.logger, "", 0),
+	}
+
+	// close the live update connections, which the server doesn't track once hijacked.
+	srv.RegisterOnShutdown(app.live.closeAll)
+
+	// serve the gRPC API on its own port if one is configured.
+	var grpcSrv *http.Server
+	if app.config.grpc.port > 0 {
+		grpcSrv = app.grpcServer()
+
+		go func() {
+			app.logger.PrintInfo("starting grpc server", map[string]string{
+				"addr": grpcSrv.Addr,
+			})
+
+			var err error
+			if grpcSrv.TLSConfig != nil {
+				err = grpcSrv.ListenAndServeTLS(app.config.tls.cert, app.config.tls.key)
+			} else {
+				err = grpcSrv.ListenAndServe()
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.PrintError(err, map[string]string{"addr": grpcSrv.Addr})
+			}
+		}()
+	}
+
+	shutdownErr := make(chan error)
+
+	go func() {
+		<-serveCtx.Done()
+
+		app.logger.PrintInfo("shutting down server", map[string]string{
+			"cause": context.Cause(serveCtx).Error(),
+		})
+
+		// fail readiness checks first, so traffic is drained before connections are closed.
+		// A server which failed has no traffic to drain.
+		app.shuttingDown.Store(true)
+		if ctx.Err() != nil {
+			time.Sleep(app.config.shutdownDelay)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdownTimeout)
+		defer cancel()
+
+		// stop accepting connections and wait for in-flight requests to complete.
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownErr <- err
+			return
+		}
+		if grpcSrv != nil {
+			err = grpcSrv.Shutdown(ctx)
+			if err != nil {
+				shutdownErr <- err
+				return
+			}
+		}
+
+		app.logger.PrintInfo("completing background tasks", map[string]string{
+			"addr": srv.Addr,
+		})
+
+		// wait for background tasks within what is left of the shutdown timeout.
+		shutdownErr <- app.completeBackgroundTasks(ctx)
+	}()
+
+	app.logger.PrintInfo("starting server", map[string]string{
+		"addr": srv.Addr,
+		"env":  app.config.env,
+	})
+
+	err := app.listenAndServe(srv)
+	if !errors.Is(err, http.ErrServerClosed) {
+		stop(err)
+		return errors.Join(err, <-shutdownErr)
+	}
+
+	err = <-shutdownErr
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("stopped server", map[string]string{
+		"addr": srv.Addr,
+	})
+	return nil
+}
+
+// listenAndServe starts srv with HTTPS if a certificate is configured or autocert domains
+// are given, and with plain HTTP otherwise.
+func (app *Application) listenAndServe(srv *http.Server) error {
+	switch {
+	case app.config.tls.autocertDomains != "":
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(strings.Split(app.config.tls.autocertDomains, ",")...),
+			Cache:      autocert.DirCache(app.config.tls.autocertCache),
+			Email:      app.config.tls.autocertEmail,
+		}
+
+		srv.TLSConfig = tlsConfig()
+		srv.TLSConfig.GetCertificate = m.GetCertificate
+		srv.TLSConfig.NextProtos = append(srv.TLSConfig.NextProtos, "h2", "http/1.1", acme.ALPNProto)
+
+		// answer the HTTP-01 challenges and redirect everything else to HTTPS, until srv is
+		// shut down or fails.
+		challengeSrv := &http.Server{
+			Addr:         ":80",
+			Handler:      m.HTTPHandler(nil),
+			IdleTimeout:  time.Minute,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 30 * time.Second,
+			ErrorLog:     srv.ErrorLog,
+		}
+		srv.RegisterOnShutdown(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdownTimeout)
+			defer cancel()
+			challengeSrv.Shutdown(ctx)
+		})
+
+		go func() {
+			err := challengeSrv.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.PrintError(err, map[string]string{"addr": challengeSrv.Addr})
+			}
+		}()
+
+		err := srv.ListenAndServeTLS("", "")
+		if !errors.Is(err, http.ErrServerClosed) {
+			challengeSrv.Close()
+		}
+		return err
+	case app.config.tls.cert != "" || app.config.tls.key != "":
+		srv.TLSConfig = tlsConfig()
+		return srv.ListenAndServeTLS(app.config.tls.cert, app.config.tls.key)
+	default:
+		return srv.ListenAndServe()
+	}
+}
+
+// tlsConfig returns the TLS settings of the server: TLS 1.2 or later with forward secret
+// AEAD cipher suites only, and the curves with assembly implementations.
+func tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
@@ -0,0 +1,596 @@
+package app
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/cql"
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+const (
+	// sruDefaultRecords is the number of records returned when maximumRecords is not set,
+	// and sruMaxRecords the most returned by a request.
+	sruDefaultRecords = 10
+	sruMaxRecords     = 100
+)
+
+// sruSchemas maps the record schemas, by short name and by identifier, to their identifiers.
+var sruSchemas = map[string]string{
+	"dc":                             "info:srw/schema/1/dc-v1.1",
+	"info:srw/schema/1/dc-v1.1":      "info:srw/schema/1/dc-v1.1",
+	"marcxml":                        "info:srw/schema/1/marcxml-v1.1",
+	"info:srw/schema/1/marcxml-v1.1": "info:srw/schema/1/marcxml-v1.1",
+}
+
+// sruParameters lists the parameters of the protocol, true for the supported ones. Other
+// parameters are unsupported, except for the "x-" extensions, which are ignored.
+var sruParameters = map[string]bool{
+	"operation":      true,
+	"version":        true,
+	"query":          true,
+	"startRecord":    true,
+	"maximumRecords": true,
+	"recordSchema":   true,
+	"recordPacking":  true,
+	"resultSetTTL":   true,
+	"stylesheet":     false,
+	"sortKeys":       false,
+	"recordXPath":    false,
+}
+
+// sruDiagnostic is an error condition of the protocol, reported in the response body with
+// 200 OK status code. Code is the number of the diagnostic in the SRU diagnostics list.
+type sruDiagnostic struct {
+	Code    int
+	Message string
+	Details string
+}
+
+func (d *sruDiagnostic) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		NS      string `xml:"xmlns,attr"`
+		URI     string `xml:"uri"`
+		Details string `xml:"details,omitempty"`
+		Message string `xml:"message"`
+	}{
+		NS:      "http://www.loc.gov/zing/srw/diagnostic/",
+		URI:     fmt.Sprintf("info:srw/diagnostic/1/%d", d.Code),
+		Details: d.Details,
+		Message: d.Message,
+	}, xml.StartElement{Name: xml.Name{Local: "diagnostic"}})
+}
+
+type sruRecord struct {
+	Schema   string `xml:"recordSchema"`
+	Packing  string `xml:"recordPacking"`
+	Data     sruRecordData
+	Position int `xml:"recordPosition,omitempty"`
+}
+
+type sruRecordData struct {
+	XMLName xml.Name    `xml:"recordData"`
+	DC      *sruDC      `xml:"srw_dc:dc"`
+	MARC    *marcRecord `xml:"record"`
+	Explain *sruExplain `xml:"explain"`
+}
+
+// sruDC is the Dublin Core description of a book in the SRU container.
+type sruDC struct {
+	NSSRUDC string `xml:"xmlns:srw_dc,attr"`
+	NSDC    string `xml:"xmlns:dc,attr"`
+	dcElements
+}
+
+// marcRecord is the MARC 21 bibliographic record of a book in MARCXML.
+type marcRecord struct {
+	NS            string             `xml:"xmlns,attr"`
+	Leader        string             `xml:"leader"`
+	ControlFields []marcControlField `xml:"controlfield"`
+	DataFields    []marcDataField    `xml:"datafield"`
+}
+
+type marcControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type marcDataField struct {
+	Tag       string         `xml:"tag,attr"`
+	Ind1      string         `xml:"ind1,attr"`
+	Ind2      string         `xml:"ind2,attr"`
+	Subfields []marcSubfield `xml:"subfield"`
+}
+
+type marcSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// sruExplain is the ZeeRex description of the server returned by the explain operation.
+type sruExplain struct {
+	NS         string `xml:"xmlns,attr"`
+	ServerInfo struct {
+		Protocol string `xml:"protocol,attr"`
+		Version  string `xml:"version,attr"`
+		Host     string `xml:"host"`
+		Port     string `xml:"port"`
+		Database string `xml:"database"`
+	} `xml:"serverInfo"`
+	DatabaseInfo struct {
+		Title string `xml:"title"`
+	} `xml:"databaseInfo"`
+	IndexInfo struct {
+		Sets []struct {
+			Name       string `xml:"name,attr"`
+			Identifier string `xml:"identifier,attr"`
+		} `xml:"set"`
+		Indexes []sruIndexInfo `xml:"index"`
+	} `xml:"indexInfo"`
+	SchemaInfo struct {
+		Schemas []struct {
+			Identifier string `xml:"identifier,attr"`
+			Name       string `xml:"name,attr"`
+			Title      string `xml:"title"`
+		} `xml:"schema"`
+	} `xml:"schemaInfo"`
+	ConfigInfo struct {
+		Defaults []sruSetting `xml:"default"`
+		Settings []sruSetting `xml:"setting"`
+	} `xml:"configInfo"`
+}
+
+type sruIndexInfo struct {
+	Title string `xml:"title"`
+	Map   struct {
+		Name struct {
+			Set  string `xml:"set,attr"`
+			Name string `xml:",chardata"`
+		} `xml:"name"`
+	} `xml:"map"`
+}
+
+type sruSetting struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type sruExplainResponse struct {
+	XMLName     xml.Name         `xml:"explainResponse"`
+	NS          string           `xml:"xmlns,attr"`
+	Version     string           `xml:"version"`
+	Record      *sruRecord       `xml:"record"`
+	Diagnostics []*sruDiagnostic `xml:"diagnostics>diagnostic"`
+}
+
+type sruSearchResponse struct {
+	XMLName            xml.Name         `xml:"searchRetrieveResponse"`
+	NS                 string           `xml:"xmlns,attr"`
+	Version            string           `xml:"version"`
+	NumberOfRecords    int              `xml:"numberOfRecords"`
+	Records            []sruRecord      `xml:"records>record"`
+	NextRecordPosition int              `xml:"nextRecordPosition,omitempty"`
+	Diagnostics        []*sruDiagnostic `xml:"diagnostics>diagnostic"`
+}
+
+// sruIndexes maps the supported CQL indexes to the name of the book field they search.
+var sruIndexes = map[string]string{
+	"cql.serverchoice": "anywhere",
+	"cql.anywhere":     "anywhere",
+	"cql.allrecords":   "all",
+	"dc.title":         "title",
+	"title":            "title",
+	"dc.subject":       "subject",
+	"subject":          "subject",
+	"dc.date":          "year",
+	"date":             "year",
+	"year":             "year",
+	"bath.isbn":        "isbn",
+	"isbn":             "isbn",
+	"dc.identifier":    "isbn",
+}
+
+// sruHandler handles the "GET /v1/sru" endpoint, an SRU 1.2 server searching the books of
+// the tenant. The explain operation describes the server; the searchRetrieve operation
+// translates a CQL query into a book filter and returns the matching books in Dublin Core
+// or MARCXML. Queries are limited to clauses joined by "and" over the indexes of sruIndexes.
+func (app *Application) sruHandler(w http.ResponseWriter, r *http.Request) {
+	args := r.URL.Query()
+
+	var resp interface{}
+	switch operation := args.Get("operation"); {
+	case operation == "explain" || operation == "" && !args.Has("query"):
+		resp = app.sruExplain(r, args)
+	case operation == "searchRetrieve" || operation == "":
+		var err error
+		resp, err = app.sruSearch(r, args)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	default:
+		resp = &sruExplainResponse{
+			NS:      "http://www.loc.gov/zing/srw/",
+			Version: "1.2",
+			Diagnostics: []*sruDiagnostic{
+				{Code: 4, Message: "Unsupported operation", Details: operation},
+			},
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(resp); err != nil {
+		app.logError(r, err)
+	}
+}
+
+// sruCheckParameters reports the first unsupported parameter or version, if any.
+func sruCheckParameters(args url.Values) *sruDiagnostic {
+	for name := range args {
+		if !sruParameters[name] && !strings.HasPrefix(name, "x-") {
+			return &sruDiagnostic{Code: 8, Message: "Unsupported parameter", Details: name}
+		}
+	}
+	if version := args.Get("version"); version != "" && version != "1.1" && version != "1.2" {
+		return &sruDiagnostic{Code: 5, Message: "Unsupported version", Details: "1.2"}
+	}
+	if packing := args.Get("recordPacking"); packing != "" && packing != "xml" {
+		return &sruDiagnostic{Code: 71, Message: "Unsupported record packing", Details: packing}
+	}
+	return nil
+}
+
+func (app *Application) sruExplain(r *http.Request, args url.Values) *sruExplainResponse {
+	resp := &sruExplainResponse{NS: "http://www.loc.gov/zing/srw/", Version: "1.2"}
+	if diag := sruCheckParameters(args); diag != nil {
+		resp.Diagnostics = append(resp.Diagnostics, diag)
+	}
+
+	explain := &sruExplain{NS: "http://explain.z3950.org/dtd/2.0/"}
+
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host, port = r.Host, "80"
+		if r.TLS != nil {
+			port = "443"
+		}
+	}
+	explain.ServerInfo.Protocol = "SRU"
+	explain.ServerInfo.Version = "1.2"
+	explain.ServerInfo.Host = host
+	explain.ServerInfo.Port = port
+	explain.ServerInfo.Database = strings.TrimPrefix(r.URL.Path, "/")
+	explain.DatabaseInfo.Title = app.config.oai.repositoryName
+
+	for _, set := range [][2]string{
+		{"cql", "info:srw/cql-context-set/1/cql-v1.2"},
+		{"dc", "info:srw/cql-context-set/1/dc-v1.1"},
+		{"bath", "http://zing.z3950.org/cql/bath/2.0/"},
+	} {
+		explain.IndexInfo.Sets = append(explain.IndexInfo.Sets, struct {
+			Name       string `xml:"name,attr"`
+			Identifier string `xml:"identifier,attr"`
+		}{set[0], set[1]})
+	}
+	for _, index := range [][2]string{
+		{"cql", "serverChoice"}, {"cql", "allRecords"}, {"dc", "title"},
+		{"dc", "subject"}, {"dc", "date"}, {"dc", "identifier"}, {"bath", "isbn"},
+	} {
+		var info sruIndexInfo
+		info.Title = index[1]
+		info.Map.Name.Set, info.Map.Name.Name = index[0], index[1]
+		explain.IndexInfo.Indexes = append(explain.IndexInfo.Indexes, info)
+	}
+
+	for _, schema := range [][3]string{
+		{"info:srw/schema/1/dc-v1.1", "dc", "Dublin Core"},
+		{"info:srw/schema/1/marcxml-v1.1", "marcxml", "MARC 21 in MARCXML"},
+	} {
+		explain.SchemaInfo.Schemas = append(explain.SchemaInfo.Schemas, struct {
+			Identifier string `xml:"identifier,attr"`
+			Name       string `xml:"name,attr"`
+			Title      string `xml:"title"`
+		}{schema[0], schema[1], schema[2]})
+	}
+
+	explain.ConfigInfo.Defaults = []sruSetting{
+		{Type: "numberOfRecords", Value: strconv.Itoa(sruDefaultRecords)},
+		{Type: "retrieveSchema", Value: "info:srw/schema/1/dc-v1.1"},
+	}
+	explain.ConfigInfo.Settings = []sruSetting{
+		{Type: "maximumRecords", Value: strconv.Itoa(sruMaxRecords)},
+	}
+
+	resp.Record = &sruRecord{
+		Schema:  "http://explain.z3950.org/dtd/2.0/",
+		Packing: "xml",
+		Data:    sruRecordData{Explain: explain},
+	}
+	return resp
+}
+
+// sruSearch runs the searchRetrieve operation. Protocol errors are reported in the
+// response, only server errors are returned.
+func (app *Application) sruSearch(r *http.Request, args url.Values) (*sruSearchResponse, error) {
+	resp := &sruSearchResponse{NS: "http://www.loc.gov/zing/srw/", Version: "1.2"}
+	fail := func(diag *sruDiagnostic) (*sruSearchResponse, error) {
+		resp.Diagnostics = append(resp.Diagnostics, diag)
+		return resp, nil
+	}
+
+	if diag := sruCheckParameters(args); diag != nil {
+		return fail(diag)
+	}
+	if !args.Has("query") {
+		return fail(&sruDiagnostic{Code: 7, Message: "Mandatory parameter not supplied", Details: "query"})
+	}
+
+	start, max := 1, sruDefaultRecords
+	if s := args.Get("startRecord"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return fail(&sruDiagnostic{Code: 6, Message: "Unsupported parameter value", Details: "startRecord"})
+		}
+		start = n
+	}
+	if s := args.Get("maximumRecords"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return fail(&sruDiagnostic{Code: 6, Message: "Unsupported parameter value", Details: "maximumRecords"})
+		}
+		max = min(n, sruMaxRecords)
+	}
+
+	schema := sruSchemas["dc"]
+	if s := args.Get("recordSchema"); s != "" {
+		var ok bool
+		if schema, ok = sruSchemas[s]; !ok {
+			return fail(&sruDiagnostic{Code: 66, Message: "Unknown schema for retrieval", Details: s})
+		}
+	}
+
+	query, err := cql.Parse(args.Get("query"))
+	if err != nil {
+		var syntaxErr *cql.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return fail(&sruDiagnostic{Code: 10, Message: "Query syntax error", Details: syntaxErr.Msg})
+		}
+		return nil, err
+	}
+
+	var filter data.BookFilter
+	if diag := sruFilter(query, &filter); diag != nil {
+		return fail(diag)
+	}
+	filter.Genres, err = app.resolveGenres(r, filter.Genres)
+	if err != nil {
+		return nil, err
+	}
+
+	books, total, err := sruPage(app.books(r), filter, start, max)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.NumberOfRecords = total
+	if start > total && total > 0 && max > 0 {
+		return fail(&sruDiagnostic{Code: 61, Message: "First record position out of range", Details: strconv.Itoa(start)})
+	}
+
+	for i, book := range books {
+		record := sruRecord{Schema: schema, Packing: "xml", Position: start + i}
+		if schema == sruSchemas["marcxml"] {
+			record.Data.MARC = bookMARC(book, app.config.oai.repositoryID)
+		} else {
+			record.Data.DC = &sruDC{
+				NSSRUDC:    "info:srw/schema/1/dc-schema",
+				NSDC:       "http://purl.org/dc/elements/1.1/",
+				dcElements: bookDC(book, app.oaiHeader(book).Identifier),
+			}
+		}
+		resp.Records = append(resp.Records, record)
+	}
+	if next := start + len(books); len(books) > 0 && next <= total {
+		resp.NextRecordPosition = next
+	}
+
+	return resp, nil
+}
+
+// sruFilter adds the conditions of the query to the filter, or returns a diagnostic if
+// the query cannot be expressed as a filter.
+func sruFilter(node cql.Node, filter *data.BookFilter) *sruDiagnostic {
+	switch node := node.(type) {
+	case *cql.Boolean:
+		if node.Op != "and" {
+			return &sruDiagnostic{Code: 37, Message: "Unsupported boolean operator", Details: node.Op}
+		}
+		if diag := sruFilter(node.Left, filter); diag != nil {
+			return diag
+		}
+		return sruFilter(node.Right, filter)
+
+	case *cql.Clause:
+		field, ok := sruIndexes[node.Index]
+		if !ok {
+			return &sruDiagnostic{Code: 16, Message: "Unsupported index", Details: node.Index}
+		}
+		unsupportedRelation := &sruDiagnostic{Code: 19, Message: "Unsupported relation", Details: node.Relation}
+
+		switch field {
+		case "all":
+			return nil
+
+		case "anywhere":
+			// free text is a web search query, where words are all required unless joined
+			// by "or", and quoted words form a phrase.
+			term := node.Term
+			switch node.Relation {
+			case "=", "all":
+			case "any":
+				term = strings.Join(strings.Fields(term), " or ")
+			case "adj", "==":
+				term = `"` + strings.ReplaceAll(term, `"`, "") + `"`
+			default:
+				return unsupportedRelation
+			}
+			filter.Query = strings.TrimSpace(filter.Query + " " + term)
+
+		case "title":
+			if node.Relation != "=" && node.Relation != "all" {
+				return unsupportedRelation
+			}
+			filter.Title = strings.TrimSpace(filter.Title + " " + node.Term)
+
+		case "subject":
+			if node.Relation != "=" && node.Relation != "==" {
+				return unsupportedRelation
+			}
+			filter.Genres = append(filter.Genres, node.Term)
+
+		case "isbn":
+			if node.Relation != "=" && node.Relation != "==" {
+				return unsupportedRelation
+			}
+			isbn := data.NormalizeISBN(strings.TrimPrefix(strings.ToLower(node.Term), "urn:isbn:"))
+			if filter.ISBN != "" && filter.ISBN != isbn {
+				// no book has two ISBNs, so search for one that none has.
+				isbn = "-"
+			}
+			filter.ISBN = isbn
+
+		case "year":
+			return sruYearFilter(node, filter)
+		}
+		return nil
+	}
+
+	return &sruDiagnostic{Code: 10, Message: "Query syntax error"}
+}
+
+// sruYearFilter narrows the year bounds of the filter to the years matching the clause.
+func sruYearFilter(clause *cql.Clause, filter *data.BookFilter) *sruDiagnostic {
+	var years []int32
+	for _, field := range strings.Fields(clause.Term) {
+		year, err := strconv.ParseInt(field, 10, 32)
+		if err != nil || year < 1 {
+			return &sruDiagnostic{Code: 36, Message: "Term in invalid format for index or relation", Details: clause.Term}
+		}
+		years = append(years, int32(year))
+	}
+	if len(years) != 1 && (clause.Relation != "within" || len(years) != 2) {
+		return &sruDiagnostic{Code: 36, Message: "Term in invalid format for index or relation", Details: clause.Term}
+	}
+
+	from, to := years[0], years[len(years)-1]
+	switch clause.Relation {
+	case "=", "==", "within":
+	case ">=":
+		to = 0
+	case ">":
+		from, to = from+1, 0
+	case "<=":
+		from = 0
+	case "<":
+		from, to = 0, to-1
+	default:
+		return &sruDiagnostic{Code: 19, Message: "Unsupported relation", Details: clause.Relation}
+	}
+
+	if from != 0 && from > filter.MinYear {
+		filter.MinYear = from
+	}
+	if to != 0 && (filter.MaxYear == 0 || to < filter.MaxYear) {
+		filter.MaxYear = to
+	}
+	return nil
+}
+
+// sruPage returns max books matching the filter from the 1-based start position, and the
+// number of matching books. Positions not aligned on a page of max books are read from two
+// pages.
+func sruPage(books data.BookModel, filter data.BookFilter, start, max int) ([]*data.Book, int, error) {
+	filters := data.Filters{Page: 1, PageSize: max, Sort: "id", SortSafelist: []string{"id"}}
+	if max == 0 {
+		// only the number of records is asked for.
+		filters.PageSize = 1
+		_, meta, _, err := books.Search(filter, filters)
+		return nil, meta.TotalRecords, err
+	}
+
+	offset := start - 1
+	filters.Page = offset/max + 1
+	page, meta, _, err := books.Search(filter, filters)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if skip := offset % max; skip > 0 && len(page) == max {
+		filters.Page++
+		next, _, _, err := books.Search(filter, filters)
+		if err != nil {
+			return nil, 0, err
+		}
+		page = append(page[skip:], next[:min(skip, len(next))]...)
+	} else if skip > 0 {
+		page = page[min(skip, len(page)):]
+	}
+
+	return page, meta.TotalRecords, nil
+}
+
+// bookMARC maps the book to a MARC 21 bibliographic record: the ISBN to field 020, the
+// title to 245, the year to 264, the pages to 300, the description to 520 and the genres
+// to uncontrolled subject headings in 650. The record is identified by the book ID in 001
+// under the repository identifier in 003.
+func bookMARC(book *data.Book, repositoryID string) *marcRecord {
+	field := func(tag, ind1, ind2 string, subfields ...marcSubfield) marcDataField {
+		return marcDataField{Tag: tag, Ind1: ind1, Ind2: ind2, Subfields: subfields}
+	}
+
+	// 008 holds the date the record was entered, the publication year if known, and
+	// fill characters for the coded fields we know nothing about.
+	dates := "n        "
+	if book.Year != nil {
+		dates = fmt.Sprintf("s%04d    ", *book.Year)
+	}
+	fixed := book.Created.UTC().Format("060102") + dates + "xx " + strings.Repeat("|", 17) + "und d"
+
+	record := &marcRecord{
+		NS:     "http://www.loc.gov/MARC21/slim",
+		Leader: "00000nam a2200000 i 4500",
+		ControlFields: []marcControlField{
+			{Tag: "001", Value: strconv.FormatInt(book.ID, 10)},
+			{Tag: "003", Value: repositoryID},
+			{Tag: "005", Value: book.Updated.UTC().Format("20060102150405.0")},
+			{Tag: "008", Value: fixed},
+		},
+	}
+
+	if book.ISBN != "" {
+		record.DataFields = append(record.DataFields, field("020", " ", " ", marcSubfield{"a", book.ISBN}))
+	}
+	record.DataFields = append(record.DataFields, field("245", "0", "0", marcSubfield{"a", book.Title}))
+	if book.Year != nil {
+		record.DataFields = append(record.DataFields, field("264", " ", "1", marcSubfield{"c", strconv.Itoa(int(*book.Year))}))
+	}
+	if book.Pages != nil {
+		record.DataFields = append(record.DataFields, field("300", " ", " ", marcSubfield{"a", fmt.Sprintf("%d pages", *book.Pages)}))
+	}
+	if description, ok := book.Metadata["description"].(string); ok {
+		record.DataFields = append(record.DataFields, field("520", " ", " ", marcSubfield{"a", description}))
+	}
+	for _, genre := range book.Genres {
+		record.DataFields = append(record.DataFields, field("650", " ", "4", marcSubfield{"a", genre}))
+	}
+
+	return record
+}
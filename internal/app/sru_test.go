@@ -0,0 +1,110 @@
+package app
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/cql"
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+func TestSRUHandler(t *testing.T) {
+	app := &Application{}
+	app.config.oai.repositoryName = "Example Library"
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "<title>Example Library</title>"},
+		{"operation=explain&version=1.2", `<schema identifier="info:srw/schema/1/marcxml-v1.1" name="marcxml">`},
+		{"operation=scan", "<uri>info:srw/diagnostic/1/4</uri>"},
+		{"operation=searchRetrieve&version=2.0&query=dune", "<uri>info:srw/diagnostic/1/5</uri>"},
+		{"operation=searchRetrieve&sortKeys=title", "<uri>info:srw/diagnostic/1/8</uri><details>sortKeys</details>"},
+		{"operation=searchRetrieve", "<uri>info:srw/diagnostic/1/7</uri><details>query</details>"},
+		{"query=dune&startRecord=0", "<uri>info:srw/diagnostic/1/6</uri><details>startRecord</details>"},
+		{"query=dune&recordSchema=mods", "<uri>info:srw/diagnostic/1/66</uri>"},
+		{"query=" + url.QueryEscape(`dc.title = "dune`), "<uri>info:srw/diagnostic/1/10</uri>"},
+		{"query=" + url.QueryEscape("dc.creator = herbert"), "<uri>info:srw/diagnostic/1/16</uri><details>dc.creator</details>"},
+		{"query=" + url.QueryEscape("dune or messiah"), "<uri>info:srw/diagnostic/1/37</uri>"},
+		{"query=" + url.QueryEscape("dc.date = sixties"), "<uri>info:srw/diagnostic/1/36</uri>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/v1/sru?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			app.sruHandler(w, r)
+
+			if w.Code != 200 || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("want %d response containing %s, got %d: %s", 200, tt.want, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestSRUFilter(t *testing.T) {
+	query, err := cql.Parse(`dune and cql.serverChoice any "messiah children" and dc.title = "dune" and dc.subject == sci-fi and dc.date within "1960 1980" and dc.date > 1964 and bath.isbn = 978-0-441-01359-3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var filter data.BookFilter
+	if diag := sruFilter(query, &filter); diag != nil {
+		t.Fatalf("unexpected diagnostic %+v", diag)
+	}
+
+	want := data.BookFilter{
+		Query:   "dune messiah or children",
+		Title:   "dune",
+		Genres:  []string{"sci-fi"},
+		MinYear: 1965,
+		MaxYear: 1980,
+		ISBN:    "9780441013593",
+	}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("want %+v, got %+v", want, filter)
+	}
+}
+
+func TestBookMARC(t *testing.T) {
+	year, pages := int32(1965), data.Pages(412)
+	book := &data.Book{
+		ID:      7,
+		Title:   "Dune",
+		Year:    &year,
+		Pages:   &pages,
+		Genres:  []string{"sci-fi"},
+		ISBN:    "9780441013593",
+		Created: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Updated: time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC),
+	}
+
+	record := bookMARC(book, "library.example.org")
+	if len(record.ControlFields[3].Value) != 40 {
+		t.Errorf("want a 40 characters 008 field, got %q", record.ControlFields[3].Value)
+	}
+
+	b, err := xml.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<controlfield tag="001">7</controlfield><controlfield tag="003">library.example.org</controlfield><controlfield tag="005">20240302120000.0</controlfield><controlfield tag="008">240301s1965    xx `,
+		`<datafield tag="020" ind1=" " ind2=" "><subfield code="a">9780441013593</subfield></datafield>`,
+		`<datafield tag="245" ind1="0" ind2="0"><subfield code="a">Dune</subfield></datafield>`,
+		`<datafield tag="264" ind1=" " ind2="1"><subfield code="c">1965</subfield></datafield>`,
+		`<datafield tag="300" ind1=" " ind2=" "><subfield code="a">412 pages</subfield></datafield>`,
+		`<datafield tag="650" ind1=" " ind2="4"><subfield code="a">sci-fi</subfield></datafield>`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("want record containing %s, got %s", want, b)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// maxTrendMonths is the longest window of genre trends, in months.
+const maxTrendMonths = 60
+
+// genreTrendsHandler handles the "GET /v1/stats/genres/trends" endpoint and returns the
+// number of books added to every genre month by month over a window, from the month "from"
+// to the month "to" inclusive, by default the last 12 months. The "genres" parameter limits
+// the response to the given genres.
+func (app *Application) genreTrendsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	now := time.Now().UTC()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	until := app.readMonth(qs, "to", thisMonth, v)
+	from := app.readMonth(qs, "from", until.AddDate(0, -11, 0), v)
+	genres := app.readCSV(qs, "genres", nil)
+
+	months := (until.Year()-from.Year())*12 + int(until.Month()-from.Month()) + 1
+	v.Check(months >= 1, "from", "must not be after to")
+	v.Check(months <= maxTrendMonths, "from", fmt.Sprintf("must not be more than %d months before to", maxTrendMonths))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	trends, err := app.books(r).GenreTrends(from, until)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if genres != nil {
+		selected := []data.GenreTrend{}
+		for _, trend := range trends {
+			if validator.In(trend.Genre, genres...) {
+				selected = append(selected, trend)
+			}
+		}
+		trends = selected
+	}
+
+	window := map[string]string{"from": from.Format(data.MonthFormat), "to": until.Format(data.MonthFormat)}
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"trends": trends, "window": window}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,26 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGenreTrendsValidation(t *testing.T) {
+	app := newTestApp()
+
+	tests := map[string]string{
+		"?from=2024-13":              "must be a month in the form YYYY-MM",
+		"?from=2024-06&to=2024-05":   "must not be after to",
+		"?from=2010-01&to=2024-12":   "must not be more than 60 months before to",
+		"?from=2024-01&to=yesterday": "must be a month in the form YYYY-MM",
+	}
+	for query, want := range tests {
+		w := httptest.NewRecorder()
+		app.genreTrendsHandler(w, httptest.NewRequest(http.MethodGet, "/v1/stats/genres/trends"+query, nil))
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: want %d with %q, got %d %s", query, http.StatusUnprocessableEntity, want, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// flushUsage adds the views and search impressions of books counted since the last run to
+// the database every usage flush interval until the server shuts down. The counts left then
+// are written by completeBackgroundTasks. Counts which cannot be written are kept for the
+// next run; those of the last run are lost.
+func (app *Application) flushUsage() {
+	if app.usage == nil {
+		return
+	}
+
+	ticker := time.NewTicker(app.config.usageFlushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Run on the worker pool so shutdown waits for it instead of closing the database under it.
+		app.background("usage flush", app.flushUsageRun)
+
+		if app.shuttingDown.Load() {
+			return
+		}
+	}
+}
+
+// completeBackgroundTasks writes the views and search impressions counted since the last
+// usage flush, then waits for the background tasks to complete until ctx is done.
+func (app *Application) completeBackgroundTasks(ctx context.Context) error {
+	if app.usage != nil {
+		app.flushUsageRun()
+	}
+	return app.worker.Shutdown(ctx)
+}
+
+// flushUsageRun adds the counted views and search impressions to the books.
+func (app *Application) flushUsageRun() {
+	usage := app.usage.Take()
+	if err := app.models.Books.AddUsage(usage); err != nil {
+		app.usage.Restore(usage)
+		app.logger.PrintError(err, map[string]string{"job": "usage flush", "books": strconv.Itoa(len(usage))})
+	}
+}
+
+// bookUsageHandler handles the "GET /v1/stats/books/usage" endpoint and returns the books
+// viewed most, or shown most in search results with sort=impressions, up to "limit" books.
+// Counts reach the database every usage flush interval, and views of books served from
+// HTTP caches are not counted.
+func (app *Application) bookUsageHandler(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Sort  string `query:"sort" validate:"oneof=views impressions"`
+		Limit int    `query:"limit" validate:"gt=0,max=100"`
+	}{Sort: "views", Limit: 20}
+
+	v := validator.New()
+	app.readQuery(r.URL.Query(), &input, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	usage, err := app.books(r).Usage(input.Sort, input.Limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"usage": usage}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,349 @@
+package app
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/events"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+const (
+	// webhookMaxAttempts is the number of times a delivery is attempted before giving up.
+	webhookMaxAttempts = 5
+	// webhookBaseDelay is the wait before the first retry, doubled for every further one.
+	webhookBaseDelay = 2 * time.Second
+	// webhookRetryInterval is the time between the checks for deliveries due for a retry.
+	webhookRetryInterval = time.Second
+	// webhookRetryBatch is the maximum number of deliveries claimed by a check.
+	webhookRetryBatch = 100
+	// webhookLease is how long a claimed delivery is left to the instance attempting it
+	// before another one may claim it. It must be longer than the webhook client timeout.
+	webhookLease = time.Minute
+)
+
+// webhookClient sends the webhook requests. Receivers are expected to answer quickly
+// and process the event asynchronously.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// createWebhookHandler handles the "POST /v1/webhooks" endpoint. It registers a URL for the
+// given event types and returns the webhook record along with the secret its deliveries are
+// signed with, which is not shown again.
+func (app *Application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	err := app.readJSON(w, r, &in)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+
+	webhook := &data.Webhook{
+		URL:    in.URL,
+		Events: in.Events,
+		Secret: hex.EncodeToString(secret),
+	}
+
+	v := validator.New()
+	if data.ValidateWebhook(v, webhook); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.webhooks(r).Insert(webhook)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/webhooks/%d", webhook.ID))
+	err = app.writeJSON(w, r, http.StatusCreated, wrapper{"webhook": webhook, "secret": webhook.Secret}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listWebhooksHandler handles the "GET /v1/webhooks" endpoint and returns the registered webhooks.
+func (app *Application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := app.webhooks(r).GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"webhooks": webhooks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteWebhookHandler handles the "DELETE /v1/webhooks/:id" endpoint and stops the
+// deliveries to the webhook.
+func (app *Application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.webhooks(r).Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"message": "webhook successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listWebhookDeliveriesHandler handles the "GET /v1/webhooks/:id/deliveries" endpoint and
+// returns the delivery log of the webhook, newest first.
+func (app *Application) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readID(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	filters := data.Filters{Page: 1, PageSize: 20}
+
+	v := validator.New()
+	app.readQuery(r.URL.Query(), &filters, v)
+	filters.Sort = "-id"
+	filters.SortSafelist = []string{"-id"}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	deliveries, meta, err := app.webhooks(r).GetDeliveries(id, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, wrapper{"deliveries": deliveries, "metadata": app.withPageURLs(r, meta)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// webhooks returns the webhook model scoped to the tenant of the request.
+func (app *Application) webhooks(r *http.Request) data.WebhookModel {
+	return app.models.Webhooks.ForTenant(app.contextGetTenant(r))
+}
+
+// dispatchWebhooks delivers the book events to the webhooks subscribed to them until the
+// event subscription is cancelled.
+func (app *Application) dispatchWebhooks() {
+	ch, cancel := app.events.Subscribe(256)
+	defer cancel()
+
+	for e := range ch {
+		if e.Type == events.Resync {
+			app.logger.PrintWarn("webhook events may have been lost", nil)
+			continue
+		}
+
+		webhooks, err := app.models.Webhooks.ForTenant(e.Tenant).ForEvent(e.Type)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"event": e.Type})
+			continue
+		}
+		if len(webhooks) == 0 {
+			continue
+		}
+
+		payload, err := app.eventPayload(e)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"event": e.Type})
+			continue
+		}
+
+		for _, webhook := range webhooks {
+			// The first attempt is made right away; the lease keeps retryWebhooks from
+			// claiming the delivery while it runs.
+			lease := time.Now().Add(webhookLease)
+			delivery := &data.WebhookDelivery{
+				WebhookID:   webhook.ID,
+				Event:       e.Type,
+				EventKey:    fmt.Sprintf("%s:%d:%d", e.Type, e.BookID, e.Version),
+				Payload:     payload,
+				NextAttempt: &lease,
+			}
+
+			// Another instance may have claimed the delivery already.
+			claimed, err := app.models.Webhooks.InsertDelivery(delivery)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"webhook_id": strconv.FormatInt(webhook.ID, 10)})
+				continue
+			}
+			if claimed {
+				app.background("webhook delivery", func() {
+					app.deliverWebhook(webhook, delivery)
+				})
+			}
+		}
+	}
+}
+
+// eventPayload returns the JSON body sent to webhooks and the message broker for the event.
+// The current state of the book is included for created and updated books if it can still
+// be read.
+func (app *Application) eventPayload(e events.Event) ([]byte, error) {
+	payload := wrapper{
+		"event":      e.Type,
+		"tenant":     e.Tenant,
+		"book_id":    e.BookID,
+		"version":    e.Version,
+		"created_at": e.Time,
+	}
+
+	if e.Type != events.BookDeleted {
+		book, err := app.models.Books.ForTenant(e.Tenant).Get(e.BookID)
+		switch {
+		case err == nil:
+			payload["book"] = book
+		case !errors.Is(err, data.ErrRecordNotFound):
+			return nil, err
+		}
+	}
+
+	return json.Marshal(payload)
+}
+
+// retryWebhooks attempts the deliveries due for a retry every webhook retry interval until
+// the server shuts down. The retries are kept in the database, so they survive restarts and
+// are shared between the API instances.
+func (app *Application) retryWebhooks() {
+	ticker := time.NewTicker(webhookRetryInterval)
+	defer ticker.Stop()
+
+	// skip the ticks while a run is still claiming deliveries.
+	var running atomic.Bool
+
+	for range ticker.C {
+		if app.shuttingDown.Load() {
+			return
+		}
+		if !running.CompareAndSwap(false, true) {
+			continue
+		}
+
+		// Run on the worker pool so shutdown waits for it instead of closing the database under it.
+		app.background("webhook retries", func() {
+			defer running.Store(false)
+			app.retryWebhooksRun()
+		})
+	}
+}
+
+// retryWebhooksRun claims the deliveries due for a retry and attempts them in the background.
+func (app *Application) retryWebhooksRun() {
+	pending, err := app.models.Webhooks.ClaimDeliveries(webhookRetryBatch, webhookLease)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"job": "webhook retries"})
+		return
+	}
+
+	for _, p := range pending {
+		app.background("webhook delivery", func() {
+			app.deliverWebhook(p.Webhook, p.Delivery)
+		})
+	}
+}
+
+// deliverWebhook makes one attempt to deliver the event and logs the outcome. Failed
+// attempts are scheduled for a retry with exponential backoff until webhookMaxAttempts is
+// reached, and made by retryWebhooks.
+func (app *Application) deliverWebhook(webhook *data.Webhook, delivery *data.WebhookDelivery) {
+	delivery.Attempts++
+	delivery.StatusCode = nil
+	delivery.Error = nil
+	delivery.NextAttempt = nil
+
+	status, err := sendWebhook(webhook, delivery)
+	if status != 0 {
+		delivery.StatusCode = &status
+	}
+	if err != nil {
+		msg := err.Error()
+		delivery.Error = &msg
+		if delivery.Attempts < webhookMaxAttempts {
+			next := time.Now().Add(webhookBaseDelay << (delivery.Attempts - 1))
+			delivery.NextAttempt = &next
+		}
+	} else {
+		now := time.Now()
+		delivery.Delivered = &now
+	}
+
+	if err := app.models.Webhooks.UpdateDelivery(delivery); err != nil {
+		app.logger.PrintError(err, map[string]string{"delivery_id": strconv.FormatInt(delivery.ID, 10)})
+	}
+
+	if err != nil && delivery.Attempts >= webhookMaxAttempts {
+		key := fmt.Sprintf("%s:%d", chatWebhookFailed, delivery.ID)
+		app.notifyChat(chatWebhookFailed, key, map[string]interface{}{"Webhook": webhook, "Delivery": delivery})
+	}
+}
+
+// sendWebhook posts the payload of the delivery to the webhook URL. The body is signed with
+// HMAC-SHA256 using the secret of the webhook over "<timestamp>.<body>", so receivers can
+// verify the sender and reject replayed requests. Responses other than 2xx are errors.
+func sendWebhook(webhook *data.Webhook, delivery *data.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(delivery.Payload)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "LibraryAPI-Webhooks/"+build.Version)
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
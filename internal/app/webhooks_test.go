@@ -0,0 +1,39 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+func TestSendWebhook(t *testing.T) {
+	var signature, timestamp string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Webhook-Signature")
+		timestamp = r.Header.Get("X-Webhook-Timestamp")
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	webhook := &data.Webhook{URL: srv.URL, Secret: "secret"}
+	delivery := &data.WebhookDelivery{ID: 1, Event: "book.created", Payload: []byte(`{"book_id":1}`)}
+
+	status, err := sendWebhook(webhook, delivery)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("got status %d, error %v", status, err)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("want signature %q, got %q", want, signature)
+	}
+}
@@ -0,0 +1,76 @@
+// Package chat posts notifications to Slack or Discord channels through their incoming
+// webhooks.
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Webhook is the incoming webhook of a chat channel.
+type Webhook struct {
+	url    string
+	field  string
+	limit  int
+	client *http.Client
+}
+
+// New returns the incoming webhook at rawURL of the given kind, slack or discord.
+func New(kind, rawURL string) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("chat: unsupported webhook URL scheme %q", u.Scheme)
+	}
+
+	w := &Webhook{url: u.String(), client: &http.Client{Timeout: 10 * time.Second}}
+	switch kind {
+	case "slack":
+		// Slack truncates longer messages itself.
+		w.field, w.limit = "text", 40000
+	case "discord":
+		// Discord rejects messages over 2000 characters.
+		w.field, w.limit = "content", 2000
+	default:
+		return nil, fmt.Errorf("chat: unknown chat %q", kind)
+	}
+	return w, nil
+}
+
+// Notify posts the message to the channel, shortened to the length the chat accepts.
+func (w *Webhook) Notify(ctx context.Context, text string) error {
+	if runes := []rune(text); len(runes) > w.limit {
+		text = string(runes[:w.limit-1]) + "…"
+	}
+
+	body, err := json.Marshal(map[string]string{w.field: text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("chat: webhook responded with %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
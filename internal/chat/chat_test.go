@@ -0,0 +1,53 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotify(t *testing.T) {
+	var got map[string]string
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+		w.Write([]byte("invalid_payload"))
+	}))
+	defer srv.Close()
+
+	slack, err := New("slack", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := slack.Notify(context.Background(), "New book: Dune"); err != nil {
+		t.Fatal(err)
+	}
+	if got["text"] != "New book: Dune" {
+		t.Errorf("want the Slack text field, got %v", got)
+	}
+
+	discord, err := New("discord", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := discord.Notify(context.Background(), strings.Repeat("é", 3000)); err != nil {
+		t.Fatal(err)
+	}
+	if n := len([]rune(got["content"])); n != 2000 {
+		t.Errorf("want the Discord content shortened to 2000 characters, got %d", n)
+	}
+
+	status = http.StatusBadRequest
+	if err := slack.Notify(context.Background(), "x"); err == nil || !strings.Contains(err.Error(), "invalid_payload") {
+		t.Errorf("want an error with the response, got %v", err)
+	}
+
+	if _, err := New("teams", srv.URL); err == nil {
+		t.Error("want an error for an unknown chat")
+	}
+}
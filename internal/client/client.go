@@ -0,0 +1,184 @@
+// Package client is a client of the books endpoints of the HTTP API, used by the command
+// line tools. It asks for bare responses, so it doesn't depend on the configured names of
+// the envelope members.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+)
+
+// Client sends requests to the API at BaseURL, e.g. "http://localhost:4000".
+type Client struct {
+	BaseURL string
+	// Token is sent as a bearer token, if not empty.
+	Token string
+	// APIKey is sent in the APIKeyHeader, if not empty, for servers enforcing quotas.
+	APIKey       string
+	APIKeyHeader string
+	// Tenant is sent in the TenantHeader, if not empty, for servers in multi-tenant mode.
+	Tenant       string
+	TenantHeader string
+	HTTPClient   *http.Client
+}
+
+// New returns a client of the API at baseURL.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		APIKeyHeader: "X-API-Key",
+		TenantHeader: "X-Tenant-ID",
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Error is an error response of the API. Message is the error message, or the messages by
+// field of a failed validation.
+type Error struct {
+	Status  int
+	Message interface{}
+}
+
+func (e *Error) Error() string {
+	if errors, ok := e.Message.(map[string]interface{}); ok {
+		fields := make([]string, 0, len(errors))
+		for field, message := range errors {
+			fields = append(fields, fmt.Sprintf("%s %v", field, message))
+		}
+		sort.Strings(fields)
+		return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), strings.Join(fields, ", "))
+	}
+	return fmt.Sprintf("%d %s: %v", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// BookInput holds the fields of a book set by clients.
+type BookInput struct {
+	Title    string          `json:"title"`
+	Language string          `json:"language,omitempty"`
+	Titles   data.Titles     `json:"titles,omitempty"`
+	Year     *int32          `json:"year,omitempty"`
+	Pages    *data.Pages     `json:"pages,omitempty"`
+	Genres   []string        `json:"genres"`
+	ISBN     string          `json:"isbn,omitempty"`
+	Metadata data.Attributes `json:"metadata,omitempty"`
+}
+
+// Input returns the fields of the book set by clients, e.g. to create a copy of an exported book.
+func Input(book *data.Book) BookInput {
+	return BookInput{
+		Title:    book.Title,
+		Language: book.Language,
+		Titles:   book.Titles,
+		Year:     book.Year,
+		Pages:    book.Pages,
+		Genres:   book.Genres,
+		ISBN:     book.ISBN,
+		Metadata: book.Metadata,
+	}
+}
+
+// ListBooks returns a page of the books matching the query, such as
+// "title=dune&genres=sci-fi&page=2&page_size=50".
+func (c *Client) ListBooks(ctx context.Context, query url.Values) ([]*data.Book, error) {
+	var books []*data.Book
+	_, err := c.do(ctx, http.MethodGet, "/v1/books?"+query.Encode(), nil, &books)
+	return books, err
+}
+
+// GetBook returns the book of the ID.
+func (c *Client) GetBook(ctx context.Context, id int64) (*data.Book, error) {
+	var book data.Book
+	_, err := c.do(ctx, http.MethodGet, "/v1/books/"+strconv.FormatInt(id, 10), nil, &book)
+	return &book, err
+}
+
+// CreateBook creates a book and returns it.
+func (c *Client) CreateBook(ctx context.Context, in BookInput) (*data.Book, error) {
+	var book data.Book
+	_, err := c.do(ctx, http.MethodPost, "/v1/books", in, &book)
+	return &book, err
+}
+
+// UpsertBook creates the book of the ISBN of in, or replaces it, and returns it. It reports
+// whether the book was created.
+func (c *Client) UpsertBook(ctx context.Context, in BookInput) (*data.Book, bool, error) {
+	isbn := in.ISBN
+	in.ISBN = ""
+
+	var book data.Book
+	status, err := c.do(ctx, http.MethodPut, "/v1/books/isbn/"+url.PathEscape(isbn), in, &book)
+	return &book, status == http.StatusCreated, err
+}
+
+// DeleteBook deletes the book of the ID.
+func (c *Client) DeleteBook(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodDelete, "/v1/books/"+strconv.FormatInt(id, 10), nil, nil)
+	return err
+}
+
+// do sends the request with body encoded as JSON, unless it is nil, and decodes the JSON
+// response into dst, unless it is nil. It returns the status of the response, and an
+// *Error for responses with an error status.
+func (c *Client) do(ctx context.Context, method, path string, body, dst interface{}) (int, error) {
+	var r io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		r = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, r)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json; envelope=bare")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.APIKey != "" {
+		req.Header.Set(c.APIKeyHeader, c.APIKey)
+	}
+	if c.Tenant != "" {
+		req.Header.Set(c.TenantHeader, c.Tenant)
+	}
+
+	rs, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode >= http.StatusBadRequest {
+		// error responses have a single member, the error, whatever its configured name.
+		var wrap map[string]interface{}
+		if err := json.NewDecoder(rs.Body).Decode(&wrap); err != nil || len(wrap) != 1 {
+			return rs.StatusCode, &Error{Status: rs.StatusCode, Message: http.StatusText(rs.StatusCode)}
+		}
+		for _, message := range wrap {
+			return rs.StatusCode, &Error{Status: rs.StatusCode, Message: message}
+		}
+	}
+
+	if dst != nil {
+		if err := json.NewDecoder(rs.Body).Decode(dst); err != nil {
+			return rs.StatusCode, fmt.Errorf("decode response of %s %s: %w", method, path, err)
+		}
+	}
+	return rs.StatusCode, nil
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" || r.Header.Get("X-Tenant-ID") != "acme" || r.Header.Get("X-API-Key") != "lib_key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error": "invalid or missing authentication token"}`)
+			return
+		}
+		if r.Header.Get("Accept") != "application/json; envelope=bare" {
+			t.Errorf("want a bare response asked for, got Accept %q", r.Header.Get("Accept"))
+		}
+
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/books/1":
+			io.WriteString(w, `{"id": 1, "title": "Dune", "year": 1965, "pages": "412 pages", "genres": ["sci-fi"], "version": 1}`)
+		case "PUT /v1/books/isbn/9780441013593":
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"id": 2, "title": "Dune", "isbn": "9780441013593", "version": 1}`)
+		case "POST /v1/books":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, `{"problem": {"title": "must be provided", "genres": "must be provided"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL + "/")
+	c.Token = "s3cret"
+	c.Tenant = "acme"
+	c.APIKey = "lib_key"
+	ctx := context.Background()
+
+	book, err := c.GetBook(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Title != "Dune" || book.Pages == nil || *book.Pages != 412 {
+		t.Errorf("want the book decoded, got %+v", book)
+	}
+
+	book, created, err := c.UpsertBook(ctx, BookInput{Title: "Dune", ISBN: "9780441013593"})
+	if err != nil || !created || book.ID != 2 {
+		t.Errorf("want the book created by its ISBN, got %+v, %t, %v", book, created, err)
+	}
+
+	_, err = c.CreateBook(ctx, BookInput{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("want a validation error, got %v", err)
+	}
+	if want := "422 Unprocessable Entity: genres must be provided, title must be provided"; err.Error() != want {
+		t.Errorf("want %q, got %q", want, err.Error())
+	}
+
+	c.Token = ""
+	if _, err := c.GetBook(ctx, 1); !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized {
+		t.Errorf("want an authentication error, got %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+// Package cql parses queries of the Contextual Query Language used by SRU, such as
+// `dc.title any "dune" and dc.date >= 1960`. Relation modifiers and proximity are not
+// supported.
+package cql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node is a parsed query, a *Clause or a *Boolean.
+type Node interface {
+	node()
+}
+
+// Clause is a search clause. A bare term has the index "cql.serverChoice" and the
+// relation "=".
+type Clause struct {
+	Index    string
+	Relation string
+	Term     string
+}
+
+// Boolean combines two queries with the operator "and", "or" or "not".
+type Boolean struct {
+	Op          string
+	Left, Right Node
+}
+
+func (*Clause) node()  {}
+func (*Boolean) node() {}
+
+// SyntaxError reports a malformed query.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("cql: %s at position %d", e.Msg, e.Pos)
+}
+
+// relations are the relation symbols and named relations accepted between an index and
+// a term.
+var relations = map[string]bool{
+	"=": true, "==": true, "<>": true, "<": true, ">": true, "<=": true, ">=": true,
+	"any": true, "all": true, "adj": true, "within": true,
+}
+
+var booleans = map[string]bool{"and": true, "or": true, "not": true, "prox": true}
+
+type token struct {
+	text   string
+	quoted bool
+	pos    int
+}
+
+type parser struct {
+	tokens []token
+	i      int
+	end    int
+}
+
+// Parse parses a query. Booleans associate to the left, as CQL requires.
+func Parse(query string) (Node, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, &SyntaxError{Pos: 0, Msg: "empty query"}
+	}
+
+	p := &parser{tokens: tokens, end: len(query)}
+	node, err := p.query()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return node, nil
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.i >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.i], true
+}
+
+func (p *parser) next() (token, error) {
+	t, ok := p.peek()
+	if !ok {
+		return token{}, &SyntaxError{Pos: p.end, Msg: "unexpected end of query"}
+	}
+	p.i++
+	return t, nil
+}
+
+func (p *parser) query() (Node, error) {
+	left, err := p.clause()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := p.peek()
+		if !ok || t.quoted || !booleans[strings.ToLower(t.text)] {
+			return left, nil
+		}
+		p.i++
+
+		right, err := p.clause()
+		if err != nil {
+			return nil, err
+		}
+		left = &Boolean{Op: strings.ToLower(t.text), Left: left, Right: right}
+	}
+}
+
+func (p *parser) clause() (Node, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if t.text == "(" && !t.quoted {
+		node, err := p.query()
+		if err != nil {
+			return nil, err
+		}
+		closing, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if closing.text != ")" || closing.quoted {
+			return nil, &SyntaxError{Pos: closing.pos, Msg: `expected ")"`}
+		}
+		return node, nil
+	}
+	if !t.quoted && (t.text == ")" || relations[t.text] && !isWord(t.text)) {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+
+	// an index followed by a relation, or a bare term.
+	if rel, ok := p.peek(); ok && !t.quoted && !rel.quoted && relations[strings.ToLower(rel.text)] {
+		p.i++
+		term, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !term.quoted && (term.text == "(" || term.text == ")") {
+			return nil, &SyntaxError{Pos: term.pos, Msg: "expected a search term"}
+		}
+		return &Clause{Index: strings.ToLower(t.text), Relation: strings.ToLower(rel.text), Term: term.text}, nil
+	}
+
+	return &Clause{Index: "cql.serverchoice", Relation: "=", Term: t.text}, nil
+}
+
+func isWord(s string) bool {
+	return s != "" && strings.IndexAny(s[:1], "=<>") < 0
+}
+
+// tokenize splits the query into parentheses, relation symbols, quoted strings with their
+// escapes removed, and words.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{text: string(c), pos: i})
+			i++
+		case c == '=' || c == '<' || c == '>':
+			j := i + 1
+			if j < len(s) && (s[j] == '=' || c == '<' && s[j] == '>') {
+				j++
+			}
+			tokens = append(tokens, token{text: s[i:j], pos: i})
+			i = j
+		case c == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				sb.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, &SyntaxError{Pos: i, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, token{text: sb.String(), quoted: true, pos: i})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t\n\r()=<>\"", s[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, token{text: s[i:j], pos: i})
+			i = j
+		}
+	}
+
+	return tokens, nil
+}
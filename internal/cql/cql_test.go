@@ -0,0 +1,50 @@
+package cql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Node
+	}{
+		{`dune`, &Clause{"cql.serverchoice", "=", "dune"}},
+		{`"dune messiah"`, &Clause{"cql.serverchoice", "=", "dune messiah"}},
+		{`dc.title ANY "dune \"messiah\""`, &Clause{"dc.title", "any", `dune "messiah"`}},
+		{`dc.date>=1960`, &Clause{"dc.date", ">=", "1960"}},
+		{`title = dune and (subject = sci-fi or subject = novel) not year < 1970`, &Boolean{"not",
+			&Boolean{"and",
+				&Clause{"title", "=", "dune"},
+				&Boolean{"or", &Clause{"subject", "=", "sci-fi"}, &Clause{"subject", "=", "novel"}},
+			},
+			&Clause{"year", "<", "1970"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, query := range []string{``, `title =`, `(dune`, `dune)`, `"dune`, `title = dune and`, `= dune`} {
+		t.Run(query, func(t *testing.T) {
+			_, err := Parse(query)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Errorf("want SyntaxError, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// APIKey identifies a client of the catalog and holds its request quotas, the allowance of
+// its access plan. A quota of 0 is unlimited. The key itself is only shown when the API key
+// is created; just its SHA-256 hash is stored.
+type APIKey struct {
+	ID           int64     `json:"id"`
+	Created      time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	DailyQuota   int64     `json:"daily_quota"`
+	MonthlyQuota int64     `json:"monthly_quota"`
+}
+
+// QuotaUsage is the number of requests made with an API key in the current day and in the
+// current month, both in UTC.
+type QuotaUsage struct {
+	Day   int64
+	Month int64
+}
+
+// ValidateAPIKey runs validation checks on the APIKey type.
+func ValidateAPIKey(v *validator.Validator, key *APIKey) {
+	v.Check(key.Name != "", "name", "must be provided")
+	v.Check(len(key.Name) <= 200, "name", "must not be more than 200 bytes long")
+
+	v.Check(key.DailyQuota >= 0, "daily_quota", "must not be negative")
+	v.Check(key.MonthlyQuota >= 0, "monthly_quota", "must not be negative")
+}
+
+// APIKeyModel struct wraps a sql.DB connection pool and works with the api_keys and
+// api_key_usage tables. The API keys are scoped to Tenant, and a key is only accepted for
+// the catalog of its tenant.
+type APIKeyModel struct {
+	DB     *sql.DB
+	Tenant string
+}
+
+// ForTenant returns a copy of the model scoped to the given tenant.
+func (m APIKeyModel) ForTenant(tenant string) APIKeyModel {
+	m.Tenant = tenant
+	return m
+}
+
+// hashAPIKey returns the hash an API key is stored and looked up by.
+func hashAPIKey(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// Insert adds a new API key record for the plaintext key, setting its ID and creation time.
+func (m APIKeyModel) Insert(key *APIKey, plaintext string) error {
+	if m.Tenant == "" {
+		return ErrMissingTenant
+	}
+
+	query := `
+		INSERT INTO api_keys (tenant_id, name, key_hash, daily_quota, monthly_quota)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, m.Tenant, key.Name, hashAPIKey(plaintext), key.DailyQuota, key.MonthlyQuota).
+		Scan(&key.ID, &key.Created)
+}
+
+// GetAll returns the API keys of the tenant, oldest first.
+func (m APIKeyModel) GetAll() ([]*APIKey, error) {
+	if m.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	query := `
+		SELECT id, created_at, name, daily_quota, monthly_quota
+		FROM api_keys
+		WHERE tenant_id = $1
+		ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, m.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		var key APIKey
+		err := rows.Scan(&key.ID, &key.Created, &key.Name, &key.DailyQuota, &key.MonthlyQuota)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Delete removes the API key with the given ID along with its usage counters.
+func (m APIKeyModel) Delete(id int64) error {
+	if m.Tenant == "" {
+		return ErrMissingTenant
+	}
+
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM api_keys
+		WHERE id = $1 AND tenant_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, m.Tenant)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// TenantOf returns the tenant the plaintext key was created for, which requests made with it
+// are scoped to. It returns ErrRecordNotFound for unknown keys.
+func (m APIKeyModel) TenantOf(plaintext string) (string, error) {
+	query := `
+		SELECT tenant_id
+		FROM api_keys
+		WHERE key_hash = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var tenant string
+	err := m.DB.QueryRowContext(ctx, query, hashAPIKey(plaintext)).Scan(&tenant)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return tenant, nil
+}
+
+// Consume counts a request made with the plaintext key at now and returns the API key and
+// its usage including the request. It returns ErrRecordNotFound for unknown keys and the
+// keys of other tenants, whose requests are not counted.
+func (m APIKeyModel) Consume(plaintext string, now time.Time) (*APIKey, QuotaUsage, error) {
+	// The month sum reads the snapshot taken before the day counter is incremented, so it
+	// only adds up the earlier days and the incremented counter of the day is added to it.
+	query := `
+		WITH today AS (
+			INSERT INTO api_key_usage (api_key_id, day, requests)
+			SELECT id, $2::date, 1 FROM api_keys WHERE key_hash = $1 AND tenant_id = $3
+			ON CONFLICT (api_key_id, day) DO UPDATE SET requests = api_key_usage.requests + 1
+			RETURNING api_key_id, requests
+		)
+		SELECT k.id, k.created_at, k.name, k.daily_quota, k.monthly_quota, t.requests,
+			t.requests + COALESCE((
+				SELECT sum(u.requests) FROM api_key_usage u
+				WHERE u.api_key_id = k.id AND u.day >= date_trunc('month', $2::date) AND u.day < $2::date
+			), 0)
+		FROM today t
+		JOIN api_keys k ON k.id = t.api_key_id`
+
+	return m.usage(query, plaintext, now)
+}
+
+// Usage returns the API key of the plaintext key and its usage at now, without counting a
+// request. It returns ErrRecordNotFound for unknown keys and the keys of other tenants.
+func (m APIKeyModel) Usage(plaintext string, now time.Time) (*APIKey, QuotaUsage, error) {
+	query := `
+		SELECT k.id, k.created_at, k.name, k.daily_quota, k.monthly_quota,
+			COALESCE(sum(u.requests) FILTER (WHERE u.day = $2::date), 0),
+			COALESCE(sum(u.requests), 0)
+		FROM api_keys k
+		LEFT JOIN api_key_usage u ON u.api_key_id = k.id AND u.day >= date_trunc('month', $2::date) AND u.day <= $2::date
+		WHERE k.key_hash = $1 AND k.tenant_id = $3
+		GROUP BY k.id`
+
+	return m.usage(query, plaintext, now)
+}
+
+// usage runs a query selecting an API key and its usage by the plaintext key and the day of now.
+func (m APIKeyModel) usage(query, plaintext string, now time.Time) (*APIKey, QuotaUsage, error) {
+	if m.Tenant == "" {
+		return nil, QuotaUsage{}, ErrMissingTenant
+	}
+
+	var key APIKey
+	var usage QuotaUsage
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, hashAPIKey(plaintext), now.UTC().Format(time.DateOnly), m.Tenant).
+		Scan(&key.ID, &key.Created, &key.Name, &key.DailyQuota, &key.MonthlyQuota, &usage.Day, &usage.Month)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, QuotaUsage{}, ErrRecordNotFound
+		default:
+			return nil, QuotaUsage{}, err
+		}
+	}
+
+	return &key, usage, nil
+}
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// MaxAttributesBytes is the largest size of JSON encoded Attributes accepted for a book.
+const MaxAttributesBytes = 16 * 1024
+
+// Attributes holds schemaless key/value metadata of a book (edition notes, external IDs, ...).
+// It is stored in the jsonb metadata column.
+type Attributes map[string]interface{}
+
+// Value satisfies the driver.Valuer interface and encodes Attributes as JSON.
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan satisfies the sql.Scanner interface and decodes Attributes from JSON.
+func (a *Attributes) Scan(src interface{}) error {
+	var js []byte
+
+	switch src := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		js = src
+	case string:
+		js = []byte(src)
+	default:
+		return errors.New("unsupported type for book metadata")
+	}
+
+	return json.Unmarshal(js, a)
+}
+
+// size returns the length of the JSON encoding of Attributes.
+func (a Attributes) size() int {
+	js, err := json.Marshal(a)
+	if err != nil {
+		return 0
+	}
+	return len(js)
+}
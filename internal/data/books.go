@@ -3,73 +3,347 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
+	"github.com/nikitashershunov/LibraryAPI/internal/events"
 	"github.com/nikitashershunov/LibraryAPI/internal/validator"
 )
 
 // Book type whose fields describe the book.
 type Book struct {
 	ID      int64     `json:"id"`
-	Created time.Time `json:"-"`
+	Created time.Time `json:"created_at"`
+	Updated time.Time `json:"updated_at"`
 	Title   string    `json:"title"`
-	Year    int32     `json:"year,omitempty"`
-	Pages   Pages     `json:"pages,omitempty"`
-	Genres  []string  `json:"genres,omitempty"`
-	Version int32     `json:"version"`
+	// OriginalTitle is set when Title is a translation chosen by Localize, it is not stored.
+	OriginalTitle string `json:"original_title,omitempty"`
+	// Language is the BCP 47 language tag of the original title, if known.
+	Language string `json:"language,omitempty"`
+	// Titles holds the translations of the title by language tag.
+	Titles   Titles     `json:"titles,omitempty"`
+	Year     *int32     `json:"year,omitempty"`
+	Pages    *Pages     `json:"pages,omitempty"`
+	Genres   []string   `json:"genres,omitempty"`
+	ISBN     string     `json:"isbn,omitempty"`
+	Metadata Attributes `json:"metadata,omitempty"`
+	Archived *time.Time `json:"archived_at,omitempty"`
+	Version  int32      `json:"version"`
+	// Checksum is the hash of the content of the book, see Book.ContentHash, set for
+	// responses; it is not stored.
+	Checksum string `json:"checksum,omitempty"`
+	// Links holds the URLs of the book and its related resources by relation, set for
+	// responses; they are not stored.
+	Links map[string]string `json:"links,omitempty"`
+}
+
+// ReadingMinutes estimates the minutes needed to read the book from its pages, see
+// Pages.ReadingMinutes. It returns false if the pages of the book are unknown.
+func (b *Book) ReadingMinutes(wordsPerPage, wordsPerMinute int) (int64, bool) {
+	if b.Pages == nil {
+		return 0, false
+	}
+	return b.Pages.ReadingMinutes(wordsPerPage, wordsPerMinute), true
 }
 
 // BookModel struct wraps a sql.DB connection pool and help to work with Book struct type
-// and books table in database.
+// and books table in database. All queries are scoped to Tenant, which must be set with
+// ForTenant before the model is used.
 type BookModel struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Retry  RetryPolicy
+	Tenant string
+	// Counts caches the total number of records of listings, nil disables caching.
+	Counts *CountCache
+	// Breaker fails calls fast while the database is unreachable, nil disables it.
+	Breaker *Breaker
+	// Faults fails a fraction of the calls for resilience tests, nil disables it.
+	Faults *FaultInjector
+	// Cache caches books and listings in a shared store, nil disables caching.
+	Cache *BookCache
+	// Index serves searches from a search engine, nil serves them from the database.
+	Index *BookIndex
+	// Outbox writes the book events to the outbox table too, for the relay to publish
+	// them to a message broker.
+	Outbox bool
+}
+
+// ForTenant returns a copy of the model scoped to the given tenant.
+func (b BookModel) ForTenant(tenant string) BookModel {
+	b.Tenant = tenant
+	return b
 }
 
 // Insert accepts a pointer to a book struct, which should contain the data for the
 // new record and inserts the record into the books table.
 func (b BookModel) Insert(book *Book) error {
+	if b.Tenant == "" {
+		return ErrMissingTenant
+	}
+
+	query := `
+		INSERT INTO books (title, year, pages, genres, isbn, metadata, tenant_id, language, titles, title_translit)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at, version`
+
+	args := []interface{}{book.Title, book.Year, book.Pages, pq.Array(book.Genres), book.ISBN, book.Metadata, b.Tenant,
+		book.Language, book.Titles, transliteration(book.Title)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := b.do(ctx, false, func(ctx context.Context) error {
+		return withTx(ctx, b.DB, func(tx *sql.Tx) error {
+			err := tx.QueryRowContext(ctx, query, args...).Scan(&book.ID, &book.Created, &book.Updated, &book.Version)
+			if err != nil {
+				return err
+			}
+			return b.notify(ctx, tx, events.BookCreated, book.ID, book.Version)
+		})
+	})
+	if err != nil {
+		return translateBookError(err)
+	}
+
+	b.Counts.Invalidate(b.Tenant)
+	b.Cache.Invalidate(b.Tenant)
+
+	return nil
+}
+
+// BulkInsert inserts many books at once using COPY, which is much faster than inserting
+// them one by one. The books are first copied into a temporary table and then moved into
+// books in a single statement that also emits their events. It returns the number of
+// inserted books; the ID and timestamps of the book structs are not filled in.
+func (b BookModel) BulkInsert(books []*Book) (int64, error) {
+	if b.Tenant == "" {
+		return 0, ErrMissingTenant
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var inserted int64
+
+	err := b.do(ctx, false, func(ctx context.Context) error {
+		return withTx(ctx, b.DB, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, `
+				CREATE TEMPORARY TABLE books_import
+				(LIKE books INCLUDING DEFAULTS)
+				ON COMMIT DROP`)
+			if err != nil {
+				return err
+			}
+
+			stmt, err := tx.PrepareContext(ctx, pq.CopyIn("books_import",
+				"title", "year", "pages", "genres", "isbn", "metadata", "tenant_id", "language", "titles", "title_translit"))
+			if err != nil {
+				return err
+			}
+
+			for _, book := range books {
+				var isbn interface{}
+				if book.ISBN != "" {
+					isbn = book.ISBN
+				}
+
+				// COPY encodes []byte as bytea, so the JSON must be passed as a string.
+				metadata, err := book.Metadata.Value()
+				if err != nil {
+					stmt.Close()
+					return err
+				}
+				titles, err := book.Titles.Value()
+				if err != nil {
+					stmt.Close()
+					return err
+				}
+
+				_, err = stmt.ExecContext(ctx, book.Title, book.Year, book.Pages, pq.Array(book.Genres),
+					isbn, string(metadata.([]byte)), b.Tenant, book.Language, string(titles.([]byte)),
+					transliteration(book.Title))
+				if err != nil {
+					stmt.Close()
+					return err
+				}
+			}
+
+			if _, err := stmt.ExecContext(ctx); err != nil {
+				stmt.Close()
+				return err
+			}
+			if err := stmt.Close(); err != nil {
+				return err
+			}
+
+			query := `
+				WITH inserted AS (
+					INSERT INTO books (title, year, pages, genres, isbn, metadata, tenant_id, language, titles, title_translit)
+					SELECT title, year, pages, genres, isbn, metadata, tenant_id, language, titles, title_translit
+					FROM books_import
+					RETURNING id, version, tenant_id
+				), outboxed AS (
+					INSERT INTO outbox (tenant_id, type, book_id, version)
+					SELECT tenant_id, $2, id, version
+					FROM inserted
+					WHERE $3
+				)
+				SELECT count(pg_notify($1, json_build_object(
+					'type', $2::text, 'tenant', tenant_id, 'book_id', id, 'version', version, 'time', now()
+				)::text))
+				FROM inserted`
+
+			return tx.QueryRowContext(ctx, query, events.Channel, events.BookCreated, b.Outbox).Scan(&inserted)
+		})
+	})
+	if err != nil {
+		return 0, translateBookError(err)
+	}
+
+	b.Counts.Invalidate(b.Tenant)
+	b.Cache.Invalidate(b.Tenant)
+
+	return inserted, nil
+}
+
+// Upsert inserts the book or, if a book with the same ISBN already exists, overwrites it
+// in a single statement. It reports whether a new record was created. book.ISBN must be set.
+func (b BookModel) Upsert(book *Book) (bool, error) {
+	if b.Tenant == "" {
+		return false, ErrMissingTenant
+	}
+
 	query := `
-		INSERT INTO books (title, year, pages, genres)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created, version`
+		INSERT INTO books (title, year, pages, genres, isbn, metadata, tenant_id, language, titles, title_translit)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (tenant_id, isbn) DO UPDATE
+		SET title = EXCLUDED.title, year = EXCLUDED.year, pages = EXCLUDED.pages,
+			genres = EXCLUDED.genres, metadata = EXCLUDED.metadata, language = EXCLUDED.language,
+			titles = EXCLUDED.titles, title_translit = EXCLUDED.title_translit, updated_at = NOW(),
+			version = books.version + 1
+		RETURNING id, created_at, updated_at, version, (xmax = 0) AS inserted`
 
-	args := []interface{}{book.Title, book.Year, book.Pages, pq.Array(book.Genres)}
+	args := []interface{}{book.Title, book.Year, book.Pages, pq.Array(book.Genres), book.ISBN, book.Metadata, b.Tenant,
+		book.Language, book.Titles, transliteration(book.Title)}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return b.DB.QueryRowContext(ctx, query, args...).Scan(&book.ID, &book.Created, &book.Version)
+	var inserted bool
+
+	err := b.do(ctx, false, func(ctx context.Context) error {
+		return withTx(ctx, b.DB, func(tx *sql.Tx) error {
+			err := tx.QueryRowContext(ctx, query, args...).Scan(&book.ID, &book.Created, &book.Updated, &book.Version, &inserted)
+			if err != nil {
+				return err
+			}
+			if inserted {
+				return b.notify(ctx, tx, events.BookCreated, book.ID, book.Version)
+			}
+			return b.notify(ctx, tx, events.BookUpdated, book.ID, book.Version)
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+
+	b.Counts.Invalidate(b.Tenant)
+	b.Cache.Invalidate(b.Tenant, book.ID)
+
+	return inserted, nil
+}
+
+// FindDuplicate returns the ID of an existing book the given one duplicates: a book with
+// the same ISBN, or with the same title, compared by normalizeTitle, and year. It returns
+// ErrRecordNotFound if there is none.
+func (b BookModel) FindDuplicate(book *Book) (int64, error) {
+	if b.Tenant == "" {
+		return 0, ErrMissingTenant
+	}
+
+	query := `
+		SELECT id
+		FROM books
+		WHERE tenant_id = $1 AND (
+			isbn = NULLIF($2, '') OR
+			(lower(regexp_replace(btrim(title), '\s+', ' ', 'g')) = $3 AND year IS NOT DISTINCT FROM $4::integer)
+		)
+		ORDER BY id
+		LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+	err := b.do(ctx, true, func(ctx context.Context) error {
+		return b.DB.QueryRowContext(ctx, query, b.Tenant, book.ISBN, normalizeTitle(book.Title), book.Year).Scan(&id)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// normalizeTitle folds the case and the spacing of a title, so that titles typed
+// differently compare equal when looking for duplicates.
+func normalizeTitle(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
 }
 
 // Get fetches a record from the books table and returns corresponding book struct.
 // It cancels query call if SQL query does not finish during 3 seconds.
 func (b BookModel) Get(id int64) (*Book, error) {
+	if b.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
+	if book, ok := b.Cache.getBook(b.Tenant, id); ok {
+		return book, nil
+	}
+
 	query := `
-		SELECT id, created, title, year, pages, genres, version
+		SELECT id, created_at, updated_at, title, language, titles, year, pages, genres, COALESCE(isbn, ''), metadata,
+			archived_at, version
 		FROM books
-		WHERE id = $1`
+		WHERE id = $1 AND tenant_id = $2`
 
 	var book Book
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := b.DB.QueryRowContext(ctx, query, id).Scan(
-		&book.ID,
-		&book.Created,
-		&book.Title,
-		&book.Year,
-		&book.Pages,
-		pq.Array(&book.Genres),
-		&book.Version,
-	)
+	err := b.do(ctx, true, func(ctx context.Context) error {
+		return b.DB.QueryRowContext(ctx, query, id, b.Tenant).Scan(
+			&book.ID,
+			&book.Created,
+			&book.Updated,
+			&book.Title,
+			&book.Language,
+			&book.Titles,
+			&book.Year,
+			&book.Pages,
+			pq.Array(&book.Genres),
+			&book.ISBN,
+			&book.Metadata,
+			&book.Archived,
+			&book.Version,
+		)
+	})
 
 	if err != nil {
 		switch {
@@ -80,145 +354,771 @@ func (b BookModel) Get(id int64) (*Book, error) {
 		}
 	}
 
+	b.Cache.setBook(b.Tenant, &book)
+
 	return &book, nil
 }
 
 // Update updates a specific book in the books table.
 func (b BookModel) Update(book *Book) error {
+	if b.Tenant == "" {
+		return ErrMissingTenant
+	}
+
 	query := `
 		UPDATE books
-		SET title = $1, year = $2, pages = $3, genres = $4, version = version + 1
-		WHERE id = $5 AND version = $6
-		RETURNING version`
+		SET title = $1, year = $2, pages = $3, genres = $4, isbn = NULLIF($5, ''),
+			metadata = $6, archived_at = $7, language = $11, titles = $12, title_translit = $13,
+			updated_at = NOW(), version = version + 1
+		WHERE id = $8 AND version = $9 AND tenant_id = $10
+		RETURNING updated_at, version`
 
 	args := []interface{}{
 		book.Title,
 		book.Year,
 		book.Pages,
 		pq.Array(book.Genres),
+		book.ISBN,
+		book.Metadata,
+		book.Archived,
 		book.ID,
 		book.Version,
+		b.Tenant,
+		book.Language,
+		book.Titles,
+		transliteration(book.Title),
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := b.DB.QueryRowContext(ctx, query, args...).Scan(&book.Version)
+	err := b.do(ctx, false, func(ctx context.Context) error {
+		return withTx(ctx, b.DB, func(tx *sql.Tx) error {
+			err := tx.QueryRowContext(ctx, query, args...).Scan(&book.Updated, &book.Version)
+			if err != nil {
+				return err
+			}
+			return b.notify(ctx, tx, events.BookUpdated, book.ID, book.Version)
+		})
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
-			return err
+			return translateBookError(err)
 		}
 	}
 
+	b.Counts.Invalidate(b.Tenant)
+	b.Cache.Invalidate(b.Tenant, book.ID)
+
 	return nil
 }
 
 // Delete is a method for deleting the record in the books table.
 func (b BookModel) Delete(id int64) error {
+	if b.Tenant == "" {
+		return ErrMissingTenant
+	}
+
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `
 		DELETE FROM books
-		WHERE id = $1`
+		WHERE id = $1 AND tenant_id = $2
+		RETURNING version`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := b.DB.ExecContext(ctx, query, id)
-	if err != nil {
-		return err
-	}
+	err := b.do(ctx, false, func(ctx context.Context) error {
+		return withTx(ctx, b.DB, func(tx *sql.Tx) error {
+			var version int32
 
-	rowsAff, err := result.RowsAffected()
+			err := tx.QueryRowContext(ctx, query, id, b.Tenant).Scan(&version)
+			if err != nil {
+				return err
+			}
+			return b.notify(ctx, tx, events.BookDeleted, id, version)
+		})
+	})
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
 	}
 
-	if rowsAff == 0 {
-		return ErrRecordNotFound
-	}
+	b.Counts.Invalidate(b.Tenant)
+	b.Cache.Invalidate(b.Tenant, id)
 
 	return nil
 }
 
+// BookFilter holds the conditions books are listed by. Zero values of the fields are ignored.
+type BookFilter struct {
+	// Query is a free text search, matched against the title in the database and
+	// against the title and genres, with typo tolerance, by a search index.
+	Query string `query:"q"`
+	// Title is matched with full text search.
+	Title string `query:"title"`
+	// Genres must all be present in the book genres.
+	Genres []string `query:"genres"`
+	// MinYear and MaxYear bound the publication year when non-zero.
+	MinYear int32
+	MaxYear int32
+	// ISBN matches the ISBN exactly. It is not indexed, so it is always looked up in the
+	// database.
+	ISBN         string
+	CreatedSince time.Time `query:"created_since"`
+	UpdatedSince time.Time `query:"updated_since"`
+	// Metadata keys must be present in the book metadata with the given values.
+	Metadata map[string]string `query:"metadata."`
+	// IncludeArchived lists archived books too, which are left out by default.
+	IncludeArchived bool `query:"include_archived"`
+}
+
+// titleVariants is the text search vector of the translations and the transliteration of
+// the title, which searches match besides the title. It is the expression of the
+// books_title_variants_idx index.
+const titleVariants = "(to_tsvector('simple', titles) || to_tsvector('simple', title_translit))"
+
+// bookSortColumns maps sort keys to the books table columns they sort by.
+var bookSortColumns = map[string]string{
+	"id":         "id",
+	"title":      "title",
+	"year":       "year",
+	"pages":      "pages",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"views":      "views",
+}
+
 // GetAll returns a list of books in the form of a string of Book type based
 // on set of provided filters.
-func (b BookModel) GetAll(title string, genres []string, filters Filters) ([]*Book, Metadata, error) {
-	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, created, title, year, pages, genres, version
-		FROM books
-		WHERE (to_tsvector('english', title) @@ plainto_tsquery('english', $1) OR $1 = '')
-		AND (genres @> $2 OR $2 = '{}')
-		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+func (b BookModel) GetAll(filter BookFilter, filters Filters) ([]*Book, Metadata, error) {
+	if b.Tenant == "" {
+		return nil, Metadata{}, ErrMissingTenant
+	}
+
+	sortColumn, ok := filters.sortColumn(bookSortColumns)
+	if !ok {
+		return nil, Metadata{}, fmt.Errorf("unsupported sort value %q", filters.Sort)
+	}
+
+	q := newSelectQuery("books")
+
+	q.Where("tenant_id = %s", b.Tenant)
+	if !filter.IncludeArchived {
+		q.Where("archived_at IS NULL")
+	}
+	if filter.Query != "" {
+		q.Where("(to_tsvector('english', title) @@ websearch_to_tsquery('english', %s) OR "+
+			titleVariants+" @@ websearch_to_tsquery('simple', %s))", filter.Query, filter.Query)
+	}
+	if filter.Title != "" {
+		q.Where("(to_tsvector('english', title) @@ plainto_tsquery('english', %s) OR "+
+			titleVariants+" @@ plainto_tsquery('simple', %s))", filter.Title, filter.Title)
+	}
+	if len(filter.Genres) > 0 {
+		q.Where("genres @> %s", pq.Array(filter.Genres))
+	}
+	if filter.MinYear != 0 {
+		q.Where("year >= %s", filter.MinYear)
+	}
+	if filter.MaxYear != 0 {
+		q.Where("year <= %s", filter.MaxYear)
+	}
+	if filter.ISBN != "" {
+		q.Where("isbn = %s", filter.ISBN)
+	}
+	if !filter.CreatedSince.IsZero() {
+		q.Where("created_at >= %s", filter.CreatedSince)
+	}
+	if !filter.UpdatedSince.IsZero() {
+		q.Where("updated_at >= %s", filter.UpdatedSince)
+	}
+
+	keys := make([]string, 0, len(filter.Metadata))
+	for key := range filter.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		q.Where("metadata ->> %s = %s", key, filter.Metadata[key])
+	}
+
+	q.OrderBy(sortColumn, filters.sortDescending())
+	if sortColumn != "id" {
+		q.OrderBy("id", false)
+	}
+	q.Page(filters.limit(), filters.offset())
+
+	// Serve the page from the shared cache if it has been listed recently.
+	filterKey := q.FilterKey()
+	listKey, cacheable := b.Cache.listKey(b.Tenant, filterKey, filters)
+	if cacheable {
+		if books, meta, ok := b.Cache.getList(listKey); ok {
+			return books, meta, nil
+		}
+	}
+
+	// Count the matching records with a window function unless the count is cached.
+	totalRecords, cached := b.Counts.get(b.Tenant, filterKey)
+	if !cached {
+		q.Columns("count(*) OVER()")
+	}
+	q.Columns("id", "created_at", "updated_at", "title", "language", "titles", "year", "pages", "genres",
+		"COALESCE(isbn, '')", "metadata", "archived_at", "version")
+
+	query, args := q.Build()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{title, pq.Array(genres), filters.limit(), filters.offset()}
+	books := []*Book{}
+
+	err := b.do(ctx, true, func(ctx context.Context) error {
+		if !cached {
+			totalRecords = 0
+		}
+		books = books[:0]
+
+		rows, err := b.DB.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var book Book
+
+			dest := []interface{}{
+				&book.ID,
+				&book.Created,
+				&book.Updated,
+				&book.Title,
+				&book.Language,
+				&book.Titles,
+				&book.Year,
+				&book.Pages,
+				pq.Array(&book.Genres),
+				&book.ISBN,
+				&book.Metadata,
+				&book.Archived,
+				&book.Version,
+			}
+			if !cached {
+				dest = append([]interface{}{&totalRecords}, dest...)
+			}
+
+			err := rows.Scan(dest...)
+			if err != nil {
+				return err
+			}
 
-	rows, err := b.DB.QueryContext(ctx, query, args...)
+			books = append(books, &book)
+		}
+
+		return rows.Err()
+	})
 	if err != nil {
 		return nil, Metadata{}, err
 	}
-	defer rows.Close()
 
-	totalRecords := 0
+	// An empty page carries no count, so only counts read from the rows are cached.
+	if !cached && len(books) > 0 {
+		b.Counts.set(b.Tenant, filterKey, totalRecords)
+	}
+
+	meta := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	if cacheable {
+		b.Cache.setList(listKey, books, meta)
+	}
+
+	return books, meta, nil
+}
+
+// ChangeCursor is the position of a book in the order of Changed.
+type ChangeCursor struct {
+	Updated time.Time
+	ID      int64
+}
+
+// Changed returns up to limit books, archived ones included, updated between from and
+// until inclusive and positioned after the cursor, ordered by update time and ID. Zero
+// times leave the range open. It is meant for harvesting the catalog incrementally, where
+// paging with the cursor of the last book is stable while books change.
+func (b BookModel) Changed(from, until time.Time, after ChangeCursor, limit int) ([]*Book, error) {
+	if b.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	q := newSelectQuery("books", "id", "created_at", "updated_at", "title", "language", "titles", "year", "pages", "genres",
+		"COALESCE(isbn, '')", "metadata", "archived_at", "version")
+
+	q.Where("tenant_id = %s", b.Tenant)
+	if !from.IsZero() {
+		q.Where("updated_at >= %s", from)
+	}
+	if !until.IsZero() {
+		q.Where("updated_at <= %s", until)
+	}
+	if !after.Updated.IsZero() {
+		q.Where("(updated_at, id) > (%s, %s)", after.Updated, after.ID)
+	}
+	q.OrderBy("updated_at", false)
+	q.OrderBy("id", false)
+	q.Page(limit, 0)
+
+	query, args := q.Build()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
 	books := []*Book{}
 
-	for rows.Next() {
-		var book Book
+	err := b.do(ctx, true, func(ctx context.Context) error {
+		books = books[:0]
 
-		err := rows.Scan(
-			&totalRecords,
-			&book.ID,
-			&book.Created,
-			&book.Title,
-			&book.Year,
-			&book.Pages,
-			pq.Array(&book.Genres),
-			&book.Version,
-		)
+		rows, err := b.DB.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var book Book
+
+			err := rows.Scan(
+				&book.ID,
+				&book.Created,
+				&book.Updated,
+				&book.Title,
+				&book.Language,
+				&book.Titles,
+				&book.Year,
+				&book.Pages,
+				pq.Array(&book.Genres),
+				&book.ISBN,
+				&book.Metadata,
+				&book.Archived,
+				&book.Version,
+			)
+			if err != nil {
+				return err
+			}
+
+			books = append(books, &book)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// After returns up to limit books with an ID greater than afterID, archived ones included,
+// in ID order. Paging with the ID of the last book reads every book once, even if books
+// change meanwhile, which suits exports of the whole catalog.
+func (b BookModel) After(afterID int64, limit int) ([]*Book, error) {
+	if b.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
 
+	q := newSelectQuery("books", "id", "created_at", "updated_at", "title", "language", "titles", "year", "pages", "genres",
+		"COALESCE(isbn, '')", "metadata", "archived_at", "version")
+
+	q.Where("tenant_id = %s", b.Tenant)
+	q.Where("id > %s", afterID)
+	q.OrderBy("id", false)
+	q.Page(limit, 0)
+
+	query, args := q.Build()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	books := []*Book{}
+
+	err := b.do(ctx, true, func(ctx context.Context) error {
+		books = books[:0]
+
+		rows, err := b.DB.QueryContext(ctx, query, args...)
 		if err != nil {
-			return nil, Metadata{}, err
+			return err
 		}
+		defer rows.Close()
 
-		books = append(books, &book)
+		for rows.Next() {
+			var book Book
+
+			err := rows.Scan(
+				&book.ID,
+				&book.Created,
+				&book.Updated,
+				&book.Title,
+				&book.Language,
+				&book.Titles,
+				&book.Year,
+				&book.Pages,
+				pq.Array(&book.Genres),
+				&book.ISBN,
+				&book.Metadata,
+				&book.Archived,
+				&book.Version,
+			)
+			if err != nil {
+				return err
+			}
+
+			books = append(books, &book)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, Metadata{}, err
+	return books, nil
+}
+
+// Search returns a page of the books matching the filter like GetAll. If the model has a
+// search index, the books are looked up in it and returned with the facets of the query;
+// if the index fails or is not used, see searchesIndex, they are listed from the database
+// without facets.
+func (b BookModel) Search(filter BookFilter, filters Filters) ([]*Book, Metadata, Facets, error) {
+	if b.Tenant == "" {
+		return nil, Metadata{}, nil, ErrMissingTenant
 	}
 
-	meta := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	if b.searchesIndex(filter, filters) {
+		books, meta, facets, err := b.Index.search(b.Tenant, filter, filters)
+		if err == nil {
+			return books, meta, facets, nil
+		}
+	}
 
-	return books, meta, nil
+	books, meta, err := b.GetAll(filter, filters)
+	return books, meta, nil, err
+}
+
+// searchesIndex reports whether Search looks the books up in the search index: if there is
+// one, the filter has no ISBN and the index holds the field the books are sorted by. Views
+// are only counted in the database, as they change too often to be reindexed.
+func (b BookModel) searchesIndex(filter BookFilter, filters Filters) bool {
+	_, sortable := filters.sortColumn(bookSearchFields)
+	return b.Index != nil && filter.ISBN == "" && sortable
+}
+
+// IndexBook writes the current state of the book to the search index. Books deleted in
+// the meantime are skipped, as their deletion is indexed with UnindexBook.
+func (b BookModel) IndexBook(id int64) error {
+	if b.Index == nil {
+		return nil
+	}
+
+	// read the book from the database, a cached copy may predate the change being indexed.
+	b.Cache = nil
+	book, err := b.Get(id)
+	switch {
+	case errors.Is(err, ErrRecordNotFound):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	return b.Index.put(b.Tenant, book)
+}
+
+// UnindexBook removes the book deleted at version from the search index.
+func (b BookModel) UnindexBook(id int64, version int32) error {
+	if b.Index == nil {
+		return nil
+	}
+	if b.Tenant == "" {
+		return ErrMissingTenant
+	}
+
+	return b.Index.remove(b.Tenant, id, version)
+}
+
+// Reindex writes the books of all tenants to the search index, in batches read in ID order.
+// It returns the number of indexed books. Like ArchiveStale it is not scoped to a tenant,
+// as it is meant for filling a new index and catching up after lost events.
+func (b BookModel) Reindex() (int64, error) {
+	if b.Index == nil {
+		return 0, nil
+	}
+
+	query := `
+		SELECT tenant_id, id, created_at, updated_at, title, language, titles, year, pages, genres,
+			COALESCE(isbn, ''), metadata, archived_at, version
+		FROM books
+		WHERE id > $1
+		ORDER BY id
+		LIMIT 500`
+
+	type tenantBook struct {
+		tenant string
+		book   *Book
+	}
+
+	var indexed int64
+	var afterID int64
+	for {
+		var batch []tenantBook
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := b.do(ctx, true, func(ctx context.Context) error {
+			batch = batch[:0]
+
+			rows, err := b.DB.QueryContext(ctx, query, afterID)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var tb tenantBook
+				var book Book
+
+				err := rows.Scan(
+					&tb.tenant,
+					&book.ID,
+					&book.Created,
+					&book.Updated,
+					&book.Title,
+					&book.Language,
+					&book.Titles,
+					&book.Year,
+					&book.Pages,
+					pq.Array(&book.Genres),
+					&book.ISBN,
+					&book.Metadata,
+					&book.Archived,
+					&book.Version,
+				)
+				if err != nil {
+					return err
+				}
+
+				tb.book = &book
+				batch = append(batch, tb)
+			}
+
+			return rows.Err()
+		})
+		cancel()
+		if err != nil {
+			return indexed, err
+		}
+		if len(batch) == 0 {
+			return indexed, nil
+		}
+
+		for _, tb := range batch {
+			if err := b.Index.put(tb.tenant, tb.book); err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+		afterID = batch[len(batch)-1].book.ID
+	}
+}
+
+// ArchiveStale archives the books of all tenants that have not been updated since before.
+// It returns the number of archived books. Unlike the other methods it is not scoped to a
+// tenant, as it is meant for the background archival job.
+func (b BookModel) ArchiveStale(before time.Time) (int64, error) {
+	query := `
+		UPDATE books
+		SET archived_at = NOW(), version = version + 1
+		WHERE archived_at IS NULL AND updated_at < $1
+		RETURNING id, version, tenant_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	type change struct {
+		id      int64
+		version int32
+		tenant  string
+	}
+
+	var changed []change
+
+	err := b.do(ctx, false, func(ctx context.Context) error {
+		return withTx(ctx, b.DB, func(tx *sql.Tx) error {
+			changed = changed[:0]
+
+			rows, err := tx.QueryContext(ctx, query, before)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var c change
+				if err := rows.Scan(&c.id, &c.version, &c.tenant); err != nil {
+					return err
+				}
+				changed = append(changed, c)
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			for _, c := range changed {
+				err := b.ForTenant(c.tenant).notify(ctx, tx, events.BookUpdated, c.id, c.version)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	b.Counts.Invalidate("")
+
+	archivedIDs := make(map[string][]int64)
+	for _, c := range changed {
+		archivedIDs[c.tenant] = append(archivedIDs[c.tenant], c.id)
+	}
+	for tenant, ids := range archivedIDs {
+		b.Cache.Invalidate(tenant, ids...)
+	}
+
+	return int64(len(changed)), nil
+}
+
+// BookRules adjusts the validation of books to the deployment.
+type BookRules struct {
+	// OptionalDetails allows books without year and pages, as archival records often lack them.
+	OptionalDetails bool
 }
 
 // ValidateBook run validation checks on the Book type.
-func ValidateBook(v *validator.Validator, book *Book) {
+func ValidateBook(v *validator.Validator, book *Book, rules BookRules) {
 	// Check book.Title
 	v.Check(book.Title != "", "title", "must be provided")
 	v.Check(len(book.Title) <= 500, "title", "must not be more than 500 bytes long")
+	v.Warn(strings.TrimSpace(book.Title) == book.Title, "title", "has leading or trailing spaces")
+
+	// Check book.Language and book.Titles, which are optional.
+	if book.Language != "" {
+		v.Check(validLanguage(book.Language), "language", "must be a valid BCP 47 language tag")
+	}
+	v.Check(len(book.Titles) <= MaxTitles, "titles", fmt.Sprintf("must not contain more than %d translations", MaxTitles))
+	titles := v.At("titles")
+	for tag, title := range book.Titles {
+		titles.Check(validLanguage(tag), tag, "must be keyed by a valid BCP 47 language tag")
+		titles.Check(title != "", tag, "must be provided")
+		titles.Check(len(title) <= 500, tag, "must not be more than 500 bytes long")
+	}
 
 	// Check book.Year
-	v.Check(book.Year != 0, "year", "must be provided")
-	v.Check(book.Year >= 1888, "year", "must be greater than 1888")
-	v.Check(book.Year <= int32(time.Now().Year()), "year", "must not be in the future")
+	if book.Year != nil {
+		v.Check(*book.Year != 0, "year", "must be provided")
+		v.Check(*book.Year >= 1900, "year", "must not be before 1900")
+		v.Check(*book.Year <= int32(time.Now().Year()), "year", "must not be in the future")
+	} else {
+		v.Check(rules.OptionalDetails, "year", "must be provided")
+	}
 
 	// Check book.Pages
-	v.Check(book.Pages != 0, "pages", "must be provided")
-	v.Check(book.Pages > 0, "pages", "must be a positive integer")
+	if book.Pages != nil {
+		v.Check(*book.Pages != 0, "pages", "must be provided")
+		v.Check(*book.Pages > 0, "pages", "must be a positive integer")
+		v.Warn(*book.Pages <= 5000, "pages", "looks unusually high")
+	} else {
+		v.Check(rules.OptionalDetails, "pages", "must be provided")
+	}
 
 	// Check book.Genres
 	v.Check(book.Genres != nil, "genres", "must be provided")
 	v.Check(len(book.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(book.Genres) <= 5, "genres", "must not contain more than 5 genres")
 	v.Check(validator.Unique(book.Genres), "genres", "must not contain duplicate values")
+
+	// Check book.ISBN, which is optional.
+	if book.ISBN != "" {
+		v.Check(validator.ISBN(book.ISBN), "isbn", "must be a valid ISBN-10 or ISBN-13")
+	}
+
+	// Check book.Metadata, which is optional.
+	v.Check(book.Metadata.size() <= MaxAttributesBytes, "metadata", fmt.Sprintf("must not be more than %d bytes long", MaxAttributesBytes))
+}
+
+// NormalizeISBN strips hyphens and spaces from an ISBN and upper-cases the check digit,
+// so the same ISBN written differently is stored only once.
+func NormalizeISBN(isbn string) string {
+	isbn = strings.NewReplacer("-", "", " ", "").Replace(isbn)
+	return strings.ToUpper(isbn)
+}
+
+// notify sends the change of a book on the events channel and, if enabled, writes it to
+// the outbox. Postgres delivers the notification only when tx commits, so listeners never
+// see rolled back changes, and the outbox row is committed along with the change.
+func (b BookModel) notify(ctx context.Context, tx *sql.Tx, eventType string, id int64, version int32) error {
+	payload, err := json.Marshal(events.Event{
+		Type:    eventType,
+		Tenant:  b.Tenant,
+		BookID:  id,
+		Version: version,
+		Time:    time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", events.Channel, string(payload))
+	if err != nil || !b.Outbox {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO outbox (tenant_id, type, book_id, version) VALUES ($1, $2, $3, $4)",
+		b.Tenant, eventType, id, version)
+	return err
+}
+
+// translateBookError maps constraint violations on the books table to the package errors.
+func translateBookError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "books_tenant_isbn_idx" {
+		return ErrDuplicateISBN
+	}
+	return err
+}
+
+// do runs fn with the retry policy of the model, unless the circuit breaker is open. Every
+// attempt may be failed by the fault injector instead.
+func (b BookModel) do(ctx context.Context, idempotent bool, fn func(ctx context.Context) error) error {
+	if err := b.Breaker.allow(); err != nil {
+		return err
+	}
+
+	err := b.Retry.do(ctx, idempotent, func(ctx context.Context) error {
+		if err := b.Faults.inject(); err != nil {
+			return err
+		}
+		return fn(ctx)
+	})
+	b.Breaker.record(err)
+	return err
 }
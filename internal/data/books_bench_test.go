@@ -0,0 +1,74 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// Run against a migrated database with:
+//
+//	TEST_DB_DSN=postgres://... go test -run=^$ -bench=Insert ./internal/data
+const benchmarkBooks = 10_000
+
+func BenchmarkInsert(b *testing.B) {
+	books := openBenchmarkModel(b)
+
+	for i := 0; i < b.N; i++ {
+		for _, book := range benchmarkBookSet(i) {
+			if err := books.Insert(book); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkBulkInsert(b *testing.B) {
+	books := openBenchmarkModel(b)
+
+	for i := 0; i < b.N; i++ {
+		if _, err := books.BulkInsert(benchmarkBookSet(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// openBenchmarkModel connects to the database in TEST_DB_DSN and returns a book model
+// scoped to a tenant whose books are deleted when the benchmark ends.
+func openBenchmarkModel(b *testing.B) BookModel {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		b.Skip("TEST_DB_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	tenant := "benchmark"
+	b.Cleanup(func() {
+		db.Exec("DELETE FROM books WHERE tenant_id = $1", tenant)
+		db.Close()
+	})
+
+	return NewModels(db).Books.ForTenant(tenant)
+}
+
+// benchmarkBookSet returns benchmarkBooks books with ISBNs unique for the run.
+func benchmarkBookSet(run int) []*Book {
+	year := int32(2000)
+	pages := Pages(300)
+
+	books := make([]*Book, benchmarkBooks)
+	for i := range books {
+		books[i] = &Book{
+			Title:  fmt.Sprintf("Benchmark book %d-%d", run, i),
+			Year:   &year,
+			Pages:  &pages,
+			Genres: []string{"benchmark"},
+		}
+	}
+	return books
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	b := NewBreaker(2, 20*time.Millisecond)
+
+	// Query errors don't count as connection failures.
+	b.record(ErrRecordNotFound)
+	b.record(syscall.ECONNREFUSED)
+	if b.State() != BreakerClosed {
+		t.Fatalf("want closed after one failure, got %s", b.State())
+	}
+
+	b.record(syscall.ECONNREFUSED)
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("want ErrCircuitOpen, got %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("want half-open after the cooldown, got %s", b.State())
+	}
+
+	// Only one probe goes through; its failure opens the breaker again.
+	if err := b.allow(); err != nil {
+		t.Fatalf("want probe allowed, got %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("want second call rejected while probing, got %v", err)
+	}
+	b.record(syscall.ECONNREFUSED)
+	if b.State() != BreakerOpen {
+		t.Fatalf("want open after a failed probe, got %s", b.State())
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := b.allow(); err != nil {
+		t.Fatalf("want probe allowed, got %v", err)
+	}
+	b.record(nil)
+	if b.State() != BreakerClosed {
+		t.Fatalf("want closed after a successful probe, got %s", b.State())
+	}
+}
+
+func TestInjectedFaultsOpenBreaker(t *testing.T) {
+	b := BookModel{
+		Retry:   RetryPolicy{MaxAttempts: 2},
+		Breaker: NewBreaker(2, time.Minute),
+		Faults:  NewFaultInjector(1),
+	}
+
+	calls := 0
+	fn := func(ctx context.Context) error {
+		calls++
+		return nil
+	}
+
+	for range 2 {
+		if err := b.do(context.Background(), true, fn); !errors.Is(err, ErrInjectedFault) {
+			t.Fatalf("want ErrInjectedFault, got %v", err)
+		}
+	}
+	if err := b.do(context.Background(), true, fn); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("want the breaker opened by the injected faults, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("want no call reaching the database, got %d", calls)
+	}
+}
@@ -0,0 +1,190 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/redis"
+)
+
+// CacheStore is the shared key/value store the BookCache keeps its entries in. It is
+// satisfied by *redis.Client, and Get must return redis.Nil for missing keys.
+type CacheStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// BookCache is a read-through cache of books and book listings shared by all instances of
+// the API. Books are cached by ID and dropped when they change. Listings are cached under
+// a generation number of the tenant, which is incremented on every change, so all listings
+// of the tenant are invalidated at once and the stale entries expire with their TTL.
+//
+// The cache is best effort: errors of the store are reported to observe and the models
+// fall back to the database. A read racing with a write may cache the old state of a
+// book until the entry expires.
+type BookCache struct {
+	store   CacheStore
+	bookTTL time.Duration
+	listTTL time.Duration
+	observe func(result string)
+}
+
+// cachedList is a cached page of a book listing.
+type cachedList struct {
+	Books    []*Book  `json:"books"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// NewBookCache returns a BookCache keeping books for bookTTL and listings for listTTL in
+// store. observe is called with "hit", "miss" or "error" for every lookup; it may be nil.
+func NewBookCache(store CacheStore, bookTTL, listTTL time.Duration, observe func(result string)) *BookCache {
+	if observe == nil {
+		observe = func(string) {}
+	}
+	return &BookCache{store: store, bookTTL: bookTTL, listTTL: listTTL, observe: observe}
+}
+
+// cacheTimeout bounds the calls to the store, which must not slow down requests more than
+// a database query would.
+const cacheTimeout = 200 * time.Millisecond
+
+func bookKey(tenant string, id int64) string {
+	return fmt.Sprintf("books:%s:%d", tenant, id)
+}
+
+func generationKey(tenant string) string {
+	return fmt.Sprintf("books:%s:gen", tenant)
+}
+
+// get decodes the entry of key into dst, reporting whether it was found. A nil BookCache
+// never has entries.
+func (c *BookCache) get(key string, dst interface{}) bool {
+	if c == nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cacheTimeout)
+	defer cancel()
+
+	js, err := c.store.Get(ctx, key)
+	switch {
+	case errors.Is(err, redis.Nil):
+		c.observe("miss")
+		return false
+	case err != nil:
+		c.observe("error")
+		return false
+	}
+
+	if err := json.Unmarshal(js, dst); err != nil {
+		c.observe("error")
+		return false
+	}
+
+	c.observe("hit")
+	return true
+}
+
+// set stores value under key for ttl.
+func (c *BookCache) set(key string, value interface{}, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+
+	js, err := json.Marshal(value)
+	if err != nil {
+		c.observe("error")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cacheTimeout)
+	defer cancel()
+
+	if err := c.store.Set(ctx, key, js, ttl); err != nil {
+		c.observe("error")
+	}
+}
+
+// getBook returns the cached book of the tenant.
+func (c *BookCache) getBook(tenant string, id int64) (*Book, bool) {
+	var book Book
+	if !c.get(bookKey(tenant, id), &book) {
+		return nil, false
+	}
+	return &book, true
+}
+
+// setBook caches the book of the tenant.
+func (c *BookCache) setBook(tenant string, book *Book) {
+	c.set(bookKey(tenant, book.ID), book, c.bookTTL)
+}
+
+// listKey returns the key of a listing page in the current generation of the tenant's
+// listings, identified by the hash of the filter key and the order and page of the query.
+// It returns false if the generation cannot be read.
+func (c *BookCache) listKey(tenant, filterKey string, filters Filters) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cacheTimeout)
+	defer cancel()
+
+	gen, err := c.store.Get(ctx, generationKey(tenant))
+	switch {
+	case errors.Is(err, redis.Nil):
+		gen = []byte("0")
+	case err != nil:
+		c.observe("error")
+		return "", false
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%d", filterKey, filters.Sort, filters.Page, filters.PageSize)))
+	return fmt.Sprintf("books:%s:list:%s:%s", tenant, gen, hex.EncodeToString(sum[:16])), true
+}
+
+// getList returns the cached listing page stored under key.
+func (c *BookCache) getList(key string) ([]*Book, Metadata, bool) {
+	var list cachedList
+	if !c.get(key, &list) {
+		return nil, Metadata{}, false
+	}
+	return list.Books, list.Metadata, true
+}
+
+// setList caches a listing page under key.
+func (c *BookCache) setList(key string, books []*Book, meta Metadata) {
+	c.set(key, cachedList{Books: books, Metadata: meta}, c.listTTL)
+}
+
+// Invalidate drops the cached books with the given IDs and all the cached listings of the
+// tenant.
+func (c *BookCache) Invalidate(tenant string, ids ...int64) {
+	if c == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cacheTimeout)
+	defer cancel()
+
+	if len(ids) > 0 {
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = bookKey(tenant, id)
+		}
+		if err := c.store.Del(ctx, keys...); err != nil {
+			c.observe("error")
+		}
+	}
+
+	if _, err := c.store.Incr(ctx, generationKey(tenant)); err != nil {
+		c.observe("error")
+	}
+}
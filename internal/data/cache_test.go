@@ -0,0 +1,130 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/nikitashershunov/LibraryAPI/internal/redis"
+)
+
+// memoryStore is a CacheStore keeping entries in a map, failing every call if down is set.
+type memoryStore struct {
+	entries map[string][]byte
+	down    bool
+}
+
+var errDown = errors.New("store is down")
+
+func (s *memoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if s.down {
+		return nil, errDown
+	}
+	v, ok := s.entries[key]
+	if !ok {
+		return nil, redis.Nil
+	}
+	return v, nil
+}
+
+func (s *memoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if s.down {
+		return errDown
+	}
+	s.entries[key] = value
+	return nil
+}
+
+func (s *memoryStore) Del(ctx context.Context, keys ...string) error {
+	if s.down {
+		return errDown
+	}
+	for _, key := range keys {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+func (s *memoryStore) Incr(ctx context.Context, key string) (int64, error) {
+	if s.down {
+		return 0, errDown
+	}
+	n, _ := strconv.ParseInt(string(s.entries[key]), 10, 64)
+	s.entries[key] = []byte(strconv.FormatInt(n+1, 10))
+	return n + 1, nil
+}
+
+func TestBookCache(t *testing.T) {
+	store := &memoryStore{entries: make(map[string][]byte)}
+	results := make(map[string]int)
+	c := NewBookCache(store, time.Minute, time.Minute, func(result string) { results[result]++ })
+
+	pages := Pages(412)
+	c.setBook("acme", &Book{ID: 1, Title: "Dune", Pages: &pages, Version: 3})
+
+	book, ok := c.getBook("acme", 1)
+	if !ok || book.Title != "Dune" || *book.Pages != 412 || book.Version != 3 {
+		t.Fatalf("want the cached book, got %+v, %v", book, ok)
+	}
+	if _, ok := c.getBook("other", 1); ok {
+		t.Fatal("want books cached by tenant")
+	}
+
+	filters := Filters{Page: 1, PageSize: 20, Sort: "id"}
+	key, ok := c.listKey("acme", "filter", filters)
+	if !ok {
+		t.Fatal("want a list key")
+	}
+	c.setList(key, []*Book{book}, Metadata{TotalRecords: 1})
+	if books, meta, ok := c.getList(key); !ok || len(books) != 1 || meta.TotalRecords != 1 {
+		t.Fatalf("want the cached list, got %v, %+v, %v", books, meta, ok)
+	}
+
+	c.Invalidate("acme", 1)
+	if _, ok := c.getBook("acme", 1); ok {
+		t.Error("want the book dropped")
+	}
+	if newKey, _ := c.listKey("acme", "filter", filters); newKey == key {
+		t.Error("want a new list key after invalidation")
+	}
+
+	store.down = true
+	if _, ok := c.getBook("acme", 1); ok {
+		t.Error("want a miss while the store is down")
+	}
+	if results["hit"] != 2 || results["miss"] != 2 || results["error"] != 1 {
+		t.Errorf("unexpected results %v", results)
+	}
+
+	var nilCache *BookCache
+	if _, ok := nilCache.getBook("acme", 1); ok {
+		t.Error("want a nil cache to be empty")
+	}
+	nilCache.Invalidate("acme", 1)
+}
+
+func TestBookCacheGenreList(t *testing.T) {
+	store := &memoryStore{entries: make(map[string][]byte)}
+	c := NewBookCache(store, time.Minute, time.Minute, nil)
+	filters := Filters{Page: 1, PageSize: 20, Sort: "id"}
+
+	// each listing builds its own query, so the genre filter is a new pq.Array every time.
+	listKey := func() string {
+		q := newSelectQuery("books")
+		q.Where("tenant_id = %s", "acme")
+		q.Where("genres @> %s", pq.Array([]string{"sci-fi"}))
+		key, ok := c.listKey("acme", q.FilterKey(), filters)
+		if !ok {
+			t.Fatal("want a list key")
+		}
+		return key
+	}
+
+	c.setList(listKey(), []*Book{{ID: 1, Title: "Dune"}}, Metadata{TotalRecords: 1})
+	if books, _, ok := c.getList(listKey()); !ok || len(books) != 1 {
+		t.Errorf("want the genre listing served from the cache, got %v, %v", books, ok)
+	}
+}
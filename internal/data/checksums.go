@@ -0,0 +1,53 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// BookChecksum is the checksum of the content of a book, see Book.ContentHash.
+type BookChecksum struct {
+	ID       int64  `json:"id"`
+	Checksum string `json:"checksum"`
+}
+
+// ContentHash returns the hex encoded SHA-256 hash of the content of the book: the fields set by
+// clients and whether it is archived. It does not depend on the ID, version or timestamps of
+// the book, so mirrors holding the same content get the same checksum, and it must be taken
+// before the title is localized.
+func (b *Book) ContentHash() string {
+	// The content is hashed as read back from the database, so the checksum of a book just
+	// written matches the one of the stored book: empty collections are left out like nil
+	// ones, and the numbers of the metadata are float64 values.
+	var metadata interface{}
+	if len(b.Metadata) > 0 {
+		js, _ := json.Marshal(b.Metadata)
+		json.Unmarshal(js, &metadata)
+	}
+	titles := b.Titles
+	if len(titles) == 0 {
+		titles = nil
+	}
+	genres := b.Genres
+	if len(genres) == 0 {
+		genres = nil
+	}
+
+	content := struct {
+		Title    string      `json:"title"`
+		Language string      `json:"language"`
+		Titles   Titles      `json:"titles"`
+		Year     *int32      `json:"year"`
+		Pages    *Pages      `json:"pages"`
+		Genres   []string    `json:"genres"`
+		ISBN     string      `json:"isbn"`
+		Metadata interface{} `json:"metadata"`
+		Archived bool        `json:"archived"`
+	}{b.Title, b.Language, titles, b.Year, b.Pages, genres, b.ISBN, metadata, b.Archived != nil}
+
+	// Maps are encoded with sorted keys, which makes the encoding deterministic.
+	js, _ := json.Marshal(content)
+	sum := sha256.Sum256(js)
+	return hex.EncodeToString(sum[:])
+}
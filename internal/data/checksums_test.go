@@ -0,0 +1,47 @@
+package data
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestBookContentHash(t *testing.T) {
+	year := int32(1965)
+	book := &Book{
+		ID:       1,
+		Title:    "Dune",
+		Year:     &year,
+		Genres:   []string{"sci-fi"},
+		Metadata: Attributes{"edition": json.Number("2")},
+		Version:  1,
+	}
+	hash := book.ContentHash()
+
+	// the same content read back from the database, under another ID and version.
+	stored := &Book{
+		ID:       7,
+		Title:    "Dune",
+		Titles:   Titles{},
+		Year:     &year,
+		Genres:   []string{"sci-fi"},
+		Metadata: Attributes{"edition": float64(2)},
+		Version:  3,
+		Updated:  time.Now(),
+	}
+	if stored.ContentHash() != hash {
+		t.Error("want the same hash of the same content")
+	}
+
+	now := time.Now()
+	stored.Archived = &now
+	if stored.ContentHash() == hash {
+		t.Error("want another hash of an archived book")
+	}
+
+	stored.Archived = nil
+	stored.Title = "Dune Messiah"
+	if stored.ContentHash() == hash {
+		t.Error("want another hash of another title")
+	}
+}
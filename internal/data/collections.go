@@ -0,0 +1,253 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// maxCollectionBooks is the number of books a collection may hold.
+const maxCollectionBooks = 100
+
+// SlugRX matches valid collection slugs: lowercase words of letters and digits joined by
+// hyphens.
+var SlugRX = regexp.MustCompile("^[a-z0-9]+(-[a-z0-9]+)*$")
+
+// Collection is a list of books featured by the staff, such as staff picks or a seasonal
+// display, identified by its slug. Its books are in the order they are featured in. It is
+// only shown to the public from Starts until Ends, when they are set.
+type Collection struct {
+	Slug        string     `json:"slug"`
+	Created     time.Time  `json:"created_at"`
+	Updated     time.Time  `json:"updated_at"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	BookIDs     []int64    `json:"book_ids"`
+	Starts      *time.Time `json:"starts_at,omitempty"`
+	Ends        *time.Time `json:"ends_at,omitempty"`
+	// Books holds the featured books, set for responses about a single collection; they
+	// are not stored.
+	Books []*Book `json:"books,omitempty"`
+}
+
+// Active reports whether the collection is shown to the public at now.
+func (c *Collection) Active(now time.Time) bool {
+	return (c.Starts == nil || !now.Before(*c.Starts)) && (c.Ends == nil || now.Before(*c.Ends))
+}
+
+// ValidateCollection runs validation checks on the Collection type.
+func ValidateCollection(v *validator.Validator, c *Collection) {
+	v.Check(c.Slug != "", "slug", "must be provided")
+	v.Check(len(c.Slug) <= 100, "slug", "must not be more than 100 bytes long")
+	v.Check(validator.Matches(c.Slug, SlugRX), "slug", "must contain only lowercase letters and digits separated by '-'")
+
+	v.Check(c.Title != "", "title", "must be provided")
+	v.Check(len(c.Title) <= 500, "title", "must not be more than 500 bytes long")
+	v.Check(len(c.Description) <= 5000, "description", "must not be more than 5000 bytes long")
+
+	v.Check(c.BookIDs != nil, "book_ids", "must be provided")
+	v.Check(len(c.BookIDs) <= maxCollectionBooks, "book_ids", "must not contain more than 100 books")
+	seen := make(map[int64]bool, len(c.BookIDs))
+	v.Each("book_ids", len(c.BookIDs), func(v *validator.Validator, i int) {
+		v.Check(c.BookIDs[i] > 0, "", "must be a positive integer")
+		v.Check(!seen[c.BookIDs[i]], "", "must not be a duplicate")
+		seen[c.BookIDs[i]] = true
+	})
+
+	if c.Starts != nil && c.Ends != nil {
+		v.Check(c.Ends.After(*c.Starts), "ends_at", "must be after starts_at")
+	}
+}
+
+// CollectionModel struct wraps a sql.DB connection pool and works with the collections
+// table. The collections are scoped to Tenant.
+type CollectionModel struct {
+	DB     *sql.DB
+	Tenant string
+}
+
+// ForTenant returns a copy of the model scoped to the given tenant.
+func (m CollectionModel) ForTenant(tenant string) CollectionModel {
+	m.Tenant = tenant
+	return m
+}
+
+// Set adds the collection or replaces the one with its slug, setting its creation and update
+// times, and reports whether it was added.
+func (m CollectionModel) Set(c *Collection) (bool, error) {
+	if m.Tenant == "" {
+		return false, ErrMissingTenant
+	}
+
+	query := `
+		INSERT INTO collections (tenant_id, slug, title, description, book_ids, starts_at, ends_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (tenant_id, slug) DO UPDATE
+		SET title = EXCLUDED.title, description = EXCLUDED.description, book_ids = EXCLUDED.book_ids,
+			starts_at = EXCLUDED.starts_at, ends_at = EXCLUDED.ends_at, updated_at = NOW()
+		RETURNING created_at, updated_at, xmax = 0`
+
+	args := []interface{}{m.Tenant, c.Slug, c.Title, c.Description, pq.Array(c.BookIDs), c.Starts, c.Ends}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var created bool
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&c.Created, &c.Updated, &created)
+	return created, err
+}
+
+// Get returns the collection with the slug, without its books, see Books.
+func (m CollectionModel) Get(slug string) (*Collection, error) {
+	if m.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	query := `
+		SELECT slug, created_at, updated_at, title, description, book_ids, starts_at, ends_at
+		FROM collections
+		WHERE tenant_id = $1 AND slug = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var c Collection
+	err := m.DB.QueryRowContext(ctx, query, m.Tenant, slug).Scan(
+		&c.Slug, &c.Created, &c.Updated, &c.Title, &c.Description, pq.Array(&c.BookIDs), &c.Starts, &c.Ends)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+// GetAll returns the collections of the tenant which are active at activeAt, or all of them
+// if activeAt is zero, the ones starting last first.
+func (m CollectionModel) GetAll(activeAt time.Time) ([]*Collection, error) {
+	if m.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	query := `
+		SELECT slug, created_at, updated_at, title, description, book_ids, starts_at, ends_at
+		FROM collections
+		WHERE tenant_id = $1 AND ($2::timestamptz IS NULL OR
+			((starts_at IS NULL OR starts_at <= $2) AND (ends_at IS NULL OR ends_at > $2)))
+		ORDER BY COALESCE(starts_at, created_at) DESC, slug`
+
+	var at *time.Time
+	if !activeAt.IsZero() {
+		at = &activeAt
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, m.Tenant, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*Collection{}
+	for rows.Next() {
+		var c Collection
+		err := rows.Scan(&c.Slug, &c.Created, &c.Updated, &c.Title, &c.Description, pq.Array(&c.BookIDs), &c.Starts, &c.Ends)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, &c)
+	}
+
+	return collections, rows.Err()
+}
+
+// Books returns the books of the collection in its order. Books deleted or archived since
+// they were added are left out.
+func (m CollectionModel) Books(c *Collection) ([]*Book, error) {
+	if m.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	query := `
+		SELECT b.id, b.created_at, b.updated_at, b.title, b.language, b.titles, b.year, b.pages, b.genres,
+			COALESCE(b.isbn, ''), b.metadata, b.archived_at, b.version
+		FROM unnest($2::bigint[]) WITH ORDINALITY AS c(id, position)
+		JOIN books b ON b.tenant_id = $1 AND b.id = c.id
+		WHERE b.archived_at IS NULL
+		ORDER BY c.position`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, m.Tenant, pq.Array(c.BookIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := []*Book{}
+	for rows.Next() {
+		var book Book
+		err := rows.Scan(
+			&book.ID,
+			&book.Created,
+			&book.Updated,
+			&book.Title,
+			&book.Language,
+			&book.Titles,
+			&book.Year,
+			&book.Pages,
+			pq.Array(&book.Genres),
+			&book.ISBN,
+			&book.Metadata,
+			&book.Archived,
+			&book.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, &book)
+	}
+
+	return books, rows.Err()
+}
+
+// Delete removes the collection with the slug. Its books are left as they are.
+func (m CollectionModel) Delete(slug string) error {
+	if m.Tenant == "" {
+		return ErrMissingTenant
+	}
+
+	query := `
+		DELETE FROM collections
+		WHERE tenant_id = $1 AND slug = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, m.Tenant, slug)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
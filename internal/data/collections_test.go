@@ -0,0 +1,66 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+func TestValidateCollection(t *testing.T) {
+	start := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name       string
+		collection Collection
+		want       map[string]string
+	}{
+		{"valid", Collection{Slug: "winter-reads-2024", Title: "Winter reads", BookIDs: []int64{3, 1}, Starts: &start, Ends: &end}, map[string]string{}},
+		{"no books", Collection{Slug: "empty", Title: "Empty", BookIDs: []int64{}}, map[string]string{}},
+		{
+			"invalid",
+			Collection{Slug: "Winter reads", BookIDs: []int64{1, 0, 1}, Starts: &end, Ends: &start},
+			map[string]string{
+				"slug":        "must contain only lowercase letters and digits separated by '-'",
+				"title":       "must be provided",
+				"book_ids[1]": "must be a positive integer",
+				"book_ids[2]": "must not be a duplicate",
+				"ends_at":     "must be after starts_at",
+			},
+		},
+		{"missing books", Collection{Slug: "picks", Title: "Picks"}, map[string]string{"book_ids": "must be provided"}},
+	}
+	for _, tt := range tests {
+		v := validator.New()
+		ValidateCollection(v, &tt.collection)
+		if !reflect.DeepEqual(v.Errors, tt.want) {
+			t.Errorf("%s: want errors %v, got %v", tt.name, tt.want, v.Errors)
+		}
+	}
+}
+
+func TestCollectionActive(t *testing.T) {
+	start := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	tests := []struct {
+		starts, ends *time.Time
+		now          time.Time
+		want         bool
+	}{
+		{nil, nil, start, true},
+		{&start, &end, start, true},
+		{&start, &end, start.Add(-time.Second), false},
+		{&start, &end, end, false},
+		{&start, nil, end.AddDate(1, 0, 0), true},
+		{nil, &end, start.AddDate(-1, 0, 0), true},
+	}
+	for _, tt := range tests {
+		c := Collection{Starts: tt.starts, Ends: tt.ends}
+		if got := c.Active(tt.now); got != tt.want {
+			t.Errorf("Active(%v) from %v until %v: want %t, got %t", tt.now, tt.starts, tt.ends, tt.want, got)
+		}
+	}
+}
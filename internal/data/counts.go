@@ -0,0 +1,82 @@
+package data
+
+import (
+	"sync"
+	"time"
+)
+
+// CountCache keeps the total number of records matching a filter combination for a
+// short time, so paging through the same listing does not recount the records on every
+// page. Entries are grouped by tenant and dropped whenever the tenant's books change.
+type CountCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]map[string]countEntry
+}
+
+type countEntry struct {
+	count   int
+	expires time.Time
+}
+
+// NewCountCache returns a CountCache keeping counts for ttl.
+func NewCountCache(ttl time.Duration) *CountCache {
+	return &CountCache{ttl: ttl, entries: make(map[string]map[string]countEntry)}
+}
+
+// get returns the cached count of key for the tenant. A nil CountCache never has entries.
+func (c *CountCache) get(tenant, key string) (int, bool) {
+	if c == nil {
+		return 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[tenant][key]
+	if !ok || time.Now().After(entry.expires) {
+		return 0, false
+	}
+	return entry.count, true
+}
+
+// set stores the count of key for the tenant. Expired entries of the tenant are removed.
+func (c *CountCache) set(tenant, key string, count int) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	counts, ok := c.entries[tenant]
+	if !ok {
+		counts = make(map[string]countEntry)
+		c.entries[tenant] = counts
+	}
+	for k, entry := range counts {
+		if now.After(entry.expires) {
+			delete(counts, k)
+		}
+	}
+
+	counts[key] = countEntry{count: count, expires: now.Add(c.ttl)}
+}
+
+// Invalidate drops all counts of the tenant, or of all tenants if tenant is empty.
+func (c *CountCache) Invalidate(tenant string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tenant == "" {
+		c.entries = make(map[string]map[string]countEntry)
+		return
+	}
+	delete(c.entries, tenant)
+}
@@ -0,0 +1,138 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// enrichmentKey is the metadata key recording the source of the enriched fields and the
+// fingerprints of the values written to them.
+const enrichmentKey = "enrichment"
+
+// Enrichment holds the details of a book found in an external catalog. Zero values are
+// missing details and are not merged.
+type Enrichment struct {
+	// Source names the catalog, e.g. "google_books", and SourceID the book in it.
+	Source      string
+	SourceID    string
+	Description string
+	Categories  []string
+	CoverURL    string
+	Year        *int32
+	Pages       *Pages
+}
+
+// Enrich merges the details of e into the book field by field and returns the names of
+// the fields it changed. The description, categories and cover URL go to the metadata of
+// the book, the year and pages to the book itself.
+//
+// A field is only written if it is empty or still holds the value written by the previous
+// enrichment, so values entered or edited by hand are never overwritten. The fingerprints
+// of the written values are kept under the "enrichment" metadata key to tell them apart.
+func Enrich(book *Book, e Enrichment, now time.Time) []string {
+	record := enrichmentRecord(book.Metadata)
+	if book.Metadata == nil {
+		book.Metadata = Attributes{}
+	}
+
+	var changed []string
+
+	// merge writes value to the field unless it has been edited since the last enrichment.
+	merge := func(field string, current, value interface{}, write func()) {
+		if value == nil {
+			return
+		}
+		if current != nil && fingerprint(current) != record[field] {
+			return
+		}
+		if current != nil && fingerprint(current) == fingerprint(value) {
+			return
+		}
+		write()
+		record[field] = fingerprint(value)
+		changed = append(changed, field)
+	}
+
+	if e.Description != "" {
+		merge("description", book.Metadata["description"], e.Description, func() {
+			book.Metadata["description"] = e.Description
+		})
+	}
+	if len(e.Categories) > 0 {
+		// stored as []interface{}, the way metadata decodes from JSON, so fingerprints match.
+		categories := make([]interface{}, len(e.Categories))
+		for i, c := range e.Categories {
+			categories[i] = c
+		}
+		merge("categories", book.Metadata["categories"], categories, func() {
+			book.Metadata["categories"] = categories
+		})
+	}
+	if e.CoverURL != "" {
+		merge("cover_url", book.Metadata["cover_url"], e.CoverURL, func() {
+			book.Metadata["cover_url"] = e.CoverURL
+		})
+	}
+	if e.Year != nil {
+		var current interface{}
+		if book.Year != nil {
+			current = *book.Year
+		}
+		merge("year", current, *e.Year, func() {
+			year := *e.Year
+			book.Year = &year
+		})
+	}
+	if e.Pages != nil {
+		var current interface{}
+		if book.Pages != nil {
+			current = int64(*book.Pages)
+		}
+		merge("pages", current, int64(*e.Pages), func() {
+			pages := *e.Pages
+			book.Pages = &pages
+		})
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	fingerprints := make(map[string]interface{}, len(record))
+	for field, fp := range record {
+		fingerprints[field] = fp
+	}
+	book.Metadata[enrichmentKey] = map[string]interface{}{
+		"source":      e.Source,
+		"source_id":   e.SourceID,
+		"enriched_at": now.UTC().Format(time.RFC3339),
+		"fields":      fingerprints,
+	}
+
+	return changed
+}
+
+// enrichmentRecord returns the fingerprints of the enriched fields recorded in metadata.
+func enrichmentRecord(metadata Attributes) map[string]string {
+	record := make(map[string]string)
+
+	enrichment, _ := metadata[enrichmentKey].(map[string]interface{})
+	fields, _ := enrichment["fields"].(map[string]interface{})
+	for field, fp := range fields {
+		if s, ok := fp.(string); ok {
+			record[field] = s
+		}
+	}
+
+	return record
+}
+
+// fingerprint returns a short hash of the JSON encoding of value. Numbers of any type
+// encode alike, so values read back from JSON metadata match the ones written.
+func fingerprint(value interface{}) string {
+	js, _ := json.Marshal(value)
+	sum := sha256.Sum256(js)
+	return hex.EncodeToString(sum[:8])
+}
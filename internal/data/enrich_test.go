@@ -0,0 +1,53 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnrich(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	year := int32(1965)
+	pages := Pages(412)
+
+	book := &Book{Title: "Dune", Metadata: Attributes{"cover_url": "https://example.com/manual.jpg"}}
+	e := Enrichment{
+		Source:      "google_books",
+		SourceID:    "B1hSG45JCX4C",
+		Description: "A desert planet.",
+		Categories:  []string{"Fiction"},
+		CoverURL:    "https://books.google.com/cover.jpg",
+		Year:        &year,
+		Pages:       &pages,
+	}
+
+	changed := Enrich(book, e, now)
+	if want := []string{"description", "categories", "year", "pages"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("want %v changed, got %v", want, changed)
+	}
+	if book.Metadata["cover_url"] != "https://example.com/manual.jpg" {
+		t.Errorf("want the cover URL entered by hand kept, got %v", book.Metadata["cover_url"])
+	}
+
+	// the book is stored and read back, then the description is edited by hand.
+	js, _ := json.Marshal(book)
+	book = &Book{}
+	json.Unmarshal(js, book)
+	book.Metadata["description"] = "Edited."
+
+	e.Description = "A desert planet, revised."
+	e.Categories = []string{"Fiction", "Science Fiction"}
+	changed = Enrich(book, e, now)
+	if want := []string{"categories"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("want %v changed, got %v", want, changed)
+	}
+	if book.Metadata["description"] != "Edited." {
+		t.Errorf("want the edited description kept, got %v", book.Metadata["description"])
+	}
+
+	if changed := Enrich(book, e, now); changed != nil {
+		t.Errorf("want nothing changed by the same enrichment, got %v", changed)
+	}
+}
@@ -0,0 +1,134 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// Statuses of export jobs.
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+// ExportFormats are the formats of export files: a JSON array of the books, or newline
+// delimited JSON with one book per line.
+var ExportFormats = []string{"json", "ndjson"}
+
+// Export is a job writing the books of a tenant to a file in the storage.
+type Export struct {
+	ID              int64      `json:"id"`
+	Created         time.Time  `json:"created_at"`
+	Format          string     `json:"format"`
+	IncludeArchived bool       `json:"include_archived"`
+	Status          string     `json:"status"`
+	Books           int64      `json:"books"`
+	Error           *string    `json:"error,omitempty"`
+	Finished        *time.Time `json:"finished_at,omitempty"`
+	// DownloadURL is the URL the file of a finished export is downloaded from, set for
+	// responses; it is not stored.
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// ValidateExport runs validation checks on the Export type.
+func ValidateExport(v *validator.Validator, export *Export) {
+	v.Check(validator.In(export.Format, ExportFormats...), "format", "must be json or ndjson")
+}
+
+// ExportModel struct wraps a sql.DB connection pool and works with the exports table. The
+// exports are scoped to Tenant.
+type ExportModel struct {
+	DB     *sql.DB
+	Tenant string
+}
+
+// ForTenant returns a copy of the model scoped to the given tenant.
+func (m ExportModel) ForTenant(tenant string) ExportModel {
+	m.Tenant = tenant
+	return m
+}
+
+// Insert adds a new pending export record, setting its ID, creation time and status.
+func (m ExportModel) Insert(export *Export) error {
+	if m.Tenant == "" {
+		return ErrMissingTenant
+	}
+
+	query := `
+		INSERT INTO exports (tenant_id, format, include_archived)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, status`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, m.Tenant, export.Format, export.IncludeArchived).
+		Scan(&export.ID, &export.Created, &export.Status)
+}
+
+// Get returns the export of the tenant with the given ID.
+func (m ExportModel) Get(id int64) (*Export, error) {
+	if m.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, format, include_archived, status, books, error, finished_at
+		FROM exports
+		WHERE id = $1 AND tenant_id = $2`
+
+	var export Export
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, m.Tenant).Scan(
+		&export.ID,
+		&export.Created,
+		&export.Format,
+		&export.IncludeArchived,
+		&export.Status,
+		&export.Books,
+		&export.Error,
+		&export.Finished,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &export, nil
+}
+
+// Update stores the status, the number of books written, the error and the finish time of
+// the export.
+func (m ExportModel) Update(export *Export) error {
+	if m.Tenant == "" {
+		return ErrMissingTenant
+	}
+
+	query := `
+		UPDATE exports
+		SET status = $1, books = $2, error = $3, finished_at = $4
+		WHERE id = $5 AND tenant_id = $6`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, export.Status, export.Books, export.Error, export.Finished, export.ID, m.Tenant)
+	return err
+}
@@ -0,0 +1,32 @@
+package data
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/rand/v2"
+)
+
+// ErrInjectedFault is returned by the database calls failed by a FaultInjector. It counts as
+// a connection failure, so the calls are retried and open the circuit breaker like those of
+// an unreachable database.
+var ErrInjectedFault = fmt.Errorf("injected database fault: %w", driver.ErrBadConn)
+
+// FaultInjector fails a fraction of the database calls of a model before they reach the
+// database, to test the retries and the circuit breaker. A nil FaultInjector fails no calls.
+type FaultInjector struct {
+	rate float64
+}
+
+// NewFaultInjector returns a FaultInjector failing the fraction rate, between 0 and 1, of
+// the calls.
+func NewFaultInjector(rate float64) *FaultInjector {
+	return &FaultInjector{rate: rate}
+}
+
+// inject returns ErrInjectedFault for the fraction of the calls to fail, nil otherwise.
+func (f *FaultInjector) inject() error {
+	if f == nil || rand.Float64() >= f.rate {
+		return nil
+	}
+	return ErrInjectedFault
+}
@@ -7,20 +7,28 @@ import (
 	"github.com/nikitashershunov/LibraryAPI/internal/validator"
 )
 
+// Filters holds the pagination and sorting of listings. The query tags name the query
+// string parameters they are read from.
 type Filters struct {
-	Page         int
-	PageSize     int
-	Sort         string
+	Page         int    `query:"page"`
+	PageSize     int    `query:"page_size"`
+	Sort         string `query:"sort"`
 	SortSafelist []string
 }
 
-// Metadata holds pagination metadata.
+// Metadata holds pagination metadata. NextURL and PrevURL are set by the handlers, which
+// know the URL of the listing.
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalPages   int    `json:"total_pages,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	HasNext      bool   `json:"has_next"`
+	HasPrev      bool   `json:"has_prev"`
+	NextURL      string `json:"next,omitempty"`
+	PrevURL      string `json:"prev,omitempty"`
 }
 
 // ValidateFilters runs validation checks on the Filters type.
@@ -42,32 +50,34 @@ func calculateMetadata(totalRecords, page, pageSize int) Metadata {
 		return Metadata{}
 	}
 
+	lastPage := int(math.Ceil(float64(totalRecords) / float64(pageSize)))
 	return Metadata{
 		CurrentPage:  page,
 		PageSize:     pageSize,
 		FirstPage:    1,
-		LastPage:     int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+		LastPage:     lastPage,
+		TotalPages:   lastPage,
 		TotalRecords: totalRecords,
+		HasNext:      page < lastPage,
+		HasPrev:      page > 1,
 	}
 }
 
-// sortColumn checks that Sort field matches a value in SortSafeList and
-// it extracts the column name from the Sort field.
-func (f Filters) sortColumn() string {
+// sortColumn checks that Sort field matches a value in SortSafeList and maps the sort key,
+// without the "-" prefix, to one of the given columns. It returns false if there is no match.
+func (f Filters) sortColumn(columns map[string]string) (string, bool) {
 	for _, safeValue := range f.SortSafelist {
 		if f.Sort == safeValue {
-			return strings.TrimPrefix(f.Sort, "-")
+			column, ok := columns[strings.TrimPrefix(f.Sort, "-")]
+			return column, ok
 		}
 	}
-	panic("unsafe sort parameter: " + f.Sort)
+	return "", false
 }
 
-// sortDirection returns the sort direction ("ASC" or "DESC") based on the Sort field.
-func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
-	}
-	return "ASC"
+// sortDescending reports whether the Sort field asks for descending order.
+func (f Filters) sortDescending() bool {
+	return strings.HasPrefix(f.Sort, "-")
 }
 
 func (f Filters) limit() int {
@@ -0,0 +1,291 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/nikitashershunov/LibraryAPI/internal/events"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// ErrGenreAliasChain is returned when an alias would map to another alias, or a genre which
+// aliases map to would become an alias itself.
+var ErrGenreAliasChain = errors.New("genre alias chain")
+
+// GenreAlias maps a variant spelling of a genre, such as "sci-fi", to the canonical genre,
+// such as "science fiction". Aliases are matched regardless of case.
+type GenreAlias struct {
+	Alias   string    `json:"alias"`
+	Created time.Time `json:"created_at"`
+	Genre   string    `json:"genre"`
+}
+
+// NormalizeGenreAlias returns the form aliases are stored and looked up in.
+func NormalizeGenreAlias(alias string) string {
+	return strings.ToLower(strings.TrimSpace(alias))
+}
+
+// ValidateGenreAlias runs validation checks on the GenreAlias type.
+func ValidateGenreAlias(v *validator.Validator, alias *GenreAlias) {
+	v.Check(alias.Alias != "", "alias", "must be provided")
+	v.Check(len(alias.Alias) <= 100, "alias", "must not be more than 100 bytes long")
+
+	v.Check(alias.Genre != "", "genre", "must be provided")
+	v.Check(len(alias.Genre) <= 100, "genre", "must not be more than 100 bytes long")
+	v.Check(NormalizeGenreAlias(alias.Genre) != alias.Alias, "genre", "must differ from the alias")
+}
+
+// GenreAliasModel struct wraps a sql.DB connection pool and works with the genre_aliases
+// table. The aliases are scoped to Tenant.
+type GenreAliasModel struct {
+	DB     *sql.DB
+	Tenant string
+}
+
+// ForTenant returns a copy of the model scoped to the given tenant.
+func (m GenreAliasModel) ForTenant(tenant string) GenreAliasModel {
+	m.Tenant = tenant
+	return m
+}
+
+// Set adds the alias or changes the genre it maps to, setting its creation time, and
+// reports whether it was added. Aliases map straight to canonical genres: it returns
+// ErrGenreAliasChain if the genre is an alias or the alias is the genre of other aliases.
+func (m GenreAliasModel) Set(alias *GenreAlias) (bool, error) {
+	if m.Tenant == "" {
+		return false, ErrMissingTenant
+	}
+
+	query := `
+		INSERT INTO genre_aliases (tenant_id, alias, genre)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (
+			SELECT 1 FROM genre_aliases
+			WHERE tenant_id = $1 AND (alias = $4 OR lower(genre) = $2)
+		)
+		ON CONFLICT (tenant_id, alias) DO UPDATE SET genre = EXCLUDED.genre
+		RETURNING created_at, xmax = 0`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var created bool
+	err := m.DB.QueryRowContext(ctx, query, m.Tenant, alias.Alias, alias.Genre, NormalizeGenreAlias(alias.Genre)).Scan(&alias.Created, &created)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrGenreAliasChain
+		default:
+			return false, err
+		}
+	}
+
+	return created, nil
+}
+
+// GetAll returns the aliases of the tenant, ordered by the genre they map to.
+func (m GenreAliasModel) GetAll() ([]*GenreAlias, error) {
+	if m.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	query := `
+		SELECT alias, created_at, genre
+		FROM genre_aliases
+		WHERE tenant_id = $1
+		ORDER BY genre, alias`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, m.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []*GenreAlias{}
+	for rows.Next() {
+		var alias GenreAlias
+		err := rows.Scan(&alias.Alias, &alias.Created, &alias.Genre)
+		if err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, &alias)
+	}
+
+	return aliases, rows.Err()
+}
+
+// Delete removes the alias. Books keep the genres they were given through it.
+func (m GenreAliasModel) Delete(alias string) error {
+	if m.Tenant == "" {
+		return ErrMissingTenant
+	}
+
+	query := `
+		DELETE FROM genre_aliases
+		WHERE tenant_id = $1 AND alias = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, m.Tenant, NormalizeGenreAlias(alias))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Resolve returns the genres with the aliases among them replaced by the genres they map to.
+// Genres which converge on the same canonical genre are only kept once, in the place of the
+// first of them, see ResolveGenres.
+func (m GenreAliasModel) Resolve(genres []string) ([]string, error) {
+	if len(genres) == 0 {
+		return genres, nil
+	}
+	if m.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	query := `
+		SELECT alias, genre
+		FROM genre_aliases
+		WHERE tenant_id = $1 AND alias = ANY($2)`
+
+	keys := make([]string, len(genres))
+	for i, genre := range genres {
+		keys[i] = NormalizeGenreAlias(genre)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, m.Tenant, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	canonical := make(map[string]string)
+	for rows.Next() {
+		var alias, genre string
+		if err := rows.Scan(&alias, &genre); err != nil {
+			return nil, err
+		}
+		canonical[alias] = genre
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ResolveGenres(genres, canonical), nil
+}
+
+// ResolveGenres replaces the genres found in aliases, keyed by NormalizeGenreAlias, by the
+// genres they map to and drops the repeats this results in. Genres repeated as given are
+// kept, for validation to report them.
+func ResolveGenres(genres []string, aliases map[string]string) []string {
+	resolved := make([]string, 0, len(genres))
+	seen := make(map[string]bool, len(genres))
+	for _, genre := range genres {
+		canonical, ok := aliases[NormalizeGenreAlias(genre)]
+		if ok {
+			genre = canonical
+		}
+		if aliased, found := seen[genre]; found && (ok || aliased) {
+			seen[genre] = true
+			continue
+		}
+		seen[genre] = ok
+		resolved = append(resolved, genre)
+	}
+	return resolved
+}
+
+// RenameGenre replaces the genre from by the genre to in all the books of the tenant which
+// have it, archived ones included, and returns the number of books changed. Books which
+// already have the genre to keep it once, in its first place, which merges the genres. The
+// books are changed in a single transaction, getting a new version and an update event.
+func (b BookModel) RenameGenre(from, to string) (int64, error) {
+	if b.Tenant == "" {
+		return 0, ErrMissingTenant
+	}
+
+	query := `
+		UPDATE books
+		SET genres = ARRAY(
+				SELECT g FROM unnest(array_replace(genres, $2, $3)) WITH ORDINALITY AS t(g, i)
+				GROUP BY g
+				ORDER BY min(i)
+			),
+			updated_at = NOW(), version = version + 1
+		WHERE tenant_id = $1 AND $2 = ANY(genres)
+		RETURNING id, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	type change struct {
+		id      int64
+		version int32
+	}
+
+	var changed []change
+
+	err := b.do(ctx, false, func(ctx context.Context) error {
+		return withTx(ctx, b.DB, func(tx *sql.Tx) error {
+			changed = changed[:0]
+
+			rows, err := tx.QueryContext(ctx, query, b.Tenant, from, to)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var c change
+				if err := rows.Scan(&c.id, &c.version); err != nil {
+					return err
+				}
+				changed = append(changed, c)
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			for _, c := range changed {
+				if err := b.notify(ctx, tx, events.BookUpdated, c.id, c.version); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]int64, len(changed))
+	for i, c := range changed {
+		ids[i] = c.id
+	}
+	b.Counts.Invalidate(b.Tenant)
+	b.Cache.Invalidate(b.Tenant, ids...)
+
+	return int64(len(changed)), nil
+}
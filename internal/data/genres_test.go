@@ -0,0 +1,26 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveGenres(t *testing.T) {
+	aliases := map[string]string{"sci-fi": "science fiction", "scifi": "science fiction"}
+
+	tests := []struct {
+		genres []string
+		want   []string
+	}{
+		{[]string{"Sci-Fi", "drama"}, []string{"science fiction", "drama"}},
+		{[]string{"sci-fi", "science fiction"}, []string{"science fiction"}},
+		{[]string{"science fiction", "scifi", "sci-fi"}, []string{"science fiction"}},
+		{[]string{"drama", "drama"}, []string{"drama", "drama"}},
+		{[]string{}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := ResolveGenres(tt.genres, aliases); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ResolveGenres(%q): want %q, got %q", tt.genres, tt.want, got)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"regexp"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
 )
 
 var (
@@ -11,15 +15,66 @@ var (
 
 	// ErrEditConflict is returned when there is a data race.
 	ErrEditConflict = errors.New("edit conflict")
+
+	// ErrDuplicateISBN is returned when another book record already has the same ISBN.
+	ErrDuplicateISBN = errors.New("duplicate isbn")
+
+	// ErrMissingTenant is returned when a model is used without being scoped to a tenant.
+	ErrMissingTenant = errors.New("missing tenant")
 )
 
+// DefaultTenant owns all records of a single-tenant deployment.
+const DefaultTenant = "default"
+
+// TenantRX matches valid tenant identifiers.
+var TenantRX = regexp.MustCompile("^[a-z0-9][a-z0-9_-]{0,62}$")
+
+// ValidateTenant runs validation checks on a tenant identifier.
+func ValidateTenant(v *validator.Validator, tenant string) {
+	v.Check(tenant != "", "tenant", "must be provided")
+	v.Check(validator.Matches(tenant, TenantRX), "tenant", "must contain only lowercase letters, digits, '-' and '_'")
+}
+
 // Models struct is a single container to hold all database models.
 type Models struct {
-	Books BookModel
+	APIKeys       APIKeyModel
+	Books         BookModel
+	Collections   CollectionModel
+	Exports       ExportModel
+	GenreAliases  GenreAliasModel
+	Notifications NotificationModel
+	Outbox        OutboxModel
+	SearchLog     SearchLogModel
+	System        SystemModel
+	Webhooks      WebhookModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Books: BookModel{DB: db},
+		APIKeys:       APIKeyModel{DB: db},
+		Books:         BookModel{DB: db, Retry: DefaultRetryPolicy},
+		Collections:   CollectionModel{DB: db},
+		Exports:       ExportModel{DB: db},
+		GenreAliases:  GenreAliasModel{DB: db},
+		Notifications: NotificationModel{DB: db},
+		Outbox:        OutboxModel{DB: db},
+		SearchLog:     SearchLogModel{DB: db},
+		System:        SystemModel{DB: db},
+		Webhooks:      WebhookModel{DB: db},
 	}
 }
+
+// withTx runs fn inside a transaction, which is committed if fn succeeds and rolled back otherwise.
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// notificationRetention is how long sent notifications are remembered. Events are
+// received by all API instances within seconds, so a day is plenty.
+const notificationRetention = 24 * time.Hour
+
+// NotificationModel records the chat notifications sent, so that every API instance
+// receiving an event does not send its own.
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+// Claim records the notification with the given key. It returns false if another instance
+// has claimed it already. Notifications older than notificationRetention are forgotten.
+func (m NotificationModel) Claim(key string) (bool, error) {
+	query := `
+		WITH pruned AS (
+			DELETE FROM notifications WHERE created_at < $2
+		)
+		INSERT INTO notifications (key)
+		VALUES ($1)
+		ON CONFLICT (key) DO NOTHING
+		RETURNING key`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, key, time.Now().Add(-notificationRetention)).Scan(&key)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/nikitashershunov/LibraryAPI/internal/events"
+)
+
+// outboxLock is the key of the advisory lock held while relaying the outbox, so a single
+// instance publishes the events at a time and in order.
+const outboxLock = 0x6f7574626f78
+
+// OutboxEvent is a book event waiting in the outbox to be published.
+type OutboxEvent struct {
+	ID int64
+	events.Event
+}
+
+// OutboxModel struct wraps a sql.DB connection pool and works with the outbox table, which
+// book changes are written to in the same transaction when BookModel.Outbox is set. It is
+// not scoped to a tenant, as the relay publishes the events of all tenants.
+type OutboxModel struct {
+	DB *sql.DB
+}
+
+// Relay passes up to limit events from the outbox to publish, oldest first, and removes the
+// published ones. It stops at the first event publish fails for, which is retried with the
+// events after it on the next call, so events are published at least once and in order.
+// It returns the number of published events; if another instance is relaying at the time
+// it returns 0 right away.
+func (m OutboxModel) Relay(limit int, publish func(OutboxEvent) error) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var published []int64
+	var publishErr error
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		var locked bool
+		err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", outboxLock).Scan(&locked)
+		if err != nil || !locked {
+			return err
+		}
+
+		query := `
+			SELECT id, created_at, tenant_id, type, book_id, version
+			FROM outbox
+			ORDER BY id
+			LIMIT $1`
+
+		rows, err := tx.QueryContext(ctx, query, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var pending []OutboxEvent
+		for rows.Next() {
+			var e OutboxEvent
+			err := rows.Scan(&e.ID, &e.Time, &e.Tenant, &e.Type, &e.BookID, &e.Version)
+			if err != nil {
+				return err
+			}
+			pending = append(pending, e)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, e := range pending {
+			if publishErr = publish(e); publishErr != nil {
+				break
+			}
+			published = append(published, e.ID)
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM outbox WHERE id = ANY($1)", pq.Array(published))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(published), publishErr
+}
+
+// Backlog returns the number of events waiting in the outbox.
+func (m OutboxModel) Backlog() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var n int
+	err := m.DB.QueryRowContext(ctx, "SELECT count(*) FROM outbox").Scan(&n)
+	return n, err
+}
@@ -3,12 +3,17 @@ package data
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
 
-// ErrInvalidCountPagesFormat returns error when we are unable to parse or convert a JSON string for Pages.
-var ErrInvalidCountPagesFormat = errors.New("invalid count pages format")
+var (
+	// ErrInvalidCountPagesFormat returns error when we are unable to parse or convert a JSON value for Pages.
+	ErrInvalidCountPagesFormat = errors.New("invalid count pages format")
+	// ErrCountPagesOutOfRange returns error when a JSON value for Pages is negative or too large.
+	ErrCountPagesOutOfRange = errors.New("count pages must be between 0 and 2147483647")
+)
 
 type Pages int64
 
@@ -20,20 +25,30 @@ func (p Pages) MarshalJSON() ([]byte, error) {
 }
 
 // UnmarshalJSON ensures that Pages satisfies the
-// json.Unmarshaler interface.
+// json.Unmarshaler interface. It accepts the "<pages> pages" form written by MarshalJSON,
+// a bare integer such as 412 and a numeric string such as "412", and rejects negative
+// counts and counts overflowing the integer column they are stored in.
 func (p *Pages) UnmarshalJSON(jsVal []byte) error {
-	unquotedJsVal, err := strconv.Unquote(string(jsVal))
-	if err != nil {
-		return ErrInvalidCountPagesFormat
-	}
-	parts := strings.Split(unquotedJsVal, " ")
-	if len(parts) != 2 || parts[1] != "pages" {
-		return ErrInvalidCountPagesFormat
+	s := string(jsVal)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = strings.TrimSuffix(unquoted, " pages")
 	}
-	i, err := strconv.ParseInt(parts[0], 10, 64)
-	if err != nil {
+
+	i, err := strconv.ParseInt(s, 10, 64)
+	switch {
+	case errors.Is(err, strconv.ErrRange):
+		return ErrCountPagesOutOfRange
+	case err != nil:
 		return ErrInvalidCountPagesFormat
+	case i < 0 || i > math.MaxInt32:
+		return ErrCountPagesOutOfRange
 	}
 	*p = Pages(i)
 	return nil
 }
+
+// ReadingMinutes estimates the minutes needed to read the pages, rounded up, given the
+// words per page and the reading speed in words per minute.
+func (p Pages) ReadingMinutes(wordsPerPage, wordsPerMinute int) int64 {
+	return int64(math.Ceil(float64(p) * float64(wordsPerPage) / float64(wordsPerMinute)))
+}
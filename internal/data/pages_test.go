@@ -0,0 +1,84 @@
+package data
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestPagesUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		js   string
+		want Pages
+		err  error
+	}{
+		{js: `"412 pages"`, want: 412},
+		{js: `412`, want: 412},
+		{js: `"412"`, want: 412},
+		{js: `0`, want: 0},
+		{js: `-1`, err: ErrCountPagesOutOfRange},
+		{js: `"-1 pages"`, err: ErrCountPagesOutOfRange},
+		{js: `2147483647`, want: 2147483647},
+		{js: `3000000000`, err: ErrCountPagesOutOfRange},
+		{js: `9223372036854775808`, err: ErrCountPagesOutOfRange},
+		{js: `412.5`, err: ErrInvalidCountPagesFormat},
+		{js: `"412 page"`, err: ErrInvalidCountPagesFormat},
+		{js: `"pages"`, err: ErrInvalidCountPagesFormat},
+		{js: `true`, err: ErrInvalidCountPagesFormat},
+	}
+
+	for _, tt := range tests {
+		var p Pages
+		err := json.Unmarshal([]byte(tt.js), &p)
+		if err != tt.err {
+			t.Errorf("%s: want error %v, got %v", tt.js, tt.err, err)
+			continue
+		}
+		if err == nil && p != tt.want {
+			t.Errorf("%s: want %d, got %d", tt.js, tt.want, p)
+		}
+	}
+
+	js, err := json.Marshal(Pages(412))
+	if err != nil || string(js) != `"412 pages"` {
+		t.Errorf(`want "412 pages", got %s (%v)`, js, err)
+	}
+}
+
+func TestReadingMinutes(t *testing.T) {
+	if got := Pages(412).ReadingMinutes(250, 240); got != 430 {
+		t.Errorf("want 430 minutes, got %d", got)
+	}
+	if _, ok := (&Book{}).ReadingMinutes(250, 240); ok {
+		t.Error("want no estimate of a book without pages")
+	}
+}
+
+// FuzzPagesUnmarshalJSON checks that page counts are never negative or too large and that the counts
+// accepted survive a round trip through their JSON form. Run with:
+//
+//	go test ./internal/data -run=^$ -fuzz=FuzzPagesUnmarshalJSON
+func FuzzPagesUnmarshalJSON(f *testing.F) {
+	for _, js := range []string{`"412 pages"`, `412`, `"412"`, `0`, `-1`, `"-1 pages"`, `3000000000`, `9223372036854775808`, `412.5`, `"412 page"`, `null`, `true`} {
+		f.Add([]byte(js))
+	}
+
+	f.Fuzz(func(t *testing.T, js []byte) {
+		var p Pages
+		if err := p.UnmarshalJSON(js); err != nil {
+			return
+		}
+		if p < 0 || p > math.MaxInt32 {
+			t.Fatalf("%s: got count %d out of range", js, p)
+		}
+
+		out, err := json.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var again Pages
+		if err := json.Unmarshal(out, &again); err != nil || again != p {
+			t.Fatalf("%s: round trip through %s gave %d (%v)", js, out, again, err)
+		}
+	})
+}
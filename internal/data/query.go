@@ -0,0 +1,117 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// selectQuery composes a SELECT statement from validated parts. Values never become part
+// of the SQL text: each of them is passed as a numbered placeholder argument, and the only
+// identifiers placed in the statement are quoted column names picked by the caller.
+type selectQuery struct {
+	columns []string
+	from    string
+	where   []string
+	orderBy []string
+	limit   int
+	offset  int
+	args    []interface{}
+}
+
+// newSelectQuery returns a query selecting the given columns from a table.
+func newSelectQuery(from string, columns ...string) *selectQuery {
+	return &selectQuery{from: from, columns: columns}
+}
+
+// Columns appends columns to the selected ones.
+func (q *selectQuery) Columns(columns ...string) *selectQuery {
+	q.columns = append(q.columns, columns...)
+	return q
+}
+
+// FilterKey returns a string identifying the conditions and their values, which is the
+// same for all queries selecting the same set of rows regardless of order and paging.
+func (q *selectQuery) FilterKey() string {
+	values := make([]interface{}, len(q.args))
+	for i, arg := range q.args {
+		values[i] = arg
+		// arguments such as pq.Array are pointers, the key must hold the value they encode.
+		if valuer, ok := arg.(driver.Valuer); ok {
+			if value, err := valuer.Value(); err == nil {
+				values[i] = value
+			}
+		}
+	}
+
+	js, _ := json.Marshal(values)
+	return strings.Join(q.where, " AND ") + "|" + string(js)
+}
+
+// arg adds a placeholder argument and returns the placeholder.
+func (q *selectQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// Where adds a condition joined with AND to the others. Each %s verb in cond is replaced
+// with a placeholder of the corresponding value from args.
+func (q *selectQuery) Where(cond string, args ...interface{}) *selectQuery {
+	placeholders := make([]interface{}, len(args))
+	for i, value := range args {
+		placeholders[i] = q.arg(value)
+	}
+	q.where = append(q.where, "("+fmt.Sprintf(cond, placeholders...)+")")
+	return q
+}
+
+// OrderBy adds a sort column. column must come from a fixed list of known columns,
+// it is quoted as an identifier; descending selects the DESC direction.
+func (q *selectQuery) OrderBy(column string, descending bool) *selectQuery {
+	direction := "ASC"
+	if descending {
+		direction = "DESC"
+	}
+	q.orderBy = append(q.orderBy, pq.QuoteIdentifier(column)+" "+direction)
+	return q
+}
+
+// Page sets LIMIT and OFFSET of the query.
+func (q *selectQuery) Page(limit, offset int) *selectQuery {
+	q.limit = limit
+	q.offset = offset
+	return q
+}
+
+// Build returns the SQL statement and its arguments. It does not modify the query.
+func (q *selectQuery) Build() (string, []interface{}) {
+	var sb strings.Builder
+
+	sb.WriteString("SELECT ")
+	sb.WriteString(strings.Join(q.columns, ", "))
+	sb.WriteString(" FROM ")
+	sb.WriteString(q.from)
+
+	if len(q.where) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(q.where, " AND "))
+	}
+
+	if len(q.orderBy) > 0 {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(strings.Join(q.orderBy, ", "))
+	}
+
+	args := append([]interface{}{}, q.args...)
+
+	if q.limit > 0 {
+		args = append(args, q.limit, q.offset)
+		fmt.Fprintf(&sb, " LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	return sb.String(), args
+}
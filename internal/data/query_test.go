@@ -0,0 +1,52 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestSelectQueryBuild(t *testing.T) {
+	q := newSelectQuery("books", "id", "title")
+	q.Where("tenant_id = %s", "default")
+	q.Where("archived_at IS NULL")
+	q.Where("metadata ->> %s = %s", "edition", "2")
+	q.OrderBy("title", true)
+	q.OrderBy("id", false)
+	q.Page(20, 40)
+
+	query, args := q.Build()
+
+	wantQuery := `SELECT id, title FROM books WHERE (tenant_id = $1) AND (archived_at IS NULL) AND (metadata ->> $2 = $3) ORDER BY "title" DESC, "id" ASC LIMIT $4 OFFSET $5`
+	if query != wantQuery {
+		t.Errorf("want query %q,\n but got %q", wantQuery, query)
+	}
+
+	wantArgs := []interface{}{"default", "edition", "2", 20, 40}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("want args %v, got %v", wantArgs, args)
+	}
+
+	// Building again must not change the result.
+	if again, _ := q.Build(); again != query {
+		t.Errorf("want repeated build to equal %q, got %q", query, again)
+	}
+}
+
+func TestSelectQueryFilterKey(t *testing.T) {
+	build := func(genres ...string) *selectQuery {
+		q := newSelectQuery("books", "id")
+		q.Where("tenant_id = %s", "default")
+		q.Where("genres @> %s", pq.Array(genres))
+		return q
+	}
+
+	key := build("sci-fi", "classic").FilterKey()
+	if again := build("sci-fi", "classic").FilterKey(); again != key {
+		t.Errorf("want separately built queries to share the key %q, got %q", key, again)
+	}
+	if other := build("sci-fi").FilterKey(); other == key {
+		t.Errorf("want other genres to change the key, got %q for both", key)
+	}
+}
@@ -0,0 +1,164 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
+)
+
+// maxLoggedStatementLength is the length SQL statements are truncated to in the log.
+const maxLoggedStatementLength = 200
+
+// NewLoggingConnector wraps a driver.Connector so every query and exec is logged at DEBUG
+// level with its duration, row count and a truncated statement. Statements taking longer
+// than slowThreshold are also logged at WARN level; zero disables slow query logging.
+// If observe is not nil it is called with the duration of every statement.
+func NewLoggingConnector(c driver.Connector, logger *jsonlog.Logger, slowThreshold time.Duration, observe func(time.Duration)) driver.Connector {
+	return &loggingConnector{Connector: c, log: &queryLogger{logger: logger, slowThreshold: slowThreshold, observe: observe}}
+}
+
+// queryLogger writes query log entries.
+type queryLogger struct {
+	logger        *jsonlog.Logger
+	slowThreshold time.Duration
+	observe       func(time.Duration)
+}
+
+// log writes the log entry of a finished statement. rows is -1 if the row count is unknown.
+func (l *queryLogger) log(query string, started time.Time, rows int64, err error) {
+	duration := time.Since(started)
+	if l.observe != nil {
+		l.observe(duration)
+	}
+
+	properties := map[string]string{
+		"statement": truncateStatement(query),
+		"duration":  duration.String(),
+	}
+	if rows >= 0 {
+		properties["rows"] = strconv.FormatInt(rows, 10)
+	}
+	if err != nil {
+		properties["error"] = err.Error()
+	}
+
+	l.logger.PrintDebug("database query", properties)
+
+	if l.slowThreshold > 0 && duration > l.slowThreshold {
+		properties["threshold"] = l.slowThreshold.String()
+		l.logger.PrintWarn("slow database query", properties)
+	}
+}
+
+// truncateStatement collapses whitespace of a SQL statement and cuts it to maxLoggedStatementLength.
+func truncateStatement(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxLoggedStatementLength {
+		return query[:maxLoggedStatementLength] + "..."
+	}
+	return query
+}
+
+type loggingConnector struct {
+	driver.Connector
+	log *queryLogger
+}
+
+// Connect satisfies the driver.Connector interface and wraps the new connection.
+func (c *loggingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &loggingConn{Conn: conn, log: c.log}, nil
+}
+
+// loggingConn wraps a driver connection that supports the context aware interfaces,
+// as the lib/pq connections do.
+type loggingConn struct {
+	driver.Conn
+	log *queryLogger
+}
+
+func (c *loggingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	started := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		c.log.log(query, started, -1, err)
+		return nil, err
+	}
+
+	return &loggingRows{Rows: rows, log: c.log, query: query, started: started}, nil
+}
+
+func (c *loggingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	started := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+
+	var rows int64 = -1
+	if err == nil {
+		if affected, err := result.RowsAffected(); err == nil {
+			rows = affected
+		}
+	}
+	c.log.log(query, started, rows, err)
+
+	return result, err
+}
+
+func (c *loggingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *loggingConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// loggingRows counts the fetched rows and logs the query once the rows are closed.
+type loggingRows struct {
+	driver.Rows
+	log     *queryLogger
+	query   string
+	started time.Time
+	count   int64
+	err     error
+}
+
+func (r *loggingRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch {
+	case err == nil:
+		r.count++
+	case !errors.Is(err, io.EOF):
+		r.err = err
+	}
+	return err
+}
+
+func (r *loggingRows) Close() error {
+	err := r.Rows.Close()
+	r.log.log(r.query, r.started, r.count, r.err)
+	return err
+}
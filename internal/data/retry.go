@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RetryPolicy describes how many times and how long a failed database call is retried.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used by the models created with NewModels.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   50 * time.Millisecond,
+	MaxDelay:    time.Second,
+}
+
+// do calls fn until it succeeds, returns a non transient error or the policy is exhausted.
+// Waits between attempts grow exponentially with full jitter, and no attempt is started
+// if the wait would outlive the deadline of ctx, which is the overall budget of the call.
+// When idempotent is false only errors after which Postgres is known to have rolled back
+// the statement are retried, since a dropped connection may hide a successful write.
+// The error of the last attempt is returned unchanged.
+func (p RetryPolicy) do(ctx context.Context, idempotent bool, fn func(ctx context.Context) error) error {
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || attempt+1 >= p.MaxAttempts || !isTransient(err, idempotent) {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a random delay between zero and the exponential backoff ceiling for attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.BaseDelay << attempt
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+// isTransient reports whether err is worth retrying: serialization failures, deadlocks
+// and, for idempotent calls, broken or reset connections.
+func isTransient(err error, idempotent bool) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "40001", pqErr.Code == "40P01":
+			return true
+		case pqErr.Code.Class() == "08":
+			return idempotent
+		default:
+			return false
+		}
+	}
+
+	if !idempotent {
+		return false
+	}
+
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	deadlock := &pq.Error{Code: "40P01"}
+
+	tests := []struct {
+		name         string
+		idempotent   bool
+		err          error
+		wantAttempts int
+	}{
+		{"success", true, nil, 1},
+		{"deadlock", false, deadlock, 3},
+		{"connection reset idempotent", true, syscall.ECONNRESET, 3},
+		{"connection reset not idempotent", false, syscall.ECONNRESET, 1},
+		{"not found", true, ErrRecordNotFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := policy.do(context.Background(), tt.idempotent, func(ctx context.Context) error {
+				attempts++
+				return tt.err
+			})
+
+			if !errors.Is(err, tt.err) {
+				t.Errorf("want error %v, got %v", tt.err, err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("want %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SearchIndex is the search engine the BookIndex keeps its documents in. It is satisfied
+// by *opensearch.Client.
+type SearchIndex interface {
+	CreateIndex(ctx context.Context, index string, body interface{}) (bool, error)
+	Index(ctx context.Context, index, id string, version int64, doc interface{}) error
+	Delete(ctx context.Context, index, id string, version int64) error
+	Search(ctx context.Context, index string, body, result interface{}) error
+}
+
+// BookIndex mirrors books into a search engine such as OpenSearch, which serves full text
+// queries with relevance ranking, typo tolerance and facets. Postgres stays the source of
+// truth: documents are written with the version of the book, so the index converges to the
+// latest state however late or out of order the writes arrive.
+//
+// Queries are best effort: errors of the index are reported to observe and the model falls
+// back to the database.
+type BookIndex struct {
+	index   SearchIndex
+	name    string
+	observe func(result string)
+}
+
+// NewBookIndex returns a BookIndex keeping the books in the index called name. observe is
+// called with "ok" or "error" for every query; it may be nil.
+func NewBookIndex(index SearchIndex, name string, observe func(result string)) *BookIndex {
+	if observe == nil {
+		observe = func(string) {}
+	}
+	return &BookIndex{index: index, name: name, observe: observe}
+}
+
+// searchTimeout bounds the calls to the index.
+const searchTimeout = 2 * time.Second
+
+// bookMapping holds the settings and mappings the index is created with. The book itself
+// is stored as is, to be returned by queries, and not indexed.
+var bookMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"dynamic": "strict",
+		"properties": map[string]interface{}{
+			"tenant": map[string]string{"type": "keyword"},
+			"id":     map[string]string{"type": "long"},
+			"title": map[string]interface{}{
+				"type":     "text",
+				"analyzer": "english",
+				"fields":   map[string]interface{}{"sort": map[string]string{"type": "keyword"}},
+			},
+			// title_variants holds the translations and the transliteration of the title.
+			"title_variants": map[string]string{"type": "text"},
+			"genres":         map[string]string{"type": "keyword"},
+			"year":           map[string]string{"type": "integer"},
+			"pages":          map[string]string{"type": "long"},
+			"metadata":       map[string]string{"type": "keyword"},
+			"archived":       map[string]string{"type": "boolean"},
+			"created_at":     map[string]string{"type": "date"},
+			"updated_at":     map[string]string{"type": "date"},
+			"book":           map[string]interface{}{"type": "object", "enabled": false},
+		},
+	},
+}
+
+// bookDocument is the indexed form of a book.
+type bookDocument struct {
+	Tenant string `json:"tenant"`
+	ID     int64  `json:"id"`
+	Title  string `json:"title"`
+	// TitleVariants holds the translations and the transliteration of the title.
+	TitleVariants []string `json:"title_variants"`
+	Genres        []string `json:"genres"`
+	Year          *int32   `json:"year,omitempty"`
+	Pages         *int64   `json:"pages,omitempty"`
+	// Metadata holds the scalar metadata of the book as "key=value" terms.
+	Metadata []string  `json:"metadata"`
+	Archived bool      `json:"archived"`
+	Created  time.Time `json:"created_at"`
+	Updated  time.Time `json:"updated_at"`
+	Book     *Book     `json:"book"`
+}
+
+// bookSearchFields maps sort keys to the document fields they sort by.
+var bookSearchFields = map[string]string{
+	"id":         "id",
+	"title":      "title.sort",
+	"year":       "year",
+	"pages":      "pages",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// Facet is the number of books matching a query with a value of a field.
+type Facet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets holds the facets of a query by field.
+type Facets map[string][]Facet
+
+// facetFields maps the facet names to the document fields they count.
+var facetFields = map[string]string{
+	"genres": "genres",
+	"years":  "year",
+}
+
+func documentID(tenant string, id int64) string {
+	return fmt.Sprintf("%s:%d", tenant, id)
+}
+
+// Setup creates the index unless it exists. It reports whether the index was created,
+// in which case it needs to be filled with Reindex.
+func (x *BookIndex) Setup() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return x.index.CreateIndex(ctx, x.name, bookMapping)
+}
+
+// put writes the book of the tenant to the index.
+func (x *BookIndex) put(tenant string, book *Book) error {
+	doc := bookDocument{
+		Tenant:   tenant,
+		ID:       book.ID,
+		Title:    book.Title,
+		Genres:   book.Genres,
+		Year:     book.Year,
+		Metadata: make([]string, 0, len(book.Metadata)),
+		Archived: book.Archived != nil,
+		Created:  book.Created,
+		Updated:  book.Updated,
+		Book:     book,
+	}
+	if book.Pages != nil {
+		pages := int64(*book.Pages)
+		doc.Pages = &pages
+	}
+	doc.TitleVariants = make([]string, 0, len(book.Titles)+1)
+	for _, title := range book.Titles {
+		doc.TitleVariants = append(doc.TitleVariants, title)
+	}
+	if translit := transliteration(book.Title); translit != "" {
+		doc.TitleVariants = append(doc.TitleVariants, translit)
+	}
+	sort.Strings(doc.TitleVariants)
+	for key, value := range book.Metadata {
+		// scalars are written as Postgres renders them with ->>, which metadata filters match.
+		switch value := value.(type) {
+		case string:
+			doc.Metadata = append(doc.Metadata, key+"="+value)
+		case float64:
+			doc.Metadata = append(doc.Metadata, key+"="+strconv.FormatFloat(value, 'f', -1, 64))
+		case bool:
+			doc.Metadata = append(doc.Metadata, key+"="+strconv.FormatBool(value))
+		}
+	}
+	sort.Strings(doc.Metadata)
+
+	ctx, cancel := context.WithTimeout(context.Background(), searchTimeout)
+	defer cancel()
+
+	return x.index.Index(ctx, x.name, documentID(tenant, book.ID), int64(book.Version), doc)
+}
+
+// remove deletes the book of the tenant, deleted at version, from the index.
+func (x *BookIndex) remove(tenant string, id int64, version int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), searchTimeout)
+	defer cancel()
+
+	return x.index.Delete(ctx, x.name, documentID(tenant, id), int64(version))
+}
+
+// search returns a page of the books of the tenant matching the filter along with the
+// facets of all the matching books. Books matching filter.Query are ranked by relevance
+// when sorted by id, the default, with ties broken by id.
+func (x *BookIndex) search(tenant string, filter BookFilter, filters Filters) ([]*Book, Metadata, Facets, error) {
+	field, ok := filters.sortColumn(bookSearchFields)
+	if !ok {
+		return nil, Metadata{}, nil, fmt.Errorf("unsupported sort value %q", filters.Sort)
+	}
+
+	conditions := []interface{}{
+		term("tenant", tenant),
+	}
+	if !filter.IncludeArchived {
+		conditions = append(conditions, term("archived", false))
+	}
+	if filter.Title != "" {
+		conditions = append(conditions, map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":    filter.Title,
+				"fields":   []string{"title", "title_variants"},
+				"type":     "cross_fields",
+				"operator": "and",
+			},
+		})
+	}
+	for _, genre := range filter.Genres {
+		conditions = append(conditions, term("genres", genre))
+	}
+	if filter.MinYear != 0 || filter.MaxYear != 0 {
+		years := make(map[string]interface{})
+		if filter.MinYear != 0 {
+			years["gte"] = filter.MinYear
+		}
+		if filter.MaxYear != 0 {
+			years["lte"] = filter.MaxYear
+		}
+		conditions = append(conditions, map[string]interface{}{"range": map[string]interface{}{"year": years}})
+	}
+	if !filter.CreatedSince.IsZero() {
+		conditions = append(conditions, since("created_at", filter.CreatedSince))
+	}
+	if !filter.UpdatedSince.IsZero() {
+		conditions = append(conditions, since("updated_at", filter.UpdatedSince))
+	}
+	for key, value := range filter.Metadata {
+		conditions = append(conditions, term("metadata", key+"="+value))
+	}
+
+	query := map[string]interface{}{"filter": conditions}
+	if filter.Query != "" {
+		query["must"] = map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     filter.Query,
+				"fields":    []string{"title^3", "title_variants^2", "genres"},
+				"fuzziness": "AUTO",
+			},
+		}
+	}
+
+	order := "asc"
+	if filters.sortDescending() {
+		order = "desc"
+	}
+	sortBy := []interface{}{map[string]string{field: order}}
+	if filter.Query != "" && filters.Sort == "id" {
+		sortBy = []interface{}{"_score", map[string]string{"id": "asc"}}
+	} else if field != "id" {
+		sortBy = append(sortBy, map[string]string{"id": "asc"})
+	}
+
+	aggs := make(map[string]interface{}, len(facetFields))
+	for name, field := range facetFields {
+		aggs[name] = map[string]interface{}{"terms": map[string]interface{}{"field": field, "size": 20}}
+	}
+
+	body := map[string]interface{}{
+		"from":             filters.offset(),
+		"size":             filters.limit(),
+		"track_total_hits": true,
+		"query":            map[string]interface{}{"bool": query},
+		"sort":             sortBy,
+		"aggs":             aggs,
+		"_source":          []string{"book"},
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source struct {
+					Book *Book `json:"book"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]struct {
+			Buckets []struct {
+				Key      interface{} `json:"key"`
+				DocCount int         `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"aggregations"`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), searchTimeout)
+	defer cancel()
+
+	err := x.index.Search(ctx, x.name, body, &result)
+	if err != nil {
+		x.observe("error")
+		return nil, Metadata{}, nil, err
+	}
+	x.observe("ok")
+
+	books := make([]*Book, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		books = append(books, hit.Source.Book)
+	}
+
+	facets := make(Facets, len(result.Aggregations))
+	for name, agg := range result.Aggregations {
+		facets[name] = make([]Facet, 0, len(agg.Buckets))
+		for _, bucket := range agg.Buckets {
+			facets[name] = append(facets[name], Facet{Value: fmt.Sprint(bucket.Key), Count: bucket.DocCount})
+		}
+	}
+
+	return books, calculateMetadata(result.Hits.Total.Value, filters.Page, filters.PageSize), facets, nil
+}
+
+func term(field string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{"term": map[string]interface{}{field: value}}
+}
+
+func since(field string, t time.Time) map[string]interface{} {
+	return map[string]interface{}{"range": map[string]interface{}{field: map[string]interface{}{"gte": t}}}
+}
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// memoryIndex is a SearchIndex keeping the last written documents and answering every
+// search with result, failing every call if down is set.
+type memoryIndex struct {
+	docs   map[string]bookDocument
+	query  map[string]interface{}
+	result string
+	down   bool
+}
+
+func (x *memoryIndex) CreateIndex(ctx context.Context, index string, body interface{}) (bool, error) {
+	return true, nil
+}
+
+func (x *memoryIndex) Index(ctx context.Context, index, id string, version int64, doc interface{}) error {
+	if x.down {
+		return errDown
+	}
+	x.docs[id] = doc.(bookDocument)
+	return nil
+}
+
+func (x *memoryIndex) Delete(ctx context.Context, index, id string, version int64) error {
+	if x.down {
+		return errDown
+	}
+	delete(x.docs, id)
+	return nil
+}
+
+func (x *memoryIndex) Search(ctx context.Context, index string, body, result interface{}) error {
+	if x.down {
+		return errDown
+	}
+	x.query = body.(map[string]interface{})
+	return json.Unmarshal([]byte(x.result), result)
+}
+
+func TestBookIndex(t *testing.T) {
+	store := &memoryIndex{docs: make(map[string]bookDocument)}
+	var results []string
+	index := NewBookIndex(store, "books", func(result string) {
+		results = append(results, result)
+	})
+
+	pages := Pages(412)
+	book := &Book{ID: 7, Title: "Dune", Pages: &pages, Genres: []string{"sci-fi"},
+		Metadata: Attributes{"edition": "first", "signed": true, "notes": map[string]interface{}{}}, Version: 3}
+	if err := index.put("acme", book); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := store.docs["acme:7"]
+	if doc.Tenant != "acme" || *doc.Pages != 412 || !reflect.DeepEqual(doc.Metadata, []string{"edition=first", "signed=true"}) {
+		t.Errorf("unexpected document %+v", doc)
+	}
+
+	store.result = `{
+		"hits": {"total": {"value": 21}, "hits": [{"_source": {"book": {"id": 7, "title": "Dune", "pages": "412 pages", "version": 3}}}]},
+		"aggregations": {"years": {"buckets": [{"key": 1965, "doc_count": 2}]}}
+	}`
+	books, meta, facets, err := index.search("acme", BookFilter{Query: "dnue"}, Filters{Page: 2, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 1 || books[0].Title != "Dune" || *books[0].Pages != 412 {
+		t.Errorf("unexpected books %+v", books)
+	}
+	if meta.TotalRecords != 21 || meta.LastPage != 2 {
+		t.Errorf("unexpected metadata %+v", meta)
+	}
+	if !reflect.DeepEqual(facets["years"], []Facet{{Value: "1965", Count: 2}}) {
+		t.Errorf("unexpected facets %+v", facets)
+	}
+	if store.query["from"] != 20 || store.query["sort"].([]interface{})[0] != "_score" {
+		t.Errorf("want the second page ranked by relevance, got %v", store.query)
+	}
+
+	store.down = true
+	if _, _, _, err := index.search("acme", BookFilter{Query: "dune"}, Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}}); !errors.Is(err, errDown) {
+		t.Errorf("want errDown, got %v", err)
+	}
+	if !reflect.DeepEqual(results, []string{"ok", "error"}) {
+		t.Errorf("want ok and error observed, got %v", results)
+	}
+}
+
+func TestSearchesIndex(t *testing.T) {
+	index := NewBookIndex(&memoryIndex{}, "books", nil)
+	sorted := func(sort string) Filters {
+		return Filters{Page: 1, PageSize: 20, Sort: sort, SortSafelist: []string{sort}}
+	}
+
+	tests := []struct {
+		name    string
+		model   BookModel
+		filter  BookFilter
+		filters Filters
+		want    bool
+	}{
+		{"indexed", BookModel{Index: index}, BookFilter{}, sorted("-year"), true},
+		{"no index", BookModel{}, BookFilter{}, sorted("-year"), false},
+		{"isbn", BookModel{Index: index}, BookFilter{ISBN: "9780441013593"}, sorted("id"), false},
+		{"views", BookModel{Index: index}, BookFilter{}, sorted("-views"), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.model.searchesIndex(tt.filter, tt.filters); got != tt.want {
+			t.Errorf("%s: want %t, got %t", tt.name, tt.want, got)
+		}
+	}
+}
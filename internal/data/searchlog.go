@@ -0,0 +1,146 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxSearchQueryLength is the length in characters search queries are cut to when recorded.
+const maxSearchQueryLength = 200
+
+// SearchQuery is the activity of a search query over a period: how many times it was
+// searched, how many of those searches found nothing, and how many books the latest search
+// found.
+type SearchQuery struct {
+	Query       string `json:"query"`
+	Searches    int64  `json:"searches"`
+	ZeroResults int64  `json:"zero_results"`
+	Hits        int64  `json:"hits"`
+}
+
+// NormalizeSearchQuery returns the form search queries are recorded in: lowercase, with
+// runs of whitespace collapsed, words containing "@", such as email addresses, replaced by
+// "[email]", and cut to maxSearchQueryLength characters. Queries differing only in those
+// respects are counted together.
+func NormalizeSearchQuery(query string) string {
+	words := strings.Fields(strings.ToLower(query))
+	for i, word := range words {
+		if strings.Contains(word, "@") {
+			words[i] = "[email]"
+		}
+	}
+	query = strings.Join(words, " ")
+
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		query = string([]rune(query)[:maxSearchQueryLength])
+	}
+	return query
+}
+
+// SearchLogModel struct wraps a sql.DB connection pool and works with the search_queries
+// table, which holds the free text searches counted by query and day, UTC. Nothing about
+// who searched is recorded. The searches are scoped to Tenant.
+type SearchLogModel struct {
+	DB     *sql.DB
+	Tenant string
+}
+
+// ForTenant returns a copy of the model scoped to the given tenant.
+func (m SearchLogModel) ForTenant(tenant string) SearchLogModel {
+	m.Tenant = tenant
+	return m
+}
+
+// Record counts a search for the query made at now, which found hits books. Blank queries
+// are not recorded.
+func (m SearchLogModel) Record(query string, hits int, now time.Time) error {
+	if m.Tenant == "" {
+		return ErrMissingTenant
+	}
+
+	query = NormalizeSearchQuery(query)
+	if query == "" {
+		return nil
+	}
+
+	zero := 0
+	if hits == 0 {
+		zero = 1
+	}
+
+	stmt := `
+		INSERT INTO search_queries (tenant_id, day, query, searches, zero_results, hits)
+		VALUES ($1, $2, $3, 1, $4, $5)
+		ON CONFLICT (tenant_id, day, query) DO UPDATE
+		SET searches = search_queries.searches + 1,
+			zero_results = search_queries.zero_results + EXCLUDED.zero_results,
+			hits = EXCLUDED.hits`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, m.Tenant, now.UTC().Format(time.DateOnly), query, zero, hits)
+	return err
+}
+
+// Top returns the limit most searched queries from the day of from to the day of until,
+// inclusive, most searched first.
+func (m SearchLogModel) Top(from, until time.Time, limit int) ([]SearchQuery, error) {
+	query := `
+		SELECT query, sum(searches), sum(zero_results), (array_agg(hits ORDER BY day DESC))[1]
+		FROM search_queries
+		WHERE tenant_id = $1 AND day >= $2 AND day <= $3
+		GROUP BY query
+		ORDER BY 2 DESC, query
+		LIMIT $4`
+
+	return m.queries(query, from, until, limit)
+}
+
+// ZeroResults returns the limit queries which most often found nothing from the day of from
+// to the day of until, inclusive, most often first. Queries count as long as some of their
+// searches found nothing, even if others found books.
+func (m SearchLogModel) ZeroResults(from, until time.Time, limit int) ([]SearchQuery, error) {
+	query := `
+		SELECT query, sum(searches), sum(zero_results), (array_agg(hits ORDER BY day DESC))[1]
+		FROM search_queries
+		WHERE tenant_id = $1 AND day >= $2 AND day <= $3
+		GROUP BY query
+		HAVING sum(zero_results) > 0
+		ORDER BY 3 DESC, query
+		LIMIT $4`
+
+	return m.queries(query, from, until, limit)
+}
+
+// queries runs a query selecting the activity of search queries of the tenant over the days
+// from and until.
+func (m SearchLogModel) queries(query string, from, until time.Time, limit int) ([]SearchQuery, error) {
+	if m.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, m.Tenant,
+		from.UTC().Format(time.DateOnly), until.UTC().Format(time.DateOnly), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queries := []SearchQuery{}
+	for rows.Next() {
+		var q SearchQuery
+		if err := rows.Scan(&q.Query, &q.Searches, &q.ZeroResults, &q.Hits); err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+
+	return queries, rows.Err()
+}
@@ -0,0 +1,24 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeSearchQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"Dune", "dune"},
+		{"  Frank   Herbert\tDune ", "frank herbert dune"},
+		{"books for Jane.Doe@example.com please", "books for [email] please"},
+		{"   ", ""},
+		{strings.Repeat("ж", 300), strings.Repeat("ж", maxSearchQueryLength)},
+	}
+	for _, tt := range tests {
+		if got := NormalizeSearchQuery(tt.query); got != tt.want {
+			t.Errorf("NormalizeSearchQuery(%q): want %q, got %q", tt.query, tt.want, got)
+		}
+	}
+}
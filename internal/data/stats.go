@@ -0,0 +1,97 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// MonthFormat is the layout of the months of genre trends, e.g. "2024-03".
+const MonthFormat = "2006-01"
+
+// GenreMonth is the activity of a genre in a month.
+type GenreMonth struct {
+	Month     string `json:"month"`
+	Additions int64  `json:"additions"`
+}
+
+// GenreTrend is the activity of a genre month by month over a window.
+type GenreTrend struct {
+	Genre     string       `json:"genre"`
+	Additions int64        `json:"additions"`
+	Months    []GenreMonth `json:"months"`
+}
+
+// GenreTrends returns the number of books added to every genre in each month from the
+// month of from to the month of until, inclusive. Months without additions are counted as
+// zero, and the genres are ordered by their total additions, most added first. Books count
+// in the month they were created in, UTC, whether or not they have been archived since.
+func (b BookModel) GenreTrends(from, until time.Time) ([]GenreTrend, error) {
+	if b.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	from = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(until.Year(), until.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+
+	query := `
+		SELECT genre, to_char(date_trunc('month', created_at AT TIME ZONE 'UTC'), 'YYYY-MM'), count(*)
+		FROM books, unnest(genres) AS genre
+		WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3
+		GROUP BY 1, 2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	counts := make(map[string]map[string]int64)
+
+	err := b.do(ctx, true, func(ctx context.Context) error {
+		clear(counts)
+
+		rows, err := b.DB.QueryContext(ctx, query, b.Tenant, from, end)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var genre, month string
+			var n int64
+			if err := rows.Scan(&genre, &month, &n); err != nil {
+				return err
+			}
+			if counts[genre] == nil {
+				counts[genre] = make(map[string]int64)
+			}
+			counts[genre][month] = n
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var months []string
+	for month := from; month.Before(end); month = month.AddDate(0, 1, 0) {
+		months = append(months, month.Format(MonthFormat))
+	}
+
+	trends := make([]GenreTrend, 0, len(counts))
+	for genre, byMonth := range counts {
+		trend := GenreTrend{Genre: genre, Months: make([]GenreMonth, len(months))}
+		for i, month := range months {
+			trend.Months[i] = GenreMonth{Month: month, Additions: byMonth[month]}
+			trend.Additions += byMonth[month]
+		}
+		trends = append(trends, trend)
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Additions != trends[j].Additions {
+			return trends[i].Additions > trends[j].Additions
+		}
+		return trends[i].Genre < trends[j].Genre
+	})
+
+	return trends, nil
+}
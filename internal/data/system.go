@@ -0,0 +1,57 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SystemModel struct wraps a sql.DB connection pool and reports the state of the database
+// the application depends on.
+type SystemModel struct {
+	DB *sql.DB
+}
+
+// Ping verifies that the database is reachable. It gives up if the database does not
+// respond during 2 seconds.
+func (s SystemModel) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return s.DB.PingContext(ctx)
+}
+
+// Stats returns the connection pool statistics.
+func (s SystemModel) Stats() sql.DBStats {
+	return s.DB.Stats()
+}
+
+// MigrationVersion returns the currently applied migration version and whether the last
+// migration failed half-way (dirty), as recorded by the migrate tool in schema_migrations.
+func (s SystemModel) MigrationVersion() (int64, bool, error) {
+	query := `
+		SELECT version, dirty
+		FROM schema_migrations
+		LIMIT 1`
+
+	var (
+		version int64
+		dirty   bool
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := s.DB.QueryRowContext(ctx, query).Scan(&version, &dirty)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, false, ErrRecordNotFound
+		default:
+			return 0, false, err
+		}
+	}
+
+	return version, dirty, nil
+}
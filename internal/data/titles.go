@@ -0,0 +1,137 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/language"
+)
+
+// MaxTitles is the largest number of translations of a book title.
+const MaxTitles = 20
+
+// Titles holds the translations of a book title by BCP 47 language tag, e.g.
+// {"en": "War and Peace"}. It is stored in the jsonb titles column.
+type Titles map[string]string
+
+// Value satisfies the driver.Valuer interface and encodes Titles as JSON.
+func (t Titles) Value() (driver.Value, error) {
+	if t == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(map[string]string(t))
+}
+
+// Scan satisfies the sql.Scanner interface and decodes Titles from JSON.
+func (t *Titles) Scan(src interface{}) error {
+	var js []byte
+
+	switch src := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		js = src
+	case string:
+		js = []byte(src)
+	default:
+		return errors.New("unsupported type for book titles")
+	}
+
+	if err := json.Unmarshal(js, t); err != nil {
+		return err
+	}
+	if len(*t) == 0 {
+		*t = nil
+	}
+	return nil
+}
+
+// Localize sets the title of the book to the variant best matching the languages of an
+// Accept-Language header, the original or one of the translations. If a translation is
+// chosen the original is kept in OriginalTitle. It returns the tag of the chosen title,
+// which is the language of the original when nothing matches better, or "" if the book has
+// no translations or the header names no language.
+func (b *Book) Localize(acceptLanguage string) string {
+	if len(b.Titles) == 0 || acceptLanguage == "" {
+		return ""
+	}
+	preferred, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(preferred) == 0 {
+		return ""
+	}
+
+	// the original comes first, so it is the fallback of the matcher.
+	original := language.Und
+	if b.Language != "" {
+		original, _ = language.Parse(b.Language)
+	}
+	tags := []language.Tag{original}
+	variants := []string{b.Language}
+	for tag, title := range b.Titles {
+		t, err := language.Parse(tag)
+		if err != nil || title == "" {
+			continue
+		}
+		tags = append(tags, t)
+		variants = append(variants, tag)
+	}
+
+	_, i, confidence := language.NewMatcher(tags).Match(preferred...)
+	if i == 0 || confidence == language.No {
+		return b.Language
+	}
+
+	b.OriginalTitle = b.Title
+	b.Title = b.Titles[variants[i]]
+	return variants[i]
+}
+
+// cyrillicLatin maps the lower case Cyrillic letters of Russian, Ukrainian and Belarusian
+// to Latin after ICAO Doc 9303, the scheme of machine readable passports.
+var cyrillicLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "ie", 'ы': "y", 'ь': "", 'э': "e", 'ю': "iu",
+	'я': "ia", 'і': "i", 'ї': "i", 'є': "ie", 'ґ': "g", 'ў': "u",
+}
+
+// Transliterate writes the Cyrillic letters of s in Latin letters, e.g. "Война и мир"
+// becomes "Voina i mir". Other characters are left as they are.
+func Transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		lower := unicode.ToLower(r)
+		latin, ok := cyrillicLatin[lower]
+		switch {
+		case !ok:
+			b.WriteRune(r)
+		case lower != r && latin != "":
+			b.WriteString(strings.ToUpper(latin[:1]) + latin[1:])
+		default:
+			b.WriteString(latin)
+		}
+	}
+	return b.String()
+}
+
+// transliteration returns the transliteration of a title stored to match searches typed
+// in Latin letters, or "" if the title has no Cyrillic letters.
+func transliteration(title string) string {
+	for _, r := range title {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return Transliterate(title)
+		}
+	}
+	return ""
+}
+
+// validLanguage reports whether tag is a well-formed BCP 47 language tag.
+func validLanguage(tag string) bool {
+	_, err := language.Parse(tag)
+	return err == nil
+}
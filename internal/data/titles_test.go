@@ -0,0 +1,76 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+func TestTransliterate(t *testing.T) {
+	tests := map[string]string{
+		"Война и мир":              "Voina i mir",
+		"Преступление и наказание": "Prestuplenie i nakazanie",
+		"Щегол, Ёжик и Юла":        "Shchegol, Ezhik i Iula",
+		"Dune":                     "Dune",
+	}
+	for title, want := range tests {
+		if got := Transliterate(title); got != want {
+			t.Errorf("%s: want %q, got %q", title, want, got)
+		}
+	}
+
+	if got := transliteration("Dune"); got != "" {
+		t.Errorf("want no transliteration of a Latin title, got %q", got)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	tests := []struct {
+		accept    string
+		wantTitle string
+		wantTag   string
+	}{
+		{"en-US,en;q=0.9", "War and Peace", "en"},
+		{"de, ru;q=0.8", "Krieg und Frieden", "de"},
+		{"ru", "Война и мир", "ru"},
+		{"ja", "Война и мир", "ru"},
+		{"", "Война и мир", ""},
+	}
+
+	for _, tt := range tests {
+		book := &Book{
+			Title:    "Война и мир",
+			Language: "ru",
+			Titles:   Titles{"en": "War and Peace", "de": "Krieg und Frieden"},
+		}
+		tag := book.Localize(tt.accept)
+		if book.Title != tt.wantTitle || tag != tt.wantTag {
+			t.Errorf("%q: want %q in %q, got %q in %q", tt.accept, tt.wantTitle, tt.wantTag, book.Title, tag)
+		}
+		if translated := book.Title != "Война и мир"; translated != (book.OriginalTitle == "Война и мир") {
+			t.Errorf("%q: want the original title kept only for translations, got %q", tt.accept, book.OriginalTitle)
+		}
+	}
+}
+
+func TestValidateBookTitles(t *testing.T) {
+	year := int32(1969)
+	book := &Book{
+		Title:    "Война и мир",
+		Language: "not a tag",
+		Titles:   Titles{"en": "War and Peace", "x y": "?", "fr": ""},
+		Year:     &year,
+		Genres:   []string{"novel"},
+	}
+
+	v := validator.New()
+	ValidateBook(v, book, BookRules{OptionalDetails: true})
+	for _, key := range []string{"language", "titles.x y", "titles.fr"} {
+		if _, ok := v.Errors[key]; !ok {
+			t.Errorf("want an error for %s, got %v", key, v.Errors)
+		}
+	}
+	if _, ok := v.Errors["titles.en"]; ok {
+		t.Errorf("want the valid translation accepted, got %v", v.Errors)
+	}
+}
@@ -0,0 +1,191 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// BookUsage is the number of times a book was viewed, fetched on its own, and shown in the
+// results of free text searches, its impressions.
+type BookUsage struct {
+	Tenant      string `json:"-"`
+	ID          int64  `json:"id"`
+	Title       string `json:"title,omitempty"`
+	Views       int64  `json:"views"`
+	Impressions int64  `json:"impressions"`
+}
+
+// UsageSorts are the orders of the book usage statistics.
+var UsageSorts = []string{"views", "impressions"}
+
+// UsageCounter counts the views and impressions of books in memory, so counting does not
+// write to the database on every request. The counts are taken in batches and added to the
+// books with BookModel.AddUsage. Nothing about who viewed a book is counted. A nil
+// UsageCounter counts nothing.
+type UsageCounter struct {
+	mu     sync.Mutex
+	counts map[usageKey]*BookUsage
+}
+
+type usageKey struct {
+	tenant string
+	id     int64
+}
+
+// NewUsageCounter returns an empty UsageCounter.
+func NewUsageCounter() *UsageCounter {
+	return &UsageCounter{counts: make(map[usageKey]*BookUsage)}
+}
+
+// View counts a view of the book of the tenant.
+func (c *UsageCounter) View(tenant string, id int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.count(tenant, id).Views++
+}
+
+// Impressions counts an impression of each of the books of the tenant.
+func (c *UsageCounter) Impressions(tenant string, ids ...int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range ids {
+		c.count(tenant, id).Impressions++
+	}
+}
+
+// Take returns the counts and resets them.
+func (c *UsageCounter) Take() []BookUsage {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	usage := make([]BookUsage, 0, len(c.counts))
+	for _, u := range c.counts {
+		usage = append(usage, *u)
+	}
+	clear(c.counts)
+
+	return usage
+}
+
+// Restore adds back counts returned by Take which could not be stored.
+func (c *UsageCounter) Restore(usage []BookUsage) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, u := range usage {
+		count := c.count(u.Tenant, u.ID)
+		count.Views += u.Views
+		count.Impressions += u.Impressions
+	}
+}
+
+// count returns the counts of the book, which the caller must hold the lock for.
+func (c *UsageCounter) count(tenant string, id int64) *BookUsage {
+	key := usageKey{tenant, id}
+	u, ok := c.counts[key]
+	if !ok {
+		u = &BookUsage{Tenant: tenant, ID: id}
+		c.counts[key] = u
+	}
+	return u
+}
+
+// AddUsage adds the counts to the views and impressions of the books, of any tenant, in a
+// single statement. The books keep their version and update time, and counts of books
+// deleted in the meantime are dropped.
+func (b BookModel) AddUsage(usage []BookUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+
+	tenants := make([]string, len(usage))
+	ids := make([]int64, len(usage))
+	views := make([]int64, len(usage))
+	impressions := make([]int64, len(usage))
+	for i, u := range usage {
+		tenants[i], ids[i], views[i], impressions[i] = u.Tenant, u.ID, u.Views, u.Impressions
+	}
+
+	query := `
+		UPDATE books
+		SET views = books.views + u.views, impressions = books.impressions + u.impressions
+		FROM unnest($1::text[], $2::bigint[], $3::bigint[], $4::bigint[]) AS u(tenant_id, id, views, impressions)
+		WHERE books.tenant_id = u.tenant_id AND books.id = u.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := b.DB.ExecContext(ctx, query, pq.Array(tenants), pq.Array(ids), pq.Array(views), pq.Array(impressions))
+	return err
+}
+
+// Usage returns the limit books of the tenant with the most views or impressions, by sort,
+// archived ones included.
+func (b BookModel) Usage(sort string, limit int) ([]BookUsage, error) {
+	if b.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+	if !validator.In(sort, UsageSorts...) {
+		return nil, fmt.Errorf("unsupported usage sort %q", sort)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, title, views, impressions
+		FROM books
+		WHERE tenant_id = $1
+		ORDER BY %s DESC, id
+		LIMIT $2`, pq.QuoteIdentifier(sort))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	usage := []BookUsage{}
+
+	err := b.do(ctx, true, func(ctx context.Context) error {
+		usage = usage[:0]
+
+		rows, err := b.DB.QueryContext(ctx, query, b.Tenant, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			u := BookUsage{Tenant: b.Tenant}
+			if err := rows.Scan(&u.ID, &u.Title, &u.Views, &u.Impressions); err != nil {
+				return err
+			}
+			usage = append(usage, u)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,51 @@
+package data
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+)
+
+func TestUsageCounter(t *testing.T) {
+	c := NewUsageCounter()
+	c.View("default", 1)
+	c.View("default", 1)
+	c.View("acme", 1)
+	c.Impressions("default", 1, 2)
+
+	byTenantID := func(a, b BookUsage) int {
+		if a.Tenant != b.Tenant {
+			return cmp.Compare(a.Tenant, b.Tenant)
+		}
+		return cmp.Compare(a.ID, b.ID)
+	}
+
+	usage := c.Take()
+	slices.SortFunc(usage, byTenantID)
+	want := []BookUsage{
+		{Tenant: "acme", ID: 1, Views: 1},
+		{Tenant: "default", ID: 1, Views: 2, Impressions: 1},
+		{Tenant: "default", ID: 2, Impressions: 1},
+	}
+	if !slices.Equal(usage, want) {
+		t.Fatalf("Take: want %v, got %v", want, usage)
+	}
+	if got := c.Take(); len(got) != 0 {
+		t.Fatalf("Take after Take: want no counts, got %v", got)
+	}
+
+	c.View("default", 2)
+	c.Restore(usage)
+	usage = c.Take()
+	slices.SortFunc(usage, byTenantID)
+	want[2].Views = 1
+	if !slices.Equal(usage, want) {
+		t.Errorf("Take after Restore: want %v, got %v", want, usage)
+	}
+
+	var nilCounter *UsageCounter
+	nilCounter.View("default", 1)
+	if got := nilCounter.Take(); got != nil {
+		t.Errorf("nil Take: want nil, got %v", got)
+	}
+}
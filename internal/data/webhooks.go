@@ -0,0 +1,317 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/nikitashershunov/LibraryAPI/internal/events"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// WebhookEvents are the event types webhooks can subscribe to.
+var WebhookEvents = []string{events.BookCreated, events.BookUpdated, events.BookDeleted}
+
+// Webhook is a URL registered to receive the events of the given types. Deliveries are
+// signed with Secret, which is only shown when the webhook is created.
+type Webhook struct {
+	ID      int64     `json:"id"`
+	Created time.Time `json:"created_at"`
+	URL     string    `json:"url"`
+	Events  []string  `json:"events"`
+	Secret  string    `json:"-"`
+}
+
+// WebhookDelivery records the delivery of a single event to a webhook. NextAttempt is set
+// while the delivery is pending and cleared once it succeeds or is given up.
+type WebhookDelivery struct {
+	ID          int64           `json:"id"`
+	WebhookID   int64           `json:"webhook_id"`
+	Created     time.Time       `json:"created_at"`
+	Event       string          `json:"event"`
+	EventKey    string          `json:"-"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	StatusCode  *int            `json:"status_code,omitempty"`
+	Error       *string         `json:"error,omitempty"`
+	Delivered   *time.Time      `json:"delivered_at,omitempty"`
+	NextAttempt *time.Time      `json:"next_attempt_at,omitempty"`
+}
+
+// PendingDelivery is a delivery due for an attempt along with the webhook it goes to.
+type PendingDelivery struct {
+	Webhook  *Webhook
+	Delivery *WebhookDelivery
+}
+
+// ValidateWebhook runs validation checks on the Webhook type.
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	u, err := url.Parse(webhook.URL)
+	v.Check(webhook.URL != "", "url", "must be provided")
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "must be an absolute http or https URL")
+	v.Check(len(webhook.URL) <= 2048, "url", "must not be more than 2048 bytes long")
+
+	v.Check(len(webhook.Events) > 0, "events", "must contain at least 1 event")
+	v.Check(validator.Unique(webhook.Events), "events", "must not contain duplicate values")
+	for _, e := range webhook.Events {
+		v.Check(validator.In(e, WebhookEvents...), "events", "must only contain book.created, book.updated or book.deleted")
+	}
+}
+
+// WebhookModel struct wraps a sql.DB connection pool and works with the webhooks and
+// webhook_deliveries tables. The webhooks are scoped to Tenant.
+type WebhookModel struct {
+	DB     *sql.DB
+	Tenant string
+}
+
+// ForTenant returns a copy of the model scoped to the given tenant.
+func (m WebhookModel) ForTenant(tenant string) WebhookModel {
+	m.Tenant = tenant
+	return m
+}
+
+// Insert adds a new webhook record, setting its ID and creation time.
+func (m WebhookModel) Insert(webhook *Webhook) error {
+	if m.Tenant == "" {
+		return ErrMissingTenant
+	}
+
+	query := `
+		INSERT INTO webhooks (tenant_id, url, events, secret)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, m.Tenant, webhook.URL, pq.Array(webhook.Events), webhook.Secret).
+		Scan(&webhook.ID, &webhook.Created)
+}
+
+// GetAll returns the webhooks of the tenant, oldest first.
+func (m WebhookModel) GetAll() ([]*Webhook, error) {
+	if m.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	query := `
+		SELECT id, created_at, url, events, secret
+		FROM webhooks
+		WHERE tenant_id = $1
+		ORDER BY id`
+
+	return m.query(query, m.Tenant)
+}
+
+// ForEvent returns the webhooks of the tenant subscribed to the event type.
+func (m WebhookModel) ForEvent(eventType string) ([]*Webhook, error) {
+	if m.Tenant == "" {
+		return nil, ErrMissingTenant
+	}
+
+	query := `
+		SELECT id, created_at, url, events, secret
+		FROM webhooks
+		WHERE tenant_id = $1 AND $2 = ANY(events)
+		ORDER BY id`
+
+	return m.query(query, m.Tenant, eventType)
+}
+
+// query runs a query selecting webhook records.
+func (m WebhookModel) query(query string, args ...interface{}) ([]*Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		err := rows.Scan(&webhook.ID, &webhook.Created, &webhook.URL, pq.Array(&webhook.Events), &webhook.Secret)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Delete removes the webhook with the given ID along with its delivery log.
+func (m WebhookModel) Delete(id int64) error {
+	if m.Tenant == "" {
+		return ErrMissingTenant
+	}
+
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM webhooks
+		WHERE id = $1 AND tenant_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, m.Tenant)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// InsertDelivery records a new delivery. Every event is delivered to a webhook once even
+// if several API instances receive it: it returns false if the delivery of the same event
+// key has been recorded already.
+func (m WebhookModel) InsertDelivery(delivery *WebhookDelivery) (bool, error) {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event, event_key, payload, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (webhook_id, event_key) DO NOTHING
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{delivery.WebhookID, delivery.Event, delivery.EventKey, string(delivery.Payload), delivery.NextAttempt}
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&delivery.ID, &delivery.Created)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// UpdateDelivery stores the outcome of the latest delivery attempt and when the next one
+// is due.
+func (m WebhookModel) UpdateDelivery(delivery *WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET attempts = $1, status_code = $2, error = $3, delivered_at = $4, next_attempt_at = $5
+		WHERE id = $6`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{delivery.Attempts, delivery.StatusCode, delivery.Error, delivery.Delivered, delivery.NextAttempt, delivery.ID}
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// ClaimDeliveries returns up to limit deliveries due for an attempt, earliest first, and
+// pushes their next attempt lease into the future so neither this nor another API instance
+// claims them again meanwhile. Deliveries whose attempt is not recorded by then, because
+// the instance stopped, are claimed again once the lease is over.
+func (m WebhookModel) ClaimDeliveries(limit int, lease time.Duration) ([]PendingDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING d.id, d.webhook_id, d.created_at, d.event, d.event_key, d.payload, d.attempts, d.next_attempt_at,
+			w.id, w.created_at, w.url, w.events, w.secret`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := []PendingDelivery{}
+	for rows.Next() {
+		var w Webhook
+		var d WebhookDelivery
+		var payload []byte
+
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Created, &d.Event, &d.EventKey, &payload, &d.Attempts, &d.NextAttempt,
+			&w.ID, &w.Created, &w.URL, pq.Array(&w.Events), &w.Secret)
+		if err != nil {
+			return nil, err
+		}
+
+		d.Payload = payload
+		pending = append(pending, PendingDelivery{Webhook: &w, Delivery: &d})
+	}
+
+	return pending, rows.Err()
+}
+
+// GetDeliveries returns a page of the delivery log of a webhook of the tenant, newest first.
+func (m WebhookModel) GetDeliveries(webhookID int64, filters Filters) ([]*WebhookDelivery, Metadata, error) {
+	if m.Tenant == "" {
+		return nil, Metadata{}, ErrMissingTenant
+	}
+
+	query := `
+		SELECT count(*) OVER(), d.id, d.webhook_id, d.created_at, d.event, d.payload, d.attempts, d.status_code, d.error, d.delivered_at, d.next_attempt_at
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.webhook_id = $1 AND w.tenant_id = $2
+		ORDER BY d.id DESC
+		LIMIT $3 OFFSET $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, webhookID, m.Tenant, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload []byte
+
+		err := rows.Scan(&totalRecords, &d.ID, &d.WebhookID, &d.Created, &d.Event, &payload,
+			&d.Attempts, &d.StatusCode, &d.Error, &d.Delivered, &d.NextAttempt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		d.Payload = payload
+		deliveries = append(deliveries, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return deliveries, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
@@ -0,0 +1,97 @@
+// Package events carries the change feed of the catalog. The data layer publishes
+// book changes with Postgres NOTIFY, a Listener receives them and a Broker fans them
+// out to the subsystems of the application.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Channel is the Postgres notification channel the book changes are sent on.
+const Channel = "book_events"
+
+// Types of the events.
+const (
+	BookCreated = "book.created"
+	BookUpdated = "book.updated"
+	BookDeleted = "book.deleted"
+
+	// Resync is delivered when events may have been lost, e.g. after the database
+	// connection was re-established or the subscriber fell behind. Subscribers that
+	// keep state should reload it from the database.
+	Resync = "resync"
+)
+
+// Event describes a single change of a book record.
+type Event struct {
+	Type    string    `json:"type"`
+	Tenant  string    `json:"tenant,omitempty"`
+	BookID  int64     `json:"book_id,omitempty"`
+	Version int32     `json:"version,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// subscription is a single subscriber of a Broker.
+type subscription struct {
+	ch     chan Event
+	lagged bool
+}
+
+// Broker fans out events to any number of subscribers. Publishing never blocks: if the
+// buffer of a subscriber is full the event is dropped for it and it receives Resync
+// as soon as it catches up.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+// NewBroker returns a new Broker without subscribers.
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[*subscription]struct{})}
+}
+
+// Subscribe registers a new subscriber with a buffer of the given size. It returns the
+// channel of events and a function that cancels the subscription and closes the channel.
+func (b *Broker) Subscribe(buffer int) (<-chan Event, func()) {
+	sub := &subscription{ch: make(chan Event, buffer)}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, sub)
+			b.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+
+	return sub.ch, cancel
+}
+
+// Publish delivers the event to all current subscribers.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if sub.lagged {
+			select {
+			case sub.ch <- Event{Type: Resync, Time: e.Time}:
+				sub.lagged = false
+			default:
+				continue
+			}
+		}
+
+		select {
+		case sub.ch <- e:
+		default:
+			sub.lagged = true
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
+)
+
+// Listener receives the notifications sent on Channel and publishes them to a Broker.
+type Listener struct {
+	listener *pq.Listener
+	broker   *Broker
+	logger   *jsonlog.Logger
+}
+
+// NewListener opens a dedicated connection to the database with the given DSN and
+// starts listening on Channel. The connection is re-established automatically.
+func NewListener(dsn string, broker *Broker, logger *jsonlog.Logger) (*Listener, error) {
+	report := func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.PrintError(err, map[string]string{"component": "events listener"})
+		}
+	}
+
+	listener := pq.NewListener(dsn, time.Second, time.Minute, report)
+	if err := listener.Listen(Channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &Listener{listener: listener, broker: broker, logger: logger}, nil
+}
+
+// Run publishes received notifications until the Listener is closed. A nil notification
+// means the connection was lost and notifications may have been missed, so Resync is
+// published instead.
+func (l *Listener) Run() {
+	for n := range l.listener.NotificationChannel() {
+		if n == nil {
+			l.broker.Publish(Event{Type: Resync, Time: time.Now().UTC()})
+			continue
+		}
+
+		var e Event
+		if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+			l.logger.PrintError(err, map[string]string{"component": "events listener"})
+			continue
+		}
+		l.broker.Publish(e)
+	}
+}
+
+// Close stops listening and closes the connection.
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
@@ -0,0 +1,252 @@
+// Package fixtures loads sets of books, webhooks and webhook deliveries into the database,
+// for tests and for seeding development databases. Sets are JSON documents such as:
+//
+//	{
+//		"tenant": "default",
+//		"books": [
+//			{"ref": "dune", "title": "Dune", "year": 1965, "pages": 412, "genres": ["sci-fi"]}
+//		],
+//		"webhooks": [
+//			{"ref": "catalog", "url": "https://example.org/hooks", "events": ["book.created"], "secret": "s3cret"}
+//		],
+//		"deliveries": [
+//			{"webhook": "catalog", "event": "book.created", "book": "dune"}
+//		]
+//	}
+//
+// Records refer to each other by their ref. The references are checked before anything is
+// written, and the records are inserted in dependency order.
+package fixtures
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// library is the built-in set, see Library.
+//
+//go:embed library.json
+var library []byte
+
+// Set is a set of fixtures.
+type Set struct {
+	// Tenant is the tenant the set is meant for, data.DefaultTenant if empty. Load takes
+	// the tenant as an argument, so that tests can load a set for tenants of their own.
+	Tenant     string     `json:"tenant"`
+	Books      []Book     `json:"books"`
+	Webhooks   []Webhook  `json:"webhooks"`
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+// Book is a book fixture.
+type Book struct {
+	// Ref names the book for the records referring to it, it may be empty.
+	Ref string `json:"ref"`
+	data.Book
+}
+
+// Webhook is a webhook fixture.
+type Webhook struct {
+	// Ref names the webhook for the records referring to it, it may be empty.
+	Ref    string   `json:"ref"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// Delivery is a fixture of a delivery of an event to a webhook, optionally of the event of
+// a book of the set.
+type Delivery struct {
+	Webhook string          `json:"webhook"`
+	Event   string          `json:"event"`
+	Book    string          `json:"book"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// InvalidError holds the problems of an invalid set, keyed by the path of the record, e.g.
+// "books[2].title" or "deliveries[0].webhook".
+type InvalidError map[string]string
+
+func (e InvalidError) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	problems := make([]string, len(keys))
+	for i, key := range keys {
+		problems[i] = key + " " + e[key]
+	}
+	return "fixtures: invalid set: " + strings.Join(problems, "; ")
+}
+
+// Library returns the built-in set of a few books, a webhook and one of its deliveries.
+func Library() (*Set, error) {
+	return Parse(strings.NewReader(string(library)))
+}
+
+// ReadFile reads the set of the named file.
+func ReadFile(name string) (*Set, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse reads a set from r. Keys other than those of the records are rejected, to catch
+// misspelled fields.
+func Parse(r io.Reader) (*Set, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	var set Set
+	if err := decoder.Decode(&set); err != nil {
+		return nil, fmt.Errorf("fixtures: %w", err)
+	}
+	return &set, nil
+}
+
+// Validate checks the records of the set and their references, adding the problems to v.
+// Books are checked with the rules, as the API would check them.
+func (s *Set) Validate(v *validator.Validator, rules data.BookRules) {
+	books := make(map[string]bool)
+	v.Each("books", len(s.Books), func(v *validator.Validator, i int) {
+		book := s.Books[i]
+		if book.Ref != "" {
+			v.Check(!books[book.Ref], "ref", "is already used by another book")
+			books[book.Ref] = true
+		}
+		data.ValidateBook(v, &book.Book, rules)
+	})
+
+	webhooks := make(map[string]bool)
+	v.Each("webhooks", len(s.Webhooks), func(v *validator.Validator, i int) {
+		webhook := s.Webhooks[i]
+		if webhook.Ref != "" {
+			v.Check(!webhooks[webhook.Ref], "ref", "is already used by another webhook")
+			webhooks[webhook.Ref] = true
+		}
+		v.Check(webhook.Secret != "", "secret", "must be provided")
+		data.ValidateWebhook(v, &data.Webhook{URL: webhook.URL, Events: webhook.Events})
+	})
+
+	v.Each("deliveries", len(s.Deliveries), func(v *validator.Validator, i int) {
+		delivery := s.Deliveries[i]
+		v.Check(webhooks[delivery.Webhook], "webhook", "must be the ref of a webhook of the set")
+		v.Check(delivery.Book == "" || books[delivery.Book], "book", "must be the ref of a book of the set")
+		v.Check(validator.In(delivery.Event, data.WebhookEvents...), "event", "must be a webhook event")
+		v.Check(delivery.Payload == nil || json.Valid(delivery.Payload), "payload", "must be JSON")
+	})
+}
+
+// Loaded holds the records inserted by Load, in the order of the set, with their IDs and
+// timestamps filled in.
+type Loaded struct {
+	Books      []*data.Book
+	Webhooks   []*data.Webhook
+	Deliveries []*data.WebhookDelivery
+
+	books    map[string]*data.Book
+	webhooks map[string]*data.Webhook
+}
+
+// Book returns the loaded book of the ref, nil if there is none.
+func (l *Loaded) Book(ref string) *data.Book {
+	return l.books[ref]
+}
+
+// Webhook returns the loaded webhook of the ref, nil if there is none.
+func (l *Loaded) Webhook(ref string) *data.Webhook {
+	return l.webhooks[ref]
+}
+
+// Load validates the set and inserts its records for the tenant: the books and webhooks
+// first, then the deliveries referring to them. Invalid sets are reported with an
+// InvalidError before anything is inserted. Records inserted before a failing one are
+// kept, loading into a fresh tenant or database is recommended.
+func Load(models data.Models, tenant string, s *Set) (*Loaded, error) {
+	v := validator.New()
+	if s.Validate(v, data.BookRules{OptionalDetails: true}); !v.Valid() {
+		return nil, InvalidError(v.Errors)
+	}
+
+	loaded := &Loaded{
+		books:    make(map[string]*data.Book),
+		webhooks: make(map[string]*data.Webhook),
+	}
+
+	bookModel := models.Books.ForTenant(tenant)
+	for i := range s.Books {
+		book := s.Books[i].Book
+		book.ISBN = data.NormalizeISBN(book.ISBN)
+		if err := bookModel.Insert(&book); err != nil {
+			return loaded, fmt.Errorf("fixtures: books[%d]: %w", i, err)
+		}
+		loaded.Books = append(loaded.Books, &book)
+		if ref := s.Books[i].Ref; ref != "" {
+			loaded.books[ref] = &book
+		}
+	}
+
+	webhookModel := models.Webhooks.ForTenant(tenant)
+	for i, fixture := range s.Webhooks {
+		webhook := &data.Webhook{URL: fixture.URL, Events: fixture.Events, Secret: fixture.Secret}
+		if err := webhookModel.Insert(webhook); err != nil {
+			return loaded, fmt.Errorf("fixtures: webhooks[%d]: %w", i, err)
+		}
+		loaded.Webhooks = append(loaded.Webhooks, webhook)
+		if fixture.Ref != "" {
+			loaded.webhooks[fixture.Ref] = webhook
+		}
+	}
+
+	for i, fixture := range s.Deliveries {
+		delivery := &data.WebhookDelivery{
+			WebhookID: loaded.webhooks[fixture.Webhook].ID,
+			Event:     fixture.Event,
+			EventKey:  fmt.Sprintf("fixture:%d", i),
+			Payload:   fixture.Payload,
+		}
+		if book := loaded.books[fixture.Book]; book != nil {
+			// the key of the delivery of the event by the API, see dispatchWebhooks.
+			delivery.EventKey = fmt.Sprintf("%s:%d:%d", fixture.Event, book.ID, book.Version)
+			if delivery.Payload == nil {
+				// the payload the API would have sent, see eventPayload.
+				payload, err := json.Marshal(map[string]interface{}{
+					"event":      fixture.Event,
+					"tenant":     tenant,
+					"book_id":    book.ID,
+					"version":    book.Version,
+					"created_at": book.Updated,
+					"book":       book,
+				})
+				if err != nil {
+					return loaded, err
+				}
+				delivery.Payload = payload
+			}
+		}
+		if delivery.Payload == nil {
+			delivery.Payload = json.RawMessage("{}")
+		}
+
+		if _, err := webhookModel.InsertDelivery(delivery); err != nil {
+			return loaded, fmt.Errorf("fixtures: deliveries[%d]: %w", i, err)
+		}
+		loaded.Deliveries = append(loaded.Deliveries, delivery)
+	}
+
+	return loaded, nil
+}
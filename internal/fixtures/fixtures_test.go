@@ -0,0 +1,108 @@
+package fixtures_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/fixtures"
+	"github.com/nikitashershunov/LibraryAPI/internal/pgtest"
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+func TestMain(m *testing.M) {
+	pgtest.Main(m)
+}
+
+func TestLibrary(t *testing.T) {
+	set, err := fixtures.Library()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := validator.New()
+	if set.Validate(v, data.BookRules{OptionalDetails: true}); !v.Valid() {
+		t.Errorf("want the built-in set valid, got %v", v.Errors)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	set, err := fixtures.Parse(strings.NewReader(`{
+		"books": [
+			{"ref": "dune", "title": "Dune", "year": 1965, "pages": 412, "genres": ["sci-fi"]},
+			{"ref": "dune", "title": "", "genres": ["sci-fi"]}
+		],
+		"webhooks": [{"ref": "catalog", "url": "ftp://example.org", "events": ["book.created"]}],
+		"deliveries": [
+			{"webhook": "catalog", "event": "book.created", "book": "dune"},
+			{"webhook": "archive", "event": "book.lent", "book": "hobbit"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := validator.New()
+	set.Validate(v, data.BookRules{OptionalDetails: true})
+
+	want := map[string]string{
+		"books[1].ref":          "is already used by another book",
+		"books[1].title":        "must be provided",
+		"webhooks[0].secret":    "must be provided",
+		"webhooks[0].url":       "must be an absolute http or https URL",
+		"deliveries[1].webhook": "must be the ref of a webhook of the set",
+		"deliveries[1].book":    "must be the ref of a book of the set",
+		"deliveries[1].event":   "must be a webhook event",
+	}
+	if !reflect.DeepEqual(v.Errors, want) {
+		t.Errorf("want errors %v, got %v", want, v.Errors)
+	}
+
+	_, err = fixtures.Load(data.Models{}, data.DefaultTenant, set)
+	var invalid fixtures.InvalidError
+	if !errors.As(err, &invalid) || len(invalid) != len(want) {
+		t.Errorf("want the set rejected before loading, got %v", err)
+	}
+}
+
+func TestParseUnknownKey(t *testing.T) {
+	_, err := fixtures.Parse(strings.NewReader(`{"users": [{"email": "alice@example.org"}]}`))
+	if err == nil || !strings.Contains(err.Error(), `unknown field "users"`) {
+		t.Errorf("want an unknown field error, got %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	db := pgtest.Open(t)
+	tenant := pgtest.Tenant(t, db)
+
+	loaded := pgtest.Seed(t, db, tenant)
+
+	dune := loaded.Book("dune")
+	if dune == nil || dune.ID == 0 || loaded.Webhook("catalog") == nil {
+		t.Fatalf("want the referenced records loaded, got %+v", loaded)
+	}
+
+	models := data.NewModels(db)
+	books, meta, err := models.Books.ForTenant(tenant).GetAll(data.BookFilter{}, data.Filters{
+		Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.TotalRecords != len(loaded.Books) || books[0].ID != dune.ID {
+		t.Errorf("want the %d books of the set, got %d", len(loaded.Books), meta.TotalRecords)
+	}
+
+	deliveries, _, err := models.Webhooks.ForTenant(tenant).GetDeliveries(loaded.Webhook("catalog").ID, data.Filters{
+		Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Event != "book.created" {
+		t.Errorf("want the delivery of the set, got %+v", deliveries)
+	}
+}
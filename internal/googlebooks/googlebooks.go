@@ -0,0 +1,116 @@
+// Package googlebooks looks up books in the Google Books API.
+package googlebooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when no volume matches the lookup.
+var ErrNotFound = errors.New("googlebooks: volume not found")
+
+// DefaultEndpoint is the volumes endpoint of the Google Books API.
+const DefaultEndpoint = "https://www.googleapis.com/books/v1/volumes"
+
+// Volume holds the details of a book in Google Books.
+type Volume struct {
+	ID          string
+	Title       string
+	Description string
+	Categories  []string
+	// Year is the year of publication, 0 if unknown.
+	Year int
+	// PageCount is 0 if unknown.
+	PageCount int
+	// Thumbnail is the URL of the cover image, if any.
+	Thumbnail string
+}
+
+// Client is a Google Books API client safe for concurrent use.
+type Client struct {
+	endpoint string
+	key      string
+	client   *http.Client
+}
+
+// New returns a Client of the API at endpoint. The API key is optional, requests without
+// one share a small anonymous quota.
+func New(endpoint, key string) *Client {
+	return &Client{
+		endpoint: endpoint,
+		key:      key,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// LookupISBN returns the first volume with the given ISBN.
+func (c *Client) LookupISBN(ctx context.Context, isbn string) (*Volume, error) {
+	q := url.Values{"q": {"isbn:" + isbn}, "maxResults": {"1"}}
+	if c.key != "" {
+		q.Set("key", c.key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("googlebooks: API responded with %s: %s", resp.Status, b)
+	}
+
+	var result struct {
+		Items []struct {
+			ID         string `json:"id"`
+			VolumeInfo struct {
+				Title         string   `json:"title"`
+				Description   string   `json:"description"`
+				Categories    []string `json:"categories"`
+				PublishedDate string   `json:"publishedDate"`
+				PageCount     int      `json:"pageCount"`
+				ImageLinks    struct {
+					Thumbnail string `json:"thumbnail"`
+				} `json:"imageLinks"`
+			} `json:"volumeInfo"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Items) == 0 {
+		return nil, ErrNotFound
+	}
+
+	item := result.Items[0]
+	info := item.VolumeInfo
+
+	// the published date is a year, a year and month or a full date.
+	year, _ := strconv.Atoi(strings.SplitN(info.PublishedDate, "-", 2)[0])
+
+	return &Volume{
+		ID:          item.ID,
+		Title:       info.Title,
+		Description: info.Description,
+		Categories:  info.Categories,
+		Year:        year,
+		PageCount:   info.PageCount,
+		// the API returns http URLs of images also served over https.
+		Thumbnail: strings.Replace(info.ImageLinks.Thumbnail, "http://", "https://", 1),
+	}, nil
+}
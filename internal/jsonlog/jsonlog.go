@@ -2,10 +2,13 @@ package jsonlog
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,41 +17,74 @@ type Level int8
 
 // Initialize constants which represent a specific severity level.
 const (
-	LevelInfo  Level = iota // 0
-	LevelError              // 1
-	LevelFatal              // 2
-	LevelOff                // 3
+	LevelDebug Level = iota // 0
+	LevelInfo               // 1
+	LevelWarn               // 2
+	LevelError              // 3
+	LevelFatal              // 4
+	LevelOff                // 5
 )
 
 // String returns a string for the severity level.
 func (l Level) String() string {
 	switch l {
+	case LevelDebug:
+		return "DEBUG"
 	case LevelInfo:
 		return "INFO"
+	case LevelWarn:
+		return "WARN"
 	case LevelError:
 		return "ERROR"
 	case LevelFatal:
 		return "FATAL"
+	case LevelOff:
+		return "OFF"
 	default:
 		return ""
 	}
 }
 
+// ParseLevel returns the severity level with the given name, case insensitive.
+func ParseLevel(name string) (Level, error) {
+	for l := LevelDebug; l <= LevelOff; l++ {
+		if strings.EqualFold(name, l.String()) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
 // Logger is the custom logger. It holds the output destination that the log entries will be
 // written to, the minimum severity level that log entries will be written for, a mutex.
+// The minimum level can be changed while the logger is in use.
 type Logger struct {
 	out      io.Writer
-	minLevel Level
+	minLevel atomic.Int32
 	mu       sync.Mutex
 }
 
 // NewLogger returns a new Logger instance which writes log entries at or above a minimum severity
 // level to a specific output destination.
 func NewLogger(out io.Writer, minLevel Level) *Logger {
-	return &Logger{
-		out:      out,
-		minLevel: minLevel,
-	}
+	l := &Logger{out: out}
+	l.minLevel.Store(int32(minLevel))
+	return l
+}
+
+// MinLevel returns the current minimum severity level.
+func (l *Logger) MinLevel() Level {
+	return Level(l.minLevel.Load())
+}
+
+// SetMinLevel changes the minimum severity level of the entries written from now on.
+func (l *Logger) SetMinLevel(level Level) {
+	l.minLevel.Store(int32(level))
+}
+
+// PrintDebug writes Debug level log entries.
+func (l *Logger) PrintDebug(message string, properties map[string]string) {
+	l.print(LevelDebug, message, properties)
 }
 
 // PrintInfo writes Info level log entries.
@@ -56,6 +92,11 @@ func (l *Logger) PrintInfo(message string, properties map[string]string) {
 	l.print(LevelInfo, message, properties)
 }
 
+// PrintWarn writes Warn level log entries.
+func (l *Logger) PrintWarn(message string, properties map[string]string) {
+	l.print(LevelWarn, message, properties)
+}
+
 // PrintError writes Error level log entries.
 func (l *Logger) PrintError(err error, properties map[string]string) {
 	l.print(LevelError, err.Error(), properties)
@@ -69,19 +110,33 @@ func (l *Logger) PrintFatal(err error, properties map[string]string) {
 
 // print is an internal method for writing a log entry.
 func (l *Logger) print(level Level, message string, properties map[string]string) (int, error) {
-	if level < l.minLevel {
+	if level < l.MinLevel() {
 		return 0, nil
 	}
 
+	var props map[string]interface{}
+	if len(properties) > 0 {
+		props = make(map[string]interface{}, len(properties))
+		for k, v := range properties {
+			props[k] = v
+		}
+	}
+
+	return l.write(level, time.Now(), message, props)
+}
+
+// write marshals a log entry into the JSON envelope shared by all the logging methods and
+// writes it to the output destination.
+func (l *Logger) write(level Level, t time.Time, message string, properties map[string]interface{}) (int, error) {
 	aux := struct {
-		Level      string            `json:"level"`
-		Time       string            `json:"time"`
-		Message    string            `json:"message"`
-		Properties map[string]string `json:"properties,omitempty"`
-		Trace      string            `json:"trace,omitempty"`
+		Level      string                 `json:"level"`
+		Time       string                 `json:"time"`
+		Message    string                 `json:"message"`
+		Properties map[string]interface{} `json:"properties,omitempty"`
+		Trace      string                 `json:"trace,omitempty"`
 	}{
 		Level:      level.String(),
-		Time:       time.Now().UTC().Format(time.RFC3339),
+		Time:       t.UTC().Format(time.RFC3339),
 		Message:    message,
 		Properties: properties,
 	}
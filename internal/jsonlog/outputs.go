@@ -0,0 +1,193 @@
+package jsonlog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// OutputOptions configures the outputs opened by OpenOutputs.
+type OutputOptions struct {
+	// MaxFileSize is the size in bytes after which log files are rotated, zero disables rotation.
+	MaxFileSize int64
+	// MaxFileBackups is the number of rotated files kept next to a log file.
+	MaxFileBackups int
+}
+
+// OpenOutputs opens the log destinations described by specs and returns a writer that
+// writes every entry to all of them. A spec is one of:
+//
+//	stdout          standard output
+//	stderr          standard error
+//	file:<path>     a file, rotated according to opts
+//	syslog[:<tag>]  the local syslog daemon
+//
+// Closing the returned writer closes all opened files and connections.
+func OpenOutputs(specs []string, opts OutputOptions) (io.WriteCloser, error) {
+	var outputs []io.Writer
+
+	closeAll := func() {
+		for _, out := range outputs {
+			if c, ok := out.(io.Closer); ok {
+				c.Close()
+			}
+		}
+	}
+
+	for _, spec := range specs {
+		kind, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+
+		var (
+			out io.Writer
+			err error
+		)
+
+		switch kind {
+		case "stdout":
+			out = os.Stdout
+		case "stderr":
+			out = os.Stderr
+		case "file":
+			if arg == "" {
+				err = errors.New("file log output needs a path, e.g. file:/var/log/api.log")
+				break
+			}
+			out, err = OpenRotatingFile(arg, opts.MaxFileSize, opts.MaxFileBackups)
+		case "syslog":
+			if arg == "" {
+				arg = "libraryapi"
+			}
+			out, err = openSyslog(arg)
+		default:
+			err = fmt.Errorf("unknown log output %q", spec)
+		}
+
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		outputs = append(outputs, out)
+	}
+
+	if len(outputs) == 0 {
+		return nil, errors.New("no log output given")
+	}
+
+	return &multiWriter{outputs: outputs}, nil
+}
+
+// multiWriter writes to all its outputs. Unlike io.MultiWriter a failing output does
+// not stop the entry from reaching the remaining ones.
+type multiWriter struct {
+	outputs []io.Writer
+}
+
+func (m *multiWriter) Write(p []byte) (int, error) {
+	var errs []error
+	for _, out := range m.outputs {
+		if _, err := out.Write(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(p), errors.Join(errs...)
+}
+
+// Close closes the outputs that can be closed, except standard output and error.
+func (m *multiWriter) Close() error {
+	var errs []error
+	for _, out := range m.outputs {
+		if out == os.Stdout || out == os.Stderr {
+			continue
+		}
+		if c, ok := out.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RotatingFile is a log file that is rotated once it grows over a size limit: the file is
+// renamed to <path>.1, older backups are shifted to <path>.2 and so on, and the oldest
+// backup over the limit is removed.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens or creates the log file at path for appending.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write satisfies the io.Writer interface, rotating the file first if p would not fit.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	if f.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+
+	return f.open()
+}
+
+// Close closes the file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
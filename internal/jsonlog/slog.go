@@ -0,0 +1,155 @@
+package jsonlog
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// contextKey is the type of the key under which log attributes are stored in a context.
+type contextKey struct{}
+
+// WithAttrs returns a copy of ctx carrying the given attributes in addition to the ones already
+// attached to it. Every entry logged through Slog with that context includes them.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	all := make([]slog.Attr, 0, len(prev)+len(attrs))
+	all = append(append(all, prev...), attrs...)
+	return context.WithValue(ctx, contextKey{}, all)
+}
+
+// Slog returns a *slog.Logger writing entries in the same JSON envelope as the Print methods
+// and honouring the minimum severity level of the Logger.
+func (l *Logger) Slog() *slog.Logger {
+	return slog.New(&handler{logger: l})
+}
+
+// handler is a slog.Handler writing through a Logger. Attributes end up in the properties
+// object of the entry, keeping their JSON types; groups become nested objects.
+type handler struct {
+	logger *Logger
+	attrs  []slog.Attr
+	groups []string
+}
+
+// slogLevel converts a slog level to the matching severity level.
+func slogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+// Enabled reports whether entries of the given level are written.
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return slogLevel(level) >= h.logger.MinLevel()
+}
+
+// Handle writes the record along with the handler and context attributes.
+func (h *handler) Handle(ctx context.Context, rec slog.Record) error {
+	props := make(map[string]interface{})
+	if attrs, ok := ctx.Value(contextKey{}).([]slog.Attr); ok {
+		for _, a := range attrs {
+			addAttr(props, a)
+		}
+	}
+
+	for _, a := range h.attrs {
+		addAttr(props, a)
+	}
+	target := props
+	for _, g := range h.groups {
+		sub, ok := target[g].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			target[g] = sub
+		}
+		target = sub
+	}
+	rec.Attrs(func(a slog.Attr) bool {
+		addAttr(target, a)
+		return true
+	})
+
+	if len(props) == 0 {
+		props = nil
+	}
+
+	t := rec.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	_, err := h.logger.write(slogLevel(rec.Level), t, rec.Message, props)
+	return err
+}
+
+// WithAttrs returns a handler which adds the attributes to every entry. Attributes added
+// while groups are open are nested in those groups.
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		attrs = []slog.Attr{{Key: h.groups[i], Value: slog.GroupValue(attrs...)}}
+	}
+	h2 := &handler{logger: h.logger, groups: h.groups}
+	h2.attrs = append(append(h2.attrs, h.attrs...), attrs...)
+	return h2
+}
+
+// WithGroup returns a handler which puts the attributes of every entry into a named group.
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := &handler{logger: h.logger, attrs: h.attrs}
+	h2.groups = append(append(h2.groups, h.groups...), name)
+	return h2
+}
+
+// addAttr stores the attribute in props. Group attributes are merged into nested objects,
+// and values without a natural JSON form are converted to strings.
+func addAttr(props map[string]interface{}, a slog.Attr) {
+	v := a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	switch v.Kind() {
+	case slog.KindGroup:
+		attrs := v.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		if a.Key == "" {
+			// Inline the attributes of a group without a key.
+			for _, ga := range attrs {
+				addAttr(props, ga)
+			}
+			return
+		}
+		sub, ok := props[a.Key].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{}, len(attrs))
+			props[a.Key] = sub
+		}
+		for _, ga := range attrs {
+			addAttr(sub, ga)
+		}
+	case slog.KindDuration:
+		props[a.Key] = v.Duration().String()
+	case slog.KindTime:
+		props[a.Key] = v.Time().UTC().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			props[a.Key] = err.Error()
+			return
+		}
+		props[a.Key] = v.Any()
+	default:
+		props[a.Key] = v.Any()
+	}
+}
@@ -0,0 +1,40 @@
+package jsonlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, LevelInfo)
+
+	ctx := WithAttrs(context.Background(), slog.String("request_id", "abc"))
+	log := logger.Slog().With("component", "test").WithGroup("book")
+
+	log.DebugContext(ctx, "skipped")
+	log.InfoContext(ctx, "saved", "id", 42, "archived", false)
+
+	var entry struct {
+		Level      string                 `json:"level"`
+		Message    string                 `json:"message"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unexpected output %q: %v", buf.String(), err)
+	}
+
+	if entry.Level != "INFO" || entry.Message != "saved" {
+		t.Errorf("got level %q message %q", entry.Level, entry.Message)
+	}
+	if entry.Properties["request_id"] != "abc" || entry.Properties["component"] != "test" {
+		t.Errorf("missing context or logger attributes: %v", entry.Properties)
+	}
+	book, _ := entry.Properties["book"].(map[string]interface{})
+	if book["id"] != float64(42) || book["archived"] != false {
+		t.Errorf("got book group %v", entry.Properties["book"])
+	}
+}
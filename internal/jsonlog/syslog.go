@@ -0,0 +1,13 @@
+//go:build !windows && !plan9
+
+package jsonlog
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// openSyslog connects to the local syslog daemon, entries are sent with the given tag.
+func openSyslog(tag string) (io.Writer, error) {
+	return syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package jsonlog
+
+import (
+	"errors"
+	"io"
+)
+
+// openSyslog is not supported on this platform.
+func openSyslog(tag string) (io.Writer, error) {
+	return nil, errors.New("syslog log output is not supported on this platform")
+}
@@ -0,0 +1,283 @@
+// Package jsonschema validates JSON values against the subset of JSON Schema used by
+// OpenAPI 3 documents: types, properties, items, bounds, patterns, enums, oneOf and local
+// $refs. Formats are not checked.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+// Schema is a JSON Schema.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Nullable             bool               `json:"nullable"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	UniqueItems          bool               `json:"uniqueItems"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Pattern              string             `json:"pattern"`
+	Enum                 []interface{}      `json:"enum"`
+	OneOf                []*Schema          `json:"oneOf"`
+
+	pattern *regexp.Regexp
+	// additional is AdditionalProperties as a schema, nil if it is a boolean or missing.
+	additional *Schema
+	// closed is set if AdditionalProperties is false.
+	closed bool
+}
+
+// Schemas holds the schemas $refs point to, by reference, e.g.
+// "#/components/schemas/Book".
+type Schemas map[string]*Schema
+
+// Compile prepares the schema and the schemas it contains for validation, compiling their
+// patterns.
+func (s *Schema) Compile() error {
+	if s.Pattern != "" {
+		rx, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("jsonschema: pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = rx
+	}
+
+	switch string(s.AdditionalProperties) {
+	case "", "true":
+	case "false":
+		s.closed = true
+	default:
+		s.additional = new(Schema)
+		if err := json.Unmarshal(s.AdditionalProperties, s.additional); err != nil {
+			return fmt.Errorf("jsonschema: additionalProperties: %w", err)
+		}
+	}
+
+	children := append([]*Schema{s.Items, s.additional}, s.OneOf...)
+	for _, child := range s.Properties {
+		children = append(children, child)
+	}
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		if err := child.Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode decodes a JSON document into a value to validate, keeping numbers as
+// json.Number so that integers are told apart from other numbers.
+func Decode(js []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(js))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Validate checks the value, as returned by Decode, against the schema and adds an error
+// to v for every violation, keyed by the path of the value. It panics on $refs missing
+// from refs, which are errors of the document.
+func (s *Schema) Validate(v *validator.Validator, value interface{}, refs Schemas) {
+	if s.Ref != "" {
+		target, ok := refs[s.Ref]
+		if !ok {
+			panic(fmt.Sprintf("jsonschema: unknown $ref %q", s.Ref))
+		}
+		target.Validate(v, value, refs)
+		return
+	}
+
+	if value == nil {
+		v.Check(s.Nullable || s.Type == "", "", "must not be null")
+		return
+	}
+
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, option := range s.OneOf {
+			if option.Matches(value, refs) {
+				matches++
+			}
+		}
+		v.Check(matches == 1, "", "must match exactly one of the accepted forms")
+	}
+
+	if len(s.Enum) > 0 {
+		v.Check(s.inEnum(value), "", "must be one of "+s.enumValues())
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if !s.checkType(v, "object") {
+			return
+		}
+		for _, key := range s.Required {
+			_, ok := value[key]
+			v.Check(ok, key, "must be provided")
+		}
+		for key, item := range value {
+			switch property, ok := s.Properties[key]; {
+			case ok:
+				property.Validate(v.At(key), item, refs)
+			case s.additional != nil:
+				s.additional.Validate(v.At(key), item, refs)
+			case s.closed:
+				v.AddError(key, "unknown key")
+			}
+		}
+
+	case []interface{}:
+		if !s.checkType(v, "array") {
+			return
+		}
+		if s.MinItems != nil {
+			v.Check(len(value) >= *s.MinItems, "", fmt.Sprintf("must contain at least %d items", *s.MinItems))
+		}
+		if s.MaxItems != nil {
+			v.Check(len(value) <= *s.MaxItems, "", fmt.Sprintf("must not contain more than %d items", *s.MaxItems))
+		}
+		if s.UniqueItems {
+			v.Check(unique(value), "", "must not contain duplicate values")
+		}
+		if s.Items != nil {
+			v.Each("", len(value), func(v *validator.Validator, i int) {
+				s.Items.Validate(v, value[i], refs)
+			})
+		}
+
+	case string:
+		if !s.checkType(v, "string") {
+			return
+		}
+		length := utf8.RuneCountInString(value)
+		if s.MinLength != nil {
+			v.Check(length >= *s.MinLength, "", fmt.Sprintf("must be at least %d characters long", *s.MinLength))
+		}
+		if s.MaxLength != nil {
+			v.Check(length <= *s.MaxLength, "", fmt.Sprintf("must not be more than %d characters long", *s.MaxLength))
+		}
+		if s.pattern != nil {
+			v.Check(s.pattern.MatchString(value), "", "must match the pattern "+s.Pattern)
+		}
+
+	case json.Number:
+		f, err := value.Float64()
+		integer := err == nil && f == math.Trunc(f) && !strings.ContainsAny(value.String(), ".eE")
+		if s.Type == "integer" && !integer {
+			v.AddError("", "must be an integer")
+			return
+		}
+		if !s.checkType(v, "number", "integer") {
+			return
+		}
+		if s.Minimum != nil {
+			v.Check(f >= *s.Minimum, "", fmt.Sprintf("must be at least %v", *s.Minimum))
+		}
+		if s.Maximum != nil {
+			v.Check(f <= *s.Maximum, "", fmt.Sprintf("must be a maximum of %v", *s.Maximum))
+		}
+
+	case bool:
+		s.checkType(v, "boolean")
+	}
+}
+
+// Matches reports whether the value is valid against the schema.
+func (s *Schema) Matches(value interface{}, refs Schemas) bool {
+	v := validator.New()
+	s.Validate(v, value, refs)
+	return v.Valid()
+}
+
+// checkType adds an error if the schema has a type other than the types of the value, and
+// reports whether the value has the type.
+func (s *Schema) checkType(v *validator.Validator, types ...string) bool {
+	if s.Type == "" || validator.In(s.Type, types...) {
+		return true
+	}
+
+	article := "a"
+	if strings.ContainsAny(s.Type[:1], "aeiou") {
+		article = "an"
+	}
+	v.AddError("", fmt.Sprintf("must be %s %s", article, s.Type))
+	return false
+}
+
+func (s *Schema) inEnum(value interface{}) bool {
+	for _, allowed := range s.Enum {
+		if equal(value, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// enumValues lists the values of the enum for an error message, e.g. "a, b or c".
+func (s *Schema) enumValues() string {
+	values := make([]string, len(s.Enum))
+	for i, value := range s.Enum {
+		values[i] = fmt.Sprint(value)
+	}
+	if len(values) == 1 {
+		return values[0]
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
+
+// unique reports whether the items are all different.
+func unique(items []interface{}) bool {
+	for i := range items {
+		for j := i + 1; j < len(items); j++ {
+			if equal(items[i], items[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// equal reports whether two JSON values are equal, comparing numbers by value.
+func equal(a, b interface{}) bool {
+	if na, ok := numberValue(a); ok {
+		nb, ok := numberValue(b)
+		return ok && na == nb
+	}
+
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+func numberValue(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
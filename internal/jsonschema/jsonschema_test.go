@@ -0,0 +1,80 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/validator"
+)
+
+func TestValidate(t *testing.T) {
+	var schema, pages Schema
+	err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"title": {"type": "string", "minLength": 1, "maxLength": 5},
+			"code": {"type": "string", "pattern": "^[A-Z]+$"},
+			"pages": {"$ref": "#/pages"},
+			"format": {"type": "string", "enum": ["atom", "rss"]},
+			"authors": {
+				"type": "array",
+				"items": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]},
+				"maxItems": 1
+			},
+			"metadata": {"type": "object", "additionalProperties": {"type": "string"}},
+			"draft": {"type": "boolean", "nullable": true}
+		},
+		"required": ["title"],
+		"additionalProperties": false
+	}`), &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{"oneOf": [{"type": "integer", "minimum": 0}, {"type": "string"}]}`), &pages); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*Schema{&schema, &pages} {
+		if err := s.Compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	refs := Schemas{"#/pages": &pages}
+
+	tests := []struct {
+		js   string
+		want map[string]string
+	}{
+		{js: `{"title": "Dune", "code": "AB", "pages": 412, "format": "rss", "draft": null, "metadata": {"a": "b"}}`, want: map[string]string{}},
+		{js: `{"title": "Dune", "pages": "412 pages"}`, want: map[string]string{}},
+		{
+			js: `{"title": "Dune Messiah", "code": "ab", "pages": -1, "format": "xml", "draft": "no",
+				"authors": [{"name": "Frank"}, {}], "metadata": {"a": 1}, "colour": "red"}`,
+			want: map[string]string{
+				"title":           "must not be more than 5 characters long",
+				"code":            "must match the pattern ^[A-Z]+$",
+				"pages":           "must match exactly one of the accepted forms",
+				"format":          "must be one of atom or rss",
+				"draft":           "must be a boolean",
+				"authors":         "must not contain more than 1 items",
+				"authors[1].name": "must be provided",
+				"metadata.a":      "must be a string",
+				"colour":          "unknown key",
+			},
+		},
+		{js: `{"title": null}`, want: map[string]string{"title": "must not be null"}},
+	}
+
+	for _, tt := range tests {
+		value, err := Decode([]byte(tt.js))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v := validator.New()
+		schema.Validate(v, value, refs)
+		if !reflect.DeepEqual(v.Errors, tt.want) {
+			t.Errorf("%s: want errors %v, got %v", tt.js, tt.want, v.Errors)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package mailer sends templated emails over SMTP.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
+)
+
+// templateFS holds the email templates. Each template defines a "subject", a "plainBody"
+// and an "htmlBody" block.
+//
+//go:embed "templates"
+var templateFS embed.FS
+
+// Config holds the SMTP server settings.
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string
+	// DryRun logs the emails instead of sending them.
+	DryRun bool
+}
+
+// Mailer renders the email templates and sends the emails.
+type Mailer struct {
+	config Config
+	logger *jsonlog.Logger
+}
+
+// New returns a Mailer sending emails with the given settings.
+func New(config Config, logger *jsonlog.Logger) (*Mailer, error) {
+	if _, err := mail.ParseAddress(config.Sender); err != nil {
+		return nil, fmt.Errorf("mailer: invalid sender: %w", err)
+	}
+	return &Mailer{config: config, logger: logger}, nil
+}
+
+// Send renders the template file, e.g. "new_book.tmpl", with data and sends the result
+// to recipient.
+func (m *Mailer) Send(recipient, templateFile string, data interface{}) error {
+	subject, plainBody, htmlBody, err := render(templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	if m.config.DryRun {
+		m.logger.PrintInfo("email not sent in dry run mode", map[string]string{
+			"recipient": recipient,
+			"subject":   subject,
+			"body":      plainBody,
+		})
+		return nil
+	}
+
+	msg, err := m.message(recipient, subject, plainBody, htmlBody)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+
+	from, err := mail.ParseAddress(m.config.Sender)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	return smtp.SendMail(addr, auth, from.Address, []string{recipient}, msg)
+}
+
+// render executes the blocks of the template file with data.
+func render(templateFile string, data interface{}) (subject, plainBody, htmlBody string, err error) {
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	subject = buf.String()
+
+	buf.Reset()
+	if err := tmpl.ExecuteTemplate(&buf, "plainBody", data); err != nil {
+		return "", "", "", err
+	}
+	plainBody = buf.String()
+
+	// The HTML body is parsed again with html/template for contextual escaping.
+	htmlTmpl, err := htmltemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	buf.Reset()
+	if err := htmlTmpl.ExecuteTemplate(&buf, "htmlBody", data); err != nil {
+		return "", "", "", err
+	}
+	htmlBody = buf.String()
+
+	return subject, plainBody, htmlBody, nil
+}
+
+// message builds a multipart/alternative MIME message with the plain text and HTML bodies.
+func (m *Mailer) message(recipient, subject, plainBody, htmlBody string) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", plainBody},
+		{"text/html; charset=UTF-8", htmlBody},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.config.Sender)
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), m.config.Host)
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	year := int32(1976)
+	data := map[string]interface{}{
+		"Tenant": "acme",
+		"Book": map[string]interface{}{
+			"Title":  "Children of Dune & Co",
+			"Year":   &year,
+			"Genres": []string{"sci-fi", "classic"},
+		},
+	}
+
+	subject, plainBody, htmlBody, err := render("new_book.tmpl", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subject != "New book: Children of Dune & Co" {
+		t.Errorf("got subject %q", subject)
+	}
+	if !strings.Contains(plainBody, `"Children of Dune & Co" (1976) has been added to the catalog of acme.`) ||
+		!strings.Contains(plainBody, "Genres: sci-fi, classic") {
+		t.Errorf("got plain body %q", plainBody)
+	}
+	if !strings.Contains(htmlBody, "<strong>Children of Dune &amp; Co</strong>") {
+		t.Errorf("want escaped title in HTML body, got %q", htmlBody)
+	}
+}
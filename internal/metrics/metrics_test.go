@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounterVec("requests_total", "Number of requests.", "method")
+	requests.Inc("GET")
+	requests.Add(2, "POST")
+
+	latency := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1})
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+
+	var sb strings.Builder
+	r.Write(&sb)
+
+	want := `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{method="GET"} 1
+requests_total{method="POST"} 2
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 0.55
+latency_seconds_count 2
+`
+
+	if sb.String() != want {
+		t.Errorf("want output\n%s\nbut got\n%s", want, sb.String())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	process, instance := NewRegistry(), NewRegistry()
+	process.NewGauge("in_flight", "In flight.").Add(2)
+	instance.NewGaugeFunc("queue_length", "Queue length.", func() float64 { return 3 })
+
+	rr := httptest.NewRecorder()
+	Handler(process, instance, nil).ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	want := `# HELP in_flight In flight.
+# TYPE in_flight gauge
+in_flight 2
+# HELP queue_length Queue length.
+# TYPE queue_length gauge
+queue_length 3
+`
+	if rr.Body.String() != want {
+		t.Errorf("want output\n%s\nbut got\n%s", want, rr.Body.String())
+	}
+}
@@ -0,0 +1,155 @@
+// Package opensearch is a minimal client of the OpenSearch and Elasticsearch REST API. It
+// implements the few calls the API keeps its search index with.
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error response of the server.
+type Error struct {
+	Status int
+	Type   string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("opensearch: status %d", e.Status)
+	}
+	return fmt.Sprintf("opensearch: %s: %s", e.Type, e.Reason)
+}
+
+// Client is an OpenSearch client safe for concurrent use.
+type Client struct {
+	base     *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+// New returns a Client for the cluster at rawURL, of the form
+// http[s]://[user:password@]host[:port]. No request is made until the first call.
+func New(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("opensearch: invalid URL %q, want http[s]://[user:password@]host[:port]", u.Redacted())
+	}
+
+	c := &Client{
+		base:   &url.URL{Scheme: u.Scheme, Host: u.Host, Path: strings.TrimSuffix(u.Path, "/")},
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+
+	return c, nil
+}
+
+// Ping checks that the cluster is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/", nil, nil, nil)
+}
+
+// CreateIndex creates the index with the settings and mappings of body. It reports
+// whether the index was created, false meaning it already existed.
+func (c *Client) CreateIndex(ctx context.Context, index string, body interface{}) (bool, error) {
+	err := c.do(ctx, http.MethodPut, "/"+url.PathEscape(index), nil, body, nil)
+	if e, ok := err.(*Error); ok && e.Type == "resource_already_exists_exception" {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Index stores doc under id with an external version. Writes of versions older than the
+// stored one are ignored, so documents can be indexed in any order.
+func (c *Client) Index(ctx context.Context, index, id string, version int64, doc interface{}) error {
+	q := url.Values{"version": {strconv.FormatInt(version, 10)}, "version_type": {"external"}}
+	err := c.do(ctx, http.MethodPut, "/"+url.PathEscape(index)+"/_doc/"+url.PathEscape(id), q, doc, nil)
+	if e, ok := err.(*Error); ok && e.Status == http.StatusConflict {
+		return nil
+	}
+	return err
+}
+
+// Delete removes the document stored under id unless it has a newer version; the version
+// of the deletion may equal the stored one. Deleting a missing document isn't an error.
+func (c *Client) Delete(ctx context.Context, index, id string, version int64) error {
+	q := url.Values{"version": {strconv.FormatInt(version, 10)}, "version_type": {"external_gte"}}
+	err := c.do(ctx, http.MethodDelete, "/"+url.PathEscape(index)+"/_doc/"+url.PathEscape(id), q, nil, nil)
+	if e, ok := err.(*Error); ok && (e.Status == http.StatusConflict || e.Status == http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Search runs the search request body against the index and decodes the response into
+// result.
+func (c *Client) Search(ctx context.Context, index string, body, result interface{}) error {
+	return c.do(ctx, http.MethodPost, "/"+url.PathEscape(index)+"/_search", nil, body, result)
+}
+
+// do sends a request with body encoded as JSON and decodes the response into result,
+// unless either is nil. Responses outside 2xx are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	u := *c.base
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	var r io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e struct {
+			Error struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&e)
+		return &Error{Status: resp.StatusCode, Type: e.Error.Type, Reason: e.Error.Reason}
+	}
+
+	if result == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,101 @@
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeCluster keeps the documents of one index with their external versions, rejecting
+// writes of older versions with 409 Conflict as OpenSearch does.
+func fakeCluster(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	versions := make(map[string]int64)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		version, _ := strconv.ParseInt(r.URL.Query().Get("version"), 10, 64)
+		current, exists := versions[r.URL.Path]
+
+		switch r.Method {
+		case http.MethodPut:
+			io.Copy(io.Discard, r.Body)
+			if exists && version <= current {
+				w.WriteHeader(http.StatusConflict)
+				io.WriteString(w, `{"error":{"type":"version_conflict_engine_exception","reason":"conflict"}}`)
+				return
+			}
+			versions[r.URL.Path] = version
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if version < current || r.URL.Query().Get("version_type") != "external_gte" {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			delete(versions, r.URL.Path)
+		case http.MethodPost:
+			io.WriteString(w, `{"hits":{"total":{"value":`+strconv.Itoa(len(versions))+`}}}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	srv := fakeCluster(t)
+
+	c, err := New("http://admin:secret@" + srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Index(ctx, "books", "acme:1", 2, map[string]string{"title": "Dune"}); err != nil {
+		t.Fatal(err)
+	}
+	// a stale write arriving late is ignored.
+	if err := c.Index(ctx, "books", "acme:1", 1, map[string]string{"title": "Dun"}); err != nil {
+		t.Errorf("want stale writes ignored, got %v", err)
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+	}
+	if err := c.Search(ctx, "books", map[string]interface{}{}, &result); err != nil || result.Hits.Total.Value != 1 {
+		t.Fatalf("want 1 hit, got %d, %v", result.Hits.Total.Value, err)
+	}
+
+	if err := c.Delete(ctx, "books", "acme:1", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, "books", "acme:1", 2); err != nil {
+		t.Errorf("want deleting a missing document to succeed, got %v", err)
+	}
+
+	c, _ = New("http://" + srv.Listener.Addr().String())
+	var e *Error
+	if err := c.Ping(ctx); !errors.As(err, &e) || e.Status != http.StatusUnauthorized {
+		t.Errorf("want 401 error, got %v", err)
+	}
+}
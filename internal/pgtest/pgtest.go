@@ -0,0 +1,256 @@
+// Package pgtest provides PostgreSQL databases to the tests that need one. The database
+// in TEST_DB_DSN is used if it is set, otherwise a disposable PostgreSQL container is
+// started with docker. Tests are skipped when neither is available.
+//
+// The migrations are applied to the database before it is handed out, and every test
+// gets a tenant of its own so that tests sharing a database do not see each other's data.
+// Seed fills the tenant with the built-in fixture set.
+package pgtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/data"
+	"github.com/nikitashershunov/LibraryAPI/internal/fixtures"
+	"github.com/nikitashershunov/LibraryAPI/migrations"
+)
+
+// Image is the PostgreSQL image of the containers started by the harness.
+const Image = "postgres:16-alpine"
+
+var (
+	once sync.Once
+	db   *sql.DB
+	// container is the ID of the container started by the harness, empty if the
+	// database in TEST_DB_DSN is used.
+	container string
+	// skip is the reason tests needing a database are skipped, if there is none.
+	skip string
+	err  error
+)
+
+// Main runs the tests of a package with m and removes the container started for them, if
+// any. Packages using Open call it from their TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		pgtest.Main(m)
+//	}
+func Main(m *testing.M) {
+	code := m.Run()
+
+	if db != nil {
+		db.Close()
+	}
+	if container != "" {
+		exec.Command("docker", "rm", "--force", "--volumes", container).Run()
+	}
+
+	os.Exit(code)
+}
+
+// Open returns a connection pool to a migrated database, shared by the tests of the
+// package. The test is skipped if no database is available.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+
+	once.Do(func() {
+		db, err = open()
+		if db != nil {
+			err = Migrate(db)
+		}
+	})
+	switch {
+	case skip != "":
+		t.Skip(skip)
+	case err != nil:
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// Tenant returns a tenant unique to the test, whose data is deleted when the test ends.
+func Tenant(t testing.TB, db *sql.DB) string {
+	t.Helper()
+
+	tenant := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM books WHERE tenant_id = $1", tenant)
+		db.Exec("DELETE FROM webhooks WHERE tenant_id = $1", tenant)
+		db.Exec("DELETE FROM outbox WHERE tenant_id = $1", tenant)
+		db.Exec("DELETE FROM api_keys WHERE tenant_id = $1", tenant)
+	})
+
+	return tenant
+}
+
+// Seed loads the built-in fixture set, fixtures.Library, for the tenant.
+func Seed(t testing.TB, db *sql.DB, tenant string) *fixtures.Loaded {
+	t.Helper()
+
+	set, err := fixtures.Library()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := fixtures.Load(data.NewModels(db), tenant, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loaded
+}
+
+// Migrate applies the migrations newer than the version recorded in schema_migrations,
+// keeping the table the same way the migrate tool does so that databases migrated by the
+// harness and by the tool can be used interchangeably.
+func Migrate(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL PRIMARY KEY,
+			dirty boolean NOT NULL
+		)`)
+	if err != nil {
+		return err
+	}
+
+	var (
+		current int64
+		dirty   bool
+	)
+	err = db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&current, &dirty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return err
+	case dirty:
+		return fmt.Errorf("pgtest: database is dirty at migration %d", current)
+	}
+
+	names, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return fmt.Errorf("pgtest: migration %s: %w", name, err)
+		}
+		if version <= current {
+			continue
+		}
+
+		query, err := fs.ReadFile(migrations.FS, name)
+		if err != nil {
+			return err
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, string(query)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("pgtest: migration %s: %w", name, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)", version); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// open connects to the database in TEST_DB_DSN or to a newly started container.
+func open() (*sql.DB, error) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		if _, err := exec.LookPath("docker"); err != nil {
+			skip = "TEST_DB_DSN is not set and docker is not available"
+			return nil, nil
+		}
+
+		var err error
+		if dsn, err = start(); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// the server of a new container takes a few seconds to accept connections.
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		err = db.Ping()
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("pgtest: connecting to the database: %w", err)
+	}
+
+	return db, nil
+}
+
+// start starts a PostgreSQL container listening on a free local port and returns the
+// DSN of its database.
+func start() (string, error) {
+	out, err := exec.Command("docker", "run", "--detach", "--rm",
+		"--env", "POSTGRES_USER=books", "--env", "POSTGRES_PASSWORD=books", "--env", "POSTGRES_DB=books",
+		"--publish", "127.0.0.1::5432", Image).Output()
+	if err != nil {
+		return "", fmt.Errorf("pgtest: starting %s: %w", Image, commandError(err))
+	}
+	container = strings.TrimSpace(string(out))
+
+	out, err = exec.Command("docker", "port", container, "5432/tcp").Output()
+	if err != nil {
+		return "", fmt.Errorf("pgtest: reading the port of the container: %w", commandError(err))
+	}
+	// the output holds one address per line, e.g. "127.0.0.1:49153".
+	addr, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+
+	return fmt.Sprintf("postgres://books:books@%s/books?sslmode=disable", addr), nil
+}
+
+// commandError adds the standard error output of a failed command to its error.
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
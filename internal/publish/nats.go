@@ -0,0 +1,183 @@
+package publish
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATS publishes messages to NATS subjects named "<prefix>.<subject>". The ID of a message
+// is sent in the Nats-Msg-Id header, which JetStream streams deduplicate on. A single
+// connection is used, dialled on the first message and again after a failure.
+type NATS struct {
+	addr   string
+	tls    bool
+	user   string
+	pass   string
+	token  string
+	prefix string
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewNATS returns a NATS publisher to the server at u, of the form
+// nats://[user:password@|token@]host[:port], or tls:// for TLS connections.
+func NewNATS(u *url.URL, prefix string) (*NATS, error) {
+	if (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+		return nil, fmt.Errorf("publish: invalid NATS URL %q", u.Redacted())
+	}
+
+	n := &NATS{addr: u.Host, tls: u.Scheme == "tls", prefix: prefix}
+	if u.Port() == "" {
+		n.addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			n.user, n.pass = u.User.Username(), pass
+		} else {
+			n.token = u.User.Username()
+		}
+	}
+
+	return n, nil
+}
+
+// Publish sends the message with HPUB and waits for the answer to a PING, which the server
+// sends after processing the message or reporting an error with it.
+func (n *NATS) Publish(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	subject := msg.Subject
+	if n.prefix != "" {
+		subject = n.prefix + "." + subject
+	}
+
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(10 * time.Second)
+	}
+	n.conn.SetDeadline(deadline)
+
+	headers := "NATS/1.0\r\nNats-Msg-Id: " + msg.ID + "\r\n\r\n"
+	frame := fmt.Sprintf("HPUB %s %d %d\r\n%s%s\r\nPING\r\n", subject, len(headers), len(headers)+len(msg.Payload), headers, msg.Payload)
+
+	err := n.roundTrip(frame)
+	if err != nil {
+		n.conn.Close()
+		n.conn = nil
+	}
+	return err
+}
+
+// connect dials the server, reads its INFO and authenticates with CONNECT.
+func (n *NATS) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("publish: unexpected NATS greeting %q", strings.TrimSpace(line))
+	}
+
+	if n.tls {
+		host, _, _ := net.SplitHostPort(n.addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+		r = bufio.NewReader(conn)
+	}
+
+	options, err := json.Marshal(map[string]interface{}{
+		"verbose":    false,
+		"pedantic":   false,
+		"headers":    true,
+		"name":       "library-api",
+		"lang":       "go",
+		"user":       n.user,
+		"pass":       n.pass,
+		"auth_token": n.token,
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	n.conn, n.r = conn, r
+	if err := n.roundTrip("CONNECT " + string(options) + "\r\nPING\r\n"); err != nil {
+		conn.Close()
+		n.conn = nil
+		return err
+	}
+	return nil
+}
+
+// roundTrip writes frame, which must end with PING, and reads until the PONG, answering
+// the PINGs of the server and returning its -ERR messages as errors.
+func (n *NATS) roundTrip(frame string) error {
+	if _, err := n.conn.Write([]byte(frame)); err != nil {
+		return err
+	}
+
+	for {
+		line, err := n.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("publish: NATS " + strings.Trim(strings.TrimPrefix(line, "-ERR "), "'"))
+		}
+	}
+}
+
+// Close closes the connection to the server.
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn = nil
+	return err
+}
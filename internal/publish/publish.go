@@ -0,0 +1,47 @@
+// Package publish sends events to a message broker. Publishers for NATS and for Kafka,
+// through the Kafka REST Proxy, implement the same interface so the broker is a matter of
+// configuration.
+package publish
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// Message is an event to publish.
+type Message struct {
+	// ID identifies the message, so consumers and brokers can drop duplicates.
+	ID string
+	// Subject is the kind of event, e.g. "book.created".
+	Subject string
+	// Key groups related messages, which are kept in order, e.g. the events of a book.
+	Key string
+	// Payload is the JSON encoded event.
+	Payload []byte
+}
+
+// Publisher publishes messages to a broker. Publish returns once the broker has accepted
+// the message.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// New returns the publisher of the given kind, kafka or nats, for the broker at rawURL.
+// topic is the Kafka topic or the prefix of the NATS subjects.
+func New(kind, rawURL, topic string) (Publisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "kafka":
+		return NewKafka(u, topic)
+	case "nats":
+		return NewNATS(u, topic)
+	default:
+		return nil, fmt.Errorf("publish: unknown publisher %q", kind)
+	}
+}
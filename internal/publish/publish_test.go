@@ -0,0 +1,118 @@
+package publish
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeNATS accepts one connection and sends the subjects and headers of the HPUB messages
+// it receives to the returned channel.
+func fakeNATS(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 10)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		io.WriteString(c, `INFO {"server_id":"fake","headers":true}`+"\r\n")
+		r := bufio.NewReader(c)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "PING":
+				io.WriteString(c, "PONG\r\n")
+			case "HPUB":
+				size, _ := strconv.Atoi(fields[3])
+				msg := make([]byte, size+2)
+				io.ReadFull(r, msg)
+				received <- fields[1] + " " + string(msg[:size])
+			}
+		}
+	}()
+
+	return ln.Addr().String(), received
+}
+
+func TestNATS(t *testing.T) {
+	addr, received := fakeNATS(t)
+
+	p, err := New("nats", "nats://"+addr, "library")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	err = p.Publish(context.Background(), Message{ID: "42", Subject: "book.created", Payload: []byte(`{"book_id":1}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "library.book.created NATS/1.0\r\nNats-Msg-Id: 42\r\n\r\n{\"book_id\":1}"
+	if got := <-received; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestKafka(t *testing.T) {
+	var got struct {
+		Records []struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		} `json:"records"`
+	}
+	fail := false
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/topics/library.books" || r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		if fail {
+			io.WriteString(w, `{"offsets":[{"partition":null,"offset":null,"error_code":50002,"error":"leader not available"}]}`)
+			return
+		}
+		io.WriteString(w, `{"offsets":[{"partition":0,"offset":7}]}`)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	p, err := NewKafka(u, "library.books")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := Message{ID: "42", Subject: "book.created", Key: "acme:1", Payload: []byte(`{"book_id":1}`)}
+	if err := p.Publish(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Records) != 1 || got.Records[0].Key != "acme:1" || string(got.Records[0].Value) != `{"book_id":1}` {
+		t.Errorf("unexpected records %+v", got.Records)
+	}
+
+	fail = true
+	if err := p.Publish(context.Background(), msg); err == nil || !strings.Contains(err.Error(), "leader not available") {
+		t.Errorf("want the record error, got %v", err)
+	}
+}
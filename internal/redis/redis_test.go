@@ -0,0 +1,121 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeServer serves GET, SET, DEL, INCR and AUTH from a map, one command at a time.
+func fakeServer(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	store := make(chan map[string]string, 1)
+	store <- make(map[string]string)
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					reply, err := readReply(r)
+					if err != nil {
+						return
+					}
+					var args []string
+					for _, arg := range reply.([]interface{}) {
+						args = append(args, string(arg.([]byte)))
+					}
+
+					m := <-store
+					switch args[0] {
+					case "AUTH":
+						if args[1] == "secret" {
+							io.WriteString(c, "+OK\r\n")
+						} else {
+							io.WriteString(c, "-WRONGPASS invalid password\r\n")
+						}
+					case "GET":
+						if v, ok := m[args[1]]; ok {
+							fmt.Fprintf(c, "$%d\r\n%s\r\n", len(v), v)
+						} else {
+							io.WriteString(c, "$-1\r\n")
+						}
+					case "SET":
+						m[args[1]] = args[2]
+						io.WriteString(c, "+OK\r\n")
+					case "DEL":
+						for _, key := range args[1:] {
+							delete(m, key)
+						}
+						fmt.Fprintf(c, ":%d\r\n", len(args)-1)
+					case "INCR":
+						n, _ := strconv.Atoi(m[args[1]])
+						m[args[1]] = strconv.Itoa(n + 1)
+						fmt.Fprintf(c, ":%d\r\n", n+1)
+					default:
+						io.WriteString(c, "-ERR unknown command\r\n")
+					}
+					store <- m
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestClient(t *testing.T) {
+	addr := fakeServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c, err := New("redis://:secret@"+addr, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.Get(ctx, "book"); !errors.Is(err, Nil) {
+		t.Fatalf("want Nil, got %v", err)
+	}
+	if err := c.Set(ctx, "book", []byte("dune\r\n"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.Get(ctx, "book"); err != nil || string(v) != "dune\r\n" {
+		t.Fatalf("want dune, got %q, %v", v, err)
+	}
+	if err := c.Del(ctx, "book"); err != nil {
+		t.Fatal(err)
+	}
+	for want := int64(1); want <= 2; want++ {
+		if n, err := c.Incr(ctx, "gen"); err != nil || n != want {
+			t.Fatalf("want %d, got %d, %v", want, n, err)
+		}
+	}
+
+	var redisErr Error
+	if err := c.Ping(ctx); !errors.As(err, &redisErr) {
+		t.Errorf("want an error reply, got %v", err)
+	}
+
+	c, _ = New("redis://:wrong@"+addr, 2)
+	if err := c.Ping(ctx); !errors.As(err, &redisErr) {
+		t.Errorf("want an authentication error, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+// Package rpc serves unary gRPC methods over net/http. It implements the parts of the gRPC
+// over HTTP/2 protocol and of the protobuf encoding the API needs, so internal services can
+// use generated gRPC clients without the server depending on the gRPC runtime.
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Code is a gRPC status code.
+type Code int
+
+// The gRPC status codes returned by the server.
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	Unauthenticated    Code = 16
+)
+
+// Error is an error returned to the client with a status code.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+// Errorf returns an Error with the code and a formatted message.
+func Errorf(code Code, format string, args ...interface{}) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error: code = %d desc = %s", e.Code, e.Message)
+}
+
+// Marshaler is a message which can be encoded.
+type Marshaler interface {
+	MarshalProto() []byte
+}
+
+// Unmarshaler is a message which can be decoded.
+type Unmarshaler interface {
+	UnmarshalProto(b []byte) error
+}
+
+// Handler handles a unary method. It calls dec to decode the request message, and returns the
+// response message or an error. md holds the request headers, i.e. the gRPC metadata.
+type Handler func(ctx context.Context, md http.Header, dec func(Unmarshaler) error) (Marshaler, error)
+
+// Server routes gRPC requests to the handlers of the registered methods.
+type Server struct {
+	methods map[string]Handler
+	// MaxMessageSize is the maximum size of a request message in bytes.
+	MaxMessageSize int
+	// ErrorLog is called with the errors which are returned to the client as Internal,
+	// whose details are not exposed. It may be nil.
+	ErrorLog func(method string, err error)
+}
+
+// NewServer returns a Server accepting request messages of up to maxMessageSize bytes.
+func NewServer(maxMessageSize int) *Server {
+	return &Server{
+		methods:        make(map[string]Handler),
+		MaxMessageSize: maxMessageSize,
+	}
+}
+
+// Handle registers the handler of the method of the service, e.g. "books.v1.Books" and "GetBook".
+func (s *Server) Handle(service, method string, h Handler) {
+	s.methods["/"+service+"/"+method] = h
+}
+
+// ServeHTTP serves a gRPC request. Only the identity encoding is supported.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	ct := r.Header.Get("Content-Type")
+	if ct != "application/grpc" && !strings.HasPrefix(ct, "application/grpc+proto") && !strings.HasPrefix(ct, "application/grpc;") {
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	h, ok := s.methods[r.URL.Path]
+	if !ok {
+		writeStatus(w, Unimplemented, fmt.Sprintf("unknown method %s", r.URL.Path))
+		return
+	}
+
+	ctx := r.Context()
+	if timeout, ok := parseTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	resp, err := s.call(ctx, r, h)
+	if err != nil {
+		var rpcErr *Error
+		switch {
+		case errors.As(err, &rpcErr):
+			writeStatus(w, rpcErr.Code, rpcErr.Message)
+		case errors.Is(err, context.DeadlineExceeded):
+			writeStatus(w, DeadlineExceeded, "deadline exceeded")
+		case errors.Is(err, context.Canceled):
+			writeStatus(w, Canceled, "request canceled")
+		default:
+			if s.ErrorLog != nil {
+				s.ErrorLog(r.URL.Path, err)
+			}
+			writeStatus(w, Internal, "the server encountered a problem and could not process the request")
+		}
+		return
+	}
+
+	b := resp.MarshalProto()
+	frame := make([]byte, 5, 5+len(b))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(b)))
+	frame = append(frame, b...)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(frame)
+	writeStatus(w, OK, "")
+}
+
+// call reads the request message and runs the handler, turning a panic into an error.
+func (s *Server) call(ctx context.Context, r *http.Request, h Handler) (resp Marshaler, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+
+	msg, err := s.readMessage(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := func(m Unmarshaler) error {
+		if err := m.UnmarshalProto(msg); err != nil {
+			return Errorf(InvalidArgument, "malformed request message: %v", err)
+		}
+		return nil
+	}
+
+	return h(ctx, r.Header, dec)
+}
+
+// readMessage reads the single length-prefixed message of a unary request.
+func (s *Server) readMessage(body io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil, Errorf(InvalidArgument, "missing request message")
+	}
+	if prefix[0] != 0 {
+		return nil, Errorf(Unimplemented, "compressed messages are not supported")
+	}
+
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if int64(n) > int64(s.MaxMessageSize) {
+		return nil, Errorf(ResourceExhausted, "request message larger than %d bytes", s.MaxMessageSize)
+	}
+
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return nil, Errorf(InvalidArgument, "truncated request message")
+	}
+	return msg, nil
+}
+
+// writeStatus sets the status trailers of the response, which are sent once the handler returns.
+func writeStatus(w http.ResponseWriter, code Code, msg string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(code)))
+	if msg != "" {
+		w.Header().Set("Grpc-Message", encodeMessage(msg))
+	}
+}
+
+// encodeMessage percent-encodes the status message as the protocol requires.
+func encodeMessage(msg string) string {
+	var sb strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&sb, "%%%02X", c)
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// parseTimeout parses the value of the grpc-timeout header, e.g. "100m" for 100 milliseconds.
+func parseTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	unit, ok := units[s[len(s)-1]]
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
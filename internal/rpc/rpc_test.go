@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type echo struct {
+	ID    int64
+	Name  string
+	Count *int32
+	Tags  []string
+}
+
+func (m *echo) MarshalProto() []byte {
+	var e Encoder
+	e.Int64(1, m.ID)
+	e.String(2, m.Name)
+	e.OptionalInt32(3, m.Count)
+	e.Strings(4, m.Tags)
+	return e.Bytes()
+}
+
+func (m *echo) UnmarshalProto(b []byte) error {
+	d := NewDecoder(b)
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.ID = d.Int64()
+		case 2:
+			m.Name = d.String()
+		case 3:
+			m.Count = d.OptionalInt32()
+		case 4:
+			m.Tags = append(m.Tags, d.String())
+		default:
+			d.Skip()
+		}
+	}
+	return d.Err()
+}
+
+func frame(b []byte) []byte {
+	f := make([]byte, 5, 5+len(b))
+	binary.BigEndian.PutUint32(f[1:], uint32(len(b)))
+	return append(f, b...)
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(1024)
+	s.Handle("test.v1.Echo", "Echo", func(ctx context.Context, md http.Header, dec func(Unmarshaler) error) (Marshaler, error) {
+		in := new(echo)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		if in.ID == 0 {
+			return nil, Errorf(NotFound, "no echo 0%%")
+		}
+		return in, nil
+	})
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	call := func(path string, m *echo) (*http.Response, []byte) {
+		var e Encoder
+		// an unknown field is skipped
+		e.String(15, "ignored")
+		body := append(e.Bytes(), m.MarshalProto()...)
+
+		resp, err := http.Post(ts.URL+path, "application/grpc", bytes.NewReader(frame(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, b
+	}
+
+	count := int32(0)
+	resp, b := call("/test.v1.Echo/Echo", &echo{ID: -7, Name: "книга", Count: &count, Tags: []string{"a", "b"}})
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Fatalf("want status 0, got %q (%s)", got, resp.Trailer.Get("Grpc-Message"))
+	}
+
+	var out echo
+	if len(b) < 5 || out.UnmarshalProto(b[5:]) != nil {
+		t.Fatalf("invalid response message %x", b)
+	}
+	if out.ID != -7 || out.Name != "книга" || out.Count == nil || *out.Count != 0 || len(out.Tags) != 2 {
+		t.Errorf("unexpected echo %+v", out)
+	}
+
+	resp, _ = call("/test.v1.Echo/Echo", &echo{})
+	if got := resp.Trailer.Get("Grpc-Status"); got != "5" {
+		t.Errorf("want status 5, got %q", got)
+	}
+	if got := resp.Trailer.Get("Grpc-Message"); got != "no echo 0%25" {
+		t.Errorf("want percent-encoded message, got %q", got)
+	}
+
+	resp, _ = call("/test.v1.Echo/Missing", &echo{})
+	if got := resp.Trailer.Get("Grpc-Status"); got != "12" {
+		t.Errorf("want status 12, got %q", got)
+	}
+}
@@ -0,0 +1,297 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Wire types of the protobuf encoding used by the messages.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// errTruncated is returned when a message ends in the middle of a field.
+var errTruncated = errors.New("rpc: truncated message")
+
+// Encoder builds a message in the protobuf binary encoding. Fields holding the zero value
+// of their type are omitted, as in proto3.
+type Encoder struct {
+	buf []byte
+}
+
+// Bytes returns the encoded message.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+func (e *Encoder) tag(field, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+// Int64 encodes an int64 field.
+func (e *Encoder) Int64(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, uint64(v))
+}
+
+// Int32 encodes an int32 field.
+func (e *Encoder) Int32(field int, v int32) {
+	e.Int64(field, int64(v))
+}
+
+// OptionalInt32 encodes an optional int32 field, which is present even if zero unless v is nil.
+func (e *Encoder) OptionalInt32(field int, v *int32) {
+	if v == nil {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, uint64(int64(*v)))
+}
+
+// OptionalInt64 encodes an optional int64 field, which is present even if zero unless v is nil.
+func (e *Encoder) OptionalInt64(field int, v *int64) {
+	if v == nil {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, uint64(*v))
+}
+
+// Bool encodes a bool field.
+func (e *Encoder) Bool(field int, v bool) {
+	if !v {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = append(e.buf, 1)
+}
+
+// OptionalBool encodes an optional bool field, which is present even if false unless v is nil.
+func (e *Encoder) OptionalBool(field int, v *bool) {
+	if v == nil {
+		return
+	}
+	e.tag(field, wireVarint)
+	if *v {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+// String encodes a string field.
+func (e *Encoder) String(field int, v string) {
+	if v == "" {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// OptionalString encodes an optional string field, which is present even if empty unless v is nil.
+func (e *Encoder) OptionalString(field int, v *string) {
+	if v == nil {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(*v)))
+	e.buf = append(e.buf, *v...)
+}
+
+// Strings encodes a repeated string field.
+func (e *Encoder) Strings(field int, vs []string) {
+	for _, v := range vs {
+		e.tag(field, wireBytes)
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+		e.buf = append(e.buf, v...)
+	}
+}
+
+// Message encodes an embedded message field.
+func (e *Encoder) Message(field int, m Marshaler) {
+	b := m.MarshalProto()
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// Decoder reads the fields of a message in the protobuf binary encoding:
+//
+//	d := rpc.NewDecoder(b)
+//	for d.Next() {
+//		switch d.Field() {
+//		case 1:
+//			m.ID = d.Int64()
+//		default:
+//			d.Skip()
+//		}
+//	}
+//	return d.Err()
+type Decoder struct {
+	buf      []byte
+	field    int
+	wireType int
+	err      error
+}
+
+// NewDecoder returns a Decoder reading the message b.
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{buf: b}
+}
+
+// Next advances to the next field, reporting false at the end of the message or on error.
+func (d *Decoder) Next() bool {
+	if d.err != nil || len(d.buf) == 0 {
+		return false
+	}
+
+	tag := d.uvarint()
+	if d.err != nil {
+		return false
+	}
+	d.field, d.wireType = int(tag>>3), int(tag&7)
+	if d.field == 0 {
+		d.err = errors.New("rpc: invalid field number 0")
+		return false
+	}
+	return true
+}
+
+// Field returns the number of the current field.
+func (d *Decoder) Field() int {
+	return d.field
+}
+
+// Err returns the first error met while decoding.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+func (d *Decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errTruncated
+		d.buf = nil
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *Decoder) expect(wireType int) bool {
+	if d.err == nil && d.wireType != wireType {
+		d.err = errors.New("rpc: unexpected wire type")
+	}
+	return d.err == nil
+}
+
+// Int64 reads the current field as int64.
+func (d *Decoder) Int64() int64 {
+	if !d.expect(wireVarint) {
+		return 0
+	}
+	return int64(d.uvarint())
+}
+
+// Int32 reads the current field as int32.
+func (d *Decoder) Int32() int32 {
+	v := d.Int64()
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		d.err = errors.New("rpc: int32 field out of range")
+		return 0
+	}
+	return int32(v)
+}
+
+// OptionalInt32 reads the current field as an optional int32.
+func (d *Decoder) OptionalInt32() *int32 {
+	v := d.Int32()
+	return &v
+}
+
+// OptionalInt64 reads the current field as an optional int64.
+func (d *Decoder) OptionalInt64() *int64 {
+	v := d.Int64()
+	return &v
+}
+
+// Bool reads the current field as bool.
+func (d *Decoder) Bool() bool {
+	return d.Int64() != 0
+}
+
+// OptionalBool reads the current field as an optional bool.
+func (d *Decoder) OptionalBool() *bool {
+	v := d.Bool()
+	return &v
+}
+
+// Bytes reads the current length-delimited field. The result aliases the message.
+func (d *Decoder) Bytes() []byte {
+	if !d.expect(wireBytes) {
+		return nil
+	}
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.buf)) {
+		d.err = errTruncated
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+// String reads the current field as string.
+func (d *Decoder) String() string {
+	return string(d.Bytes())
+}
+
+// OptionalString reads the current field as an optional string.
+func (d *Decoder) OptionalString() *string {
+	v := d.String()
+	return &v
+}
+
+// Message reads the current field into the embedded message m.
+func (d *Decoder) Message(m Unmarshaler) {
+	b := d.Bytes()
+	if d.err != nil {
+		return
+	}
+	d.err = m.UnmarshalProto(b)
+}
+
+// Skip skips the current field, which is how unknown fields are handled.
+func (d *Decoder) Skip() {
+	switch d.wireType {
+	case wireVarint:
+		d.uvarint()
+	case wireFixed64:
+		d.skip(8)
+	case wireBytes:
+		d.Bytes()
+	case wireFixed32:
+		d.skip(4)
+	default:
+		d.err = errors.New("rpc: unsupported wire type")
+	}
+}
+
+func (d *Decoder) skip(n int) {
+	if len(d.buf) < n {
+		d.err = errTruncated
+		return
+	}
+	d.buf = d.buf[n:]
+}
@@ -0,0 +1,192 @@
+// Package sentry reports errors to a Sentry-compatible server using the store endpoint
+// of the Sentry protocol, version 7.
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Client sends error events to the project identified by a DSN.
+type Client struct {
+	endpoint    string
+	auth        string
+	environment string
+	release     string
+	http        *http.Client
+}
+
+// New returns a Client for a DSN of the form "https://<key>@<host>/<project>".
+func New(dsn, environment, release string) (*Client, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sentry: invalid DSN: %w", err)
+	}
+
+	key := u.User.Username()
+	project := strings.TrimPrefix(u.Path, "/")
+	i := strings.LastIndex(project, "/")
+	prefix := ""
+	if i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+	if u.Scheme == "" || u.Host == "" || key == "" || project == "" {
+		return nil, errors.New("sentry: DSN must be of the form https://<key>@<host>/<project>")
+	}
+
+	return &Client{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=libraryapi/%s, sentry_key=%s", release, key),
+		environment: environment,
+		release:     release,
+		http:        &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+// Request holds the metadata of the HTTP request during which an error happened.
+type Request struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Event is the payload sent to the server.
+type Event struct {
+	ID          string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Request     *Request          `json:"request,omitempty"`
+	Exception   struct {
+		Values []exception `json:"values"`
+	} `json:"exception"`
+}
+
+type exception struct {
+	Type       string     `json:"type"`
+	Value      string     `json:"value"`
+	Stacktrace stacktrace `json:"stacktrace"`
+}
+
+type stacktrace struct {
+	Frames []frame `json:"frames"`
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// sensitiveHeaders are never sent to the server.
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"X-Api-Key":     true,
+}
+
+// NewEvent returns an event for err with the stack trace of the caller, skip frames up.
+// If r is not nil, its method, URL and non-sensitive headers are attached.
+func NewEvent(err error, skip int, r *http.Request, tags map[string]string) *Event {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	e := &Event{
+		ID:        hex.EncodeToString(b),
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Level:     "error",
+		Platform:  "go",
+		Tags:      tags,
+	}
+
+	if r != nil {
+		e.Request = &Request{Method: r.Method, URL: r.URL.String(), Headers: make(map[string]string)}
+		for name := range r.Header {
+			if !sensitiveHeaders[name] {
+				e.Request.Headers[name] = r.Header.Get(name)
+			}
+		}
+	}
+
+	// Report the innermost error type, which is more telling than the wrapping one.
+	inner := err
+	for errors.Unwrap(inner) != nil {
+		inner = errors.Unwrap(inner)
+	}
+	e.Exception.Values = []exception{{
+		Type:       fmt.Sprintf("%T", inner),
+		Value:      err.Error(),
+		Stacktrace: stacktrace{Frames: callers(skip + 1)},
+	}}
+	return e
+}
+
+// callers returns the frames of the current goroutine's stack, skip frames up, ordered
+// from the outermost call as the protocol expects.
+func callers(skip int) []frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+
+	var frames []frame
+	it := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := it.Next()
+		frames = append(frames, frame{
+			Function: f.Function,
+			Filename: f.File,
+			Lineno:   f.Line,
+			InApp:    strings.HasPrefix(f.Function, "main.") || strings.Contains(f.Function, "LibraryAPI/"),
+		})
+		if !more {
+			break
+		}
+	}
+
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+// Send delivers the event to the server.
+func (c *Client) Send(ctx context.Context, e *Event) error {
+	e.Environment = c.environment
+	e.Release = c.release
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", c.auth)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sentry: server responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSend(t *testing.T) {
+	var got Event
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/42/store/" {
+			t.Errorf("got path %q", r.URL.Path)
+		}
+		auth = r.Header.Get("X-Sentry-Auth")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	c, err := New(strings.Replace(srv.URL, "://", "://public@", 1)+"/42", "testing", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/books/1", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("User-Agent", "test")
+
+	err = c.Send(context.Background(), NewEvent(errors.New("boom"), 0, r, map[string]string{"request_id": "abc"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(auth, "sentry_key=public") {
+		t.Errorf("got auth header %q", auth)
+	}
+	if got.Environment != "testing" || got.Tags["request_id"] != "abc" {
+		t.Errorf("got event %+v", got)
+	}
+	if got.Request == nil || got.Request.Headers["Authorization"] != "" || got.Request.Headers["User-Agent"] != "test" {
+		t.Errorf("got request %+v", got.Request)
+	}
+	frames := got.Exception.Values[0].Stacktrace.Frames
+	if last := frames[len(frames)-1]; !strings.HasSuffix(last.Function, "TestSend") {
+		t.Errorf("want innermost frame in TestSend, got %q", last.Function)
+	}
+}
+
+func TestNewInvalidDSN(t *testing.T) {
+	for _, dsn := range []string{"", "https://sentry.example.com/1", "https://key@sentry.example.com/"} {
+		if _, err := New(dsn, "", ""); err == nil {
+			t.Errorf("want error for DSN %q", dsn)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Package storage stores uploaded files, such as book covers, on the local filesystem or
+// in an S3 compatible object store like MinIO.
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when no object is stored under the key.
+	ErrNotFound = errors.New("storage: object not found")
+	// ErrNoPresign is returned by PresignGet of backends which cannot issue URLs, in which
+	// case the caller serves the object itself.
+	ErrNoPresign = errors.New("storage: presigned URLs not supported")
+	// ErrContentType is returned by SniffContentType when the content is of a type which
+	// isn't allowed.
+	ErrContentType = errors.New("storage: content type not allowed")
+)
+
+// Object describes a stored object.
+type Object struct {
+	Key         string
+	ContentType string
+	Size        int64
+	Modified    time.Time
+}
+
+// Storage is a store of objects addressed by slash separated keys, e.g. "covers/acme/1".
+type Storage interface {
+	// Put stores the content read from r under key, replacing any existing object.
+	Put(ctx context.Context, key, contentType string, r io.Reader) error
+	// Get returns the object stored under key along with its content, which the caller
+	// must close.
+	Get(ctx context.Context, key string) (Object, io.ReadCloser, error)
+	// Stat returns the object stored under key.
+	Stat(ctx context.Context, key string) (Object, error)
+	// Delete removes the object stored under key. Deleting a missing object isn't an error.
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL the object can be downloaded from without credentials until
+	// it expires.
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// SniffContentType detects the content type of r from its first 512 bytes, as browsers
+// do, instead of trusting the type declared by the client. It returns ErrContentType if
+// the type is not one of allowed, and otherwise the type and a reader of the full content.
+func SniffContentType(r io.Reader, allowed ...string) (string, io.Reader, error) {
+	br := bufio.NewReaderSize(r, 512)
+
+	head, err := br.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", nil, err
+	}
+
+	contentType := http.DetectContentType(head)
+	for _, t := range allowed {
+		if contentType == t {
+			return contentType, br, nil
+		}
+	}
+	return contentType, nil, ErrContentType
+}
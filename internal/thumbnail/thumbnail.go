@@ -0,0 +1,147 @@
+// Package thumbnail scales images down, such as book covers for list views, with the
+// standard library alone. Images are decoded from JPEG or PNG and thumbnails are encoded in
+// the same format; WebP is neither decoded nor encoded.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+var (
+	// ErrFormat is returned when the image is not a JPEG or PNG image.
+	ErrFormat = errors.New("thumbnail: unsupported image format")
+	// ErrTooLarge is returned when the image has more pixels than MaxPixels.
+	ErrTooLarge = errors.New("thumbnail: image too large")
+)
+
+// MaxPixels is the most pixels an image may have to be decoded, so a small file declaring
+// huge dimensions cannot exhaust the memory.
+const MaxPixels = 50_000_000
+
+// Image is a decoded image along with its format, "jpeg" or "png".
+type Image struct {
+	image.Image
+	Format string
+}
+
+// Decode reads a JPEG or PNG image from r.
+func Decode(r io.Reader) (*Image, error) {
+	// The content is read twice, by DecodeConfig and Decode.
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(content))
+	switch {
+	case errors.Is(err, image.ErrFormat):
+		return nil, ErrFormat
+	case err != nil:
+		return nil, err
+	case format != "jpeg" && format != "png":
+		return nil, ErrFormat
+	case cfg.Width*cfg.Height > MaxPixels:
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Image: img, Format: format}, nil
+}
+
+// ContentType returns the media type of the format of the image.
+func (img *Image) ContentType() string {
+	return "image/" + img.Format
+}
+
+// Encode writes the image to w in its format.
+func (img *Image) Encode(w io.Writer) error {
+	if img.Format == "png" {
+		return png.Encode(w, img.Image)
+	}
+	return jpeg.Encode(w, img.Image, &jpeg.Options{Quality: 85})
+}
+
+// Fit returns the size of a w×h image scaled down to fit in a size×size square, keeping its
+// aspect ratio. Images which fit already keep their size.
+func Fit(w, h, size int) (int, int) {
+	if w <= size && h <= size {
+		return w, h
+	}
+	if w >= h {
+		return size, max(h*size/w, 1)
+	}
+	return max(w*size/h, 1), size
+}
+
+// Scale returns the image scaled down to fit in a size×size square, see Fit, and false if
+// it fits already and needs no thumbnail.
+func (img *Image) Scale(size int) (*Image, bool) {
+	b := img.Bounds()
+	w, h := Fit(b.Dx(), b.Dy(), size)
+	if w == b.Dx() && h == b.Dy() {
+		return nil, false
+	}
+	return &Image{Image: Resize(img.Image, w, h), Format: img.Format}, true
+}
+
+// Resize scales src to w×h. Every pixel of the result is the average of the source pixels
+// it covers (a box filter), which keeps downscaled images free of the aliasing of nearest
+// neighbour sampling. It is meant for scaling down; scaling up repeats source pixels.
+func Resize(src image.Image, w, h int) *image.RGBA {
+	// Averaging premultiplied colors keeps transparent pixels from darkening their
+	// neighbours.
+	b := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+	sw, sh := b.Dx(), b.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := span(y, h, sh)
+		for x := 0; x < w; x++ {
+			x0, x1 := span(x, w, sw)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					bl += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8((r + n/2) / n)
+			dst.Pix[i+1] = uint8((g + n/2) / n)
+			dst.Pix[i+2] = uint8((bl + n/2) / n)
+			dst.Pix[i+3] = uint8((a + n/2) / n)
+		}
+	}
+	return dst
+}
+
+// span returns the range of the source pixels covered by pixel i of n in a source of m
+// pixels, at least one pixel wide.
+func span(i, n, m int) (int, int) {
+	from, to := i*m/n, (i+1)*m/n
+	if to <= from {
+		to = from + 1
+	}
+	return from, min(to, m)
+}
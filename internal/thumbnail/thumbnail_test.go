@@ -0,0 +1,92 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		w, h, size   int
+		wantW, wantH int
+	}{
+		{1200, 1800, 320, 213, 320},
+		{1800, 1200, 320, 320, 213},
+		{500, 500, 160, 160, 160},
+		{100, 150, 320, 100, 150},
+		{4000, 2, 160, 160, 1},
+	}
+	for _, tt := range tests {
+		w, h := Fit(tt.w, tt.h, tt.size)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("Fit(%d, %d, %d): want %dx%d, got %dx%d", tt.w, tt.h, tt.size, tt.wantW, tt.wantH, w, h)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	// a 4x2 image of a black and a white 2x2 square scales to a black and a white pixel,
+	// and a checkerboard averages to grey.
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			if x >= 2 {
+				src.Set(x, y, color.White)
+			} else {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+	dst := Resize(src, 2, 1)
+	if dst.RGBAAt(0, 0) != (color.RGBA{0, 0, 0, 255}) || dst.RGBAAt(1, 0) != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("want a black and a white pixel, got %v and %v", dst.RGBAAt(0, 0), dst.RGBAAt(1, 0))
+	}
+
+	checker := image.NewGray(image.Rect(10, 10, 12, 12))
+	checker.Set(10, 10, color.White)
+	checker.Set(11, 11, color.White)
+	dst = Resize(checker, 1, 1)
+	if got := dst.RGBAAt(0, 0); got.R != 128 || got.A != 255 {
+		t.Errorf("want grey, got %v", got)
+	}
+}
+
+func TestDecodeAndScale(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 600, 900))); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType() != "image/png" {
+		t.Errorf("want image/png, got %s", img.ContentType())
+	}
+
+	thumb, ok := img.Scale(300)
+	if !ok || thumb.Bounds().Dx() != 200 || thumb.Bounds().Dy() != 300 {
+		t.Fatalf("want a 200x300 thumbnail, got %v", thumb)
+	}
+	if _, ok := img.Scale(1000); ok {
+		t.Error("want no thumbnail of an image fitting already")
+	}
+
+	buf.Reset()
+	if err := thumb.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(&buf); err != nil {
+		t.Errorf("want the thumbnail encoded as png: %v", err)
+	}
+
+	if _, err := Decode(strings.NewReader("RIFF....WEBPVP8 ")); !errors.Is(err, ErrFormat) {
+		t.Errorf("want ErrFormat for webp, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package validator
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sync"
+)
+
+var (
+	// EmailRX matches email addresses, following the HTML living standard.
+	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
+	// LanguageTagRX matches BCP 47 language tags such as "en", "en-GB", "zh-Hant-TW" or
+	// "sr-Latn-RS", without the grandfathered tags.
+	LanguageTagRX = regexp.MustCompile(`(?i)^(([a-z]{2,3}(-[a-z]{3}){0,3}|[a-z]{4,8})(-[a-z]{4})?(-([a-z]{2}|[0-9]{3}))?(-([a-z0-9]{5,8}|[0-9][a-z0-9]{3}))*(-[0-9a-wyz](-[a-z0-9]{2,8})+)*(-x(-[a-z0-9]{1,8})+)?|x(-[a-z0-9]{1,8})+)$`)
+)
+
+// namedRule is a rule registered with Register.
+type namedRule struct {
+	message string
+	check   func(string) bool
+}
+
+// registry holds the registered rules by name.
+var registry = struct {
+	sync.RWMutex
+	rules map[string]namedRule
+}{rules: make(map[string]namedRule)}
+
+// Register adds a named rule checking string values, available as a rule of validate
+// struct tags and to CheckRule and Is. message is the error of the values failing check.
+// Rules are meant to be registered at init time; Register panics if the name is taken.
+func Register(name, message string, check func(string) bool) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.rules[name]; ok || builtinTagRules[name] {
+		panic(fmt.Sprintf("validator: rule %q is already registered", name))
+	}
+	registry.rules[name] = namedRule{message: message, check: check}
+}
+
+// lookupRule returns the rule registered with name.
+func lookupRule(name string) (namedRule, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	r, ok := registry.rules[name]
+	return r, ok
+}
+
+// Is reports whether value passes the registered rule. It panics if there is no rule
+// with the name.
+func Is(name, value string) bool {
+	r, ok := lookupRule(name)
+	if !ok {
+		panic(fmt.Sprintf("validator: unknown rule %q", name))
+	}
+	return r.check(value)
+}
+
+// CheckRule adds the error message of the registered rule to the map if value fails it.
+// It panics if there is no rule with the name.
+func (v *Validator) CheckRule(name, key, value string) {
+	r, ok := lookupRule(name)
+	if !ok {
+		panic(fmt.Sprintf("validator: unknown rule %q", name))
+	}
+	v.Check(r.check(value), key, r.message)
+}
+
+func init() {
+	Register("isbn", "must be a valid ISBN-10 or ISBN-13", ISBN)
+	Register("isbn10", "must be a valid ISBN-10", func(s string) bool {
+		return len(s) == 10 && ISBN(s)
+	})
+	Register("isbn13", "must be a valid ISBN-13", func(s string) bool {
+		return len(s) == 13 && ISBN(s)
+	})
+	Register("email", "must be a valid email address", func(s string) bool {
+		return len(s) <= 254 && Matches(s, EmailRX)
+	})
+	Register("url", "must be an absolute http or https URL", func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+	})
+	Register("bcp47", "must be a valid BCP 47 language tag", func(s string) bool {
+		return len(s) <= 35 && Matches(s, LanguageTagRX)
+	})
+}
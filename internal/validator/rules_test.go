@@ -0,0 +1,74 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinRules(t *testing.T) {
+	tests := []struct {
+		rule  string
+		value string
+		want  bool
+	}{
+		{"isbn", "0441013597", true},
+		{"isbn10", "0441013597", true},
+		{"isbn10", "9780441013593", false},
+		{"isbn13", "9780441013593", true},
+		{"isbn13", "9780441013594", false},
+		{"email", "reader@example.org", true},
+		{"email", "reader@", false},
+		{"email", strings.Repeat("a", 250) + "@b.co", false},
+		{"url", "https://example.org/hooks", true},
+		{"url", "/hooks", false},
+		{"bcp47", "en", true},
+		{"bcp47", "en-GB", true},
+		{"bcp47", "zh-Hant-TW", true},
+		{"bcp47", "sr-Latn-RS", true},
+		{"bcp47", "x-klingon", true},
+		{"bcp47", "e", false},
+		{"bcp47", "en--GB", false},
+		{"bcp47", "en_GB", false},
+		{"bcp47", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := Is(tt.rule, tt.value); got != tt.want {
+			t.Errorf("Is(%q, %q) = %v, want %v", tt.rule, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-uppercase", "must be uppercase", func(s string) bool {
+		return s == strings.ToUpper(s)
+	})
+
+	v := New()
+	v.CheckRule("test-uppercase", "code", "abc")
+	v.Struct(struct {
+		Code     string `json:"code2" validate:"test-uppercase"`
+		Language string `json:"language" validate:"omitempty,bcp47"`
+	}{Code: "abc", Language: "en GB"})
+
+	want := map[string]string{
+		"code":     "must be uppercase",
+		"code2":    "must be uppercase",
+		"language": "must be a valid BCP 47 language tag",
+	}
+	if !reflect.DeepEqual(v.Errors, want) {
+		t.Errorf("want errors %v, got %v", want, v.Errors)
+	}
+
+	for _, name := range []string{"test-uppercase", "required"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("want a panic registering %q twice", name)
+				}
+			}()
+			Register(name, "", nil)
+		}()
+	}
+}
@@ -0,0 +1,330 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// rule is a parsed rule of a validate struct tag.
+type rule struct {
+	name string
+	arg  string
+	// num is arg parsed as a number, for the min, max and gt rules.
+	num float64
+	// list is arg split on spaces, for the oneof rule.
+	list []string
+	// named is the rule registered with the name, if it is not a built-in one.
+	named *namedRule
+}
+
+// builtinTagRules are the rules of validate tags handled by Struct itself, the others
+// being looked up in the registry.
+var builtinTagRules = map[string]bool{
+	"required": true, "omitempty": true, "dive": true,
+	"min": true, "max": true, "gt": true, "oneof": true, "unique": true,
+}
+
+// field is a struct field with validation rules, or holding a struct to validate.
+type field struct {
+	index     []int
+	key       string
+	omitEmpty bool
+	rules     []rule
+	// nested is set for fields holding a struct, whose fields are validated too.
+	nested bool
+	// dive is set if the elemRules apply to the items of the slice held by the field.
+	dive          bool
+	elemOmitEmpty bool
+	elemRules     []rule
+}
+
+// fieldCache holds the validated fields of the struct types seen, by fieldCacheKey.
+var fieldCache sync.Map
+
+// fieldCacheKey is the key of the fields of a struct type keyed by the names in tag.
+type fieldCacheKey struct {
+	t   reflect.Type
+	tag string
+}
+
+// Struct checks the fields of the struct s, or of the struct s points to, against the
+// rules of their validate tags and adds an error for every failed rule, keyed by the JSON
+// name of the field. Rules are separated by commas:
+//
+//	Title  string   `json:"title" validate:"required,max=500"`
+//	Genres []string `json:"genres" validate:"required,min=1,max=5,unique"`
+//	Format string   `json:"format" validate:"omitempty,oneof=atom rss"`
+//
+// The rules are:
+//
+//   - required: the value must not be the zero value; a pointer must be set and point to
+//     a non-zero value
+//   - omitempty: the other rules are skipped for the zero value
+//   - min=n, max=n: bounds of the length in bytes of strings, of the number of items of
+//     slices and maps, and of numbers
+//   - gt=n: numbers must be greater than n
+//   - oneof=a b c: strings must be one of the values separated by spaces
+//   - unique: slices of strings must not hold the same value twice
+//   - dive: the rules after it apply to every item of a slice, e.g. "max=5,dive,max=50",
+//     with errors keyed by the path of the item such as "genres[2]"
+//
+// Any other rule is the name of a rule checking strings added with Register, such as the
+// built-in isbn, isbn10, isbn13, email, url and bcp47 rules.
+//
+// Rules of a nil pointer other than required are skipped. Fields of embedded structs are
+// checked like fields of s, and fields holding structs, or slices of structs with the dive
+// rule, are checked with errors keyed by their path, e.g. "authors[0].name". Struct panics
+// on invalid rules, which are programming errors.
+func (v *Validator) Struct(s interface{}) {
+	v.StructByTag(s, "json")
+}
+
+// StructByTag checks the fields of the struct s like Struct, but keys the errors by the
+// names of the fields in tag, e.g. "query", rather than by their JSON names.
+func (v *Validator) StructByTag(s interface{}, tag string) {
+	rv := reflect.ValueOf(s)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validator: Struct of non-struct type %s", rv.Type()))
+	}
+
+	v.structValue(rv, tag)
+}
+
+func (v *Validator) structValue(rv reflect.Value, tag string) {
+	for _, f := range structFields(rv.Type(), tag) {
+		v.checkField(rv.FieldByIndex(f.index), f, tag)
+	}
+}
+
+// structFields returns the fields of the struct type t with validation rules, keyed by
+// their names in tag.
+func structFields(t reflect.Type, tag string) []field {
+	if fields, ok := fieldCache.Load(fieldCacheKey{t, tag}); ok {
+		return fields.([]field)
+	}
+
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			for _, f := range structFields(sf.Type, tag) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		f := field{index: []int{i}, key: fieldKey(sf, tag), nested: isStruct(sf.Type)}
+		rules := sf.Tag.Get("validate")
+		if rules == "-" || rules == "" && !f.nested {
+			continue
+		}
+
+		if rules != "" {
+			for _, r := range strings.Split(rules, ",") {
+				name, arg, _ := strings.Cut(strings.TrimSpace(r), "=")
+				switch {
+				case name == "dive":
+					if f.dive || sf.Type.Kind() != reflect.Slice && sf.Type.Kind() != reflect.Array {
+						panic(fmt.Sprintf("validator: %s.%s: rule \"dive\" needs a slice or an array", t.Name(), sf.Name))
+					}
+					f.dive = true
+				case name == "omitempty" && f.dive:
+					f.elemOmitEmpty = true
+				case name == "omitempty":
+					f.omitEmpty = true
+				case f.dive:
+					f.elemRules = append(f.elemRules, parseRule(t, sf, name, arg))
+				default:
+					f.rules = append(f.rules, parseRule(t, sf, name, arg))
+				}
+			}
+		}
+		fields = append(fields, f)
+	}
+
+	fieldCache.Store(fieldCacheKey{t, tag}, fields)
+	return fields
+}
+
+// fieldKey returns the error key of the field, its name in tag if it has one.
+func fieldKey(sf reflect.StructField, tag string) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get(tag), ","); name != "" && name != "-" {
+		return name
+	}
+	return sf.Name
+}
+
+func parseRule(t reflect.Type, sf reflect.StructField, name, arg string) rule {
+	r := rule{name: name, arg: arg}
+
+	invalid := func(reason string) {
+		panic(fmt.Sprintf("validator: %s.%s: rule %q %s", t.Name(), sf.Name, name, reason))
+	}
+
+	switch name {
+	case "required", "unique":
+		if arg != "" {
+			invalid("takes no argument")
+		}
+	case "min", "max", "gt":
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			invalid("needs a number")
+		}
+		r.num = n
+	case "oneof":
+		r.list = strings.Fields(arg)
+		if len(r.list) == 0 {
+			invalid("needs values")
+		}
+	default:
+		named, ok := lookupRule(name)
+		if !ok {
+			invalid("is unknown")
+		}
+		if arg != "" {
+			invalid("takes no argument")
+		}
+		r.named = &named
+	}
+	return r
+}
+
+func (v *Validator) checkField(value reflect.Value, f field, tag string) {
+	for _, r := range f.rules {
+		if r.name == "required" {
+			v.Check(!isZero(value), f.key, "must be provided")
+		}
+	}
+
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	if f.omitEmpty && value.IsZero() {
+		return
+	}
+
+	for _, r := range f.rules {
+		if ok, message := r.check(value); !ok {
+			v.AddError(f.key, message)
+		}
+	}
+
+	switch {
+	case f.dive:
+		for i := 0; i < value.Len(); i++ {
+			item := value.Index(i)
+			v.checkField(item, field{
+				key:       Index(f.key, i),
+				omitEmpty: f.elemOmitEmpty,
+				rules:     f.elemRules,
+				nested:    isStruct(item.Type()),
+			}, tag)
+		}
+	case f.nested && value.Kind() == reflect.Struct:
+		v.At(f.key).structValue(value, tag)
+	}
+}
+
+// isStruct reports whether t is a struct or a pointer to one.
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// isZero reports whether the value is the zero value, or a pointer to one.
+func isZero(value reflect.Value) bool {
+	if value.Kind() == reflect.Pointer {
+		return value.IsNil() || value.Elem().IsZero()
+	}
+	return value.IsZero()
+}
+
+// check runs the rule against value, returning false and the error message if it fails.
+// Rules not applying to the kind of value pass.
+func (r rule) check(value reflect.Value) (bool, string) {
+	n := format(r.num)
+
+	switch value.Kind() {
+	case reflect.String:
+		s := value.String()
+		switch r.name {
+		case "min":
+			return float64(len(s)) >= r.num, fmt.Sprintf("must be at least %s bytes long", n)
+		case "max":
+			return float64(len(s)) <= r.num, fmt.Sprintf("must not be more than %s bytes long", n)
+		case "oneof":
+			return In(s, r.list...), "must be " + listValues(r.list)
+		}
+		if r.named != nil {
+			return r.named.check(s), r.named.message
+		}
+
+	case reflect.Slice, reflect.Array, reflect.Map:
+		switch r.name {
+		case "min":
+			return float64(value.Len()) >= r.num, fmt.Sprintf("must contain at least %s items", n)
+		case "max":
+			return float64(value.Len()) <= r.num, fmt.Sprintf("must not contain more than %s items", n)
+		case "unique":
+			if strs, ok := value.Interface().([]string); ok {
+				return Unique(strs), "must not contain duplicate values"
+			}
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		var x float64
+		switch {
+		case value.CanInt():
+			x = float64(value.Int())
+		case value.CanUint():
+			x = float64(value.Uint())
+		default:
+			x = value.Float()
+		}
+		switch r.name {
+		case "min":
+			return x >= r.num, fmt.Sprintf("must be at least %s", n)
+		case "max":
+			return x <= r.num, fmt.Sprintf("must be a maximum of %s", n)
+		case "gt":
+			return x > r.num, fmt.Sprintf("must be greater than %s", n)
+		}
+	}
+
+	return true, ""
+}
+
+func format(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// listValues lists the values for an error message, e.g. "a, b or c".
+func listValues(values []string) string {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
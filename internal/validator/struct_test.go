@@ -0,0 +1,145 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Paging struct {
+	PageSize int `json:"page_size" validate:"gt=0,max=100"`
+}
+
+type input struct {
+	Title   string   `json:"title" validate:"required,max=10"`
+	Year    *int32   `json:"year" validate:"required,min=1888"`
+	Pages   *int64   `json:"pages" validate:"gt=0"`
+	Genres  []string `json:"genres" validate:"required,min=1,max=2,unique"`
+	ISBN    string   `json:"isbn" validate:"omitempty,isbn"`
+	Format  string   `json:"format,omitempty" validate:"oneof=atom rss json"`
+	Webhook string   `validate:"omitempty,url"`
+	Ignored string   `json:"ignored"`
+	Paging
+}
+
+func TestStruct(t *testing.T) {
+	year, pages := int32(1600), int64(0)
+	tests := []struct {
+		name string
+		in   input
+		want map[string]string
+	}{
+		{
+			name: "valid",
+			in:   input{Title: "Dune", Year: ptr(int32(1965)), Genres: []string{"sci-fi"}, ISBN: "9780441013593", Format: "rss", Paging: Paging{PageSize: 20}},
+			want: map[string]string{},
+		},
+		{
+			name: "missing",
+			in:   input{Format: "atom", Paging: Paging{PageSize: 20}},
+			want: map[string]string{
+				"title":  "must be provided",
+				"year":   "must be provided",
+				"genres": "must be provided",
+			},
+		},
+		{
+			name: "invalid",
+			in: input{
+				Title: strings.Repeat("x", 11), Year: &year, Pages: &pages,
+				Genres: []string{"a", "a", "b"}, ISBN: "123", Format: "xml", Webhook: "ftp://example.org",
+			},
+			want: map[string]string{
+				"title":     "must not be more than 10 bytes long",
+				"year":      "must be at least 1888",
+				"pages":     "must be greater than 0",
+				"genres":    "must not contain more than 2 items",
+				"isbn":      "must be a valid ISBN-10 or ISBN-13",
+				"format":    "must be atom, rss or json",
+				"Webhook":   "must be an absolute http or https URL",
+				"page_size": "must be greater than 0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.Struct(&tt.in)
+			if !reflect.DeepEqual(v.Errors, tt.want) {
+				t.Errorf("want errors %v, got %v", tt.want, v.Errors)
+			}
+		})
+	}
+}
+
+func TestStructInvalidRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("want a panic for an unknown rule")
+		}
+	}()
+
+	New().Struct(struct {
+		Title string `validate:"requird"`
+	}{})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+type author struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type nestedInput struct {
+	Genres  []string `json:"genres" validate:"max=3,dive,required,max=5"`
+	Authors []author `json:"authors" validate:"min=1,dive"`
+	Editor  *author  `json:"editor"`
+	Series  struct {
+		Title string `json:"title" validate:"required"`
+	} `json:"series"`
+}
+
+func TestStructNested(t *testing.T) {
+	v := New()
+	v.Struct(nestedInput{
+		Genres:  []string{"sci-fi", "", "novel"},
+		Authors: []author{{Name: "Frank Herbert"}, {}},
+		Editor:  &author{},
+	})
+
+	want := map[string]string{
+		"genres[0]":       "must not be more than 5 bytes long",
+		"genres[1]":       "must be provided",
+		"authors[1].name": "must be provided",
+		"editor.name":     "must be provided",
+		"series.title":    "must be provided",
+	}
+	if !reflect.DeepEqual(v.Errors, want) {
+		t.Errorf("want errors %v, got %v", want, v.Errors)
+	}
+}
+
+func TestValidatorPaths(t *testing.T) {
+	v := New()
+	v.Each("books", 2, func(v *Validator, i int) {
+		v.Check(i == 0, "title", "must be provided")
+		v.Check(i == 0, "", "must be an object")
+		v.At("genres").Check(false, Index("", 1), "must not be empty")
+	})
+
+	want := map[string]string{
+		"books[0].genres[1]": "must not be empty",
+		"books[1].title":     "must be provided",
+		"books[1]":           "must be an object",
+		"books[1].genres[1]": "must not be empty",
+	}
+	if !reflect.DeepEqual(v.Errors, want) {
+		t.Errorf("want errors %v, got %v", want, v.Errors)
+	}
+	if v.At("books[0]").Valid() {
+		t.Error("want nested validators to share the errors")
+	}
+}
@@ -1,27 +1,75 @@
 package validator
 
-// Validator struct type contains map of validation errors.
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Validator struct type contains map of validation errors, and of warnings about values
+// which are valid but look wrong. Errors and warnings of nested values are keyed by their
+// path, e.g. "genres[2]" or "authors[0].name".
 type Validator struct {
 	Errors map[string]string
+	// Warnings do not make the values invalid, they are reported along with the result.
+	Warnings map[string]string
+	// prefix is the path of the value validated by a validator returned by At.
+	prefix string
 }
 
-// New creates new Validator instance with empty errors map.
+// New creates new Validator instance with empty errors and warnings maps.
 func New() *Validator {
-	return &Validator{Errors: make(map[string]string)}
+	return &Validator{Errors: make(map[string]string), Warnings: make(map[string]string)}
 }
 
-// Valid returns true if the errors map doesn't contain any entries.
+// Valid returns true if the errors map doesn't contain any entries. The map is shared
+// with the validators returned by At, so errors of nested values count too.
 func (v *Validator) Valid() bool {
 	return len(v.Errors) == 0
 }
 
 // AddError adds error message to the map.
 func (v *Validator) AddError(key, message string) {
+	key = v.path(key)
 	if _, exists := v.Errors[key]; !exists {
 		v.Errors[key] = message
 	}
 }
 
+// At returns a validator of the nested value at key, adding its errors to the map of v
+// under keys prefixed with the path of the value, e.g. v.At("authors[0]") adds the errors
+// of key "name" as "authors[0].name".
+func (v *Validator) At(key string) *Validator {
+	return &Validator{Errors: v.Errors, Warnings: v.Warnings, prefix: v.path(key)}
+}
+
+// Each calls fn for each of the n items of the slice at key, with a validator adding the
+// errors of item i under "key[i]". Errors of the item itself have the empty key.
+func (v *Validator) Each(key string, n int, fn func(v *Validator, i int)) {
+	for i := 0; i < n; i++ {
+		fn(v.At(Index(key, i)), i)
+	}
+}
+
+// Index returns the key of item i of the slice at key, e.g. "genres[2]".
+func Index(key string, i int) string {
+	return fmt.Sprintf("%s[%d]", key, i)
+}
+
+// path returns the full key of key in the value validated by v.
+func (v *Validator) path(key string) string {
+	switch {
+	case v.prefix == "":
+		return key
+	case key == "":
+		return v.prefix
+	case strings.HasPrefix(key, "["):
+		return v.prefix + key
+	default:
+		return v.prefix + "." + key
+	}
+}
+
 // Check adds error message to the map only if a validation check is false.
 func (v *Validator) Check(ok bool, key, message string) {
 	if !ok {
@@ -29,6 +77,38 @@ func (v *Validator) Check(ok bool, key, message string) {
 	}
 }
 
+// AddWarning adds warning message to the warnings map.
+func (v *Validator) AddWarning(key, message string) {
+	key = v.path(key)
+	if _, exists := v.Warnings[key]; !exists {
+		v.Warnings[key] = message
+	}
+}
+
+// Warn adds warning message to the warnings map only if a check is false. Warnings do
+// not affect Valid.
+func (v *Validator) Warn(ok bool, key, message string) {
+	if !ok {
+		v.AddWarning(key, message)
+	}
+}
+
+// Warned returns the warnings of keys without an error, as the error says more about the
+// value, or nil if there are none.
+func (v *Validator) Warned() map[string]string {
+	var warnings map[string]string
+	for key, message := range v.Warnings {
+		if _, failed := v.Errors[key]; failed {
+			continue
+		}
+		if warnings == nil {
+			warnings = make(map[string]string)
+		}
+		warnings[key] = message
+	}
+	return warnings
+}
+
 // In returns true if a specific value is in a list of strings.
 func In(value string, list ...string) bool {
 	for i := range list {
@@ -39,6 +119,11 @@ func In(value string, list ...string) bool {
 	return false
 }
 
+// Matches returns true if a string value matches a specific regexp pattern.
+func Matches(value string, rx *regexp.Regexp) bool {
+	return rx.MatchString(value)
+}
+
 // Unique returns true if all string values are unique.
 func Unique(values []string) bool {
 	uniqueValues := make(map[string]bool)
@@ -49,3 +134,40 @@ func Unique(values []string) bool {
 
 	return len(values) == len(uniqueValues)
 }
+
+// ISBN returns true if value is a valid ISBN-10 or ISBN-13 with a correct check digit.
+// Hyphens and spaces are expected to be stripped by the caller.
+func ISBN(value string) bool {
+	switch len(value) {
+	case 10:
+		sum := 0
+		for i, r := range value {
+			var digit int
+			switch {
+			case r >= '0' && r <= '9':
+				digit = int(r - '0')
+			case r == 'X' && i == 9:
+				digit = 10
+			default:
+				return false
+			}
+			sum += digit * (10 - i)
+		}
+		return sum%11 == 0
+	case 13:
+		sum := 0
+		for i, r := range value {
+			if r < '0' || r > '9' {
+				return false
+			}
+			digit := int(r - '0')
+			if i%2 == 1 {
+				digit *= 3
+			}
+			sum += digit
+		}
+		return sum%10 == 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,35 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWarnings(t *testing.T) {
+	v := New()
+	v.Warn(false, "year", "looks unusually old")
+	v.Warn(true, "pages", "looks unusually high")
+	v.At("authors[0]").Warn(false, "name", "has leading or trailing spaces")
+
+	if !v.Valid() {
+		t.Fatalf("want warnings not to make the values invalid, got errors %v", v.Errors)
+	}
+
+	want := map[string]string{
+		"year":            "looks unusually old",
+		"authors[0].name": "has leading or trailing spaces",
+	}
+	if got := v.Warned(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want warnings %v, got %v", want, got)
+	}
+
+	v.Check(false, "year", "must not be in the future")
+	want = map[string]string{"authors[0].name": "has leading or trailing spaces"}
+	if got := v.Warned(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want warnings of keys without errors %v, got %v", want, got)
+	}
+
+	if got := New().Warned(); got != nil {
+		t.Errorf("want no warnings, got %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+// Package vcs reports the version control and build details of the running binary.
+package vcs
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Info holds the details of a build.
+type Info struct {
+	Version   string
+	Commit    string
+	Time      string
+	Modified  bool
+	GoVersion string
+}
+
+// Read returns the build details of the binary. The version and build time given via
+// -ldflags take precedence; otherwise they are derived from the module version and the
+// VCS settings stamped by the go command, with "dev" as the last resort version.
+func Read(version, buildTime string) Info {
+	info := Info{
+		Version:   version,
+		Time:      buildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		if info.Version == "" {
+			info.Version = "dev"
+		}
+		return info
+	}
+
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.time":
+			if info.Time == "" {
+				info.Time = s.Value
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+
+	if info.Version == "" {
+		switch {
+		case bi.Main.Version != "" && bi.Main.Version != "(devel)":
+			info.Version = bi.Main.Version
+		case len(info.Commit) >= 7:
+			info.Version = info.Commit[:7]
+			if info.Modified {
+				info.Version += "-dirty"
+			}
+		default:
+			info.Version = "dev"
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clientFrame returns a masked frame as sent by a client.
+func clientFrame(opcode byte, payload []byte) []byte {
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+func TestConn(t *testing.T) {
+	closed := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			msg, err := c.ReadMessage()
+			if err != nil {
+				closed <- err
+				return
+			}
+			c.WriteMessage(append([]byte("echo: "), msg...), time.Now().Add(time.Second))
+		}
+	}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want 400 for a plain request, got %d", resp.StatusCode)
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	resp, err = http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("want 101, got %d", resp.StatusCode)
+	}
+	// the example of RFC 6455 section 1.3.
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected Sec-WebSocket-Accept %q", got)
+	}
+
+	conn.Write(clientFrame(opPing, []byte("hi")))
+	conn.Write(clientFrame(opText, []byte("hello")))
+
+	for _, want := range []struct {
+		opcode  byte
+		payload string
+	}{{opPong, "hi"}, {opText, "echo: hello"}} {
+		var head [2]byte
+		io.ReadFull(br, head[:])
+		payload := make([]byte, head[1])
+		io.ReadFull(br, payload)
+		if head[0]&0x0F != want.opcode || string(payload) != want.payload {
+			t.Errorf("want opcode %d %q, got %d %q", want.opcode, want.payload, head[0]&0x0F, payload)
+		}
+	}
+
+	conn.Write(clientFrame(opClose, binary.BigEndian.AppendUint16(nil, CloseGoingAway)))
+
+	var closeErr *CloseError
+	if err := <-closed; !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway {
+		t.Errorf("want CloseError with code 1001, got %v", err)
+	}
+}
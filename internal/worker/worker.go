@@ -0,0 +1,117 @@
+// Package worker runs tasks submitted by handlers in the background on a fixed number of
+// goroutines, so that slow work such as sending emails or webhooks does not hold up
+// responses, and the server can wait for queued work when it shuts down.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
+)
+
+var (
+	// ErrQueueFull is returned by Submit when all the workers are busy and the queue is full.
+	ErrQueueFull = errors.New("worker: queue is full")
+	// ErrStopped is returned by Submit after Shutdown has been called.
+	ErrStopped = errors.New("worker: pool is stopped")
+)
+
+// task is a unit of work with a name used in log entries.
+type task struct {
+	name string
+	fn   func()
+}
+
+// Pool is a fixed set of workers consuming tasks from a bounded queue.
+type Pool struct {
+	tasks  chan task
+	logger *jsonlog.Logger
+	wg     sync.WaitGroup
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+// New returns a Pool running workers goroutines with room for queueSize waiting tasks.
+func New(workers, queueSize int, logger *jsonlog.Logger) *Pool {
+	p := &Pool{
+		tasks:  make(chan task, queueSize),
+		logger: logger,
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+
+	return p
+}
+
+// Submit queues fn to run on a worker. It never blocks: if the queue is full ErrQueueFull
+// is returned, and the caller decides whether the work can be dropped.
+func (p *Pool) Submit(name string, fn func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		return ErrStopped
+	}
+
+	select {
+	case p.tasks <- task{name: name, fn: fn}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Len returns the number of tasks waiting in the queue.
+func (p *Pool) Len() int {
+	return len(p.tasks)
+}
+
+// Shutdown stops accepting tasks and waits until the queued ones have run, or ctx is done.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("worker: %d queued tasks did not complete: %w", p.Len(), ctx.Err())
+	}
+}
+
+// work runs tasks until the queue is closed and drained.
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for t := range p.tasks {
+		p.run(t)
+	}
+}
+
+// run runs a task, recovering from a panic so it doesn't take the process down.
+func (p *Pool) run(t task) {
+	defer func() {
+		if err := recover(); err != nil {
+			p.logger.PrintError(fmt.Errorf("%s", err), map[string]string{"task": t.name})
+		}
+	}()
+
+	t.fn()
+}
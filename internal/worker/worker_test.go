@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nikitashershunov/LibraryAPI/internal/jsonlog"
+)
+
+func TestPool(t *testing.T) {
+	p := New(2, 10, jsonlog.NewLogger(io.Discard, jsonlog.LevelInfo))
+
+	var done atomic.Int32
+	for i := 0; i < 10; i++ {
+		err := p.Submit("count", func() {
+			time.Sleep(time.Millisecond)
+			done.Add(1)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Submit("panic", func() { panic("boom") }); err != nil && !errors.Is(err, ErrQueueFull) {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if done.Load() != 10 {
+		t.Errorf("want all 10 queued tasks to run before shutdown returns, got %d", done.Load())
+	}
+	if err := p.Submit("late", func() {}); !errors.Is(err, ErrStopped) {
+		t.Errorf("want ErrStopped, got %v", err)
+	}
+}
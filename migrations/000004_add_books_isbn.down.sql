@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS books_isbn_idx;
+
+ALTER TABLE books DROP COLUMN IF EXISTS isbn;
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS books_updated_at_idx;
+
+ALTER TABLE books DROP COLUMN IF EXISTS updated_at;
+
+ALTER TABLE books RENAME COLUMN created_at TO created;
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS books_metadata_idx;
+
+ALTER TABLE books DROP COLUMN IF EXISTS metadata;
\ No newline at end of file
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS books_tenant_id_idx;
+
+DROP INDEX IF EXISTS books_tenant_isbn_idx;
+
+CREATE UNIQUE INDEX IF NOT EXISTS books_isbn_idx ON books (isbn);
+
+ALTER TABLE books DROP COLUMN IF EXISTS tenant_id;
\ No newline at end of file
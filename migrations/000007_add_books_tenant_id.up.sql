@@ -0,0 +1,7 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+
+DROP INDEX IF EXISTS books_isbn_idx;
+
+CREATE UNIQUE INDEX IF NOT EXISTS books_tenant_isbn_idx ON books (tenant_id, isbn);
+
+CREATE INDEX IF NOT EXISTS books_tenant_id_idx ON books (tenant_id, id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS books_active_idx;
+
+ALTER TABLE books DROP COLUMN IF EXISTS archived_at;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS archived_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS books_active_idx ON books (tenant_id, id) WHERE archived_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,7 @@
+UPDATE books SET year = date_part('year', now()) WHERE year IS NULL;
+
+UPDATE books SET pages = 0 WHERE pages IS NULL;
+
+ALTER TABLE books ALTER COLUMN pages SET NOT NULL;
+
+ALTER TABLE books ALTER COLUMN year SET NOT NULL;
\ No newline at end of file
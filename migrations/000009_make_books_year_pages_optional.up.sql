@@ -0,0 +1,3 @@
+ALTER TABLE books ALTER COLUMN year DROP NOT NULL;
+
+ALTER TABLE books ALTER COLUMN pages DROP NOT NULL;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    tenant_id text NOT NULL,
+    type text NOT NULL,
+    book_id bigint NOT NULL,
+    version integer NOT NULL
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS notifications (
+    key text PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS notifications_created_at_idx ON notifications (created_at);
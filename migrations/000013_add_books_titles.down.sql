@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS books_title_variants_idx;
+
+ALTER TABLE books DROP COLUMN IF EXISTS title_translit;
+ALTER TABLE books DROP COLUMN IF EXISTS titles;
+ALTER TABLE books DROP COLUMN IF EXISTS language;
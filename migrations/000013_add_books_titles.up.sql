@@ -0,0 +1,6 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS language text NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS titles jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS title_translit text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS books_title_variants_idx
+    ON books USING GIN ((to_tsvector('simple', titles) || to_tsvector('simple', title_translit)));
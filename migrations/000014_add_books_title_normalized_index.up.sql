@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS books_title_normalized_idx
+    ON books (tenant_id, lower(regexp_replace(btrim(title), '\s+', ' ', 'g')));
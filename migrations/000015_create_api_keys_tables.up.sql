@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    key_hash bytea NOT NULL UNIQUE,
+    daily_quota bigint NOT NULL,
+    monthly_quota bigint NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_key_usage (
+    api_key_id bigint NOT NULL REFERENCES api_keys ON DELETE CASCADE,
+    day date NOT NULL,
+    requests bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (api_key_id, day)
+);
\ No newline at end of file
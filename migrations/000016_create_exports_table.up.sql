@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS exports (
+    id bigserial PRIMARY KEY,
+    tenant_id text NOT NULL DEFAULT 'default',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    format text NOT NULL,
+    include_archived boolean NOT NULL DEFAULT false,
+    status text NOT NULL DEFAULT 'pending',
+    books bigint NOT NULL DEFAULT 0,
+    error text,
+    finished_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS exports_tenant_id_idx ON exports (tenant_id);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS genre_aliases;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS genre_aliases (
+    tenant_id text NOT NULL DEFAULT 'default',
+    alias text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    genre text NOT NULL,
+    PRIMARY KEY (tenant_id, alias)
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS search_queries;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS search_queries (
+    tenant_id text NOT NULL DEFAULT 'default',
+    day date NOT NULL,
+    query text NOT NULL,
+    searches bigint NOT NULL DEFAULT 0,
+    zero_results bigint NOT NULL DEFAULT 0,
+    hits bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant_id, day, query)
+);
\ No newline at end of file
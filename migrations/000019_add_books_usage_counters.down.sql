@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS books_views_idx;
+
+ALTER TABLE books DROP COLUMN IF EXISTS impressions;
+
+ALTER TABLE books DROP COLUMN IF EXISTS views;
\ No newline at end of file
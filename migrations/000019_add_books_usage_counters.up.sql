@@ -0,0 +1,5 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS views bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS impressions bigint NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS books_views_idx ON books (tenant_id, views);
\ No newline at end of file
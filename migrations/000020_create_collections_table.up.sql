@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS collections (
+    tenant_id text NOT NULL DEFAULT 'default',
+    slug text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    title text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    book_ids bigint[] NOT NULL DEFAULT '{}',
+    starts_at timestamp(0) with time zone,
+    ends_at timestamp(0) with time zone,
+    PRIMARY KEY (tenant_id, slug)
+);
\ No newline at end of file
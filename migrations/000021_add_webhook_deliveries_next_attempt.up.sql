@@ -0,0 +1,3 @@
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS next_attempt_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_next_attempt_at_idx ON webhook_deliveries (next_attempt_at) WHERE next_attempt_at IS NOT NULL;
\ No newline at end of file
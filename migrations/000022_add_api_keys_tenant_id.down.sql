@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS api_keys_tenant_id_idx;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS api_keys_tenant_id_idx ON api_keys (tenant_id);
\ No newline at end of file
@@ -0,0 +1,382 @@
+// Package booksv1 holds the messages of the Books service defined in books.proto. They're
+// written by hand against the rpc package encoder and must be kept in sync with the field
+// numbers of the definition.
+package booksv1
+
+import "github.com/nikitashershunov/LibraryAPI/internal/rpc"
+
+// Service is the full name of the Books service.
+const Service = "books.v1.Books"
+
+// Book message.
+type Book struct {
+	ID           int64
+	CreatedAt    string
+	UpdatedAt    string
+	Title        string
+	Year         *int32
+	Pages        *int64
+	Genres       []string
+	ISBN         string
+	MetadataJSON string
+	ArchivedAt   *string
+	Version      int32
+}
+
+func (m *Book) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.Int64(1, m.ID)
+	e.String(2, m.CreatedAt)
+	e.String(3, m.UpdatedAt)
+	e.String(4, m.Title)
+	e.OptionalInt32(5, m.Year)
+	e.OptionalInt64(6, m.Pages)
+	e.Strings(7, m.Genres)
+	e.String(8, m.ISBN)
+	e.String(9, m.MetadataJSON)
+	e.OptionalString(10, m.ArchivedAt)
+	e.Int32(11, m.Version)
+	return e.Bytes()
+}
+
+func (m *Book) UnmarshalProto(b []byte) error {
+	d := rpc.NewDecoder(b)
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.ID = d.Int64()
+		case 2:
+			m.CreatedAt = d.String()
+		case 3:
+			m.UpdatedAt = d.String()
+		case 4:
+			m.Title = d.String()
+		case 5:
+			m.Year = d.OptionalInt32()
+		case 6:
+			m.Pages = d.OptionalInt64()
+		case 7:
+			m.Genres = append(m.Genres, d.String())
+		case 8:
+			m.ISBN = d.String()
+		case 9:
+			m.MetadataJSON = d.String()
+		case 10:
+			m.ArchivedAt = d.OptionalString()
+		case 11:
+			m.Version = d.Int32()
+		default:
+			d.Skip()
+		}
+	}
+	return d.Err()
+}
+
+// GetBookRequest message.
+type GetBookRequest struct {
+	ID int64
+}
+
+func (m *GetBookRequest) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.Int64(1, m.ID)
+	return e.Bytes()
+}
+
+func (m *GetBookRequest) UnmarshalProto(b []byte) error {
+	d := rpc.NewDecoder(b)
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.ID = d.Int64()
+		default:
+			d.Skip()
+		}
+	}
+	return d.Err()
+}
+
+// ListBooksRequest message.
+type ListBooksRequest struct {
+	Title           string
+	Genres          []string
+	IncludeArchived bool
+	Page            int32
+	PageSize        int32
+	Sort            string
+}
+
+func (m *ListBooksRequest) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.String(1, m.Title)
+	e.Strings(2, m.Genres)
+	e.Bool(3, m.IncludeArchived)
+	e.Int32(4, m.Page)
+	e.Int32(5, m.PageSize)
+	e.String(6, m.Sort)
+	return e.Bytes()
+}
+
+func (m *ListBooksRequest) UnmarshalProto(b []byte) error {
+	d := rpc.NewDecoder(b)
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.Title = d.String()
+		case 2:
+			m.Genres = append(m.Genres, d.String())
+		case 3:
+			m.IncludeArchived = d.Bool()
+		case 4:
+			m.Page = d.Int32()
+		case 5:
+			m.PageSize = d.Int32()
+		case 6:
+			m.Sort = d.String()
+		default:
+			d.Skip()
+		}
+	}
+	return d.Err()
+}
+
+// Metadata message.
+type Metadata struct {
+	CurrentPage  int32
+	PageSize     int32
+	FirstPage    int32
+	LastPage     int32
+	TotalRecords int64
+}
+
+func (m *Metadata) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.Int32(1, m.CurrentPage)
+	e.Int32(2, m.PageSize)
+	e.Int32(3, m.FirstPage)
+	e.Int32(4, m.LastPage)
+	e.Int64(5, m.TotalRecords)
+	return e.Bytes()
+}
+
+func (m *Metadata) UnmarshalProto(b []byte) error {
+	d := rpc.NewDecoder(b)
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.CurrentPage = d.Int32()
+		case 2:
+			m.PageSize = d.Int32()
+		case 3:
+			m.FirstPage = d.Int32()
+		case 4:
+			m.LastPage = d.Int32()
+		case 5:
+			m.TotalRecords = d.Int64()
+		default:
+			d.Skip()
+		}
+	}
+	return d.Err()
+}
+
+// ListBooksResponse message.
+type ListBooksResponse struct {
+	Books    []*Book
+	Metadata *Metadata
+}
+
+func (m *ListBooksResponse) MarshalProto() []byte {
+	var e rpc.Encoder
+	for _, book := range m.Books {
+		e.Message(1, book)
+	}
+	if m.Metadata != nil {
+		e.Message(2, m.Metadata)
+	}
+	return e.Bytes()
+}
+
+func (m *ListBooksResponse) UnmarshalProto(b []byte) error {
+	d := rpc.NewDecoder(b)
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			book := new(Book)
+			d.Message(book)
+			m.Books = append(m.Books, book)
+		case 2:
+			m.Metadata = new(Metadata)
+			d.Message(m.Metadata)
+		default:
+			d.Skip()
+		}
+	}
+	return d.Err()
+}
+
+// CreateBookRequest message.
+type CreateBookRequest struct {
+	Title        string
+	Year         *int32
+	Pages        *int64
+	Genres       []string
+	ISBN         string
+	MetadataJSON string
+}
+
+func (m *CreateBookRequest) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.String(1, m.Title)
+	e.OptionalInt32(2, m.Year)
+	e.OptionalInt64(3, m.Pages)
+	e.Strings(4, m.Genres)
+	e.String(5, m.ISBN)
+	e.String(6, m.MetadataJSON)
+	return e.Bytes()
+}
+
+func (m *CreateBookRequest) UnmarshalProto(b []byte) error {
+	d := rpc.NewDecoder(b)
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.Title = d.String()
+		case 2:
+			m.Year = d.OptionalInt32()
+		case 3:
+			m.Pages = d.OptionalInt64()
+		case 4:
+			m.Genres = append(m.Genres, d.String())
+		case 5:
+			m.ISBN = d.String()
+		case 6:
+			m.MetadataJSON = d.String()
+		default:
+			d.Skip()
+		}
+	}
+	return d.Err()
+}
+
+// Genres message.
+type Genres struct {
+	Values []string
+}
+
+func (m *Genres) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.Strings(1, m.Values)
+	return e.Bytes()
+}
+
+func (m *Genres) UnmarshalProto(b []byte) error {
+	d := rpc.NewDecoder(b)
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.Values = append(m.Values, d.String())
+		default:
+			d.Skip()
+		}
+	}
+	return d.Err()
+}
+
+// UpdateBookRequest message.
+type UpdateBookRequest struct {
+	ID              int64
+	Title           *string
+	Year            *int32
+	Pages           *int64
+	Genres          *Genres
+	ISBN            *string
+	MetadataJSON    *string
+	Archived        *bool
+	ExpectedVersion *int32
+}
+
+func (m *UpdateBookRequest) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.Int64(1, m.ID)
+	e.OptionalString(2, m.Title)
+	e.OptionalInt32(3, m.Year)
+	e.OptionalInt64(4, m.Pages)
+	if m.Genres != nil {
+		e.Message(5, m.Genres)
+	}
+	e.OptionalString(6, m.ISBN)
+	e.OptionalString(7, m.MetadataJSON)
+	e.OptionalBool(8, m.Archived)
+	e.OptionalInt32(9, m.ExpectedVersion)
+	return e.Bytes()
+}
+
+func (m *UpdateBookRequest) UnmarshalProto(b []byte) error {
+	d := rpc.NewDecoder(b)
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.ID = d.Int64()
+		case 2:
+			m.Title = d.OptionalString()
+		case 3:
+			m.Year = d.OptionalInt32()
+		case 4:
+			m.Pages = d.OptionalInt64()
+		case 5:
+			m.Genres = new(Genres)
+			d.Message(m.Genres)
+		case 6:
+			m.ISBN = d.OptionalString()
+		case 7:
+			m.MetadataJSON = d.OptionalString()
+		case 8:
+			m.Archived = d.OptionalBool()
+		case 9:
+			m.ExpectedVersion = d.OptionalInt32()
+		default:
+			d.Skip()
+		}
+	}
+	return d.Err()
+}
+
+// DeleteBookRequest message.
+type DeleteBookRequest struct {
+	ID int64
+}
+
+func (m *DeleteBookRequest) MarshalProto() []byte {
+	var e rpc.Encoder
+	e.Int64(1, m.ID)
+	return e.Bytes()
+}
+
+func (m *DeleteBookRequest) UnmarshalProto(b []byte) error {
+	d := rpc.NewDecoder(b)
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.ID = d.Int64()
+		default:
+			d.Skip()
+		}
+	}
+	return d.Err()
+}
+
+// DeleteBookResponse message.
+type DeleteBookResponse struct{}
+
+func (m *DeleteBookResponse) MarshalProto() []byte {
+	return nil
+}
+
+func (m *DeleteBookResponse) UnmarshalProto(b []byte) error {
+	d := rpc.NewDecoder(b)
+	for d.Next() {
+		d.Skip()
+	}
+	return d.Err()
+}